@@ -0,0 +1,119 @@
+package jclass
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 参与 ABI 指纹的修饰符：影响下游编译的部分，不含 ACC_SUPER、synchronized、strictfp 等实现细节
+const (
+	abiClassFlags  = apiClassFlags
+	abiFieldFlags  = apiFieldFlags | FIELD_ACC_VOLATILE | FIELD_ACC_TRANSIENT | FIELD_ACC_ENUM
+	abiMethodFlags = apiMethodFlags | METHOD_ACC_VARARGS | METHOD_ACC_NATIVE
+)
+
+// 参与 ABI 指纹的属性；注解只要保留在类文件中（CLASS 或 RUNTIME）就会被编译器与注解处理器看到
+var abiAttributes = map[string]bool{
+	"Signature":                            true,
+	"Exceptions":                           true,
+	"AnnotationDefault":                    true,
+	"PermittedSubclasses":                  true,
+	"Record":                               true,
+	"Module":                               true,
+	"RuntimeVisibleAnnotations":            true,
+	"RuntimeInvisibleAnnotations":          true,
+	"RuntimeVisibleParameterAnnotations":   true,
+	"RuntimeInvisibleParameterAnnotations": true,
+	"RuntimeVisibleTypeAnnotations":        true,
+	"RuntimeInvisibleTypeAnnotations":      true,
+}
+
+// 属性中参与 ABI 指纹的部分，按名称排序，与常量池布局无关
+func abiAttributeLines(cf *ClassFile, attrs []*AttributeInfo, constantValue bool) []string {
+	var rs []string
+	for _, attr := range attrs {
+		name := attr.NameString()
+		if abiAttributes[name] || constantValue && name == "ConstantValue" {
+			key, _ := attributeValue(cf, attr)
+			rs = append(rs, "  "+name+" "+key)
+		}
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+// 类的 ABI 描述：类名、修饰符、父类、接口、public 与 protected 成员的签名及其注解、
+// static final 字段的常量值，每项一行并排序。方法体、private 与包级成员、调试信息、
+// 合成成员以及常量池顺序都不计入
+func abiLines(cf *ClassFile) []string {
+	interfaces := cf.InterfaceStrings()
+	sort.Strings(interfaces)
+	rs := []string{
+		fmt.Sprintf("class %s 0x%04x", cf.ThisClassString(), cf.AccessFlags&abiClassFlags),
+		"super " + cf.SuperClassString(),
+		"interfaces " + strings.Join(interfaces, " "),
+	}
+	rs = append(rs, abiAttributeLines(cf, cf.Attributes, false)...)
+
+	var members []string
+	for _, f := range cf.Fields {
+		if f.AccessFlags&(FIELD_ACC_PUBLIC|FIELD_ACC_PROTECTED) == 0 || f.AccessFlags&FIELD_ACC_SYNTHETIC != 0 {
+			continue
+		}
+		constant := f.AccessFlags&(FIELD_ACC_STATIC|FIELD_ACC_FINAL) == FIELD_ACC_STATIC|FIELD_ACC_FINAL
+		lines := []string{fmt.Sprintf("field %s %s 0x%04x", f.NameString(), f.DescriptorString(), f.AccessFlags&abiFieldFlags)}
+		lines = append(lines, abiAttributeLines(cf, f.Attributes, constant)...)
+		members = append(members, strings.Join(lines, "\n"))
+	}
+	for _, m := range cf.Methods {
+		if m.AccessFlags&(METHOD_ACC_PUBLIC|METHOD_ACC_PROTECTED) == 0 || m.AccessFlags&METHOD_ACC_SYNTHETIC != 0 {
+			continue
+		}
+		lines := []string{fmt.Sprintf("method %s %s 0x%04x", m.NameString(), m.DescriptorString(), m.AccessFlags&abiMethodFlags)}
+		lines = append(lines, abiAttributeLines(cf, m.Attributes, false)...)
+		members = append(members, strings.Join(lines, "\n"))
+	}
+	sort.Strings(members)
+	return append(rs, members...)
+}
+
+// 类的 ABI 指纹（SHA-256 的十六进制形式）：只在下游可见的 API 变化时改变，
+// 可用于构建缓存判断依赖方是否需要重新编译。覆盖的内容见 abiLines
+func ABIHash(cf *ClassFile) string {
+	sum := sha256.Sum256([]byte(strings.Join(abiLines(cf), "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// 对其他 jar 可见的类：public 类（包括 public 与 protected 的嵌套类）以及 module-info
+func abiVisible(cf *ClassFile) bool {
+	return cf.IsPublic() || cf.ThisClassString() == "module-info"
+}
+
+// 每个对外可见的类的 ABI 指纹，键为内部类名
+func (j *Jar) ABIHashes() map[string]string {
+	rs := make(map[string]string)
+	for _, name := range j.ClassNames() {
+		if cf := j.Class(name); abiVisible(cf) {
+			rs[name] = ABIHash(cf)
+		}
+	}
+	return rs
+}
+
+// jar 的 ABI 指纹：按类名排序汇总对外可见类的 ABI 指纹，增删可见类或任一类的 ABI 变化时改变
+func (j *Jar) ABIHash() string {
+	hashes := j.ABIHashes()
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s %s\n", name, hashes[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -11,6 +11,7 @@ const (
 	CLASS_ACC_SYNTHETIC  ClassAccessFlags = 0x1000
 	CLASS_ACC_ANNOTATION ClassAccessFlags = 0x2000
 	CLASS_ACC_ENUM       ClassAccessFlags = 0x4000
+	CLASS_ACC_MODULE     ClassAccessFlags = 0x8000
 )
 
 type FieldAccessFlags uint16
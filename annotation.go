@@ -3,6 +3,7 @@ package jclass
 import (
 	"encoding/binary"
 	"io"
+	"strings"
 )
 
 type Annotation struct {
@@ -17,6 +18,29 @@ func (a *Annotation) TypeString() string {
 	return ((*ConstantUtf8Info)(a.cp[a.TypeIndex])).Utf8()
 }
 
+// 注解类型的内部类名，如 java/lang/Deprecated
+func (a *Annotation) TypeName() string {
+	desc := a.TypeString()
+	if strings.HasPrefix(desc, "L") && strings.HasSuffix(desc, ";") {
+		return desc[1 : len(desc)-1]
+	}
+	return desc
+}
+
+// 注解类型以及元素值中引用的所有类名（枚举类型、类字面量、嵌套注解）
+func (a *Annotation) Classes() []string {
+	var rs []string
+	a.collectClasses(&rs)
+	return rs
+}
+
+func (a *Annotation) collectClasses(rs *[]string) {
+	*rs = append(*rs, DescriptorClasses(a.TypeString())...)
+	for _, evp := range a.ElementValuePairs {
+		evp.Value.collectClasses(rs)
+	}
+}
+
 func (a *Annotation) ConstantPoolInfo(i uint16) *ConstantPoolInfo {
 	return a.cp[int(i)]
 }
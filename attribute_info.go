@@ -12,7 +12,16 @@ type AttributeInfo struct {
 	Length    uint32
 	Info      []byte
 
-	Annotations []*Annotation
+	Annotations          []*Annotation
+	ParameterAnnotations [][]*Annotation
+	DefaultValue         *ElementValue
+	BootstrapMethods     []*BootstrapMethod
+	Code                 *CodeAttribute
+	ExceptionIndexTable  []uint16
+	SignatureIndex       uint16
+	Module               *ModuleAttribute
+	InnerClasses         []*InnerClass
+	ConstantValueIndex   uint16
 
 	cp []*ConstantPoolInfo
 }
@@ -25,10 +34,41 @@ func (i *AttributeInfo) NameString() string {
 	return ((*ConstantUtf8Info)(i.cp[i.NameIndex])).Utf8()
 }
 
+func (i *AttributeInfo) SignatureString() string {
+	return ((*ConstantUtf8Info)(i.cp[i.SignatureIndex])).Utf8()
+}
+
 func (a *AttributeInfo) ConstantPoolInfo(i uint16) *ConstantPoolInfo {
 	return a.cp[int(i)]
 }
 
+// 按名称查找属性，不存在时返回 nil
+func findAttribute(attrs []*AttributeInfo, name string) *AttributeInfo {
+	for _, attr := range attrs {
+		if attr.NameString() == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// Signature 属性中的泛型签名，不存在时返回空串
+func signatureOf(attrs []*AttributeInfo) string {
+	if attr := findAttribute(attrs, "Signature"); attr != nil {
+		return attr.SignatureString()
+	}
+	return ""
+}
+
+// RuntimeVisibleAnnotations 与 RuntimeInvisibleAnnotations 中的全部注解
+func annotationsOf(attrs []*AttributeInfo) []*Annotation {
+	var rs []*Annotation
+	for _, attr := range attrs {
+		rs = append(rs, attr.Annotations...)
+	}
+	return rs
+}
+
 func NewAttributeInfo(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*AttributeInfo, []byte, error) {
 	rs := AttributeInfo{}
 	byteOrder := binary.BigEndian
@@ -45,21 +85,20 @@ func NewAttributeInfo(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*Attribu
 	}
 	rs.Length = byteOrder.Uint32(buf)
 
-	size := int(rs.Length)
-	if cap(buf) < size {
-		buf = make([]byte, size)
-	}
-	_, err = io.ReadFull(r, buf[:size])
+	rs.Info, err = readBytes(r, int(rs.Length))
 	if err != nil {
 		return nil, buf, err
 	}
-	rs.Info = make([]byte, size)
-	copy(rs.Info, buf)
 
 	rs.cp = cp
 
+	// 属性名无效时无法判断如何解析，先检查再读取
+	if int(rs.NameIndex) >= len(cp) || cp[rs.NameIndex] == nil || cp[rs.NameIndex].Tag != 1 {
+		return nil, buf, fmt.Errorf("%s: name index #%d is not a CONSTANT_Utf8", ERR_MALFORMED_ATTRIBUTE, rs.NameIndex)
+	}
+
 	switch rs.NameString() {
-	case "RuntimeVisibleAnnotations":
+	case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
 		r := bytes.NewReader(rs.Info)
 		_, err = io.ReadFull(r, buf[:2])
 		if err != nil {
@@ -74,6 +113,105 @@ func NewAttributeInfo(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*Attribu
 			}
 			rs.Annotations[i] = ann
 		}
+
+	case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+		r := bytes.NewReader(rs.Info)
+		_, err = io.ReadFull(r, buf[:1])
+		if err != nil {
+			return nil, buf, err
+		}
+		params := int(buf[0])
+		rs.ParameterAnnotations = make([][]*Annotation, params)
+		var ann *Annotation
+		for i := 0; i < params; i++ {
+			_, err = io.ReadFull(r, buf[:2])
+			if err != nil {
+				return nil, buf, err
+			}
+			num := int(byteOrder.Uint16(buf))
+			rs.ParameterAnnotations[i] = make([]*Annotation, num)
+			for j := 0; j < num; j++ {
+				ann, buf, err = NewAnnotation(r, buf, cp)
+				if err != nil {
+					return nil, buf, err
+				}
+				rs.ParameterAnnotations[i][j] = ann
+			}
+		}
+
+	case "AnnotationDefault":
+		rs.DefaultValue, buf, err = NewElementValue(bytes.NewReader(rs.Info), buf, cp)
+		if err != nil {
+			return nil, buf, err
+		}
+
+	case "Code":
+		rs.Code, buf, err = NewCodeAttribute(bytes.NewReader(rs.Info), buf, cp)
+		if err != nil {
+			return nil, buf, err
+		}
+
+	case "Exceptions":
+		if len(rs.Info) < 2 || len(rs.Info) < 2+2*int(byteOrder.Uint16(rs.Info)) {
+			return nil, buf, fmt.Errorf("%s: Exceptions", ERR_MALFORMED_ATTRIBUTE)
+		}
+		num := int(byteOrder.Uint16(rs.Info))
+		rs.ExceptionIndexTable = make([]uint16, num)
+		for i := 0; i < num; i++ {
+			rs.ExceptionIndexTable[i] = byteOrder.Uint16(rs.Info[2+2*i:])
+		}
+
+	case "Signature":
+		if len(rs.Info) < 2 {
+			return nil, buf, fmt.Errorf("%s: Signature", ERR_MALFORMED_ATTRIBUTE)
+		}
+		rs.SignatureIndex = byteOrder.Uint16(rs.Info)
+
+	case "InnerClasses":
+		if len(rs.Info) < 2 || len(rs.Info) < 2+8*int(byteOrder.Uint16(rs.Info)) {
+			return nil, buf, fmt.Errorf("%s: InnerClasses", ERR_MALFORMED_ATTRIBUTE)
+		}
+		num := int(byteOrder.Uint16(rs.Info))
+		rs.InnerClasses = make([]*InnerClass, num)
+		for i := 0; i < num; i++ {
+			entry := rs.Info[2+8*i:]
+			rs.InnerClasses[i] = &InnerClass{
+				InnerClassInfoIndex:   byteOrder.Uint16(entry),
+				OuterClassInfoIndex:   byteOrder.Uint16(entry[2:]),
+				InnerNameIndex:        byteOrder.Uint16(entry[4:]),
+				InnerClassAccessFlags: ClassAccessFlags(byteOrder.Uint16(entry[6:])),
+				cp:                    cp,
+			}
+		}
+
+	case "ConstantValue":
+		if len(rs.Info) < 2 {
+			return nil, buf, fmt.Errorf("%s: ConstantValue", ERR_MALFORMED_ATTRIBUTE)
+		}
+		rs.ConstantValueIndex = byteOrder.Uint16(rs.Info)
+
+	case "Module":
+		rs.Module, buf, err = NewModuleAttribute(bytes.NewReader(rs.Info), buf, cp)
+		if err != nil {
+			return nil, buf, err
+		}
+
+	case "BootstrapMethods":
+		r := bytes.NewReader(rs.Info)
+		_, err = io.ReadFull(r, buf[:2])
+		if err != nil {
+			return nil, buf, err
+		}
+		num := int(byteOrder.Uint16(buf))
+		rs.BootstrapMethods = make([]*BootstrapMethod, num)
+		var bm *BootstrapMethod
+		for i := 0; i < num; i++ {
+			bm, buf, err = NewBootstrapMethod(r, buf, cp)
+			if err != nil {
+				return nil, buf, err
+			}
+			rs.BootstrapMethods[i] = bm
+		}
 	}
 
 	return &rs, buf, nil
@@ -0,0 +1,48 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+type BootstrapMethod struct {
+	BootstrapMethodRef    uint16
+	NumBootstrapArguments uint16
+	BootstrapArguments    []uint16
+
+	cp []*ConstantPoolInfo
+}
+
+func (m *BootstrapMethod) MethodHandle() *ConstantMethodHandleInfo {
+	return (*ConstantMethodHandleInfo)(m.cp[m.BootstrapMethodRef])
+}
+
+func (m *BootstrapMethod) ConstantPoolInfo(i uint16) *ConstantPoolInfo {
+	return m.cp[int(i)]
+}
+
+func NewBootstrapMethod(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*BootstrapMethod, []byte, error) {
+	rs := BootstrapMethod{
+		cp: cp,
+	}
+	byteOrder := binary.BigEndian
+
+	_, err := io.ReadFull(r, buf[:4])
+	if err != nil {
+		return nil, buf, err
+	}
+	rs.BootstrapMethodRef = byteOrder.Uint16(buf)
+	rs.NumBootstrapArguments = byteOrder.Uint16(buf[2:])
+
+	num := int(rs.NumBootstrapArguments)
+	rs.BootstrapArguments = make([]uint16, num)
+	for i := 0; i < num; i++ {
+		_, err = io.ReadFull(r, buf[:2])
+		if err != nil {
+			return nil, buf, err
+		}
+		rs.BootstrapArguments[i] = byteOrder.Uint16(buf)
+	}
+
+	return &rs, buf, nil
+}
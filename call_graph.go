@@ -0,0 +1,404 @@
+package jclass
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// 类层次分析：虚调用分派到声明类型在 classpath 上的所有具体子类型
+	CALL_GRAPH_CHA = "cha"
+	// 快速类型分析：只分派到可达代码中实例化过的类型
+	CALL_GRAPH_RTA = "rta"
+)
+
+type CallNode struct {
+	Class      string
+	Name       string
+	Descriptor string
+	// 不在 classpath 上的方法为 nil
+	Method *MethodInfo
+
+	Callers []*CallEdge
+	Callees []*CallEdge
+}
+
+func MethodKey(class, name, descriptor string) string {
+	return class + "." + name + descriptor
+}
+
+func (n *CallNode) String() string {
+	return MethodKey(n.Class, n.Name, n.Descriptor)
+}
+
+type CallEdge struct {
+	Caller *CallNode
+	Callee *CallNode
+	// 调用指令在调用方方法中的偏移
+	Offset int
+	Opcode byte
+}
+
+func (e *CallEdge) String() string {
+	return fmt.Sprintf("%s -> %s (%s @%d)", e.Caller, e.Callee, OpcodeName(e.Opcode), e.Offset)
+}
+
+type CallGraph struct {
+	Mode  string
+	Nodes map[string]*CallNode
+
+	classPath *ClassPath
+	edges     map[[2]*CallNode]map[int]bool
+}
+
+// 虚调用点
+type callSite struct {
+	caller     *CallNode
+	class      string
+	name       string
+	descriptor string
+	offset     int
+	opcode     byte
+}
+
+type callGraphBuilder struct {
+	g         *CallGraph
+	p         *ClassPath
+	worklist  []*CallNode
+	reachable map[*CallNode]bool
+
+	// RTA 状态：虚调用点按声明类型索引，实例化类型按其超类型（含自身）索引
+	instantiated map[string]bool
+	sites        map[string][]*callSite
+	subtypes     map[string][]string
+	// 接收者类型上按方法签名分派的结果
+	targets map[string]*dispatchTarget
+}
+
+type dispatchTarget struct {
+	node *CallNode
+	r    Resolution
+}
+
+// 从 entries（MethodKey 形式）出发构建调用图；entries 为空时把 classpath 上所有方法都作为入口。
+// RTA 模式下实例化类型的集合只来自可达方法中的 new 指令。
+func (p *ClassPath) CallGraph(mode string, entries []string) (*CallGraph, error) {
+	switch mode {
+	case CALL_GRAPH_CHA, CALL_GRAPH_RTA:
+	default:
+		return nil, fmt.Errorf("invalid call graph mode: %s", mode)
+	}
+
+	b := &callGraphBuilder{
+		g: &CallGraph{
+			Mode:      mode,
+			Nodes:     make(map[string]*CallNode),
+			classPath: p,
+			edges:     make(map[[2]*CallNode]map[int]bool),
+		},
+		p:            p,
+		reachable:    make(map[*CallNode]bool),
+		instantiated: make(map[string]bool),
+		sites:        make(map[string][]*callSite),
+		subtypes:     make(map[string][]string),
+		targets:      make(map[string]*dispatchTarget),
+	}
+
+	if len(entries) == 0 {
+		for _, name := range p.ClassNames() {
+			for _, m := range p.Class(name).Methods {
+				b.reach(b.node(name, m.NameString(), m.DescriptorString()))
+			}
+		}
+	} else {
+		for _, entry := range entries {
+			nodes := b.g.Find(entry)
+			if len(nodes) == 0 {
+				class, name, desc := ParseMethodPattern(entry)
+				cf := p.Class(class)
+				if cf == nil {
+					return nil, fmt.Errorf("entry point not found: %s", entry)
+				}
+				for _, m := range cf.Methods {
+					if m.NameString() == name && (desc == "" || m.DescriptorString() == desc) {
+						nodes = append(nodes, b.node(class, name, m.DescriptorString()))
+					}
+				}
+				if len(nodes) == 0 {
+					return nil, fmt.Errorf("entry point not found: %s", entry)
+				}
+			}
+			for _, n := range nodes {
+				b.reach(n)
+			}
+		}
+	}
+
+	for len(b.worklist) > 0 {
+		n := b.worklist[0]
+		b.worklist = b.worklist[1:]
+		b.scan(n)
+	}
+
+	return b.g, nil
+}
+
+func (b *callGraphBuilder) node(class, name, descriptor string) *CallNode {
+	key := MethodKey(class, name, descriptor)
+	if n, ok := b.g.Nodes[key]; ok {
+		return n
+	}
+	n := &CallNode{Class: class, Name: name, Descriptor: descriptor}
+	if cf := b.p.Class(class); cf != nil {
+		n.Method = cf.FindMethod(name, descriptor)
+	}
+	b.g.Nodes[key] = n
+	return n
+}
+
+func (b *callGraphBuilder) reach(n *CallNode) {
+	if b.reachable[n] {
+		return
+	}
+	b.reachable[n] = true
+	if n.Method != nil && n.Method.Code() != nil {
+		b.worklist = append(b.worklist, n)
+	}
+
+	// 类初始化块随类的首次使用执行
+	if n.Name != "<clinit>" {
+		if cf := b.p.Class(n.Class); cf != nil && cf.FindMethod("<clinit>", "()V") != nil {
+			b.reach(b.node(n.Class, "<clinit>", "()V"))
+		}
+	}
+}
+
+func (b *callGraphBuilder) edge(caller, callee *CallNode, offset int, opcode byte) {
+	key := [2]*CallNode{caller, callee}
+	if b.g.edges[key] == nil {
+		b.g.edges[key] = make(map[int]bool)
+	}
+	if b.g.edges[key][offset] {
+		return
+	}
+	b.g.edges[key][offset] = true
+
+	e := &CallEdge{Caller: caller, Callee: callee, Offset: offset, Opcode: opcode}
+	caller.Callees = append(caller.Callees, e)
+	callee.Callers = append(callee.Callers, e)
+	b.reach(callee)
+}
+
+// 静态解析的调用目标；方法在 classpath 上找不到时使用引用本身
+func (b *callGraphBuilder) resolve(class, name, descriptor string) *CallNode {
+	if owner, m, r := b.p.ResolveMethod(class, name, descriptor); r == RESOLVE_FOUND && owner != nil {
+		return b.node(owner.ThisClassString(), m.NameString(), m.DescriptorString())
+	}
+	return b.node(class, name, descriptor)
+}
+
+func (b *callGraphBuilder) scan(n *CallNode) {
+	cf := b.p.Class(n.Class)
+	instructions, err := n.Method.Code().Instructions()
+	if err != nil {
+		return
+	}
+
+	for _, ins := range instructions {
+		switch ins.Opcode {
+		case OP_INVOKESTATIC, OP_INVOKESPECIAL:
+			class, name, desc := cf.MemberRefAt(ins.Index)
+			if strings.HasPrefix(class, "[") {
+				continue
+			}
+			b.edge(n, b.resolve(class, name, desc), ins.Offset, ins.Opcode)
+
+		case OP_INVOKEVIRTUAL, OP_INVOKEINTERFACE:
+			class, name, desc := cf.MemberRefAt(ins.Index)
+			if strings.HasPrefix(class, "[") {
+				class = "java/lang/Object"
+			}
+			site := &callSite{n, class, name, desc, ins.Offset, ins.Opcode}
+			if b.g.Mode == CALL_GRAPH_RTA {
+				b.sites[class] = append(b.sites[class], site)
+				for _, t := range b.subtypes[class] {
+					b.dispatch(site, t)
+				}
+				if b.p.Class(class) == nil {
+					b.edge(n, b.node(class, name, desc), ins.Offset, ins.Opcode)
+				}
+			} else {
+				b.dispatchCHA(site)
+			}
+
+		case OP_INVOKEDYNAMIC:
+			if impl := lambdaImplementation(cf, ins.Index); impl != nil {
+				b.edge(n, b.resolve(impl.Class, impl.Name, impl.Descriptor), ins.Offset, ins.Opcode)
+			}
+
+		case OP_NEW:
+			if b.g.Mode == CALL_GRAPH_RTA {
+				b.instantiate(cf.ClassNameAt(ins.Index))
+			}
+		}
+	}
+}
+
+func (b *callGraphBuilder) dispatchCHA(site *callSite) {
+	found := false
+	for _, t := range b.p.AllSubtypes(site.class) {
+		cf := b.p.Class(t)
+		if cf == nil || cf.AccessFlags&(CLASS_ACC_INTERFACE|CLASS_ACC_ABSTRACT) != 0 {
+			continue
+		}
+		if owner, m, r := b.p.Dispatch(t, site.name, site.descriptor); r == RESOLVE_FOUND {
+			b.edge(site.caller, b.node(owner.ThisClassString(), m.NameString(), m.DescriptorString()), site.offset, site.opcode)
+			found = true
+		}
+	}
+	if !found || b.p.Class(site.class) == nil {
+		b.edge(site.caller, b.resolve(site.class, site.name, site.descriptor), site.offset, site.opcode)
+	}
+}
+
+// RTA：为调用点添加到实例化类型 t 的分派边，t 是调用点声明类型的子类型。
+// 同一类型与方法签名只查找一次
+func (b *callGraphBuilder) dispatch(site *callSite, t string) {
+	key := MethodKey(t, site.name, site.descriptor)
+	target := b.targets[key]
+	if target == nil {
+		target = &dispatchTarget{}
+		if owner, m, r := b.p.Dispatch(t, site.name, site.descriptor); r == RESOLVE_FOUND {
+			target.node = b.node(owner.ThisClassString(), m.NameString(), m.DescriptorString())
+		} else {
+			target.r = r
+		}
+		b.targets[key] = target
+	}
+	if target.node != nil {
+		b.edge(site.caller, target.node, site.offset, site.opcode)
+	} else if target.r == RESOLVE_UNKNOWN {
+		b.edge(site.caller, b.resolve(site.class, site.name, site.descriptor), site.offset, site.opcode)
+	}
+}
+
+func (b *callGraphBuilder) instantiate(t string) {
+	if b.instantiated[t] {
+		return
+	}
+	b.instantiated[t] = true
+	if cf := b.p.Class(t); cf != nil && cf.FindMethod("<clinit>", "()V") != nil {
+		b.reach(b.node(t, "<clinit>", "()V"))
+	}
+	for _, super := range append([]string{t}, b.p.Supertypes(t)...) {
+		b.subtypes[super] = append(b.subtypes[super], t)
+		for _, site := range b.sites[super] {
+			b.dispatch(site, t)
+		}
+	}
+}
+
+// invokedynamic 若由 LambdaMetafactory 引导，返回 lambda 或方法引用的实现方法
+func lambdaImplementation(cf *ClassFile, index uint16) *Reference {
+	indy := (*ConstantInvokeDynamicInfo)(cf.ConstantPool[index])
+	bms := cf.BootstrapMethods()
+	if int(indy.BootstrapMethodAttrIndex()) >= len(bms) {
+		return nil
+	}
+	bm := bms[indy.BootstrapMethodAttrIndex()]
+	class, _, _ := cf.MemberRefAt(bm.MethodHandle().ReferenceIndex())
+	if class != "java/lang/invoke/LambdaMetafactory" || len(bm.BootstrapArguments) < 2 {
+		return nil
+	}
+
+	info := cf.ConstantPool[bm.BootstrapArguments[1]]
+	if info.Tag != 15 {
+		return nil
+	}
+	return cf.ReferenceAt((*ConstantMethodHandleInfo)(info).ReferenceIndex())
+}
+
+// 解析 com/acme/Foo.bar、com.acme.Foo.bar 或 com.acme.Foo.bar(I)V 形式的方法模式，
+// 返回内部形式的类名、方法名与描述符（可能为空）
+func ParseMethodPattern(pattern string) (class, name, descriptor string) {
+	if i := strings.IndexByte(pattern, '('); i >= 0 {
+		pattern, descriptor = pattern[:i], pattern[i:]
+	}
+	i := strings.LastIndexByte(pattern, '.')
+	if i < 0 {
+		return "", pattern, descriptor
+	}
+	return strings.Replace(pattern[:i], ".", "/", -1), pattern[i+1:], descriptor
+}
+
+// 按方法模式查找节点，未给出描述符时匹配所有重载
+func (g *CallGraph) Find(pattern string) []*CallNode {
+	class, name, desc := ParseMethodPattern(pattern)
+	var rs []*CallNode
+	for _, n := range g.Nodes {
+		if n.Class == class && n.Name == name && (desc == "" || n.Descriptor == desc) {
+			rs = append(rs, n)
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].String() < rs[j].String()
+	})
+	return rs
+}
+
+// 按名称排序的所有节点
+func (g *CallGraph) SortedNodes() []*CallNode {
+	rs := make([]*CallNode, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		rs = append(rs, n)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].String() < rs[j].String()
+	})
+	return rs
+}
+
+func (g *CallGraph) WriteDot(w io.Writer) error {
+	s := &strings.Builder{}
+	fmt.Fprintf(s, "digraph %q {\n", g.Mode+" call graph")
+	for _, n := range g.SortedNodes() {
+		for _, e := range n.Callees {
+			fmt.Fprintf(s, "\t%q -> %q;\n", n.String(), e.Callee.String())
+		}
+	}
+	s.WriteString("}\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+func (g *CallGraph) WriteJSON(w io.Writer) error {
+	type edge struct {
+		Caller string `json:"caller"`
+		Callee string `json:"callee"`
+		Offset int    `json:"offset"`
+		Kind   string `json:"kind"`
+	}
+	out := struct {
+		Mode  string   `json:"mode"`
+		Nodes []string `json:"nodes"`
+		Edges []edge   `json:"edges"`
+	}{
+		Mode:  g.Mode,
+		Nodes: []string{},
+		Edges: []edge{},
+	}
+	for _, n := range g.SortedNodes() {
+		out.Nodes = append(out.Nodes, n.String())
+		for _, e := range n.Callees {
+			out.Edges = append(out.Edges, edge{n.String(), e.Callee.String(), e.Offset, OpcodeName(e.Opcode)})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
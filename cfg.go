@@ -0,0 +1,484 @@
+package jclass
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type BasicBlock struct {
+	Index int
+	// 第一条指令的偏移与最后一条指令之后的偏移
+	Start int
+	End   int
+
+	Instructions []*Instruction
+
+	// 正常控制流的后继，条件跳转时先为跳转目标后为顺序执行的下一块
+	Successors []*BasicBlock
+	// 覆盖本块的异常处理器入口
+	Handlers []*BasicBlock
+	// 所有前驱，包括经由异常边进入的
+	Predecessors []*BasicBlock
+
+	// 直接支配者；入口块与不可达块为 nil
+	Idom *BasicBlock
+	// 支配树中的子节点
+	Dominated []*BasicBlock
+
+	// 是否可以从入口到达
+	Reachable bool
+}
+
+func (b *BasicBlock) String() string {
+	return fmt.Sprintf("B%d[%d,%d)", b.Index, b.Start, b.End)
+}
+
+func (b *BasicBlock) Last() *Instruction {
+	return b.Instructions[len(b.Instructions)-1]
+}
+
+// b 是否支配 other（包括 b == other）
+func (b *BasicBlock) Dominates(other *BasicBlock) bool {
+	for o := other; o != nil; o = o.Idom {
+		if o == b {
+			return true
+		}
+	}
+	return false
+}
+
+type Loop struct {
+	Header *BasicBlock
+	// 回边的源块
+	Latches []*BasicBlock
+	// 循环体内的所有块（含 Header），按偏移排序
+	Blocks []*BasicBlock
+	// 直接外层循环
+	Parent *Loop
+}
+
+func (l *Loop) Contains(b *BasicBlock) bool {
+	for _, block := range l.Blocks {
+		if block == b {
+			return true
+		}
+	}
+	return false
+}
+
+type ControlFlowGraph struct {
+	Code   *CodeAttribute
+	Blocks []*BasicBlock
+	Entry  *BasicBlock
+
+	blockAt map[int]*BasicBlock
+}
+
+// 偏移处起始的基本块，不存在时返回 nil
+func (g *ControlFlowGraph) BlockAt(offset int) *BasicBlock {
+	return g.blockAt[offset]
+}
+
+// 由 Code 属性构建控制流图，并计算支配树
+func NewControlFlowGraph(code *CodeAttribute) (*ControlFlowGraph, error) {
+	instructions, err := code.Instructions()
+	if err != nil {
+		return nil, err
+	}
+	if len(instructions) == 0 {
+		return nil, ERR_TRUNCATED_CODE
+	}
+
+	g := &ControlFlowGraph{
+		Code:    code,
+		blockAt: make(map[int]*BasicBlock),
+	}
+
+	// 跳转目标与异常表偏移都必须是指令的起点，否则无法对应到基本块
+	starts := make(map[int]bool, len(instructions))
+	for _, ins := range instructions {
+		starts[ins.Offset] = true
+	}
+	for _, entry := range code.ExceptionTable {
+		start, end, handler := int(entry.StartPc), int(entry.EndPc), int(entry.HandlerPc)
+		if !starts[start] || !starts[end] && end != len(code.Code) || start >= end {
+			return nil, fmt.Errorf("[%d, %d): %s", start, end, ERR_INVALID_EXCEPTION_RANGE)
+		}
+		if !starts[handler] {
+			return nil, fmt.Errorf("%d: %s", handler, ERR_MISALIGNED_TARGET)
+		}
+	}
+
+	// 划分基本块的起点
+	leaders := map[int]bool{0: true}
+	for _, ins := range instructions {
+		next := ins.Offset + ins.Length
+		switch {
+		case ins.IsSwitch():
+			if !starts[ins.Default] {
+				return nil, fmt.Errorf("%d: %s", ins.Offset, ERR_MISALIGNED_TARGET)
+			}
+			leaders[ins.Default] = true
+			for _, target := range ins.Targets {
+				if !starts[target] {
+					return nil, fmt.Errorf("%d: %s", ins.Offset, ERR_MISALIGNED_TARGET)
+				}
+				leaders[target] = true
+			}
+			leaders[next] = true
+		case ins.IsBranch():
+			if !starts[ins.Branch] {
+				return nil, fmt.Errorf("%d: %s", ins.Offset, ERR_MISALIGNED_TARGET)
+			}
+			leaders[ins.Branch] = true
+			leaders[next] = true
+		case ins.EndsFlow():
+			leaders[next] = true
+		}
+	}
+	for _, entry := range code.ExceptionTable {
+		leaders[int(entry.StartPc)] = true
+		leaders[int(entry.EndPc)] = true
+		leaders[int(entry.HandlerPc)] = true
+	}
+
+	var block *BasicBlock
+	for _, ins := range instructions {
+		if leaders[ins.Offset] || block == nil {
+			block = &BasicBlock{Index: len(g.Blocks), Start: ins.Offset}
+			g.Blocks = append(g.Blocks, block)
+			g.blockAt[ins.Offset] = block
+		}
+		block.Instructions = append(block.Instructions, ins)
+		block.End = ins.Offset + ins.Length
+	}
+	g.Entry = g.Blocks[0]
+
+	link := func(from, to *BasicBlock) {
+		for _, s := range from.Successors {
+			if s == to {
+				return
+			}
+		}
+		from.Successors = append(from.Successors, to)
+	}
+
+	for i, b := range g.Blocks {
+		last := b.Last()
+		switch {
+		case last.IsSwitch():
+			for _, target := range last.Targets {
+				link(b, g.blockAt[target])
+			}
+			link(b, g.blockAt[last.Default])
+		case last.IsBranch():
+			link(b, g.blockAt[last.Branch])
+			// jsr 的返回点由 ret 连接，见 linkSubroutines
+			if last.IsConditionalBranch() && i+1 < len(g.Blocks) {
+				link(b, g.Blocks[i+1])
+			}
+		case last.EndsFlow():
+		default:
+			if i+1 < len(g.Blocks) {
+				link(b, g.Blocks[i+1])
+			}
+		}
+
+		for _, entry := range code.ExceptionTable {
+			if b.Start >= int(entry.StartPc) && b.End <= int(entry.EndPc) {
+				handler := g.blockAt[int(entry.HandlerPc)]
+				dup := false
+				for _, h := range b.Handlers {
+					dup = dup || h == handler
+				}
+				if !dup {
+					b.Handlers = append(b.Handlers, handler)
+				}
+			}
+		}
+	}
+
+	g.linkSubroutines(link)
+
+	for _, b := range g.Blocks {
+		for _, s := range b.Successors {
+			s.Predecessors = append(s.Predecessors, b)
+		}
+		for _, h := range b.Handlers {
+			h.Predecessors = append(h.Predecessors, b)
+		}
+	}
+
+	g.computeDominators()
+	return g, nil
+}
+
+// 旧版本类文件中的 jsr/ret 子程序：把每条 ret 连接到调用其所属子程序的 jsr 的下一条指令
+func (g *ControlFlowGraph) linkSubroutines(link func(from, to *BasicBlock)) {
+	// 子程序入口 -> 调用点的返回块
+	callers := make(map[*BasicBlock][]*BasicBlock)
+	var entries []*BasicBlock
+	for i, b := range g.Blocks {
+		if last := b.Last(); last.IsJsr() {
+			entry := g.blockAt[last.Branch]
+			if callers[entry] == nil {
+				entries = append(entries, entry)
+			}
+			if i+1 < len(g.Blocks) {
+				callers[entry] = append(callers[entry], g.Blocks[i+1])
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	owned := make(map[*BasicBlock]bool)
+	for _, entry := range entries {
+		// 从子程序入口出发，不进入嵌套子程序，而是直接跳到它的返回点
+		visited := make(map[*BasicBlock]bool)
+		stack := []*BasicBlock{entry}
+		for len(stack) > 0 {
+			b := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visited[b] {
+				continue
+			}
+			visited[b] = true
+
+			last := b.Last()
+			switch {
+			case last.Opcode == OP_RET:
+				for _, ret := range callers[entry] {
+					link(b, ret)
+				}
+				owned[b] = true
+			case last.IsJsr():
+				if b.Index+1 < len(g.Blocks) {
+					stack = append(stack, g.Blocks[b.Index+1])
+				}
+			default:
+				stack = append(stack, b.Successors...)
+			}
+			stack = append(stack, b.Handlers...)
+		}
+	}
+
+	// 无法归属到任何子程序的 ret 保守地连接到所有返回点
+	for _, b := range g.Blocks {
+		if b.Last().Opcode == OP_RET && !owned[b] {
+			for _, entry := range entries {
+				for _, ret := range callers[entry] {
+					link(b, ret)
+				}
+			}
+		}
+	}
+}
+
+// 按逆后序排列可达块
+func (g *ControlFlowGraph) reversePostorder() []*BasicBlock {
+	var order []*BasicBlock
+	visited := make(map[*BasicBlock]bool)
+	var visit func(b *BasicBlock)
+	visit = func(b *BasicBlock) {
+		visited[b] = true
+		for _, s := range b.Successors {
+			if !visited[s] {
+				visit(s)
+			}
+		}
+		for _, h := range b.Handlers {
+			if !visited[h] {
+				visit(h)
+			}
+		}
+		order = append(order, b)
+	}
+	visit(g.Entry)
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// Cooper、Harvey 与 Kennedy 的迭代支配算法
+func (g *ControlFlowGraph) computeDominators() {
+	order := g.reversePostorder()
+	rpo := make(map[*BasicBlock]int, len(order))
+	for i, b := range order {
+		rpo[b] = i
+		b.Reachable = true
+	}
+
+	idom := make(map[*BasicBlock]*BasicBlock)
+	idom[g.Entry] = g.Entry
+
+	intersect := func(a, b *BasicBlock) *BasicBlock {
+		for a != b {
+			for rpo[a] > rpo[b] {
+				a = idom[a]
+			}
+			for rpo[b] > rpo[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, b := range order[1:] {
+			var newIdom *BasicBlock
+			for _, p := range b.Predecessors {
+				if idom[p] == nil {
+					continue
+				}
+				if newIdom == nil {
+					newIdom = p
+				} else {
+					newIdom = intersect(p, newIdom)
+				}
+			}
+			if idom[b] != newIdom {
+				idom[b] = newIdom
+				changed = true
+			}
+		}
+	}
+
+	for _, b := range order[1:] {
+		b.Idom = idom[b]
+		b.Idom.Dominated = append(b.Idom.Dominated, b)
+	}
+	for _, b := range g.Blocks {
+		sort.Slice(b.Dominated, func(i, j int) bool {
+			return b.Dominated[i].Start < b.Dominated[j].Start
+		})
+	}
+}
+
+// 由回边（目标支配源的边）确定的自然循环，同一循环头的回边合并为一个循环；按循环头偏移排序
+func (g *ControlFlowGraph) Loops() []*Loop {
+	var rs []*Loop
+	byHeader := make(map[*BasicBlock]*Loop)
+
+	for _, b := range g.Blocks {
+		if !b.Reachable {
+			continue
+		}
+		for _, s := range append(append([]*BasicBlock(nil), b.Successors...), b.Handlers...) {
+			if !s.Dominates(b) {
+				continue
+			}
+			// 调用子程序的 jsr 边不构成循环
+			if last := b.Last(); last.IsJsr() && s == g.blockAt[last.Branch] {
+				continue
+			}
+			loop := byHeader[s]
+			if loop == nil {
+				loop = &Loop{Header: s}
+				byHeader[s] = loop
+				rs = append(rs, loop)
+			}
+			loop.Latches = append(loop.Latches, b)
+		}
+	}
+
+	for _, loop := range rs {
+		body := map[*BasicBlock]bool{loop.Header: true}
+		stack := append([]*BasicBlock(nil), loop.Latches...)
+		for len(stack) > 0 {
+			b := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if body[b] {
+				continue
+			}
+			body[b] = true
+			for _, p := range b.Predecessors {
+				if p.Reachable {
+					stack = append(stack, p)
+				}
+			}
+		}
+		for b := range body {
+			loop.Blocks = append(loop.Blocks, b)
+		}
+		sort.Slice(loop.Blocks, func(i, j int) bool {
+			return loop.Blocks[i].Start < loop.Blocks[j].Start
+		})
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Header.Start < rs[j].Header.Start
+	})
+
+	// 外层循环是包含本循环头的最小的其他循环
+	for _, loop := range rs {
+		for _, other := range rs {
+			if other == loop || !other.Contains(loop.Header) || len(other.Blocks) <= len(loop.Blocks) {
+				continue
+			}
+			if loop.Parent == nil || len(other.Blocks) < len(loop.Parent.Blocks) {
+				loop.Parent = other
+			}
+		}
+	}
+
+	return rs
+}
+
+// 以 Graphviz DOT 格式输出，异常边以虚线表示
+func (g *ControlFlowGraph) WriteDot(w io.Writer, name string) error {
+	s := &strings.Builder{}
+	fmt.Fprintf(s, "digraph %q {\n", name)
+	s.WriteString("\tnode [shape=box, fontname=monospace];\n")
+
+	for _, b := range g.Blocks {
+		label := &strings.Builder{}
+		fmt.Fprintf(label, "B%d\\l", b.Index)
+		for _, ins := range b.Instructions {
+			label.WriteString(strings.Replace(ins.String(), `"`, `\"`, -1))
+			label.WriteString("\\l")
+		}
+		style := ""
+		if !b.Reachable {
+			style = ", style=dotted"
+		}
+		fmt.Fprintf(s, "\tB%d [label=\"%s\"%s];\n", b.Index, label, style)
+	}
+
+	for _, b := range g.Blocks {
+		for _, succ := range b.Successors {
+			fmt.Fprintf(s, "\tB%d -> B%d;\n", b.Index, succ.Index)
+		}
+		for _, h := range b.Handlers {
+			fmt.Fprintf(s, "\tB%d -> B%d [style=dashed];\n", b.Index, h.Index)
+		}
+	}
+
+	s.WriteString("}\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// 支配树的 DOT 表示
+func (g *ControlFlowGraph) WriteDominatorTreeDot(w io.Writer, name string) error {
+	s := &strings.Builder{}
+	fmt.Fprintf(s, "digraph %q {\n", name)
+	for _, b := range g.Blocks {
+		if !b.Reachable {
+			continue
+		}
+		fmt.Fprintf(s, "\tB%d [label=%q];\n", b.Index, b.String())
+		for _, d := range b.Dominated {
+			fmt.Fprintf(s, "\tB%d -> B%d;\n", b.Index, d.Index)
+		}
+	}
+	s.WriteString("}\n")
+	_, err := io.WriteString(w, s.String())
+	return err
+}
@@ -6,8 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -196,14 +197,159 @@ func (cf *ClassFile) AccessFlagsString() string {
 }
 
 func (cf *ClassFile) getClassName(index uint16) string {
-	classInfo := (*ConstantClassInfo)(cf.ConstantPool[index])
-	nameIndex := classInfo.NameIndex()
-	utf8 := ((*ConstantUtf8Info)(cf.ConstantPool[nameIndex])).Utf8()
-	var debug string
+	utf8 := cf.ClassNameAt(index)
 	if DEBUG {
-		debug = fmt.Sprintf("/* %d -> %d */", index, nameIndex)
+		nameIndex := ((*ConstantClassInfo)(cf.ConstantPool[index])).NameIndex()
+		return fmt.Sprintf("%s /* %d -> %d */", utf8, index, nameIndex)
 	}
-	return fmt.Sprintf("%s %s", utf8, debug)
+	return utf8
+}
+
+// 返回常量池中 CONSTANT_Utf8 项的字符串
+func (cf *ClassFile) Utf8At(index uint16) string {
+	return ((*ConstantUtf8Info)(cf.ConstantPool[index])).Utf8()
+}
+
+// 返回常量池中 CONSTANT_Class 项的内部类名，如 java/lang/String 或 [I
+func (cf *ClassFile) ClassNameAt(index uint16) string {
+	return cf.Utf8At(((*ConstantClassInfo)(cf.ConstantPool[index])).NameIndex())
+}
+
+// 返回常量池中 CONSTANT_NameAndType 项的名称与描述符
+func (cf *ClassFile) NameAndTypeAt(index uint16) (name, descriptor string) {
+	info := (*ConstantNameAndTypeInfo)(cf.ConstantPool[index])
+	return cf.Utf8At(info.NameIndex()), cf.Utf8At(info.DescriptorIndex())
+}
+
+// 返回常量池中 CONSTANT_Fieldref、CONSTANT_Methodref 或 CONSTANT_InterfaceMethodref 项
+// 所引用的类名、成员名与描述符
+func (cf *ClassFile) MemberRefAt(index uint16) (class, name, descriptor string) {
+	// 三种引用的布局一致：class_index 与 name_and_type_index
+	info := (*ConstantFieldrefInfo)(cf.ConstantPool[index])
+	class = cf.ClassNameAt(info.ClassIndex())
+	name, descriptor = cf.NameAndTypeAt(info.NameAndTypeIndex())
+	return
+}
+
+// 方法句柄的 reference_kind 名称，下标即 kind
+var methodHandleKinds = []string{"", "getField", "getStatic", "putField", "putStatic",
+	"invokeVirtual", "invokeStatic", "invokeSpecial", "newInvokeSpecial", "invokeInterface"}
+
+// 常量池项的可读形式，其中的索引都已解析，如 method java/lang/Object.<init>()V、string "abc"。
+// 用于与常量池布局无关的比较与显示
+func (cf *ClassFile) ConstantString(index uint16) string {
+	if int(index) >= len(cf.ConstantPool) || cf.ConstantPool[index] == nil {
+		return fmt.Sprintf("#%d", index)
+	}
+	info := cf.ConstantPool[index]
+	switch info.Tag {
+	case 1:
+		return strconv.Quote(cf.Utf8At(index))
+	case 3:
+		return "int " + strconv.Itoa(int((*ConstantIntegerInfo)(info).Integer()))
+	case 4:
+		return "float " + strconv.FormatFloat(float64((*ConstantFloatInfo)(info).Float()), 'g', -1, 32)
+	case 5:
+		return "long " + strconv.FormatInt((*ConstantLongInfo)(info).Long(), 10)
+	case 6:
+		return "double " + strconv.FormatFloat((*ConstantDoubleInfo)(info).Double(), 'g', -1, 64)
+	case 7:
+		return "class " + cf.ClassNameAt(index)
+	case 8:
+		return "string " + strconv.Quote(cf.Utf8At((*ConstantStringInfo)(info).StringIndex()))
+	case 9:
+		class, name, desc := cf.MemberRefAt(index)
+		return "field " + class + "." + name + ":" + desc
+	case 10, 11:
+		class, name, desc := cf.MemberRefAt(index)
+		if info.Tag == 11 {
+			return "interface method " + class + "." + name + desc
+		}
+		return "method " + class + "." + name + desc
+	case 12:
+		name, desc := cf.NameAndTypeAt(index)
+		return name + ":" + desc
+	case 15:
+		handle := (*ConstantMethodHandleInfo)(info)
+		kind := strconv.Itoa(int(handle.ReferenceKind()))
+		if int(handle.ReferenceKind()) < len(methodHandleKinds) {
+			kind = methodHandleKinds[handle.ReferenceKind()]
+		}
+		return "handle " + kind + " " + cf.ConstantString(handle.ReferenceIndex())
+	case 16:
+		return "methodtype " + cf.Utf8At((*ConstantMethodTypeInfo)(info).DescriptorIndex())
+	case 17, 18:
+		// 两者布局相同
+		dynamic := (*ConstantDynamicInfo)(info)
+		name, desc := cf.NameAndTypeAt(dynamic.NameAndTypeIndex())
+		kind := "dynamic"
+		if info.Tag == 18 {
+			kind = "invokedynamic"
+		}
+		return fmt.Sprintf("%s #%d %s:%s", kind, dynamic.BootstrapMethodAttrIndex(), name, desc)
+	case 19:
+		return "module " + cf.Utf8At((*ConstantModuleInfo)(info).NameIndex())
+	case 20:
+		return "package " + cf.Utf8At((*ConstantPackageInfo)(info).NameIndex())
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+// 按名称查找类属性，不存在时返回 nil
+func (cf *ClassFile) Attribute(name string) *AttributeInfo {
+	return findAttribute(cf.Attributes, name)
+}
+
+// 泛型签名，不存在时返回空串
+func (cf *ClassFile) SignatureString() string {
+	return signatureOf(cf.Attributes)
+}
+
+// SourceFile 属性中的源文件名，不存在时返回空串
+func (cf *ClassFile) SourceFileString() string {
+	if attr := cf.Attribute("SourceFile"); attr != nil && len(attr.Info) == 2 {
+		return cf.Utf8At(binary.BigEndian.Uint16(attr.Info))
+	}
+	return ""
+}
+
+// 类上的全部注解（运行时可见与不可见）
+func (cf *ClassFile) Annotations() []*Annotation {
+	return annotationsOf(cf.Attributes)
+}
+
+// module-info.class 中的 Module 属性，普通类返回 nil
+func (cf *ClassFile) Module() *ModuleAttribute {
+	if attr := cf.Attribute("Module"); attr != nil {
+		return attr.Module
+	}
+	return nil
+}
+
+func (cf *ClassFile) InnerClasses() []*InnerClass {
+	if attr := cf.Attribute("InnerClasses"); attr != nil {
+		return attr.InnerClasses
+	}
+	return nil
+}
+
+// 若本类是内部类，返回 InnerClasses 属性中描述自身的项，否则返回 nil
+func (cf *ClassFile) InnerClassEntry() *InnerClass {
+	self := cf.ThisClassString()
+	for _, c := range cf.InnerClasses() {
+		if c.InnerClassString() == self {
+			return c
+		}
+	}
+	return nil
+}
+
+func (cf *ClassFile) BootstrapMethods() []*BootstrapMethod {
+	attr := cf.Attribute("BootstrapMethods")
+	if attr == nil {
+		return nil
+	}
+	return attr.BootstrapMethods
 }
 
 func (cf *ClassFile) ThisClassString() string {
@@ -243,6 +389,44 @@ func (cf *ClassFile) HasMethod() bool {
 	return cf.MethodsCount > 0
 }
 
+// 按名称与描述符查找本类声明的字段，不存在时返回 nil
+func (cf *ClassFile) FindField(name, descriptor string) *FieldInfo {
+	for _, field := range cf.Fields {
+		if field.NameString() == name && field.DescriptorString() == descriptor {
+			return field
+		}
+	}
+	return nil
+}
+
+// 按名称与描述符查找本类声明的方法，不存在时返回 nil
+func (cf *ClassFile) FindMethod(name, descriptor string) *MethodInfo {
+	for _, method := range cf.Methods {
+		if method.NameString() == name && method.DescriptorString() == descriptor {
+			return method
+		}
+	}
+	return nil
+}
+
+// JVMS 2.9.3 签名多态方法（MethodHandle.invoke 等）可以匹配任意描述符
+func (cf *ClassFile) findSignaturePolymorphic(name string) *MethodInfo {
+	switch cf.ThisClassString() {
+	case "java/lang/invoke/MethodHandle", "java/lang/invoke/VarHandle":
+	default:
+		return nil
+	}
+
+	for _, method := range cf.Methods {
+		if method.NameString() == name &&
+			method.AccessFlags&(METHOD_ACC_VARARGS|METHOD_ACC_NATIVE) == METHOD_ACC_VARARGS|METHOD_ACC_NATIVE &&
+			strings.HasPrefix(method.DescriptorString(), "([Ljava/lang/Object;)") {
+			return method
+		}
+	}
+	return nil
+}
+
 func NewClassFileFromPath(path string) (*ClassFile, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -290,7 +474,7 @@ func NewClassFile(r io.Reader) (*ClassFile, error) {
 	for i := 1; i < int(rs.ConstantPoolCount); i++ {
 		info, buf, err = NewConstantPoolInfo(r, buf)
 		if err != nil {
-			log.Fatalln(err)
+			return nil, err
 		}
 
 		rs.ConstantPool[i] = info
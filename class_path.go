@@ -0,0 +1,385 @@
+package jclass
+
+import (
+	"sort"
+	"strings"
+)
+
+// ClassPath 按顺序组合多个 jar 或类目录，同名类以先出现者为准（与 JVM 一致）
+type ClassPath struct {
+	Jars []*Jar
+
+	classes map[string]*ClassFile
+	owners  map[string]*Jar
+
+	// 直接子类与直接实现/继承的子接口，首次使用时构建
+	subtypes map[string][]string
+}
+
+func NewClassPath(paths ...string) (*ClassPath, error) {
+	jars := make([]*Jar, 0, len(paths))
+	for _, path := range paths {
+		jar, err := NewJarFromPath(path)
+		if err != nil {
+			return nil, err
+		}
+		jars = append(jars, jar)
+	}
+	return NewClassPathFromJars(jars...), nil
+}
+
+func NewClassPathFromJars(jars ...*Jar) *ClassPath {
+	rs := &ClassPath{
+		Jars:    jars,
+		classes: make(map[string]*ClassFile),
+		owners:  make(map[string]*Jar),
+	}
+	for _, jar := range jars {
+		for _, name := range jar.ClassNames() {
+			if _, ok := rs.classes[name]; ok {
+				continue
+			}
+			rs.classes[name] = jar.Class(name)
+			rs.owners[name] = jar
+		}
+	}
+	return rs
+}
+
+func (p *ClassPath) Class(name string) *ClassFile {
+	return p.classes[name]
+}
+
+// 返回类所在的 jar，找不到时返回 nil
+func (p *ClassPath) JarOf(name string) *Jar {
+	return p.owners[name]
+}
+
+func (p *ClassPath) ClassNames() []string {
+	rs := make([]string, 0, len(p.classes))
+	for name := range p.classes {
+		rs = append(rs, name)
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+type Resolution int
+
+const (
+	RESOLVE_FOUND Resolution = iota
+	RESOLVE_MISSING
+	// 查找途中遇到不在 classpath 上的类型，无法断定成员是否存在
+	RESOLVE_UNKNOWN
+)
+
+func (r Resolution) String() string {
+	switch r {
+	case RESOLVE_FOUND:
+		return "found"
+	case RESOLVE_MISSING:
+		return "missing"
+	default:
+		return "unknown"
+	}
+}
+
+// JVMS 5.4.3.2 字段解析：自身、直接及间接超接口、超类
+func (p *ClassPath) ResolveField(class, name, descriptor string) (owner *ClassFile, field *FieldInfo, rs Resolution) {
+	visited := make(map[string]bool)
+	var lookup func(c string) bool
+	complete := true
+
+	lookup = func(c string) bool {
+		if c == "" || visited[c] {
+			return false
+		}
+		visited[c] = true
+
+		cf := p.Class(c)
+		if cf == nil {
+			if c != "java/lang/Object" {
+				complete = false
+			}
+			return false
+		}
+
+		if f := cf.FindField(name, descriptor); f != nil {
+			owner, field = cf, f
+			return true
+		}
+		for _, iface := range cf.InterfaceStrings() {
+			if lookup(iface) {
+				return true
+			}
+		}
+		return lookup(cf.SuperClassString())
+	}
+
+	switch {
+	case lookup(class):
+		rs = RESOLVE_FOUND
+	case complete:
+		rs = RESOLVE_MISSING
+	default:
+		rs = RESOLVE_UNKNOWN
+	}
+	return
+}
+
+// JVMS 5.4.3.3 与 5.4.3.4 方法解析：先查找类及其超类（接口则查找自身与 java/lang/Object），
+// 再查找所有超接口中非 private、非 static 的方法。
+// 方法来自未加载的 java/lang/Object 时，返回 RESOLVE_FOUND 但 owner 与 method 为 nil。
+func (p *ClassPath) ResolveMethod(class, name, descriptor string) (*ClassFile, *MethodInfo, Resolution) {
+	complete := true
+
+	c := class
+	for c != "" {
+		cf := p.Class(c)
+		if cf == nil {
+			if c == "java/lang/Object" {
+				if objectMethods[name+descriptor] {
+					return nil, nil, RESOLVE_FOUND
+				}
+			} else {
+				complete = false
+			}
+			break
+		}
+
+		if m := cf.FindMethod(name, descriptor); m != nil {
+			return cf, m, RESOLVE_FOUND
+		}
+		if m := cf.findSignaturePolymorphic(name); m != nil {
+			return cf, m, RESOLVE_FOUND
+		}
+
+		// 接口的 super_class 总是 java/lang/Object
+		c = cf.SuperClassString()
+	}
+
+	visited := make(map[string]bool)
+	queue := p.directInterfaces(class, visited)
+	for len(queue) > 0 {
+		iface := queue[0]
+		queue = queue[1:]
+
+		cf := p.Class(iface)
+		if cf == nil {
+			complete = false
+			continue
+		}
+		if m := cf.FindMethod(name, descriptor); m != nil &&
+			m.AccessFlags&(METHOD_ACC_PRIVATE|METHOD_ACC_STATIC) == 0 {
+			return cf, m, RESOLVE_FOUND
+		}
+		for _, super := range cf.InterfaceStrings() {
+			if !visited[super] {
+				visited[super] = true
+				queue = append(queue, super)
+			}
+		}
+	}
+
+	if complete {
+		return nil, nil, RESOLVE_MISSING
+	}
+	return nil, nil, RESOLVE_UNKNOWN
+}
+
+// 收集类及其所有超类直接实现的接口
+func (p *ClassPath) directInterfaces(class string, visited map[string]bool) []string {
+	var rs []string
+	for c := class; c != ""; {
+		cf := p.Class(c)
+		if cf == nil {
+			break
+		}
+		for _, iface := range cf.InterfaceStrings() {
+			if !visited[iface] {
+				visited[iface] = true
+				rs = append(rs, iface)
+			}
+		}
+		c = cf.SuperClassString()
+	}
+	return rs
+}
+
+// 判断 class 是否可以赋值给 target（class 等于 target、继承或实现了 target）
+func (p *ClassPath) IsAssignable(class, target string) Resolution {
+	if target == "java/lang/Object" {
+		return RESOLVE_FOUND
+	}
+
+	complete := true
+	visited := make(map[string]bool)
+	queue := []string{class}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c == "" || visited[c] {
+			continue
+		}
+		visited[c] = true
+		if c == target {
+			return RESOLVE_FOUND
+		}
+
+		cf := p.Class(c)
+		if cf == nil {
+			if c != "java/lang/Object" {
+				complete = false
+			}
+			continue
+		}
+		queue = append(queue, cf.SuperClassString())
+		queue = append(queue, cf.InterfaceStrings()...)
+	}
+	if complete {
+		return RESOLVE_MISSING
+	}
+	return RESOLVE_UNKNOWN
+}
+
+// 直接子类型：继承 name 的类，以及实现或继承 name 接口的类与接口
+func (p *ClassPath) DirectSubtypes(name string) []string {
+	if p.subtypes == nil {
+		p.subtypes = make(map[string][]string)
+		for _, class := range p.ClassNames() {
+			cf := p.Class(class)
+			if super := cf.SuperClassString(); super != "" {
+				p.subtypes[super] = append(p.subtypes[super], class)
+			}
+			for _, iface := range cf.InterfaceStrings() {
+				p.subtypes[iface] = append(p.subtypes[iface], class)
+			}
+		}
+	}
+	return p.subtypes[name]
+}
+
+// name 自身及其在 classpath 上的所有直接与间接子类型，按名称排序
+func (p *ClassPath) AllSubtypes(name string) []string {
+	visited := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		for _, sub := range p.DirectSubtypes(c) {
+			if !visited[sub] {
+				visited[sub] = true
+				queue = append(queue, sub)
+			}
+		}
+	}
+
+	rs := make([]string, 0, len(visited))
+	for c := range visited {
+		rs = append(rs, c)
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+// 虚方法分派（JVMS 5.4.6）：在接收者类型 class 上选择实际执行的方法。
+// 先沿超类链查找非抽象的实例方法，找不到时在超接口中查找默认方法。
+// 超类中的私有方法不能被覆盖，也不会被选中
+func (p *ClassPath) Dispatch(class, name, descriptor string) (*ClassFile, *MethodInfo, Resolution) {
+	complete := true
+
+	for c := class; c != ""; {
+		cf := p.Class(c)
+		if cf == nil {
+			complete = false
+			break
+		}
+		excluded := METHOD_ACC_STATIC | METHOD_ACC_ABSTRACT
+		if c != class {
+			excluded |= METHOD_ACC_PRIVATE
+		}
+		if m := cf.FindMethod(name, descriptor); m != nil && m.AccessFlags&excluded == 0 {
+			return cf, m, RESOLVE_FOUND
+		}
+		c = cf.SuperClassString()
+	}
+
+	visited := make(map[string]bool)
+	queue := p.directInterfaces(class, visited)
+	for len(queue) > 0 {
+		iface := queue[0]
+		queue = queue[1:]
+
+		cf := p.Class(iface)
+		if cf == nil {
+			complete = false
+			continue
+		}
+		if m := cf.FindMethod(name, descriptor); m != nil &&
+			m.AccessFlags&(METHOD_ACC_STATIC|METHOD_ACC_ABSTRACT|METHOD_ACC_PRIVATE) == 0 {
+			return cf, m, RESOLVE_FOUND
+		}
+		for _, super := range cf.InterfaceStrings() {
+			if !visited[super] {
+				visited[super] = true
+				queue = append(queue, super)
+			}
+		}
+	}
+
+	if complete {
+		return nil, nil, RESOLVE_MISSING
+	}
+	return nil, nil, RESOLVE_UNKNOWN
+}
+
+// java/lang/Object 的方法，用于 JDK 类不在 classpath 上时的方法解析
+var objectMethods = map[string]bool{
+	"<init>()V":                    true,
+	"clone()Ljava/lang/Object;":    true,
+	"equals(Ljava/lang/Object;)Z":  true,
+	"finalize()V":                  true,
+	"getClass()Ljava/lang/Class;":  true,
+	"hashCode()I":                  true,
+	"notify()V":                    true,
+	"notifyAll()V":                 true,
+	"toString()Ljava/lang/String;": true,
+	"wait()V":                      true,
+	"wait(J)V":                     true,
+	"wait(JI)V":                    true,
+}
+
+// 包名（内部形式），如 java/lang/String -> java/lang；默认包返回空串
+func PackageOf(class string) string {
+	i := strings.LastIndexByte(class, '/')
+	if i < 0 {
+		return ""
+	}
+	return class[:i]
+}
+
+// 所有直接与间接的超类和超接口（不含自身），不在 classpath 上的类型也会列出但不再向上查找
+func (p *ClassPath) Supertypes(name string) []string {
+	var rs []string
+	visited := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		cf := p.Class(queue[0])
+		queue = queue[1:]
+		if cf == nil {
+			continue
+		}
+		supers := cf.InterfaceStrings()
+		if super := cf.SuperClassString(); super != "" {
+			supers = append([]string{super}, supers...)
+		}
+		for _, super := range supers {
+			if !visited[super] {
+				visited[super] = true
+				rs = append(rs, super)
+				queue = append(queue, super)
+			}
+		}
+	}
+	return rs
+}
@@ -0,0 +1,93 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+type classWriter struct {
+	bytes.Buffer
+}
+
+func (w *classWriter) u1(v uint8) {
+	w.WriteByte(v)
+}
+
+func (w *classWriter) u2(v uint16) {
+	w.WriteByte(byte(v >> 8))
+	w.WriteByte(byte(v))
+}
+
+func (w *classWriter) u4(v uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	w.Write(buf[:])
+}
+
+func (w *classWriter) attributes(attrs []*AttributeInfo) {
+	w.u2(uint16(len(attrs)))
+	for _, attr := range attrs {
+		w.u2(attr.NameIndex)
+		w.u4(uint32(len(attr.Info)))
+		w.Write(attr.Info)
+	}
+}
+
+// 按 class 文件格式序列化。各计数字段取自对应切片的实际长度，属性按原始的 Info 字节写出，
+// 因此修改解析出的结构（如 Code、Annotations）不会反映到输出中，需要直接修改 Info
+func (cf *ClassFile) Bytes() []byte {
+	w := &classWriter{}
+
+	w.u4(MAGIC)
+	w.u2(cf.MinorVersion)
+	w.u2(cf.MajorVersion)
+
+	w.u2(uint16(len(cf.ConstantPool)))
+	for i := 1; i < len(cf.ConstantPool); i++ {
+		info := cf.ConstantPool[i]
+		if info == nil {
+			continue
+		}
+		w.u1(info.Tag)
+		w.Write(info.Info)
+	}
+
+	w.u2(uint16(cf.AccessFlags))
+	w.u2(cf.ThisClass)
+	w.u2(cf.SuperClass)
+
+	w.u2(uint16(len(cf.Interfaces)))
+	for _, iface := range cf.Interfaces {
+		w.u2(iface)
+	}
+
+	w.u2(uint16(len(cf.Fields)))
+	for _, field := range cf.Fields {
+		w.u2(uint16(field.AccessFlags))
+		w.u2(field.NameIndex)
+		w.u2(field.DescriptorIndex)
+		w.attributes(field.Attributes)
+	}
+
+	w.u2(uint16(len(cf.Methods)))
+	for _, method := range cf.Methods {
+		w.u2(uint16(method.AccessFlags))
+		w.u2(method.NameIndex)
+		w.u2(method.DescriptorIndex)
+		w.attributes(method.Attributes)
+	}
+
+	w.attributes(cf.Attributes)
+	return w.Bytes()
+}
+
+func (cf *ClassFile) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(cf.Bytes())
+	return int64(n), err
+}
+
+// 通过序列化再解析得到的深拷贝
+func (cf *ClassFile) Clone() (*ClassFile, error) {
+	return NewClassFile(bytes.NewReader(cf.Bytes()))
+}
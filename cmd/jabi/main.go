@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+var verbose = flag.Bool("v", false, "also print the hash of every visible class")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jabi [-v] jar|dir|file.class ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, path := range flag.Args() {
+		if strings.HasSuffix(path, ".class") {
+			cf, err := jclass.NewClassFileFromPath(path)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			fmt.Printf("%s  %s\n", jclass.ABIHash(cf), path)
+			continue
+		}
+
+		jar, err := jclass.NewJarFromPath(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("%s  %s\n", jar.ABIHash(), path)
+		if !*verbose {
+			continue
+		}
+		hashes := jar.ABIHashes()
+		names := make([]string, 0, len(hashes))
+		for name := range hashes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s  %s\n", hashes[name], name)
+		}
+	}
+}
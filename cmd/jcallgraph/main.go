@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	mode    = flag.String("mode", "cha", "analysis mode: cha or rta")
+	entry   = flag.String("entry", "", "comma separated entry methods, e.g. com.acme.Main.main; default: all main methods (rta) or all methods (cha)")
+	callers = flag.String("callers", "", "print callers of the method, e.g. com.acme.Foo.bar or com.acme.Foo.bar(I)V")
+	callees = flag.String("callees", "", "print callees of the method")
+	format  = flag.String("format", "text", "output format for the whole graph: text, dot or json")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jcallgraph [flags] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var entries []string
+	if *entry != "" {
+		entries = strings.Split(*entry, ",")
+	} else if *mode == jclass.CALL_GRAPH_RTA {
+		for _, name := range classPath.ClassNames() {
+			if m := classPath.Class(name).FindMethod("main", "([Ljava/lang/String;)V"); m != nil && m.AccessFlags&jclass.METHOD_ACC_STATIC != 0 {
+				entries = append(entries, jclass.MethodKey(name, "main", "([Ljava/lang/String;)V"))
+			}
+		}
+		if len(entries) == 0 {
+			log.Fatalln("no main method found, use -entry")
+		}
+	}
+
+	graph, err := classPath.CallGraph(*mode, entries)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *callers != "" || *callees != "" {
+		query(graph, *callers, true)
+		query(graph, *callees, false)
+		return
+	}
+
+	switch *format {
+	case "text":
+		for _, n := range graph.SortedNodes() {
+			for _, e := range n.Callees {
+				fmt.Println(e)
+			}
+		}
+	case "dot":
+		err = graph.WriteDot(os.Stdout)
+	case "json":
+		err = graph.WriteJSON(os.Stdout)
+	default:
+		log.Fatalln("invalid format:", *format)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func query(graph *jclass.CallGraph, pattern string, callers bool) {
+	if pattern == "" {
+		return
+	}
+	nodes := graph.Find(pattern)
+	if len(nodes) == 0 {
+		fmt.Fprintln(os.Stderr, "method not in call graph:", pattern)
+		return
+	}
+	for _, n := range nodes {
+		edges := n.Callees
+		if callers {
+			edges = n.Callers
+		}
+		for _, e := range edges {
+			fmt.Println(e)
+		}
+	}
+}
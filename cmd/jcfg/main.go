@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	method = flag.String("method", "", "method name, optionally followed by its descriptor, e.g. main([Ljava/lang/String;)V")
+	dom    = flag.Bool("dom", false, "print the dominator tree instead of the control flow graph")
+	loops  = flag.Bool("loops", false, "list natural loops instead of printing a graph")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jcfg [flags] file.class")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classFile, err := jclass.NewClassFileFromPath(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, m := range classFile.Methods {
+		id := m.NameString() + m.DescriptorString()
+		if *method != "" && m.NameString() != *method && id != *method {
+			continue
+		}
+		code := m.Code()
+		if code == nil {
+			continue
+		}
+
+		cfg, err := jclass.NewControlFlowGraph(code)
+		if err != nil {
+			log.Fatalln(id+":", err)
+		}
+
+		switch {
+		case *loops:
+			for _, loop := range cfg.Loops() {
+				blocks := make([]string, len(loop.Blocks))
+				for i, b := range loop.Blocks {
+					blocks[i] = b.String()
+				}
+				fmt.Printf("%s: loop at %s: %s\n", id, loop.Header, strings.Join(blocks, " "))
+			}
+		case *dom:
+			err = cfg.WriteDominatorTreeDot(os.Stdout, id)
+		default:
+			err = cfg.WriteDot(os.Stdout, id)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
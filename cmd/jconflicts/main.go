@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	format         = flag.String("format", "text", "output format: text or json")
+	allowIdentical = flag.Bool("allow-identical", false, "do not fail on byte-identical duplicate classes")
+	allowSplit     = flag.Bool("allow-split", false, "do not fail on split packages")
+)
+
+type jsonCopy struct {
+	Jar         string   `json:"jar"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+type jsonDuplicate struct {
+	Class     string      `json:"class"`
+	Identical bool        `json:"identical"`
+	Copies    []*jsonCopy `json:"copies"`
+}
+
+type jsonSplit struct {
+	Package string   `json:"package"`
+	Jars    []string `json:"jars"`
+}
+
+type jsonReport struct {
+	Duplicates    []*jsonDuplicate `json:"duplicates"`
+	SplitPackages []*jsonSplit     `json:"splitPackages"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jconflicts [-format text|json] [-allow-identical] [-allow-split] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	duplicates := classPath.DuplicateClasses()
+	splits := classPath.SplitPackages()
+
+	failed := false
+	report := &jsonReport{Duplicates: []*jsonDuplicate{}, SplitPackages: []*jsonSplit{}}
+	for _, d := range duplicates {
+		if !d.Identical || !*allowIdentical {
+			failed = true
+		}
+		jd := &jsonDuplicate{Class: strings.Replace(d.Name, "/", ".", -1), Identical: d.Identical}
+		for i, jar := range d.Jars {
+			jd.Copies = append(jd.Copies, &jsonCopy{Jar: jar.Path, Differences: d.Differences[i]})
+		}
+		report.Duplicates = append(report.Duplicates, jd)
+	}
+	for _, s := range splits {
+		if !*allowSplit {
+			failed = true
+		}
+		js := &jsonSplit{Package: strings.Replace(s.Package, "/", ".", -1)}
+		for _, jar := range s.Jars {
+			js.Jars = append(js.Jars, jar.Path)
+		}
+		report.SplitPackages = append(report.SplitPackages, js)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		for _, d := range report.Duplicates {
+			state := "differs"
+			if d.Identical {
+				state = "identical"
+			}
+			fmt.Printf("duplicate class %s (%s)\n", d.Class, state)
+			for i, c := range d.Copies {
+				if i == 0 {
+					fmt.Printf("\t%s (loaded)\n", c.Jar)
+					continue
+				}
+				fmt.Printf("\t%s\n", c.Jar)
+				for _, diff := range c.Differences {
+					fmt.Printf("\t\t%s\n", diff)
+				}
+			}
+		}
+		for _, s := range report.SplitPackages {
+			fmt.Printf("split package %s\n", s.Package)
+			for _, jar := range s.Jars {
+				fmt.Printf("\t%s\n", jar)
+			}
+		}
+		if len(duplicates) > 0 || len(splits) > 0 {
+			fmt.Printf("%d duplicate classes, %d split packages\n", len(duplicates), len(splits))
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
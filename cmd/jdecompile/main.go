@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	output = flag.String("o", "", "output directory for the .java files")
+	class  = flag.String("c", "", "decompile only this class (e.g. com/acme/Foo) and print it")
+	method = flag.String("m", "", "with -c, print only the body of methods with this name")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jdecompile (-o dir | -c class [-m method]) app.jar [lib.jar ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || (*output == "") == (*class == "") || *method != "" && *class == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 只反编译第一个 jar 中的类，其余 jar 用于查找内部类与枚举 switch 的映射表
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *class != "" {
+		cf := classPath.Jars[0].Class(strings.Replace(*class, ".", "/", -1))
+		if cf == nil {
+			log.Fatalln("class not found:", *class)
+		}
+		if *method == "" {
+			fmt.Print(classPath.Decompile(cf))
+			return
+		}
+		for _, m := range cf.Methods {
+			if m.NameString() != *method {
+				continue
+			}
+			body, err := classPath.DecompileMethod(cf, m)
+			if err != nil {
+				log.Fatalln(m.NameString()+m.DescriptorString()+":", err)
+			}
+			fmt.Printf("// %s%s\n%s\n", m.NameString(), m.DescriptorString(), body)
+		}
+		return
+	}
+
+	sources := classPath.DecompileJar(classPath.Jars[0])
+	for name, source := range sources {
+		path := filepath.Join(*output, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatalln(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "decompiled %d classes to %s\n", len(sources), *output)
+}
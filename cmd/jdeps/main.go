@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	level   = flag.String("level", "package", "aggregation level: class, package, jar or module")
+	format  = flag.String("format", "summary", "output format: summary, dot or json")
+	exclude = flag.String("exclude", "", "comma separated class name patterns to ignore, e.g. java.*,javax.*")
+	cycles  = flag.Bool("cycles", false, "report cycles between packages and exit non-zero if any")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jdeps [flags] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	patterns := strings.Split(*exclude, ",")
+	graph, err := classPath.DependencyGraph(*level, patterns)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch *format {
+	case "summary":
+		err = graph.WriteSummary(os.Stdout)
+	case "dot":
+		err = graph.WriteDot(os.Stdout)
+	case "json":
+		err = graph.WriteJSON(os.Stdout)
+	default:
+		log.Fatalln("invalid format:", *format)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if *cycles {
+		packages := graph
+		if *level != jclass.DEPENDENCY_LEVEL_PACKAGE {
+			packages, err = classPath.DependencyGraph(jclass.DEPENDENCY_LEVEL_PACKAGE, patterns)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		found := packages.Cycles()
+		for _, cycle := range found {
+			fmt.Fprintln(os.Stderr, "package cycle:", strings.Join(cycle, " <-> "))
+		}
+		if len(found) > 0 {
+			os.Exit(1)
+		}
+	}
+}
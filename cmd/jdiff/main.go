@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var code = flag.Bool("code", false, "show instruction diffs of changed methods")
+
+func printClassDiff(d *jclass.ClassDiff) {
+	for _, c := range d.Changes {
+		fmt.Println("  " + c.String())
+		for _, line := range c.Lines {
+			fmt.Println("      " + line)
+		}
+	}
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jdiff [-code] a.class|a.jar|dir b.class|b.jar|dir")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts := &jclass.DiffOptions{Instructions: *code}
+
+	if strings.HasSuffix(flag.Arg(0), ".class") && strings.HasSuffix(flag.Arg(1), ".class") {
+		a, err := jclass.NewClassFileFromPath(flag.Arg(0))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		b, err := jclass.NewClassFileFromPath(flag.Arg(1))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		d := jclass.DiffClasses(a, b, opts)
+		if len(d.Changes) == 0 {
+			return
+		}
+		fmt.Println(d.Name)
+		printClassDiff(d)
+		os.Exit(1)
+	}
+
+	a, err := jclass.NewJarFromPath(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	b, err := jclass.NewJarFromPath(flag.Arg(1))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	d := jclass.DiffJars(a, b, opts)
+	for _, name := range d.RemovedClasses {
+		fmt.Println("- class " + name)
+	}
+	for _, name := range d.AddedClasses {
+		fmt.Println("+ class " + name)
+	}
+	for _, c := range d.Classes {
+		fmt.Println("~ class " + c.Name)
+		printClassDiff(c)
+	}
+	for _, name := range d.RemovedResources {
+		fmt.Println("- " + name)
+	}
+	for _, name := range d.AddedResources {
+		fmt.Println("+ " + name)
+	}
+	for _, name := range d.ChangedResources {
+		fmt.Println("~ " + name)
+	}
+	if !d.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var ignore = flag.String("ignore", "java/,javax/,jdk/,sun/,com/sun/",
+	"comma separated class name prefixes assumed to be provided by the runtime")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jlinkage [flags] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	problems := classPath.CheckLinkage(strings.Split(*ignore, ","))
+
+	// 按引用方 jar 与缺失目标分组输出
+	var jar *jclass.Jar
+	var target string
+	for _, problem := range problems {
+		if problem.Jar != jar {
+			jar = problem.Jar
+			target = ""
+			fmt.Println(jar)
+		}
+		if t := problem.Ref.String(); t != target {
+			target = t
+			var via string
+			if problem.Bootstrap {
+				via = " (bootstrap method handle)"
+			}
+			fmt.Printf("\t%s: %s%s\n", target, problem.Reason, via)
+		}
+		fmt.Printf("\t\t%s\n", problem.Class)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%d unresolved references\n", len(problems))
+		os.Exit(1)
+	}
+}
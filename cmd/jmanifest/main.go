@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"sort"
+)
+
+var format = flag.String("format", "text", "output format: text or json")
+
+type jsonJar struct {
+	Path                  string                       `json:"path"`
+	MainClass             string                       `json:"mainClass,omitempty"`
+	ClassPath             []string                     `json:"classPath,omitempty"`
+	AutomaticModuleName   string                       `json:"automaticModuleName,omitempty"`
+	ModuleName            string                       `json:"moduleName"`
+	MultiRelease          bool                         `json:"multiRelease,omitempty"`
+	ImplementationVersion string                       `json:"implementationVersion,omitempty"`
+	Attributes            map[string]string            `json:"attributes,omitempty"`
+	Sections              map[string]map[string]string `json:"sections,omitempty"`
+	Maven                 []string                     `json:"maven,omitempty"`
+	Services              map[string][]string          `json:"services,omitempty"`
+	Issues                []string                     `json:"issues,omitempty"`
+}
+
+func attributes(s *jclass.ManifestSection) map[string]string {
+	rs := make(map[string]string)
+	for _, attr := range s.Attributes {
+		rs[attr.Name] = attr.Value
+	}
+	return rs
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jmanifest [-format text|json] jar ...")
+		fmt.Fprintln(os.Stderr, "Main-Class is looked up in the jar first, then in the other jars given")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var reports []*jsonJar
+	failed := false
+	for _, jar := range classPath.Jars {
+		m, err := jar.Manifest()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		report := &jsonJar{
+			Path:       jar.Path,
+			ModuleName: jar.ModuleName(),
+			Services:   jar.Services(),
+		}
+		if m != nil {
+			report.MainClass = m.Main.Get("Main-Class")
+			report.ClassPath = m.ClassPath()
+			report.AutomaticModuleName = m.AutomaticModuleName()
+			report.MultiRelease = m.IsMultiRelease()
+			report.ImplementationVersion = m.ImplementationVersion()
+			report.Attributes = attributes(m.Main)
+			for _, section := range m.Sections {
+				if report.Sections == nil {
+					report.Sections = make(map[string]map[string]string)
+				}
+				report.Sections[section.Name()] = attributes(section)
+			}
+		}
+		for _, coord := range jar.MavenCoordinates() {
+			report.Maven = append(report.Maven, coord.String())
+		}
+		for _, issue := range jar.CheckMetadata(classPath) {
+			failed = true
+			report.Issues = append(report.Issues, issue.String())
+		}
+		reports = append(reports, report)
+
+		if *format != "text" {
+			continue
+		}
+		fmt.Println(jar.Path)
+		fmt.Printf("  module: %s\n", report.ModuleName)
+		if m == nil {
+			fmt.Println("  no manifest")
+		} else {
+			for _, attr := range m.Main.Attributes {
+				fmt.Printf("  %s: %s\n", attr.Name, attr.Value)
+			}
+			if len(m.Sections) > 0 {
+				fmt.Printf("  %d entry sections\n", len(m.Sections))
+			}
+		}
+		for _, coord := range report.Maven {
+			fmt.Printf("  maven: %s\n", coord)
+		}
+		services := make([]string, 0, len(report.Services))
+		for service := range report.Services {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+		for _, service := range services {
+			fmt.Printf("  service %s\n", service)
+			for _, provider := range report.Services[service] {
+				fmt.Printf("    %s\n", provider)
+			}
+		}
+		for _, issue := range report.Issues {
+			fmt.Printf("  ERROR %s\n", issue)
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+)
+
+var (
+	release = flag.String("release", "", "list the entry each class resolves to when running on this Java release, e.g. 11")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jmrcheck [-release 11] jar ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		jar, err := jclass.NewJarFromPath(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if *release != "" {
+			n, err := jclass.ParseJavaRelease(*release)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			view, err := jar.ForRelease(n)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			for _, name := range view.ClassNames() {
+				entry := view.Entry(name + ".class")
+				if entry == nil {
+					fmt.Printf("%s!/%s\n", path, name)
+					continue
+				}
+				fmt.Printf("%s!/%s\n", path, entry.Name)
+			}
+		}
+
+		issues, err := jar.MultiReleaseIssues()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, issue := range issues {
+			failed = true
+			fmt.Printf("%s!/%s\n", path, issue)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
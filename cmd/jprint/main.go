@@ -9,6 +9,10 @@ import (
 	"strings"
 )
 
+// 若参数是目录，用于判断类是否间接实现了 java.io.Serializable，目录中的类也直接取自其中
+var classPath *jclass.ClassPath
+var root string
+
 func main() {
 	defer func() {
 		if e := recover(); e != nil {
@@ -16,20 +20,69 @@ func main() {
 		}
 	}()
 
+	root = os.Args[1]
+	if info, err := os.Stat(root); err == nil && info.IsDir() {
+		classPath, err = jclass.NewClassPath(root)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
 	err := filepath.Walk(os.Args[1], walk)
 	if err != nil {
 		log.Fatalln(err)
 	}
 }
 
+// 类路径不完整时可能无法断定，返回 RESOLVE_UNKNOWN
+func isSerializable(classFile *jclass.ClassFile) jclass.Resolution {
+	if classPath != nil {
+		return classPath.IsAssignable(classFile.ThisClassString(), "java/io/Serializable")
+	}
+	for _, name := range classFile.InterfaceStrings() {
+		if name == "java/io/Serializable" || name == "java/io/Externalizable" {
+			return jclass.RESOLVE_FOUND
+		}
+	}
+	if _, ok := jclass.DeclaredSerialVersionUID(classFile); ok {
+		return jclass.RESOLVE_FOUND
+	}
+	// 单个类文件：父类或接口可能间接实现 Serializable
+	if super := classFile.SuperClassString(); super != "" && super != "java/lang/Object" || len(classFile.InterfaceStrings()) > 0 {
+		return jclass.RESOLVE_UNKNOWN
+	}
+	return jclass.RESOLVE_MISSING
+}
+
+// 目录中的类已由 classPath 解析，不再重复读取
+func classFileOf(path string) (*jclass.ClassFile, error) {
+	if classPath != nil {
+		if name, err := filepath.Rel(root, path); err == nil {
+			if entry := classPath.Jars[0].Entry(filepath.ToSlash(name)); entry != nil && entry.Class != nil {
+				return entry.Class, nil
+			}
+		}
+	}
+	return jclass.NewClassFileFromPath(path)
+}
+
 func walk(path string, info os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
 	if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), ".class") {
-		classFile, err := jclass.NewClassFileFromPath(path)
+		classFile, err := classFileOf(path)
 		if err != nil {
 			log.Fatalln(err)
 		}
 
 		fmt.Println("//", path)
+		switch isSerializable(classFile) {
+		case jclass.RESOLVE_FOUND:
+			fmt.Printf("// serialVersionUID: %dL\n", jclass.ComputeSerialVersionUID(classFile))
+		case jclass.RESOLVE_UNKNOWN:
+			fmt.Println("// serialVersionUID: unknown, some supertypes are not available")
+		}
 		fmt.Println(classFile)
 		fmt.Println()
 	}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	mappingPath = flag.String("mapping", "", "mapping file")
+	format      = flag.String("format", "", "mapping format: proguard, tiny, srg or tsrg (detected from content by default)")
+	from        = flag.String("from", "", "source namespace of tiny/tsrg2 mappings (first namespace by default)")
+	to          = flag.String("to", "", "target namespace of tiny/tsrg2 mappings (second namespace by default)")
+	reverse     = flag.Bool("reverse", false, "apply the mapping in reverse, e.g. deobfuscate with a ProGuard mapping")
+	lib         = flag.String("lib", "", "comma separated library jars or directories used to resolve the class hierarchy")
+	output      = flag.String("o", "", "output jar")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jremap -mapping mapping.txt [-reverse] -o out.jar jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *mappingPath == "" || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*mappingPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	mapping, err := jclass.ParseMapping(f, *format, *from, *to)
+	f.Close()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *reverse {
+		mapping = mapping.Reverse()
+	}
+
+	var jars, all []*jclass.Jar
+	for _, path := range flag.Args() {
+		jar, err := jclass.NewJarFromPath(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		jars = append(jars, jar)
+	}
+	all = append(all, jars...)
+	if *lib != "" {
+		for _, path := range strings.Split(*lib, ",") {
+			jar, err := jclass.NewJarFromPath(path)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			all = append(all, jar)
+		}
+	}
+
+	remapper := &jclass.Remapper{Mapping: mapping, ClassPath: jclass.NewClassPathFromJars(all...)}
+	out, err := remapper.RemapJars(*output, jars...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = out.WriteFile(""); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+)
+
+var (
+	target  = flag.String("target", "8", "target Java release, e.g. 8 or 1.8")
+	output  = flag.String("o", "", "output jar; only report problems when empty")
+	verbose = flag.Bool("v", false, "also list constructs that were rewritten automatically")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jretarget -target 8 [-o out.jar] [-v] jar|dir")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	release, err := jclass.ParseJavaRelease(*target)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	jar, err := jclass.NewJarFromPath(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	retargeter := jclass.NewRetargeter(jclass.ClassVersion(release), jclass.NewClassPathFromJars(jar))
+	out, issues, err := retargeter.RetargetJar(*output, jar)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	failed := 0
+	for _, issue := range issues {
+		if !issue.Fixed {
+			failed++
+		}
+		if !issue.Fixed || *verbose {
+			fmt.Println(issue)
+		}
+	}
+
+	if *output != "" {
+		if err = out.WriteFile(""); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d problems cannot be downgraded to Java %d automatically\n", failed, release)
+		os.Exit(1)
+	}
+}
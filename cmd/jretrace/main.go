@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	mappingPath = flag.String("mapping", "", "ProGuard/R8 mapping file")
+	jars        = flag.String("jar", "", "comma separated obfuscated jars or directories, used to disambiguate overloads by line numbers")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jretrace -mapping mapping.txt [-jar app.jar] [stacktrace.txt ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *mappingPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*mappingPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	mapping, err := jclass.ParseMapping(f, jclass.MAPPING_PROGUARD, "", "")
+	f.Close()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var classPath *jclass.ClassPath
+	if *jars != "" {
+		if classPath, err = jclass.NewClassPath(strings.Split(*jars, ",")...); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	retracer := jclass.NewRetracer(mapping, classPath)
+
+	if flag.NArg() == 0 {
+		if err = retracer.Retrace(os.Stdin, os.Stdout); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		err = retracer.Retrace(f, os.Stdout)
+		f.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
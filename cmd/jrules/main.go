@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: jrules check -rules file jar|dir ...")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "check" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	rulesPath := flags.String("rules", "", "architecture rules file")
+	flags.Parse(os.Args[2:])
+	if *rulesPath == "" || flags.NArg() == 0 {
+		usage()
+	}
+
+	file, err := os.Open(*rulesPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rules, err := jclass.ParseRules(file)
+	file.Close()
+	if err != nil {
+		log.Fatalln(*rulesPath+":", err)
+	}
+
+	classPath, err := jclass.NewClassPath(flags.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	violations := rules.Check(classPath)
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+
+	if len(violations) > 0 {
+		fmt.Printf("%d violations\n", len(violations))
+		os.Exit(1)
+	}
+}
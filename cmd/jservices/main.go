@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var ignore = flag.String("ignore", "java/,javax/,jdk/,sun/,com/sun/",
+	"comma separated class name prefixes assumed to be provided by the runtime")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jservices [flags] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	problems := classPath.CheckServices(strings.Split(*ignore, ","))
+
+	var jar *jclass.Jar
+	for _, problem := range problems {
+		if problem.Jar != jar {
+			jar = problem.Jar
+			fmt.Println(jar)
+		}
+		fmt.Printf("\t%s\n", problem)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%d service loader problems\n", len(problems))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+var (
+	relocations stringList
+	exclude     = flag.String("exclude", "", "comma separated class name patterns not to relocate, e.g. com.google.common.Internal*")
+	strs        = flag.Bool("strings", false, "also relocate string constants that look like class names or resource paths")
+	output      = flag.String("o", "", "output jar")
+)
+
+func main() {
+	flag.Var(&relocations, "relocate", "relocation rule from:to, e.g. com.google.common:shaded.guava (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jshade -relocate from:to [-relocate ...] -o out.jar jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || len(relocations) == 0 || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	relocator := &jclass.Relocator{Strings: *strs}
+	for _, s := range relocations {
+		rel, err := jclass.ParseRelocation(s)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if *exclude != "" {
+			rel.Excludes = strings.Split(*exclude, ",")
+		}
+		relocator.Relocations = append(relocator.Relocations, rel)
+	}
+
+	var jars []*jclass.Jar
+	for _, path := range flag.Args() {
+		jar, err := jclass.NewJarFromPath(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		jars = append(jars, jar)
+	}
+
+	out, err := relocator.RelocateJars(*output, jars...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = out.WriteFile(""); err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+)
+
+var (
+	config  = flag.String("config", "", "ProGuard-like keep rules file")
+	output  = flag.String("o", "", "output jar")
+	verbose = flag.Bool("v", false, "list removed classes and members")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jshrink -config keep.pro -o out.jar jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *config == "" || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(*config)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rules, err := jclass.ParseKeepConfig(file)
+	file.Close()
+	if err != nil {
+		log.Fatalln(*config+":", err)
+	}
+	for _, option := range rules.Ignored {
+		fmt.Fprintln(os.Stderr, "warning: ignored option", option)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	jar, removed, err := classPath.Shrink(rules, *output)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = jar.WriteFile(""); err != nil {
+		log.Fatalln(err)
+	}
+
+	if *verbose {
+		removed.WriteText(os.Stderr)
+	}
+	fmt.Fprintf(os.Stderr, "removed %d classes, %d methods, %d fields\n",
+		len(removed.Classes), len(removed.Methods), len(removed.Fields))
+}
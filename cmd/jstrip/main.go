@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	all            = flag.Bool("g", false, "strip all debug information (same as -lines -vars -source -debug-extension)")
+	lines          = flag.Bool("lines", false, "strip LineNumberTable")
+	vars           = flag.Bool("vars", false, "strip LocalVariableTable and LocalVariableTypeTable")
+	source         = flag.Bool("source", false, "strip SourceFile")
+	sourceBaseName = flag.Bool("source-basename", false, "keep only the file name in SourceFile, dropping build machine directories")
+	debugExtension = flag.Bool("debug-extension", false, "strip SourceDebugExtension")
+	attributes     = flag.String("attributes", "", "comma separated additional attributes to strip, e.g. MethodParameters")
+	timestamp      = flag.String("timestamp", jclass.REPRODUCIBLE_TIMESTAMP.Format(time.RFC3339), "timestamp of all jar entries (RFC 3339)")
+	output         = flag.String("o", "", "output jar")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jstrip [-g] [-lines] [-vars] [-source] -o out.jar jar|dir")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	modified, err := time.Parse(time.RFC3339, *timestamp)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	opts := &jclass.StripOptions{
+		LineNumbers:          *lines,
+		LocalVariables:       *vars,
+		SourceFile:           *source,
+		SourceFileBaseName:   *sourceBaseName,
+		SourceDebugExtension: *debugExtension,
+	}
+	if *all {
+		*opts = *jclass.STRIP_ALL
+	}
+	if *attributes != "" {
+		opts.Attributes = strings.Split(*attributes, ",")
+	}
+
+	jar, err := jclass.NewJarFromPath(flag.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	out, err := jar.Normalize(*output, opts, modified)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = out.WriteFile(""); err != nil {
+		log.Fatalln(err)
+	}
+}
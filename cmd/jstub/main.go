@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var output = flag.String("o", "", "output directory for the .java stubs")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jstub -o dir app.jar [lib.jar ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 只为第一个 jar 生成存根，其余 jar 用于查找父类与成员类
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	stubs := classPath.JavaStubs(classPath.Jars[0])
+	for name, source := range stubs {
+		path := filepath.Join(*output, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			log.Fatalln(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d stubs to %s\n", len(stubs), *output)
+}
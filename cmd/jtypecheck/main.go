@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jtypecheck app.jar [lib.jar ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// 只校验第一个 jar 中的类，其余 jar 用于判断类型之间的赋值关系
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// 先做格式检查，格式有误的类不再校验字节码
+	jar := classPath.Jars[0]
+	var invalid, failed int
+	for _, name := range jar.ClassNames() {
+		cf := jar.Class(name)
+		if problems := cf.Validate(); len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Printf("%s: %s\n", name, problem)
+			}
+			invalid++
+			continue
+		}
+		for _, err := range classPath.Verify(cf) {
+			fmt.Println(err)
+			failed++
+		}
+	}
+
+	if invalid > 0 || failed > 0 {
+		fmt.Printf("%d malformed classes, %d methods failed verification\n", invalid, failed)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	mains       = flag.Bool("main", true, "use public static main methods as entry points")
+	services    = flag.Bool("services", true, "use providers listed in META-INF/services as entry points")
+	annotations = flag.String("annotations", "", "comma separated annotations marking entry classes or members, e.g. RestController,org.junit.Test")
+	api         = flag.String("api", "", "comma separated public API package patterns, e.g. com.acme.api..")
+	entry       = flag.String("entry", "", "comma separated entry methods, e.g. com.acme.Main.run")
+	format      = flag.String("format", "text", "output format: text or json")
+)
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: junused [flags] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	classPath, err := jclass.NewClassPath(flag.Args()...)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	reachable, err := classPath.Reachable(&jclass.EntryPoints{
+		Mains:       *mains,
+		Services:    *services,
+		Annotations: split(*annotations),
+		APIPackages: split(*api),
+		Methods:     split(*entry),
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	report := classPath.Unused(reachable)
+	switch *format {
+	case "text":
+		err = report.WriteText(os.Stdout)
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	default:
+		log.Fatalln("invalid format:", *format)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+	"strings"
+)
+
+var (
+	trustStore = flag.String("truststore", "", "PEM file of trusted root certificates; signer chains are not checked when empty")
+	verbose    = flag.Bool("v", false, "list every class with its signers")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jverify [-truststore roots.pem] [-v] jar ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var roots *x509.CertPool
+	if *trustStore != "" {
+		var err error
+		if roots, err = jclass.LoadTrustStore(*trustStore); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		jar, err := jclass.NewJarFromPath(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		signers, issues, err := jar.VerifySignatures(roots)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		fmt.Println(path)
+		if len(signers) == 0 {
+			failed = true
+			fmt.Println("  not signed")
+		}
+		for _, signer := range signers {
+			cert := signer.Certificate()
+			fmt.Printf("  signer %s\n", signer.Name)
+			fmt.Printf("    subject: %s\n", cert.Subject)
+			fmt.Printf("    issuer:  %s\n", cert.Issuer)
+			fmt.Printf("    valid:   %s - %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+			if !signer.SigningTime.IsZero() {
+				fmt.Printf("    signed:  %s\n", signer.SigningTime.Format("2006-01-02 15:04:05 MST"))
+			}
+			if !signer.Timestamp.IsZero() {
+				fmt.Printf("    timestamp: %s\n", signer.Timestamp.Format("2006-01-02 15:04:05 MST"))
+			}
+			switch {
+			case roots == nil:
+			case signer.Trusted:
+				fmt.Println("    trusted")
+			default:
+				failed = true
+				fmt.Printf("    ERROR untrusted: %v\n", signer.TrustError)
+			}
+		}
+		for _, issue := range issues {
+			failed = true
+			fmt.Printf("  ERROR %s\n", issue)
+		}
+		if *verbose {
+			for _, name := range jar.ClassNames() {
+				var names []string
+				for _, signer := range jar.ClassSigners(name) {
+					names = append(names, signer.Name)
+				}
+				if len(names) == 0 {
+					names = []string{"unsigned"}
+				}
+				fmt.Printf("  %s: %s\n", name, strings.Join(names, ", "))
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/wdsgyj/jclass"
+	"log"
+	"os"
+)
+
+var (
+	max     = flag.String("max", "", "highest allowed Java release for the base layer, e.g. 8; META-INF/versions/N layers are checked against N")
+	format  = flag.String("format", "text", "output format: text or json")
+	verbose = flag.Bool("v", false, "list every class with its version")
+)
+
+type jsonVersion struct {
+	Major   uint16 `json:"major"`
+	Minor   uint16 `json:"minor"`
+	Release string `json:"release"`
+	Preview bool   `json:"preview,omitempty"`
+	Count   int    `json:"count,omitempty"`
+	Entry   string `json:"entry,omitempty"`
+}
+
+type jsonLayer struct {
+	Release    int            `json:"release"`
+	Limit      string         `json:"limit,omitempty"`
+	Versions   []*jsonVersion `json:"versions"`
+	Violations []*jsonVersion `json:"violations,omitempty"`
+}
+
+type jsonJar struct {
+	Path   string       `json:"path"`
+	Layers []*jsonLayer `json:"layers"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jversion [-max 8] [-format text|json] [-v] jar|dir ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	limit := uint16(0xFFFF)
+	if *max != "" {
+		release, err := jclass.ParseJavaRelease(*max)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		limit = jclass.ClassVersion(release)
+	}
+
+	var reports []*jsonJar
+	failed := 0
+	for _, path := range flag.Args() {
+		jar, err := jclass.NewJarFromPath(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		layers, err := jar.VersionLayers()
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		report := &jsonJar{Path: path}
+		if *format == "text" {
+			fmt.Println(path)
+		}
+		for _, layer := range layers {
+			violations := layer.Violations(limit)
+			failed += len(violations)
+
+			if *format != "text" {
+				l := &jsonLayer{Release: layer.Release}
+				if layer.Limit(limit) != 0xFFFF {
+					l.Limit = jclass.JavaReleaseName(layer.Limit(limit))
+				}
+				for _, v := range layer.Histogram() {
+					l.Versions = append(l.Versions, &jsonVersion{
+						Major:   v.Major,
+						Minor:   v.Minor,
+						Release: jclass.JavaReleaseName(v.Major),
+						Preview: v.Minor == jclass.PREVIEW_MINOR_VERSION,
+						Count:   v.Count,
+					})
+				}
+				for _, c := range violations {
+					l.Violations = append(l.Violations, &jsonVersion{
+						Major:   c.Major,
+						Minor:   c.Minor,
+						Release: jclass.JavaReleaseName(c.Major),
+						Preview: c.Preview(),
+						Entry:   c.Entry,
+					})
+				}
+				report.Layers = append(report.Layers, l)
+				continue
+			}
+
+			fmt.Printf("  %s\n", layer)
+			for _, v := range layer.Histogram() {
+				fmt.Printf("    %-28s %d\n", v, v.Count)
+			}
+			if *verbose {
+				for _, c := range layer.Classes {
+					fmt.Printf("      %d.%d %s\n", c.Major, c.Minor, c.Entry)
+				}
+			}
+			for _, c := range violations {
+				if c.Preview() {
+					fmt.Printf("  ERROR %s: compiled with %s preview features\n", c.Entry, jclass.JavaReleaseName(c.Major))
+				} else {
+					fmt.Printf("  ERROR %s: %s exceeds %s\n", c.Entry, jclass.JavaReleaseName(c.Major), jclass.JavaReleaseName(layer.Limit(limit)))
+				}
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,119 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+type ExceptionTableEntry struct {
+	StartPc   uint16
+	EndPc     uint16
+	HandlerPc uint16
+	// 0 表示 finally（捕获任意异常）
+	CatchType uint16
+}
+
+type CodeAttribute struct {
+	MaxStack   uint16
+	MaxLocals  uint16
+	CodeLength uint32
+	Code       []byte
+
+	ExceptionTableLength uint16
+	ExceptionTable       []*ExceptionTableEntry
+
+	AttributesCount uint16
+	Attributes      []*AttributeInfo
+
+	cp []*ConstantPoolInfo
+}
+
+func (c *CodeAttribute) ConstantPoolInfo(i uint16) *ConstantPoolInfo {
+	return c.cp[int(i)]
+}
+
+// 按名称查找属性，如 LineNumberTable，不存在时返回 nil
+func (c *CodeAttribute) Attribute(name string) *AttributeInfo {
+	return findAttribute(c.Attributes, name)
+}
+
+type LineNumberEntry struct {
+	StartPc    uint16
+	LineNumber uint16
+}
+
+// 所有 LineNumberTable 属性中的行号项，按出现顺序；没有行号信息时返回 nil
+func (c *CodeAttribute) LineNumberTable() []*LineNumberEntry {
+	var rs []*LineNumberEntry
+	for _, attr := range c.Attributes {
+		if attr.NameString() != "LineNumberTable" || len(attr.Info) < 2 {
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(attr.Info))
+		for i := 0; i < n && 2+4*i+4 <= len(attr.Info); i++ {
+			rs = append(rs, &LineNumberEntry{
+				StartPc:    binary.BigEndian.Uint16(attr.Info[2+4*i:]),
+				LineNumber: binary.BigEndian.Uint16(attr.Info[2+4*i+2:]),
+			})
+		}
+	}
+	return rs
+}
+
+func NewCodeAttribute(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*CodeAttribute, []byte, error) {
+	rs := CodeAttribute{
+		cp: cp,
+	}
+	byteOrder := binary.BigEndian
+
+	_, err := io.ReadFull(r, buf[:8])
+	if err != nil {
+		return nil, buf, err
+	}
+	rs.MaxStack = byteOrder.Uint16(buf)
+	rs.MaxLocals = byteOrder.Uint16(buf[2:])
+	rs.CodeLength = byteOrder.Uint32(buf[4:])
+
+	rs.Code, err = readBytes(r, int(rs.CodeLength))
+	if err != nil {
+		return nil, buf, err
+	}
+
+	_, err = io.ReadFull(r, buf[:2])
+	if err != nil {
+		return nil, buf, err
+	}
+	rs.ExceptionTableLength = byteOrder.Uint16(buf)
+
+	rs.ExceptionTable = make([]*ExceptionTableEntry, rs.ExceptionTableLength)
+	for i := 0; i < int(rs.ExceptionTableLength); i++ {
+		_, err = io.ReadFull(r, buf[:8])
+		if err != nil {
+			return nil, buf, err
+		}
+		rs.ExceptionTable[i] = &ExceptionTableEntry{
+			StartPc:   byteOrder.Uint16(buf),
+			EndPc:     byteOrder.Uint16(buf[2:]),
+			HandlerPc: byteOrder.Uint16(buf[4:]),
+			CatchType: byteOrder.Uint16(buf[6:]),
+		}
+	}
+
+	_, err = io.ReadFull(r, buf[:2])
+	if err != nil {
+		return nil, buf, err
+	}
+	rs.AttributesCount = byteOrder.Uint16(buf)
+
+	rs.Attributes = make([]*AttributeInfo, rs.AttributesCount)
+	var attr *AttributeInfo
+	for i := 0; i < int(rs.AttributesCount); i++ {
+		attr, buf, err = NewAttributeInfo(r, buf, cp)
+		if err != nil {
+			return nil, buf, err
+		}
+		rs.Attributes[i] = attr
+	}
+
+	return &rs, buf, nil
+}
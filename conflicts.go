@@ -0,0 +1,112 @@
+package jclass
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// 在 classpath 上多个 jar 中出现的同名类，Jars[0] 中的类会被加载
+type DuplicateClass struct {
+	Name string
+	Jars []*Jar
+	// 所有副本逐字节相同
+	Identical bool
+	// 与 Jars[0] 中副本的差异，按 jar 顺序，首个 jar 对应的项为空
+	Differences [][]string
+}
+
+// 多个 jar 中都有类的包，模块系统不允许这种拆分包
+type SplitPackage struct {
+	Package string
+	Jars    []*Jar
+}
+
+// 类文件在 jar 中的原始字节；条目路径与类名不一致时重新序列化
+func classBytes(jar *Jar, name string) []byte {
+	if entry := jar.Entry(name + ".class"); entry != nil {
+		return entry.Data
+	}
+	return jar.Class(name).Bytes()
+}
+
+// 两个同名类的差异：版本号与公开 API；都相同而字节不同时说明只有实现不同
+func classDifferences(old, new *ClassFile) []string {
+	var rs []string
+	if old.MajorVersion != new.MajorVersion || old.MinorVersion != new.MinorVersion {
+		rs = append(rs, fmt.Sprintf("class file version %d.%d (%s) -> %d.%d (%s)",
+			old.MajorVersion, old.MinorVersion, JavaReleaseName(old.MajorVersion),
+			new.MajorVersion, new.MinorVersion, JavaReleaseName(new.MajorVersion)))
+	}
+	rs = append(rs, publicAPIDiff(old, new)...)
+	if len(rs) == 0 {
+		rs = append(rs, "same public API, implementation differs")
+	}
+	return rs
+}
+
+// 在多个 jar 中出现的类，按类名排序。module-info 不计入
+func (p *ClassPath) DuplicateClasses() []*DuplicateClass {
+	jars := make(map[string][]*Jar)
+	for _, jar := range p.Jars {
+		for _, name := range jar.ClassNames() {
+			if name != "module-info" {
+				jars[name] = append(jars[name], jar)
+			}
+		}
+	}
+
+	var rs []*DuplicateClass
+	for name, owners := range jars {
+		if len(owners) < 2 {
+			continue
+		}
+		d := &DuplicateClass{
+			Name:        name,
+			Jars:        owners,
+			Identical:   true,
+			Differences: make([][]string, len(owners)),
+		}
+		first := owners[0].Class(name)
+		data := classBytes(owners[0], name)
+		for i, jar := range owners[1:] {
+			if bytes.Equal(data, classBytes(jar, name)) {
+				continue
+			}
+			d.Identical = false
+			d.Differences[i+1] = classDifferences(first, jar.Class(name))
+		}
+		rs = append(rs, d)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Name < rs[j].Name
+	})
+	return rs
+}
+
+// 被多个 jar 拆分的包，按包名排序。默认包与 module-info 不计入
+func (p *ClassPath) SplitPackages() []*SplitPackage {
+	jars := make(map[string][]*Jar)
+	for _, jar := range p.Jars {
+		seen := make(map[string]bool)
+		for _, name := range jar.ClassNames() {
+			pkg := PackageOf(name)
+			if pkg == "" || seen[pkg] {
+				continue
+			}
+			seen[pkg] = true
+			jars[pkg] = append(jars[pkg], jar)
+		}
+	}
+
+	var rs []*SplitPackage
+	for pkg, owners := range jars {
+		if len(owners) > 1 {
+			rs = append(rs, &SplitPackage{Package: pkg, Jars: owners})
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Package < rs[j].Package
+	})
+	return rs
+}
@@ -53,9 +53,18 @@ func (i *ConstantPoolInfo) String() string {
 	case 16:
 		return fmt.Sprintf("%s", (*ConstantMethodTypeInfo)(i))
 
+	case 17:
+		return fmt.Sprintf("%s", (*ConstantDynamicInfo)(i))
+
 	case 18:
 		return fmt.Sprintf("%s", (*ConstantInvokeDynamicInfo)(i))
 
+	case 19:
+		return fmt.Sprintf("%s", (*ConstantModuleInfo)(i))
+
+	case 20:
+		return fmt.Sprintf("%s", (*ConstantPackageInfo)(i))
+
 	default:
 		panic(fmt.Errorf("invalid tag: %d", i.Tag))
 	}
@@ -71,13 +80,13 @@ func NewConstantPoolInfo(r io.Reader, buf []byte) (*ConstantPoolInfo, []byte, er
 
 	rs.Tag = buf[0]
 	switch rs.Tag {
-	case 7, 8, 16:
+	case 7, 8, 16, 19, 20:
 		rs.Info, err = readEnoughBytes(r, buf, 2)
 		if err != nil {
 			return nil, buf, err
 		}
 
-	case 3, 4, 9, 10, 11, 12, 18:
+	case 3, 4, 9, 10, 11, 12, 17, 18:
 		rs.Info, err = readEnoughBytes(r, buf, 4)
 		if err != nil {
 			return nil, buf, err
@@ -96,8 +105,8 @@ func NewConstantPoolInfo(r io.Reader, buf []byte) (*ConstantPoolInfo, []byte, er
 		}
 
 		length := binary.BigEndian.Uint16(buf)
-		bufSize := 2 + length
-		if len(buf) < int(bufSize) {
+		bufSize := 2 + int(length)
+		if len(buf) < bufSize {
 			newBuf := make([]byte, bufSize)
 			copy(newBuf[:2], buf)
 			buf = newBuf
@@ -118,7 +127,7 @@ func NewConstantPoolInfo(r io.Reader, buf []byte) (*ConstantPoolInfo, []byte, er
 		}
 
 	default:
-		panic(fmt.Errorf("invalid constant pool tag: %d", rs.Tag))
+		return nil, buf, fmt.Errorf("invalid constant pool tag: %d", rs.Tag)
 	}
 
 	return &rs, buf, nil
@@ -345,3 +354,50 @@ func (i *ConstantInvokeDynamicInfo) String() string {
 	return fmt.Sprintf("ConstantInvokeDynamicInfo [BootstrapMethodAttrIndex: %d, NameAndTypeIndex: %d]",
 		i.BootstrapMethodAttrIndex(), i.NameAndTypeIndex())
 }
+
+// CONSTANT_Dynamic 17
+type ConstantDynamicInfo ConstantPoolInfo
+
+func (i *ConstantDynamicInfo) BootstrapMethodAttrIndex() uint16 {
+	return binary.BigEndian.Uint16(i.Info)
+}
+
+func (i *ConstantDynamicInfo) NameAndTypeIndex() uint16 {
+	return binary.BigEndian.Uint16(i.Info[2:])
+}
+
+func (i *ConstantDynamicInfo) String() string {
+	return fmt.Sprintf("ConstantDynamicInfo [BootstrapMethodAttrIndex: %d, NameAndTypeIndex: %d]",
+		i.BootstrapMethodAttrIndex(), i.NameAndTypeIndex())
+}
+
+// CONSTANT_Module 19
+type ConstantModuleInfo ConstantPoolInfo
+
+func (i *ConstantModuleInfo) NameIndex() uint16 {
+	return binary.BigEndian.Uint16(i.Info)
+}
+
+func (i *ConstantModuleInfo) String() string {
+	return fmt.Sprintf("ConstantModuleInfo [NameIndex: %d]", i.NameIndex())
+}
+
+// CONSTANT_Package 20
+type ConstantPackageInfo ConstantPoolInfo
+
+func (i *ConstantPackageInfo) NameIndex() uint16 {
+	return binary.BigEndian.Uint16(i.Info)
+}
+
+func (i *ConstantPackageInfo) String() string {
+	return fmt.Sprintf("ConstantPackageInfo [NameIndex: %d]", i.NameIndex())
+}
+
+// 构造 CONSTANT_Utf8 常量池项
+func NewConstantUtf8(s string) *ConstantPoolInfo {
+	data := encodeModifiedUTF8(s)
+	info := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(info, uint16(len(data)))
+	copy(info[2:], data)
+	return &ConstantPoolInfo{Tag: 1, Info: info}
+}
@@ -0,0 +1,606 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ERR_MALFORMED_ATTRIBUTE = errors.New("malformed attribute")
+	ERR_LDC_INDEX           = errors.New("ldc operand exceeds 255")
+)
+
+// 常量池项内部指向其他常量池项的索引在 Info 中的偏移
+func constantPoolInfoRefs(info *ConstantPoolInfo) []int {
+	switch info.Tag {
+	case 7, 8, 16, 19, 20:
+		return []int{0}
+	case 9, 10, 11, 12:
+		return []int{0, 2}
+	case 15:
+		return []int{1}
+	case 17, 18:
+		// 前两个字节是 BootstrapMethods 下标，不是常量池索引
+		return []int{2}
+	}
+	return nil
+}
+
+// 常量池索引出现的位置，用于需要区分 Utf8 用途的改写（如重命名成员）
+type refKind int
+
+const (
+	refOther refKind = iota
+	// 描述符或签名
+	refDescriptor
+	// 字段名、方法名，owner 为其描述符的索引
+	refFieldName
+	refMethodName
+	// 注解元素名，owner 为注解类型描述符的索引
+	refElementName
+	// 枚举常量名，owner 为枚举类型描述符的索引
+	refEnumConst
+	// InnerClasses 中的简单名，owner 为内部类 Class 项的索引
+	refInnerName
+	// Record 组件名，owner 为组件描述符的索引
+	refRecordComponent
+	// EnclosingMethod 的 NameAndType，owner 为外围类 Class 项的索引
+	refEnclosingMethod
+	// ldc 的单字节操作数，新索引不能超过 255
+	refLdc
+)
+
+// 访问者返回新的索引；owner 为原始索引，含义见 refKind
+type refVisitor func(index uint16, kind refKind, owner uint16) uint16
+
+// 在属性的原始字节上移动的游标，越界时 panic，由 visitConstantPoolRefs 统一转换为错误
+type refCursor struct {
+	cf    *ClassFile
+	b     []byte
+	pos   int
+	visit refVisitor
+}
+
+type refCursorError struct {
+	err error
+}
+
+func (c *refCursor) fail(err error) {
+	panic(refCursorError{err})
+}
+
+func (c *refCursor) need(n int) {
+	if c.pos+n > len(c.b) {
+		c.fail(ERR_MALFORMED_ATTRIBUTE)
+	}
+}
+
+func (c *refCursor) skip(n int) {
+	c.need(n)
+	c.pos += n
+}
+
+func (c *refCursor) u1() int {
+	c.need(1)
+	c.pos++
+	return int(c.b[c.pos-1])
+}
+
+func (c *refCursor) u2() int {
+	c.need(2)
+	c.pos += 2
+	return int(binary.BigEndian.Uint16(c.b[c.pos-2:]))
+}
+
+func (c *refCursor) u4() int {
+	c.need(4)
+	c.pos += 4
+	return int(binary.BigEndian.Uint32(c.b[c.pos-4:]))
+}
+
+// 当前位置之后第 off 字节处的 u2，不移动游标
+func (c *refCursor) peek(off int) uint16 {
+	c.need(off + 2)
+	return binary.BigEndian.Uint16(c.b[c.pos+off:])
+}
+
+// 访问当前位置的常量池索引，0 表示无引用，不访问
+func (c *refCursor) refAs(kind refKind, owner uint16) {
+	c.need(2)
+	if index := binary.BigEndian.Uint16(c.b[c.pos:]); index != 0 {
+		binary.BigEndian.PutUint16(c.b[c.pos:], c.visit(index, kind, owner))
+	}
+	c.pos += 2
+}
+
+func (c *refCursor) ref() {
+	c.refAs(refOther, 0)
+}
+
+func (c *refCursor) refs(n int) {
+	for i := 0; i < n; i++ {
+		c.ref()
+	}
+}
+
+func (c *refCursor) annotation() {
+	typ := c.peek(0)
+	c.refAs(refDescriptor, 0)
+	for n := c.u2(); n > 0; n-- {
+		c.refAs(refElementName, typ)
+		c.elementValue()
+	}
+}
+
+func (c *refCursor) elementValue() {
+	switch tag := c.u1(); tag {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 's':
+		c.ref()
+	case 'c':
+		c.refAs(refDescriptor, 0)
+	case 'e':
+		typ := c.peek(0)
+		c.refAs(refDescriptor, 0)
+		c.refAs(refEnumConst, typ)
+	case '@':
+		c.annotation()
+	case '[':
+		for n := c.u2(); n > 0; n-- {
+			c.elementValue()
+		}
+	default:
+		c.fail(fmt.Errorf("%s: invalid element value tag %c", ERR_MALFORMED_ATTRIBUTE, tag))
+	}
+}
+
+// JVMS 4.7.20 type_annotation
+func (c *refCursor) typeAnnotation() {
+	switch target := c.u1(); {
+	case target == 0x00, target == 0x01, target == 0x16:
+		c.skip(1)
+	case target >= 0x10 && target <= 0x12, target == 0x17, target >= 0x42 && target <= 0x46:
+		c.skip(2)
+	case target >= 0x13 && target <= 0x15:
+	case target == 0x40, target == 0x41:
+		c.skip(6 * c.u2())
+	case target >= 0x47 && target <= 0x4B:
+		c.skip(3)
+	default:
+		c.fail(fmt.Errorf("%s: invalid type annotation target 0x%02x", ERR_MALFORMED_ATTRIBUTE, target))
+	}
+	c.skip(2 * c.u1())
+	c.annotation()
+}
+
+func (c *refCursor) verificationTypes(n int) {
+	for ; n > 0; n-- {
+		switch c.u1() {
+		case 7:
+			c.ref()
+		case 8:
+			c.skip(2)
+		}
+	}
+}
+
+func (c *refCursor) stackMapTable() {
+	for n := c.u2(); n > 0; n-- {
+		switch frame := c.u1(); {
+		case frame < 64:
+		case frame < 128:
+			c.verificationTypes(1)
+		case frame == 247:
+			c.skip(2)
+			c.verificationTypes(1)
+		case frame >= 248 && frame <= 251:
+			c.skip(2)
+		case frame >= 252 && frame <= 254:
+			c.skip(2)
+			c.verificationTypes(frame - 251)
+		case frame == 255:
+			c.skip(2)
+			c.verificationTypes(c.u2())
+			c.verificationTypes(c.u2())
+		default:
+			c.fail(fmt.Errorf("%s: invalid stack map frame type %d", ERR_MALFORMED_ATTRIBUTE, frame))
+		}
+	}
+}
+
+func (c *refCursor) code() {
+	c.skip(4)
+	length := c.u4()
+	c.need(length)
+	code := c.b[c.pos : c.pos+length]
+	instructions, err := DecodeInstructions(code)
+	if err != nil {
+		c.fail(err)
+	}
+	for _, ins := range instructions {
+		if !ins.usesConstantPool() {
+			continue
+		}
+		if ins.Opcode == OP_LDC {
+			index := c.visit(uint16(code[ins.Offset+1]), refLdc, 0)
+			if index > 0xFF {
+				c.fail(fmt.Errorf("%d: %s", ins.Offset, ERR_LDC_INDEX))
+			}
+			code[ins.Offset+1] = byte(index)
+			continue
+		}
+		index := binary.BigEndian.Uint16(code[ins.Offset+1:])
+		binary.BigEndian.PutUint16(code[ins.Offset+1:], c.visit(index, refOther, 0))
+	}
+	c.pos += length
+
+	for n := c.u2(); n > 0; n-- {
+		c.skip(6)
+		c.ref()
+	}
+	c.attributes()
+}
+
+// 嵌套在 Code、Record 中的属性表
+func (c *refCursor) attributes() {
+	for n := c.u2(); n > 0; n-- {
+		c.need(2)
+		name := c.cf.Utf8At(binary.BigEndian.Uint16(c.b[c.pos:]))
+		c.ref()
+		length := c.u4()
+		c.need(length)
+		inner := &refCursor{cf: c.cf, b: c.b[c.pos : c.pos+length], visit: c.visit}
+		inner.attribute(name)
+		c.pos += length
+	}
+}
+
+func (c *refCursor) attribute(name string) {
+	switch name {
+	case "ConstantValue", "SourceFile", "NestHost", "ModuleMainClass", "ModuleTarget":
+		c.ref()
+
+	case "Signature":
+		c.refAs(refDescriptor, 0)
+
+	case "Exceptions", "NestMembers", "PermittedSubclasses", "ModulePackages":
+		c.refs(c.u2())
+
+	case "InnerClasses":
+		for n := c.u2(); n > 0; n-- {
+			inner := c.peek(0)
+			c.refs(2)
+			c.refAs(refInnerName, inner)
+			c.skip(2)
+		}
+
+	case "EnclosingMethod":
+		class := c.peek(0)
+		c.ref()
+		c.refAs(refEnclosingMethod, class)
+
+	case "Synthetic", "Deprecated":
+
+	case "SourceDebugExtension":
+		c.pos = len(c.b)
+
+	case "LineNumberTable":
+		c.skip(4 * c.u2())
+
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		for n := c.u2(); n > 0; n-- {
+			c.skip(4)
+			c.ref()
+			c.refAs(refDescriptor, 0)
+			c.skip(2)
+		}
+
+	case "MethodParameters":
+		for n := c.u1(); n > 0; n-- {
+			c.ref()
+			c.skip(2)
+		}
+
+	case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
+		for n := c.u2(); n > 0; n-- {
+			c.annotation()
+		}
+
+	case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+		for np := c.u1(); np > 0; np-- {
+			for n := c.u2(); n > 0; n-- {
+				c.annotation()
+			}
+		}
+
+	case "RuntimeVisibleTypeAnnotations", "RuntimeInvisibleTypeAnnotations":
+		for n := c.u2(); n > 0; n-- {
+			c.typeAnnotation()
+		}
+
+	case "AnnotationDefault":
+		c.elementValue()
+
+	case "BootstrapMethods":
+		for n := c.u2(); n > 0; n-- {
+			c.ref()
+			c.refs(c.u2())
+		}
+
+	case "Module":
+		c.ref()
+		c.skip(2)
+		c.ref()
+		for n := c.u2(); n > 0; n-- {
+			c.ref()
+			c.skip(2)
+			c.ref()
+		}
+		// exports 与 opens 的结构相同
+		for i := 0; i < 2; i++ {
+			for n := c.u2(); n > 0; n-- {
+				c.ref()
+				c.skip(2)
+				c.refs(c.u2())
+			}
+		}
+		c.refs(c.u2())
+		for n := c.u2(); n > 0; n-- {
+			c.ref()
+			c.refs(c.u2())
+		}
+
+	case "Record":
+		for n := c.u2(); n > 0; n-- {
+			desc := c.peek(2)
+			c.refAs(refRecordComponent, desc)
+			c.refAs(refDescriptor, 0)
+			c.attributes()
+		}
+
+	case "StackMapTable":
+		c.stackMapTable()
+
+	case "Code":
+		c.code()
+
+	// JDK 自身使用的非标准属性，其中的常量池索引同样需要访问
+	case "ModuleHashes":
+		c.ref()
+		for n := c.u2(); n > 0; n-- {
+			c.ref()
+			c.skip(c.u2())
+		}
+
+	case "ModuleResolution":
+		c.skip(2)
+
+	case "CharacterRangeTable":
+		c.skip(14 * c.u2())
+
+	default:
+		// 无法识别的属性按 JVMS 4.7.1 视为不透明的字节，原样保留，只有属性名被访问
+		c.pos = len(c.b)
+	}
+}
+
+func (cf *ClassFile) visitAttributes(attrs []*AttributeInfo, visit refVisitor) {
+	for _, attr := range attrs {
+		name := attr.NameString()
+		attr.NameIndex = visit(attr.NameIndex, refOther, 0)
+		c := &refCursor{cf: cf, b: attr.Info, visit: visit}
+		c.attribute(name)
+	}
+}
+
+// 访问类结构（不含常量池自身）中所有指向常量池的索引，visit 返回的索引会写回原位置。
+// 属性只修改原始的 Info 字节，无法识别的属性原样保留；已知属性格式错误时返回 ERR_MALFORMED_ATTRIBUTE，此时部分索引可能已被改写
+func (cf *ClassFile) visitConstantPoolRefs(visit refVisitor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(refCursorError)
+			if !ok {
+				panic(r)
+			}
+			err = e.err
+		}
+	}()
+
+	cf.ThisClass = visit(cf.ThisClass, refOther, 0)
+	if cf.SuperClass != 0 {
+		cf.SuperClass = visit(cf.SuperClass, refOther, 0)
+	}
+	for i, iface := range cf.Interfaces {
+		cf.Interfaces[i] = visit(iface, refOther, 0)
+	}
+	for _, field := range cf.Fields {
+		desc := field.DescriptorIndex
+		field.NameIndex = visit(field.NameIndex, refFieldName, desc)
+		field.DescriptorIndex = visit(desc, refDescriptor, 0)
+		cf.visitAttributes(field.Attributes, visit)
+	}
+	for _, method := range cf.Methods {
+		desc := method.DescriptorIndex
+		method.NameIndex = visit(method.NameIndex, refMethodName, desc)
+		method.DescriptorIndex = visit(desc, refDescriptor, 0)
+		cf.visitAttributes(method.Attributes, visit)
+	}
+	cf.visitAttributes(cf.Attributes, visit)
+	return nil
+}
+
+// 返回去掉未被引用的常量池项后的新类，保持剩余项的相对顺序（因此 ldc 的单字节索引不会溢出）。
+// 类中含有格式错误的属性时返回 ERR_MALFORMED_ATTRIBUTE
+func (cf *ClassFile) CompactConstantPool() (*ClassFile, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	used := make([]bool, len(c.ConstantPool))
+	var mark func(index uint16, kind refKind, owner uint16) uint16
+	mark = func(index uint16, kind refKind, owner uint16) uint16 {
+		if int(index) >= len(used) || c.ConstantPool[index] == nil {
+			panic(refCursorError{fmt.Errorf("invalid constant pool index: %d", index)})
+		}
+		if !used[index] {
+			used[index] = true
+			info := c.ConstantPool[index]
+			for _, off := range constantPoolInfoRefs(info) {
+				mark(binary.BigEndian.Uint16(info.Info[off:]), refOther, 0)
+			}
+		}
+		return index
+	}
+	if err := c.visitConstantPoolRefs(mark); err != nil {
+		return nil, err
+	}
+
+	mapping := make([]uint16, len(c.ConstantPool))
+	pool := []*ConstantPoolInfo{nil}
+	for i := 1; i < len(c.ConstantPool); i++ {
+		info := c.ConstantPool[i]
+		if info == nil || !used[i] {
+			continue
+		}
+		mapping[i] = uint16(len(pool))
+		pool = append(pool, info)
+		if info.Tag == 5 || info.Tag == 6 {
+			pool = append(pool, nil)
+		}
+	}
+
+	for _, info := range pool {
+		if info == nil {
+			continue
+		}
+		for _, off := range constantPoolInfoRefs(info) {
+			binary.BigEndian.PutUint16(info.Info[off:], mapping[binary.BigEndian.Uint16(info.Info[off:])])
+		}
+	}
+	remap := func(index uint16, kind refKind, owner uint16) uint16 {
+		return mapping[index]
+	}
+	if err := c.visitConstantPoolRefs(remap); err != nil {
+		return nil, err
+	}
+	c.ConstantPool = pool
+
+	return NewClassFile(bytes.NewReader(c.Bytes()))
+}
+
+// 返回常量池规范化后的新类：合并内容相同的项，去掉未被引用的项，并按引用在类结构中首次出现的顺序排列，
+// ldc 引用的项排在最前以保证单字节索引不溢出。内容相同的类因此得到字节相同的常量池，与编译器的分配顺序无关。
+// 类中含有格式错误的属性时返回 ERR_MALFORMED_ATTRIBUTE
+func (cf *ClassFile) CanonicalConstantPool() (*ClassFile, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, err
+	}
+	n := len(c.ConstantPool)
+	valid := func(index uint16) uint16 {
+		if int(index) >= n || c.ConstantPool[index] == nil {
+			panic(refCursorError{fmt.Errorf("invalid constant pool index: %d", index)})
+		}
+		return index
+	}
+
+	// 合并内容相同的项：引用的子项先归并到代表项，再按标签与内容比较
+	reps := make([]uint16, n)
+	keys := make(map[string]uint16)
+	var rep func(index uint16) uint16
+	rep = func(index uint16) uint16 {
+		if reps[valid(index)] != 0 {
+			return reps[index]
+		}
+		info := c.ConstantPool[index]
+		for _, off := range constantPoolInfoRefs(info) {
+			binary.BigEndian.PutUint16(info.Info[off:], rep(binary.BigEndian.Uint16(info.Info[off:])))
+		}
+		key := string(append([]byte{info.Tag}, info.Info...))
+		if r, ok := keys[key]; ok {
+			reps[index] = r
+		} else {
+			keys[key] = index
+			reps[index] = index
+		}
+		return reps[index]
+	}
+
+	// 先序遍历确定新顺序
+	var order []uint16
+	placed := make([]bool, n)
+	var place func(index uint16)
+	place = func(index uint16) {
+		if placed[index] {
+			return
+		}
+		placed[index] = true
+		order = append(order, index)
+		info := c.ConstantPool[index]
+		for _, off := range constantPoolInfoRefs(info) {
+			place(binary.BigEndian.Uint16(info.Info[off:]))
+		}
+	}
+
+	var ldcs []uint16
+	err = c.visitConstantPoolRefs(func(index uint16, kind refKind, owner uint16) uint16 {
+		index = rep(index)
+		if kind == refLdc {
+			ldcs = append(ldcs, index)
+		}
+		return index
+	})
+	if err != nil {
+		return nil, err
+	}
+	// ldc 引用的项本身先占据低位索引，其子项随后再排
+	for _, index := range ldcs {
+		if !placed[index] {
+			placed[index] = true
+			order = append(order, index)
+		}
+	}
+	for _, index := range ldcs {
+		info := c.ConstantPool[index]
+		for _, off := range constantPoolInfoRefs(info) {
+			place(binary.BigEndian.Uint16(info.Info[off:]))
+		}
+	}
+	err = c.visitConstantPoolRefs(func(index uint16, kind refKind, owner uint16) uint16 {
+		place(index)
+		return index
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := make([]uint16, n)
+	pool := []*ConstantPoolInfo{nil}
+	for _, index := range order {
+		info := c.ConstantPool[index]
+		mapping[index] = uint16(len(pool))
+		pool = append(pool, info)
+		if info.Tag == 5 || info.Tag == 6 {
+			pool = append(pool, nil)
+		}
+	}
+	for _, info := range pool {
+		if info == nil {
+			continue
+		}
+		for _, off := range constantPoolInfoRefs(info) {
+			binary.BigEndian.PutUint16(info.Info[off:], mapping[binary.BigEndian.Uint16(info.Info[off:])])
+		}
+	}
+	err = c.visitConstantPoolRefs(func(index uint16, kind refKind, owner uint16) uint16 {
+		return mapping[index]
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.ConstantPool = pool
+
+	return NewClassFile(bytes.NewReader(c.Bytes()))
+}
@@ -0,0 +1,1320 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ERR_DECOMPILE_SUBROUTINE = errors.New("jsr/ret subroutines are not supported")
+)
+
+// 反编译一个类时共享的状态
+type classDecompiler struct {
+	p       *ClassPath
+	w       *stubWriter
+	results map[*MethodInfo]*dresult
+	// 枚举 switch 的合成映射表，键为类名与字段名，值为序号到常量名的映射
+	switchMaps map[string]map[int32]string
+}
+
+type dresult struct {
+	m     *methodDecompiler
+	stmts []*dstmt
+	err   error
+}
+
+func newClassDecompiler(p *ClassPath, cf *ClassFile) *classDecompiler {
+	c := &classDecompiler{
+		p:          p,
+		results:    make(map[*MethodInfo]*dresult),
+		switchMaps: make(map[string]map[int32]string),
+	}
+	c.w = &stubWriter{
+		p:        p,
+		b:        &bytes.Buffer{},
+		inners:   make(map[string]*InnerClass),
+		names:    make(map[string]string),
+		visiting: make(map[string]bool),
+		d:        c,
+		pkg:      PackageOf(cf.ThisClassString()),
+	}
+	c.w.addInnerClasses(cf)
+	return c
+}
+
+// 反编译方法体，结果按方法缓存；字节码无法处理时返回错误而不是 panic
+func (c *classDecompiler) decompile(cf *ClassFile, m *MethodInfo) (r *dresult) {
+	if r = c.results[m]; r != nil {
+		return r
+	}
+	r = &dresult{}
+	c.results[m] = r
+	defer func() {
+		if e := recover(); e != nil {
+			r.stmts, r.err = nil, fmt.Errorf("%v", e)
+		}
+	}()
+	r.m = newMethodDecompiler(c, cf, m, nil)
+	r.stmts, r.err = r.m.run()
+	return r
+}
+
+func (c *classDecompiler) methodBody(indent int, cf *ClassFile, m *MethodInfo) {
+	r := c.decompile(cf, m)
+	if r.err == nil {
+		r.m.writeStmts(c.w.b, indent, r.stmts)
+		return
+	}
+	// 失败时保留字节码，便于人工阅读
+	c.w.printf(indent, "// decompilation failed: %s\n", r.err)
+	if code := m.Code(); code != nil {
+		if instructions, err := code.Instructions(); err == nil {
+			for _, ins := range instructions {
+				c.w.printf(indent, "// %s\n", ins)
+			}
+		}
+	}
+	c.w.printf(indent, "throw new UnsupportedOperationException();\n")
+}
+
+func (c *classDecompiler) parameterNames(cf *ClassFile, m *MethodInfo, count int) []string {
+	r := c.decompile(cf, m)
+	if r.m == nil || len(r.m.params) < count {
+		return methodParameterNames(cf, m, count)
+	}
+	var rs []string
+	for _, v := range r.m.params[len(r.m.params)-count:] {
+		rs = append(rs, v.name)
+	}
+	return rs
+}
+
+func (c *classDecompiler) staticInitializer(indent int, cf *ClassFile, m *MethodInfo) {
+	if r := c.decompile(cf, m); r.err == nil && len(r.stmts) == 0 {
+		return
+	}
+	c.w.b.WriteString("\n")
+	c.w.printf(indent, "static {\n")
+	c.methodBody(indent+1, cf, m)
+	c.w.printf(indent, "}\n")
+}
+
+// 从 <clinit> 中找出枚举常量的构造参数，并从静态初始化块中去掉创建常量与 $VALUES 的语句
+func (c *classDecompiler) enumConstants(cf *ClassFile, names []string) []string {
+	m := cf.FindMethod("<clinit>", "()V")
+	if m == nil {
+		return names
+	}
+	r := c.decompile(cf, m)
+	if r.err != nil {
+		return names
+	}
+	constants := make(map[string]string)
+	for _, name := range names {
+		constants[name] = name
+	}
+	var stmts []*dstmt
+	for _, s := range r.stmts {
+		if s.kind == sExpr && s.e.kind == dAssign {
+			target, value := s.e.args[0], s.e.args[1]
+			if target.kind == dField && target.owner == "" && target.recv == nil {
+				if _, ok := constants[target.text]; ok && value.kind == dNew && len(value.args) >= 2 {
+					if len(value.args) > 2 {
+						hints := value.hints
+						if len(hints) > 2 {
+							hints = hints[2:]
+						}
+						constants[target.text] += "(" + r.m.arguments(&dexpr{args: value.args[2:], hints: hints}) + ")"
+					}
+					continue
+				}
+				if target.text == "$VALUES" || target.text == "ENUM$VALUES" {
+					continue
+				}
+			}
+		}
+		stmts = append(stmts, s)
+	}
+	r.stmts = stmts
+
+	rs := make([]string, len(names))
+	for i, name := range names {
+		rs[i] = constants[name]
+	}
+	return rs
+}
+
+// 枚举 switch 的映射表：javac 生成的合成类在 <clinit> 中为 $SwitchMap$... 数组按 ordinal() 赋值
+func (c *classDecompiler) switchMap(class, field string) map[int32]string {
+	key := class + "." + field
+	if rs, ok := c.switchMaps[key]; ok {
+		return rs
+	}
+	c.switchMaps[key] = nil
+	cf := c.p.Class(class)
+	if cf == nil {
+		return nil
+	}
+	m := cf.FindMethod("<clinit>", "()V")
+	if m == nil {
+		return nil
+	}
+	r := c.decompile(cf, m)
+	if r.err != nil {
+		return nil
+	}
+	rs := make(map[int32]string)
+	walkStmts(r.stmts, func(s *dstmt) {
+		if s.kind != sExpr || s.e.kind != dAssign {
+			return
+		}
+		target, value := s.e.args[0], s.e.args[1]
+		if target.kind != dIndex || value.kind != dLiteral {
+			return
+		}
+		array, index := target.args[0], target.args[1]
+		if array.kind != dField || array.text != field || index.kind != dCall || index.text != "ordinal" || index.recv == nil || index.recv.kind != dField {
+			return
+		}
+		if v, err := strconv.Atoi(value.text); err == nil {
+			rs[int32(v)] = index.recv.text
+		}
+	})
+	c.switchMaps[key] = rs
+	return rs
+}
+
+// 方法的一个局部变量表项
+type dlocal struct {
+	start, end, slot int
+	name, desc, sig  string
+	v                *dvar
+}
+
+type methodDecompiler struct {
+	c    *classDecompiler
+	cf   *ClassFile
+	m    *MethodInfo
+	code *CodeAttribute
+	g    *ControlFlowGraph
+	// 返回类型描述符
+	result string
+	ctor   bool
+	this   *dvar
+	params []*dvar
+	// 参数所在的局部变量下标
+	slots  map[int]*dvar
+	locals []*dlocal
+	// 没有局部变量表项时按下标与类别区分的变量
+	vars  map[string]*dvar
+	names map[string]bool
+	// 带初始化列表的数组创建的长度
+	arrays map[*dexpr]int
+	// 枚举 switch 映射数组所在的类
+	fieldClasses map[*dexpr]string
+
+	blocks map[*BasicBlock]*dblock
+	order  []*dblock
+	// 输出语句时的缩进，lambda 的方法体按此缩进
+	indent int
+}
+
+// captured 为 lambda 捕获的参数沿用的外层变量名
+func newMethodDecompiler(c *classDecompiler, cf *ClassFile, m *MethodInfo, captured []string) *methodDecompiler {
+	desc := m.DescriptorString()
+	md := &methodDecompiler{
+		c:      c,
+		cf:     cf,
+		m:      m,
+		code:   m.Code(),
+		result: desc[strings.LastIndexByte(desc, ')')+1:],
+		ctor:   m.NameString() == "<init>",
+		slots:  make(map[int]*dvar),
+		vars:   make(map[string]*dvar),
+		names:  make(map[string]bool),
+		arrays: make(map[*dexpr]int),
+
+		fieldClasses: make(map[*dexpr]string),
+	}
+	if md.code != nil {
+		md.readLocals()
+	}
+	for _, l := range md.locals {
+		md.names[l.name] = true
+	}
+
+	slot := 0
+	if m.AccessFlags&METHOD_ACC_STATIC == 0 {
+		md.this = &dvar{name: "this", typ: "L" + cf.ThisClassString() + ";", declared: true}
+		md.slots[0] = md.this
+		slot = 1
+		for _, l := range md.locals {
+			if l.slot == 0 && l.start == 0 {
+				l.v = md.this
+			}
+		}
+	}
+	params := descriptorParameters(desc)
+	fallback := methodParameterNames(cf, m, len(params))
+	for i, p := range params {
+		v := &dvar{name: fallback[i], typ: p, declared: true}
+		for _, l := range md.locals {
+			if l.slot == slot && l.start == 0 && localKind(l.desc) == localKind(p) {
+				v.name, v.sig, l.v = l.name, l.sig, v
+			}
+		}
+		if i < len(captured) {
+			v.name = captured[i]
+		}
+		md.names[v.name] = true
+		md.params = append(md.params, v)
+		md.slots[slot] = v
+		slot++
+		if p == "J" || p == "D" {
+			slot++
+		}
+	}
+	return md
+}
+
+// 方法描述符中各参数的描述符
+func descriptorParameters(desc string) []string {
+	var rs []string
+	for i := 1; i < len(desc) && desc[i] != ')'; {
+		j := i
+		for j < len(desc) && desc[j] == '[' {
+			j++
+		}
+		if j < len(desc) && desc[j] == 'L' {
+			k := strings.IndexByte(desc[j:], ';')
+			if k < 0 {
+				break
+			}
+			j += k
+		}
+		if j >= len(desc) {
+			break
+		}
+		j++
+		rs = append(rs, desc[i:j])
+		i = j
+	}
+	return rs
+}
+
+// 局部变量的类别：与 xload、xstore 指令对应的 I J F D A
+func localKind(desc string) byte {
+	if desc == "" {
+		return 'A'
+	}
+	switch desc[0] {
+	case 'L', '[':
+		return 'A'
+	case 'Z', 'B', 'C', 'S', 'I':
+		return 'I'
+	}
+	return desc[0]
+}
+
+var localPrefixes = map[byte]string{'I': "i", 'J': "l", 'F': "f", 'D': "d", 'A': "obj"}
+
+func (m *methodDecompiler) readLocals() {
+	read := func(name string, f func(start, length, slot int, name, desc string)) {
+		attr := m.code.Attribute(name)
+		if attr == nil || len(attr.Info) < 2 {
+			return
+		}
+		b := attr.Info
+		for i, n := 0, int(binary.BigEndian.Uint16(b)); i < n && 2+10*i+10 <= len(b); i++ {
+			e := b[2+10*i:]
+			f(int(binary.BigEndian.Uint16(e)), int(binary.BigEndian.Uint16(e[2:])), int(binary.BigEndian.Uint16(e[8:])),
+				m.cf.Utf8At(binary.BigEndian.Uint16(e[4:])), m.cf.Utf8At(binary.BigEndian.Uint16(e[6:])))
+		}
+	}
+	read("LocalVariableTable", func(start, length, slot int, name, desc string) {
+		m.locals = append(m.locals, &dlocal{start: start, end: start + length, slot: slot, name: name, desc: desc})
+	})
+	read("LocalVariableTypeTable", func(start, length, slot int, name, sig string) {
+		for _, l := range m.locals {
+			if l.start == start && l.end == start+length && l.slot == slot {
+				l.sig = sig
+			}
+		}
+	})
+}
+
+// 不与已有名称冲突的变量名
+func (m *methodDecompiler) fresh(base string) string {
+	name := base
+	for i := 2; m.names[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	m.names[name] = true
+	return name
+}
+
+func (m *methodDecompiler) temp(typ string) *dvar {
+	return &dvar{name: m.fresh("tmp"), typ: typ, temp: true}
+}
+
+// pc 处访问的局部变量：优先按局部变量表，其次是参数，否则按下标与类别生成名称。
+// 写入时 pc 为下一条指令的偏移，局部变量表的作用域从写入之后开始
+func (m *methodDecompiler) local(slot, pc int, kind byte, store bool) *dvar {
+	var match *dlocal
+	for _, l := range m.locals {
+		if l.slot == slot && localKind(l.desc) == kind && pc >= l.start && (pc < l.end || pc == l.start) {
+			match = l
+			break
+		}
+	}
+	// javac 的作用域从变量确定赋值之后开始，在各分支中赋值的变量取其后最近的表项
+	if match == nil && store {
+		for _, l := range m.locals {
+			if l.slot == slot && localKind(l.desc) == kind && l.start > pc && (match == nil || l.start < match.start) {
+				match = l
+			}
+		}
+		for _, l := range m.locals {
+			if match != nil && l.slot == slot && l.start < match.start && l.end > pc {
+				match = nil
+			}
+		}
+	}
+	if match != nil {
+		if match.v == nil {
+			// 作用域被拆成多段的同一变量共用一个 dvar
+			for _, o := range m.locals {
+				if o.v != nil && o.slot == slot && o.name == match.name && o.desc == match.desc {
+					match.v = o.v
+					break
+				}
+			}
+		}
+		if match.v == nil {
+			match.v = &dvar{name: match.name, typ: match.desc, sig: match.sig}
+		}
+		return match.v
+	}
+	if v := m.slots[slot]; v != nil && localKind(v.typ) == kind {
+		return v
+	}
+	key := strconv.Itoa(slot) + string(kind)
+	v := m.vars[key]
+	if v == nil {
+		v = &dvar{name: m.fresh(localPrefixes[kind] + strconv.Itoa(slot))}
+		if kind != 'A' && kind != 'I' {
+			v.typ = string(kind)
+		}
+		m.vars[key] = v
+	}
+	return v
+}
+
+func (m *methodDecompiler) isThis(e *dexpr) bool {
+	return e != nil && e.kind == dLocal && m.this != nil && e.v == m.this
+}
+
+// 类在源码中的形式，本类为空
+func (m *methodDecompiler) owner(class string) string {
+	if class == m.cf.ThisClassString() {
+		return ""
+	}
+	return m.c.w.sourceName(class)
+}
+
+// CONSTANT_Class 表示的类型在源码中的形式与描述符，数组类以描述符命名
+func (m *methodDecompiler) classType(name string) (text, desc string) {
+	if strings.HasPrefix(name, "[") {
+		return m.c.w.descriptorType(name), name
+	}
+	return m.c.w.sourceName(name), "L" + name + ";"
+}
+
+// ldc 加载的常量
+func (m *methodDecompiler) constant(index uint16) *dexpr {
+	info := m.cf.ConstantPool[index]
+	switch info.Tag {
+	case 3:
+		return dlit(strconv.Itoa(int((*ConstantIntegerInfo)(info).Integer())), "I")
+	case 4:
+		return dlit(javaLiteral(info, m.cf.ConstantPool, "F"), "F")
+	case 5:
+		return dlit(javaLiteral(info, m.cf.ConstantPool, "J"), "J")
+	case 6:
+		return dlit(javaLiteral(info, m.cf.ConstantPool, "D"), "D")
+	case 8:
+		return dlit(javaLiteral(info, m.cf.ConstantPool, ""), "Ljava/lang/String;")
+	case 7:
+		text, _ := m.classType(m.cf.ClassNameAt(index))
+		return dlit(text+".class", "Ljava/lang/Class;")
+	}
+	return &dexpr{kind: dRaw, text: "/* " + strings.Replace(m.cf.ConstantString(index), "*/", "* /", -1) + " */ null"}
+}
+
+// 反编译过程中的基本块
+type dblock struct {
+	b     *BasicBlock
+	stmts []*dstmt
+	// 条件跳转的条件：为真时转到 succs[0]，否则转到 succs[1]
+	cond  *dexpr
+	sw    *dswitch
+	succs []*dblock
+	preds []*dblock
+	// 以 return 或 athrow 结束
+	exit bool
+	// 入口处操作数栈上的值（自底向上）所保存到的临时变量
+	in []*dvar
+	// 异常处理器入口处栈顶的异常
+	caught *dvar
+	// 已并入前驱的短路条件
+	merged bool
+	// 直接后必经块
+	ipdom *dblock
+}
+
+type dswitch struct {
+	e       *dexpr
+	keys    []int32
+	targets []*dblock
+	def     *dblock
+}
+
+func (m *methodDecompiler) run() ([]*dstmt, error) {
+	if m.code == nil {
+		return nil, nil
+	}
+	g, err := NewControlFlowGraph(m.code)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range g.Blocks {
+		if last := b.Last(); last.IsJsr() || last.Opcode == OP_RET {
+			return nil, ERR_DECOMPILE_SUBROUTINE
+		}
+	}
+	m.g = g
+	m.blocks = make(map[*BasicBlock]*dblock)
+	order := g.reversePostorder()
+	for _, b := range order {
+		db := &dblock{b: b}
+		m.blocks[b] = db
+		m.order = append(m.order, db)
+	}
+	for _, e := range m.code.ExceptionTable {
+		if db := m.blocks[g.BlockAt(int(e.HandlerPc))]; db != nil && db.caught == nil {
+			typ := "Ljava/lang/Throwable;"
+			if e.CatchType != 0 {
+				typ = "L" + m.cf.ClassNameAt(e.CatchType) + ";"
+			}
+			db.caught = &dvar{name: m.fresh("e"), typ: typ, declared: true}
+		}
+	}
+	for _, db := range m.order {
+		for _, s := range db.b.Successors {
+			if t := m.blocks[s]; t != nil {
+				db.succs = append(db.succs, t)
+				t.preds = append(t.preds, db)
+			}
+		}
+	}
+	for _, db := range m.order {
+		m.translate(db)
+	}
+	m.mergeConditions()
+	return m.simplify(m.structure()), nil
+}
+
+// 模拟一个基本块中操作数栈的状态
+type dframe struct {
+	m     *methodDecompiler
+	db    *dblock
+	stack []*dexpr
+}
+
+func (f *dframe) push(e *dexpr) {
+	f.stack = append(f.stack, e)
+}
+
+func (f *dframe) pop() *dexpr {
+	if len(f.stack) == 0 {
+		return &dexpr{kind: dRaw, text: "/* stack underflow */ null"}
+	}
+	e := f.stack[len(f.stack)-1]
+	f.stack = f.stack[:len(f.stack)-1]
+	return e
+}
+
+func (f *dframe) popN(n int) []*dexpr {
+	rs := make([]*dexpr, n)
+	for i := n - 1; i >= 0; i-- {
+		rs[i] = f.pop()
+	}
+	return rs
+}
+
+func (f *dframe) onStack(e *dexpr) bool {
+	for _, s := range f.stack {
+		if s == e {
+			return true
+		}
+	}
+	return false
+}
+
+// 把值保存到临时变量，栈上同一个值的各个副本都改为读取该变量
+func (f *dframe) spill(e *dexpr) *dexpr {
+	v := f.m.temp(e.vtype())
+	f.db.stmts = append(f.db.stmts, &dstmt{kind: sExpr, e: &dexpr{kind: dAssign, typ: v.typ, args: []*dexpr{dload(v), e}}})
+	for i, s := range f.stack {
+		if s == e {
+			f.stack[i] = dload(v)
+		}
+	}
+	return dload(v)
+}
+
+// 输出语句前，把栈上有副作用或读取了被写入位置的值先保存到临时变量，保持求值顺序
+func (f *dframe) emit(s *dstmt, writes func(*dexpr) bool) {
+	for _, e := range f.stack {
+		if hasSideEffect(e) || writes != nil && containsExpr(e, writes) {
+			f.spill(e)
+		}
+	}
+	f.db.stmts = append(f.db.stmts, s)
+}
+
+func localWrites(v *dvar) func(*dexpr) bool {
+	return func(e *dexpr) bool { return e.kind == dLocal && e.v == v }
+}
+
+func fieldWrites(name string) func(*dexpr) bool {
+	return func(e *dexpr) bool { return e.kind == dField && e.text == name }
+}
+
+func arrayWrites(e *dexpr) bool {
+	return e.kind == dIndex
+}
+
+// 赋值：value 为 x + 1 且 x 仍在栈上时是 x++ 作为值，value 仍在栈上时赋值作为表达式
+func (f *dframe) store(target, value *dexpr, writes func(*dexpr) bool) {
+	op := value
+	if op.kind == dCast {
+		op = op.args[0]
+	}
+	if op.kind == dBinary && (op.text == "+" || op.text == "-") && isOne(op.args[1]) && sameExpr(op.args[0], target) {
+		for i, e := range f.stack {
+			if e == op.args[0] {
+				f.stack[i] = &dexpr{kind: dPostfix, text: op.text + op.text, typ: e.vtype(), args: []*dexpr{target}}
+				return
+			}
+		}
+	}
+	a := &dexpr{kind: dAssign, typ: value.vtype(), args: []*dexpr{target, value}}
+	for i, e := range f.stack {
+		if e == value {
+			f.stack[i] = a
+			return
+		}
+	}
+	f.emit(&dstmt{kind: sExpr, e: a}, writes)
+}
+
+// 被 dup 复制的值：有副作用时先保存到临时变量，避免求值两次；紧接着赋值的除外，赋值会成为表达式
+func (f *dframe) dupValue(e *dexpr, next *Instruction) *dexpr {
+	switch e.kind {
+	case dLiteral, dLocal, dNewArray:
+		return e
+	case dNew:
+		if e.uninit {
+			return e
+		}
+	case dField:
+		if e.recv == nil || e.recv.kind == dLocal {
+			return e
+		}
+	}
+	if next != nil && isStoreOpcode(next.Opcode) {
+		return e
+	}
+	for _, s := range f.stack {
+		if hasSideEffect(s) {
+			f.spill(s)
+		}
+	}
+	return f.spill(e)
+}
+
+func isStoreOpcode(op byte) bool {
+	return op >= OP_ISTORE && op <= OP_SASTORE || op == OP_PUTFIELD || op == OP_PUTSTATIC
+}
+
+func isWide(e *dexpr) bool {
+	t := e.vtype()
+	return t == "J" || t == "D"
+}
+
+var dArithmetic = []string{"+", "-", "*", "/", "%"}
+
+var dConditions = map[byte]string{
+	OP_IFEQ: "==", OP_IFNE: "!=", OP_IFLT: "<", OP_IFGE: ">=", OP_IFGT: ">", OP_IFLE: "<=",
+	OP_IF_ICMPEQ: "==", OP_IF_ICMPNE: "!=", OP_IF_ICMPLT: "<", OP_IF_ICMPGE: ">=", OP_IF_ICMPGT: ">", OP_IF_ICMPLE: "<=",
+	OP_IF_ACMPEQ: "==", OP_IF_ACMPNE: "!=", OP_IFNULL: "==", OP_IFNONNULL: "!=",
+}
+
+var dConversions = map[byte]string{
+	OP_I2L: "J", OP_I2F: "F", OP_I2D: "D", OP_L2I: "I", OP_L2F: "F", OP_L2D: "D",
+	OP_F2I: "I", OP_F2L: "J", OP_F2D: "D", OP_D2I: "I", OP_D2L: "J", OP_D2F: "F",
+	OP_I2B: "B", OP_I2C: "C", OP_I2S: "S",
+}
+
+var dCompares = map[byte]string{
+	OP_LCMP: "Long.compare", OP_FCMPL: "Float.compare", OP_FCMPG: "Float.compare",
+	OP_DCMPL: "Double.compare", OP_DCMPG: "Double.compare",
+}
+
+// newarray 的 atype
+var dArrayTypes = map[int32]string{4: "Z", 5: "C", 6: "F", 7: "D", 8: "B", 9: "S", 10: "I", 11: "J"}
+
+// 把基本块翻译为语句，结尾的条件跳转与 switch 记录在块上
+func (m *methodDecompiler) translate(db *dblock) {
+	f := &dframe{m: m, db: db}
+	for _, v := range db.in {
+		f.push(dload(v))
+	}
+	if db.caught != nil {
+		f.push(dload(db.caught))
+	}
+	instructions := db.b.Instructions
+	for i, ins := range instructions {
+		var prev, next *Instruction
+		if i > 0 {
+			prev = instructions[i-1]
+		}
+		if i+1 < len(instructions) {
+			next = instructions[i+1]
+		}
+		f.execute(ins, prev, next)
+	}
+
+	if db.cond != nil && len(db.succs) != 2 {
+		// 跳转目标就是下一条指令
+		if hasSideEffect(db.cond) {
+			f.emit(&dstmt{kind: sExpr, e: db.cond}, nil)
+		}
+		db.cond = nil
+	}
+	if len(f.stack) == 0 || db.exit {
+		return
+	}
+	// 留在栈上的值经临时变量传给后继
+	// 各后继尽量共用同一组临时变量，已在临时变量中的值沿用该变量
+	var shared []*dvar
+	for _, s := range db.succs {
+		if len(s.in) == len(f.stack) && shared == nil {
+			shared = s.in
+		}
+	}
+	if shared == nil {
+		for _, e := range f.stack {
+			if e.kind == dLocal && e.v.temp {
+				shared = append(shared, e.v)
+			} else {
+				shared = append(shared, m.temp(e.vtype()))
+			}
+		}
+	}
+	assigned := make(map[*dvar]bool)
+	for _, s := range db.succs {
+		if s.in == nil {
+			s.in = shared
+		}
+		for i, e := range f.stack {
+			if i >= len(s.in) || assigned[s.in[i]] {
+				continue
+			}
+			v := s.in[i]
+			assigned[v] = true
+			if e.kind == dLocal && e.v == v {
+				continue
+			}
+			db.stmts = append(db.stmts, &dstmt{kind: sExpr, e: &dexpr{kind: dAssign, typ: v.typ, args: []*dexpr{dload(v), e}}})
+		}
+	}
+}
+
+func (f *dframe) execute(ins, prev, next *Instruction) {
+	m := f.m
+	op := ins.Opcode
+	switch {
+	case op == OP_NOP:
+	case op == OP_ACONST_NULL:
+		f.push(dlit("null", ""))
+	case op >= OP_ICONST_M1 && op <= OP_ICONST_5:
+		f.push(dlit(strconv.Itoa(int(op)-int(OP_ICONST_0)), "I"))
+	case op == OP_LCONST_0 || op == OP_LCONST_1:
+		f.push(dlit(strconv.Itoa(int(op-OP_LCONST_0))+"L", "J"))
+	case op >= OP_FCONST_0 && op <= OP_FCONST_2:
+		f.push(dlit(strconv.Itoa(int(op-OP_FCONST_0))+".0f", "F"))
+	case op == OP_DCONST_0 || op == OP_DCONST_1:
+		f.push(dlit(strconv.Itoa(int(op-OP_DCONST_0))+".0", "D"))
+	case op == OP_BIPUSH || op == OP_SIPUSH:
+		f.push(dlit(strconv.Itoa(int(ins.Const)), "I"))
+	case op == OP_LDC || op == OP_LDC_W || op == OP_LDC2_W:
+		f.push(m.constant(ins.Index))
+
+	case op >= OP_ILOAD && op <= OP_ALOAD_3:
+		kind := "IJFDA"[(op-OP_ILOAD_0)/4]
+		if op < OP_ILOAD_0 {
+			kind = "IJFDA"[op-OP_ILOAD]
+		}
+		f.push(dload(m.local(ins.LocalIndex(), ins.Offset, kind, false)))
+	case op >= OP_IALOAD && op <= OP_SALOAD:
+		index := f.pop()
+		array := f.pop()
+		typ := string("IJFD BCS"[op-OP_IALOAD])
+		if t := array.vtype(); strings.HasPrefix(t, "[") {
+			typ = t[1:]
+		} else if op == OP_AALOAD {
+			typ = ""
+		}
+		f.push(&dexpr{kind: dIndex, typ: typ, args: []*dexpr{array, index}})
+
+	case op >= OP_ISTORE && op <= OP_ASTORE_3:
+		kind := "IJFDA"[(op-OP_ISTORE_0)/4]
+		if op < OP_ISTORE_0 {
+			kind = "IJFDA"[op-OP_ISTORE]
+		}
+		value := f.pop()
+		v := m.local(ins.LocalIndex(), ins.Offset+ins.Length, kind, true)
+		if v.typ == "" {
+			switch t := value.vtype(); {
+			case kind == 'A' && t != "":
+				v.typ = t
+			case kind == 'A':
+				v.typ = "Ljava/lang/Object;"
+			case t == "Z" || t == "B" || t == "C" || t == "S":
+				v.typ = t
+			default:
+				v.typ = "I"
+			}
+		}
+		f.store(dload(v), value, localWrites(v))
+	case op >= OP_IASTORE && op <= OP_SASTORE:
+		value := f.pop()
+		index := f.pop()
+		array := f.pop()
+		// 数组初始化列表：new int[]{a, b}
+		if array.kind == dNewArray && f.onStack(array) && index.kind == dLiteral {
+			if !array.init && len(array.args) == 1 && array.args[0].kind == dLiteral && index.text == "0" {
+				if n, err := strconv.Atoi(array.args[0].text); err == nil && n > 0 {
+					array.init, array.args = true, nil
+					m.arrays[array] = n
+				}
+			}
+			if array.init && index.text == strconv.Itoa(len(array.args)) && len(array.args) < m.arrays[array] {
+				array.args = append(array.args, value)
+				return
+			}
+		}
+		elem := ""
+		if t := array.vtype(); strings.HasPrefix(t, "[") {
+			elem = t[1:]
+		}
+		f.store(&dexpr{kind: dIndex, typ: elem, args: []*dexpr{array, index}}, value, arrayWrites)
+
+	case op == OP_POP || op == OP_POP2:
+		values := []*dexpr{f.pop()}
+		if op == OP_POP2 && !isWide(values[0]) {
+			values = append([]*dexpr{f.pop()}, values...)
+		}
+		for _, e := range values {
+			if hasSideEffect(e) && !f.onStack(e) {
+				f.emit(&dstmt{kind: sExpr, e: e}, nil)
+			}
+		}
+	case op == OP_DUP:
+		v := f.dupValue(f.pop(), next)
+		f.push(v)
+		f.push(v)
+	case op == OP_DUP_X1:
+		v1 := f.dupValue(f.pop(), next)
+		v2 := f.pop()
+		f.stack = append(f.stack, v1, v2, v1)
+	case op == OP_DUP_X2:
+		v1 := f.dupValue(f.pop(), next)
+		v2 := f.pop()
+		if isWide(v2) {
+			f.stack = append(f.stack, v1, v2, v1)
+		} else {
+			v3 := f.pop()
+			f.stack = append(f.stack, v1, v3, v2, v1)
+		}
+	case op == OP_DUP2:
+		v1 := f.dupValue(f.pop(), next)
+		if isWide(v1) {
+			f.stack = append(f.stack, v1, v1)
+		} else {
+			v2 := f.dupValue(f.pop(), next)
+			f.stack = append(f.stack, v2, v1, v2, v1)
+		}
+	case op == OP_DUP2_X1:
+		v1 := f.dupValue(f.pop(), next)
+		if isWide(v1) {
+			v2 := f.pop()
+			f.stack = append(f.stack, v1, v2, v1)
+		} else {
+			v2 := f.dupValue(f.pop(), next)
+			v3 := f.pop()
+			f.stack = append(f.stack, v2, v1, v3, v2, v1)
+		}
+	case op == OP_DUP2_X2:
+		v1 := f.dupValue(f.pop(), next)
+		if isWide(v1) {
+			v2 := f.pop()
+			if isWide(v2) {
+				f.stack = append(f.stack, v1, v2, v1)
+			} else {
+				v3 := f.pop()
+				f.stack = append(f.stack, v1, v3, v2, v1)
+			}
+		} else {
+			v2 := f.dupValue(f.pop(), next)
+			v3 := f.pop()
+			if isWide(v3) {
+				f.stack = append(f.stack, v2, v1, v3, v2, v1)
+			} else {
+				v4 := f.pop()
+				f.stack = append(f.stack, v2, v1, v4, v3, v2, v1)
+			}
+		}
+	case op == OP_SWAP:
+		v1 := f.pop()
+		v2 := f.pop()
+		f.stack = append(f.stack, v1, v2)
+
+	case op >= OP_IADD && op <= OP_DREM:
+		r := f.pop()
+		l := f.pop()
+		f.push(dbinary(dArithmetic[(op-OP_IADD)/4], l, r, string("IJFD"[(op-OP_IADD)%4])))
+	case op >= OP_INEG && op <= OP_DNEG:
+		e := f.pop()
+		if e.kind == dLiteral && !strings.HasPrefix(e.text, "-") && !strings.Contains(e.text, " ") {
+			f.push(dlit("-"+e.text, e.typ))
+		} else {
+			f.push(&dexpr{kind: dUnary, text: "-", typ: string("IJFD"[op-OP_INEG]), args: []*dexpr{e}})
+		}
+	case op >= OP_ISHL && op <= OP_LXOR:
+		r := f.pop()
+		l := f.pop()
+		operator := []string{"<<", ">>", ">>>", "&", "|", "^"}[(op-OP_ISHL)/2]
+		typ := string("IJ"[(op-OP_ISHL)%2])
+		if l.vtype() == "Z" && r.vtype() == "Z" {
+			typ = "Z"
+		}
+		f.push(dbinary(operator, l, r, typ))
+	case op == OP_IINC:
+		v := m.local(ins.LocalIndex(), ins.Offset, 'I', false)
+		if ins.Const == 1 || ins.Const == -1 {
+			operator := "++"
+			if ins.Const < 0 {
+				operator = "--"
+			}
+			// 紧接在读取之后的 iinc：x++ 作为值
+			if n := len(f.stack); n > 0 && prev != nil && prev.LocalIndex() == ins.LocalIndex() && prev.Opcode >= OP_ILOAD && prev.Opcode <= OP_ALOAD_3 &&
+				f.stack[n-1].kind == dLocal && f.stack[n-1].v == v {
+				f.stack[n-1] = &dexpr{kind: dPostfix, text: operator, typ: v.typ, args: []*dexpr{dload(v)}}
+				return
+			}
+		}
+		operator, amount := "+", int(ins.Const)
+		if amount < 0 {
+			operator, amount = "-", -amount
+		}
+		value := dbinary(operator, dload(v), dlit(strconv.Itoa(amount), "I"), "I")
+		f.emit(&dstmt{kind: sExpr, e: &dexpr{kind: dAssign, typ: "I", args: []*dexpr{dload(v), value}}}, localWrites(v))
+	case dConversions[op] != "":
+		e := f.pop()
+		typ := dConversions[op]
+		switch {
+		case typ == "J" && e.kind == dLiteral && e.typ == "I":
+			f.push(dlit(e.text+"L", "J"))
+		case typ == "C" && e.kind == dLiteral && e.typ == "I":
+			f.push(coerce(e, "C"))
+		default:
+			f.push(&dexpr{kind: dCast, text: primitiveJavaNames[typ[0]], typ: typ, args: []*dexpr{e}})
+		}
+	case dCompares[op] != "":
+		r := f.pop()
+		l := f.pop()
+		f.push(&dexpr{kind: dCompare, text: dCompares[op], typ: "I", args: []*dexpr{l, r}})
+
+	case op >= OP_IFEQ && op <= OP_IFLE:
+		v := f.pop()
+		switch operator := dConditions[op]; {
+		case v.kind == dCompare:
+			f.db.cond = dbinary(operator, v.args[0], v.args[1], "Z")
+		case v.vtype() == "Z" && op == OP_IFNE:
+			f.db.cond = v
+		case v.vtype() == "Z" && op == OP_IFEQ:
+			f.db.cond = negate(v)
+		default:
+			f.db.cond = dbinary(operator, v, dlit("0", "I"), "Z")
+		}
+	case op >= OP_IF_ICMPEQ && op <= OP_IF_ACMPNE:
+		r := f.pop()
+		l := f.pop()
+		f.db.cond = dbinary(dConditions[op], l, r, "Z")
+	case op == OP_IFNULL || op == OP_IFNONNULL:
+		f.db.cond = dbinary(dConditions[op], f.pop(), dlit("null", ""), "Z")
+	case ins.IsGoto():
+	case ins.IsSwitch():
+		sw := &dswitch{e: f.pop(), keys: ins.Keys, def: m.blocks[m.g.BlockAt(ins.Default)]}
+		for _, target := range ins.Targets {
+			sw.targets = append(sw.targets, m.blocks[m.g.BlockAt(target)])
+		}
+		f.db.sw = sw
+
+	case op >= OP_IRETURN && op <= OP_ARETURN:
+		f.emit(&dstmt{kind: sReturn, e: f.pop()}, nil)
+		f.db.exit = true
+	case op == OP_RETURN:
+		f.emit(&dstmt{kind: sReturn}, nil)
+		f.db.exit = true
+	case op == OP_ATHROW:
+		f.emit(&dstmt{kind: sThrow, e: f.pop()}, nil)
+		f.db.exit = true
+
+	case op == OP_GETSTATIC:
+		class, name, desc := m.cf.MemberRefAt(ins.Index)
+		e := &dexpr{kind: dField, text: name, typ: desc, owner: m.owner(class)}
+		if strings.HasPrefix(name, "$SwitchMap$") {
+			m.fieldClasses[e] = class
+		}
+		f.push(e)
+	case op == OP_PUTSTATIC:
+		class, name, desc := m.cf.MemberRefAt(ins.Index)
+		f.store(&dexpr{kind: dField, text: name, typ: desc, owner: m.owner(class)}, f.pop(), fieldWrites(name))
+	case op == OP_GETFIELD:
+		_, name, desc := m.cf.MemberRefAt(ins.Index)
+		recv := f.pop()
+		if strings.HasPrefix(name, "this$") && m.isThis(recv) {
+			// 内部类引用外部类实例的合成字段
+			f.push(dlit(m.c.w.descriptorType(desc)+".this", desc))
+		} else {
+			f.push(&dexpr{kind: dField, text: name, typ: desc, recv: recv})
+		}
+	case op == OP_PUTFIELD:
+		_, name, desc := m.cf.MemberRefAt(ins.Index)
+		value := f.pop()
+		recv := f.pop()
+		// 构造方法中保存外部类实例与捕获变量的合成字段
+		if m.ctor && m.isThis(recv) && (strings.HasPrefix(name, "this$") || strings.HasPrefix(name, "val$")) {
+			return
+		}
+		f.store(&dexpr{kind: dField, text: name, typ: desc, recv: recv}, value, fieldWrites(name))
+
+	case op >= OP_INVOKEVIRTUAL && op <= OP_INVOKEINTERFACE:
+		class, name, desc := m.cf.MemberRefAt(ins.Index)
+		f.invoke(op, class, name, desc)
+	case op == OP_INVOKEDYNAMIC:
+		f.invokedynamic(ins)
+
+	case op == OP_NEW:
+		text, desc := m.classType(m.cf.ClassNameAt(ins.Index))
+		f.push(&dexpr{kind: dNew, text: text, typ: desc, uninit: true})
+	case op == OP_NEWARRAY:
+		elem := dArrayTypes[ins.Const]
+		if elem == "" {
+			elem = "I"
+		}
+		f.push(&dexpr{kind: dNewArray, text: primitiveJavaNames[elem[0]] + "[]", typ: "[" + elem, args: []*dexpr{f.pop()}})
+	case op == OP_ANEWARRAY:
+		text, desc := m.classType(m.cf.ClassNameAt(ins.Index))
+		f.push(&dexpr{kind: dNewArray, text: text + "[]", typ: "[" + desc, args: []*dexpr{f.pop()}})
+	case op == OP_MULTIANEWARRAY:
+		text, desc := m.classType(m.cf.ClassNameAt(ins.Index))
+		f.push(&dexpr{kind: dNewArray, text: text, typ: desc, args: f.popN(int(ins.Const))})
+	case op == OP_ARRAYLENGTH:
+		f.push(&dexpr{kind: dField, text: "length", typ: "I", recv: f.pop()})
+	case op == OP_CHECKCAST:
+		text, desc := m.classType(m.cf.ClassNameAt(ins.Index))
+		f.push(&dexpr{kind: dCast, text: text, typ: desc, args: []*dexpr{f.pop()}})
+	case op == OP_INSTANCEOF:
+		text, _ := m.classType(m.cf.ClassNameAt(ins.Index))
+		f.push(&dexpr{kind: dInstanceOf, text: text, typ: "Z", args: []*dexpr{f.pop()}})
+	case op == OP_MONITORENTER || op == OP_MONITOREXIT:
+		text := "monitorenter"
+		if op == OP_MONITOREXIT {
+			text = "monitorexit"
+		}
+		f.emit(&dstmt{kind: sMonitor, text: text, e: f.pop()}, nil)
+	default:
+		f.emit(&dstmt{kind: sComment, text: ins.String()}, nil)
+	}
+}
+
+func (f *dframe) invoke(op byte, class, name, desc string) {
+	m := f.m
+	params := descriptorParameters(desc)
+	result := desc[strings.LastIndexByte(desc, ')')+1:]
+	args := f.popN(len(params))
+	var recv *dexpr
+	if op != OP_INVOKESTATIC {
+		recv = f.pop()
+	}
+	e := &dexpr{kind: dCall, text: name, typ: result, args: args, hints: params}
+	switch {
+	case name == "<init>" && recv.kind == dNew && recv.uninit:
+		recv.uninit, recv.args, recv.hints = false, args, params
+		if !f.onStack(recv) {
+			f.emit(&dstmt{kind: sExpr, e: recv}, nil)
+		}
+		return
+	case name == "<init>" && m.isThis(recv):
+		// 枚举与无参的父类构造方法调用由编译器生成
+		if class == "java/lang/Enum" || class != m.cf.ThisClassString() && len(args) == 0 {
+			return
+		}
+		e.text = "super"
+		if class == m.cf.ThisClassString() {
+			e.text = "this"
+		}
+	case name == "<init>":
+		e.text, e.recv = "/* "+class+".<init> */", recv
+	case op == OP_INVOKESPECIAL && m.isThis(recv) && class != m.cf.ThisClassString():
+		e.owner = "super"
+	case op == OP_INVOKESTATIC:
+		e.owner = m.owner(class)
+	case !m.isThis(recv):
+		e.recv = recv
+	}
+	if name == "toString" && len(args) == 0 && (class == "java/lang/StringBuilder" || class == "java/lang/StringBuffer") {
+		if s := f.concatenation(recv); s != nil {
+			f.push(s)
+			return
+		}
+	}
+	if result == "V" {
+		f.emit(&dstmt{kind: sExpr, e: e}, nil)
+	} else {
+		f.push(e)
+	}
+}
+
+// new StringBuilder().append(a).append(b).toString() 还原为 a + b
+func (f *dframe) concatenation(e *dexpr) *dexpr {
+	var parts []*dexpr
+	for e.kind == dCall && e.text == "append" && len(e.args) == 1 && e.recv != nil && e.typ == e.recv.vtype() {
+		parts = append([]*dexpr{coerce(e.args[0], e.hints[0])}, parts...)
+		e = e.recv
+	}
+	if e.kind != dNew || e.uninit || e.typ != "Ljava/lang/StringBuilder;" && e.typ != "Ljava/lang/StringBuffer;" {
+		return nil
+	}
+	switch len(e.args) {
+	case 0:
+	case 1:
+		first := e.args[0]
+		if first.kind == dCall && first.text == "valueOf" && first.owner == f.m.c.w.sourceName("java/lang/String") && len(first.args) == 1 {
+			first = coerce(first.args[0], first.hints[0])
+		} else if first.vtype() != "Ljava/lang/String;" {
+			return nil
+		}
+		parts = append([]*dexpr{first}, parts...)
+	default:
+		return nil
+	}
+	return concat(parts)
+}
+
+// 字符串拼接；第一项不是字符串时以 "" 开头，保证按字符串拼接求值
+func concat(parts []*dexpr) *dexpr {
+	const str = "Ljava/lang/String;"
+	if len(parts) == 0 || parts[0].vtype() != str {
+		parts = append([]*dexpr{dlit(`""`, str)}, parts...)
+	}
+	e := parts[0]
+	for _, p := range parts[1:] {
+		e = dbinary("+", e, p, str)
+	}
+	return e
+}
+
+func (f *dframe) invokedynamic(ins *Instruction) {
+	m := f.m
+	info := (*ConstantInvokeDynamicInfo)(m.cf.ConstantPool[ins.Index])
+	name, desc := m.cf.NameAndTypeAt(info.NameAndTypeIndex())
+	params := descriptorParameters(desc)
+	result := desc[strings.LastIndexByte(desc, ')')+1:]
+	args := f.popN(len(params))
+
+	var bm *BootstrapMethod
+	bsm := ""
+	if bsms := m.cf.BootstrapMethods(); int(info.BootstrapMethodAttrIndex()) < len(bsms) {
+		bm = bsms[info.BootstrapMethodAttrIndex()]
+		_, bsm, _ = m.cf.MemberRefAt(bm.MethodHandle().ReferenceIndex())
+	}
+	var e *dexpr
+	switch bsm {
+	case "metafactory", "altMetafactory":
+		e = m.lambda(bm, result, args)
+	case "makeConcatWithConstants":
+		e = m.concatRecipe(bm, args, params)
+	case "makeConcat":
+		for i := range args {
+			args[i] = coerce(args[i], params[i])
+		}
+		e = concat(args)
+	}
+	if e == nil {
+		e = &dexpr{kind: dCall, text: "/* invokedynamic " + bsm + " */ " + name, typ: result, args: args, hints: params}
+	}
+	if result == "V" {
+		f.emit(&dstmt{kind: sExpr, e: e}, nil)
+	} else {
+		f.push(e)
+	}
+}
+
+// StringConcatFactory.makeConcatWithConstants 的拼接配方：\1 为参数，\2 为常量
+func (m *methodDecompiler) concatRecipe(bm *BootstrapMethod, args []*dexpr, params []string) *dexpr {
+	if len(bm.BootstrapArguments) == 0 || m.cf.ConstantPool[bm.BootstrapArguments[0]].Tag != 8 {
+		return nil
+	}
+	recipe := m.cf.Utf8At((*ConstantStringInfo)(m.cf.ConstantPool[bm.BootstrapArguments[0]]).StringIndex())
+	constants := bm.BootstrapArguments[1:]
+	var parts []*dexpr
+	text := &strings.Builder{}
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, dlit(javaStringLiteral(text.String()), "Ljava/lang/String;"))
+			text.Reset()
+		}
+	}
+	for _, c := range recipe {
+		switch {
+		case c == 1 && len(args) > 0:
+			flush()
+			parts = append(parts, coerce(args[0], params[0]))
+			args, params = args[1:], params[1:]
+		case c == 2 && len(constants) > 0:
+			flush()
+			parts = append(parts, m.constant(constants[0]))
+			constants = constants[1:]
+		default:
+			text.WriteRune(c)
+		}
+	}
+	flush()
+	return concat(parts)
+}
+
+// LambdaMetafactory 生成的函数式接口实例：本类的合成 lambda 方法内联为 lambda 表达式，其余写成方法引用
+func (m *methodDecompiler) lambda(bm *BootstrapMethod, result string, captured []*dexpr) *dexpr {
+	if len(bm.BootstrapArguments) < 3 || m.cf.ConstantPool[bm.BootstrapArguments[1]].Tag != 15 {
+		return nil
+	}
+	handle := (*ConstantMethodHandleInfo)(m.cf.ConstantPool[bm.BootstrapArguments[1]])
+	kind := handle.ReferenceKind()
+	class, name, desc := m.cf.MemberRefAt(handle.ReferenceIndex())
+	if class == m.cf.ThisClassString() && strings.HasPrefix(name, "lambda$") {
+		if impl := m.cf.FindMethod(name, desc); impl != nil && impl.Code() != nil {
+			if l := m.inlineLambda(impl, captured); l != nil {
+				// 捕获的变量留在 args 中，便于统计变量的使用
+				return &dexpr{kind: dLambda, typ: result, args: captured, lambda: l}
+			}
+		}
+	}
+
+	e := &dexpr{kind: dLambda, text: name, typ: result, owner: m.c.w.sourceName(class)}
+	switch {
+	case len(captured) > 1:
+		return nil
+	case kind == 8:
+		e.text = "new"
+	case len(captured) == 1 && kind == 7 && class != m.cf.ThisClassString():
+		e.owner = "super"
+	case len(captured) == 1:
+		e.owner, e.recv = "", captured[0]
+	}
+	return e
+}
+
+func (m *methodDecompiler) inlineLambda(impl *MethodInfo, captured []*dexpr) *dlambda {
+	if impl.AccessFlags&METHOD_ACC_STATIC == 0 {
+		if len(captured) == 0 || !m.isThis(captured[0]) {
+			return nil
+		}
+		captured = captured[1:]
+	}
+	var names []string
+	for _, e := range captured {
+		if e.kind != dLocal {
+			return nil
+		}
+		names = append(names, e.v.name)
+	}
+	sub := newMethodDecompiler(m.c, m.cf, impl, names)
+	stmts, err := sub.run()
+	if err != nil {
+		return nil
+	}
+	l := &dlambda{m: sub}
+	for _, v := range sub.params[len(names):] {
+		l.params = append(l.params, v.name)
+	}
+	switch {
+	case len(stmts) == 1 && stmts[0].kind == sReturn && stmts[0].e != nil:
+		l.expr = stmts[0].e
+	case len(stmts) == 1 && stmts[0].kind == sExpr && stmts[0].v == nil:
+		l.expr = stmts[0].e
+	default:
+		l.body = stmts
+	}
+	return l
+}
+
+// 反编译单个方法，返回方法体的 Java 语句（实验性）
+func (p *ClassPath) DecompileMethod(cf *ClassFile, m *MethodInfo) (string, error) {
+	c := newClassDecompiler(p, cf)
+	r := c.decompile(cf, m)
+	if r.err != nil {
+		return "", r.err
+	}
+	b := &bytes.Buffer{}
+	r.m.writeStmts(b, 0, r.stmts)
+	return b.String(), nil
+}
+
+// 把类 cf 反编译为 Java 源码（实验性）。能还原 if/else、各种循环、try/catch、switch
+// （包括字符串与枚举 switch）以及 lambda 与方法引用，局部变量名取自 LocalVariableTable；
+// 无法还原的方法体以注释形式保留字节码。成员类写在外部类中，匿名类与局部类需单独反编译。
+// 输出的源码便于阅读，不保证可以重新编译
+func (p *ClassPath) Decompile(cf *ClassFile) string {
+	c := newClassDecompiler(p, cf)
+	if pkg := PackageOf(cf.ThisClassString()); pkg != "" {
+		fmt.Fprintf(c.w.b, "package %s;\n\n", strings.Replace(pkg, "/", ".", -1))
+	}
+	c.w.class(0, cf, nil)
+	return c.w.b.String()
+}
+
+// jar 中每个顶层类反编译得到的源码，键为源文件路径。成员类写在外部类中，
+// 匿名类与局部类单独输出；module-info 与 package-info 不输出
+func (p *ClassPath) DecompileJar(jar *Jar) map[string]string {
+	rs := make(map[string]string)
+	for _, name := range jar.ClassNames() {
+		cf := jar.Class(name)
+		if inner := cf.InnerClassEntry(); inner != nil && inner.OuterClassString() != "" && inner.InnerNameString() != "" ||
+			name == "module-info" || strings.HasSuffix(name, "/package-info") || name == "package-info" {
+			continue
+		}
+		rs[name+".java"] = p.Decompile(cf)
+	}
+	return rs
+}
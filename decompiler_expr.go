@@ -0,0 +1,390 @@
+package jclass
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+type dexprKind int
+
+const (
+	dLiteral dexprKind = iota
+	dLocal
+	dUnary
+	dCast
+	dBinary
+	// lcmp、fcmpl 等的结果，通常与随后的条件跳转合并为比较
+	dCompare
+	dTernary
+	dAssign
+	// x++、x--
+	dPostfix
+	dField
+	dIndex
+	dCall
+	dNew
+	dNewArray
+	dInstanceOf
+	dLambda
+	// 无法还原为 Java 表达式的片段，按原样输出
+	dRaw
+)
+
+// 反编译得到的表达式
+type dexpr struct {
+	kind dexprKind
+	// 字面量、运算符、成员名，或类型在源码中的形式
+	text string
+	// 值的描述符，未知时为空
+	typ  string
+	args []*dexpr
+	v    *dvar
+	// 字段访问与方法调用的接收者；静态成员与对 this 的调用为 nil
+	recv *dexpr
+	// 静态成员所属类在源码中的形式，本类的静态成员为空
+	owner string
+	// 实参对应的形参描述符，用于把 int 常量写成 boolean 或 char
+	hints []string
+	// 尚未调用构造方法的 new
+	uninit bool
+	// 带初始化列表的数组创建，元素在 args 中
+	init   bool
+	lambda *dlambda
+}
+
+// 局部变量
+type dvar struct {
+	name string
+	// 描述符，未知时为空
+	typ string
+	// LocalVariableTypeTable 中的泛型签名
+	sig string
+	// 参数、this、catch 与 lambda 参数不需要声明
+	declared bool
+	// 为跨基本块传递操作数栈上的值而引入的临时变量
+	temp bool
+}
+
+type dlambda struct {
+	params []string
+	// 方法体只有一条 return 或表达式语句时为表达式形式
+	expr *dexpr
+	body []*dstmt
+	m    *methodDecompiler
+}
+
+func dlit(text, typ string) *dexpr {
+	return &dexpr{kind: dLiteral, text: text, typ: typ}
+}
+
+func dload(v *dvar) *dexpr {
+	return &dexpr{kind: dLocal, v: v}
+}
+
+func dbinary(op string, l, r *dexpr, typ string) *dexpr {
+	return &dexpr{kind: dBinary, text: op, typ: typ, args: []*dexpr{l, r}}
+}
+
+// 表达式的描述符；局部变量的类型可能在首次赋值时才确定
+func (e *dexpr) vtype() string {
+	if e.kind == dLocal {
+		return e.v.typ
+	}
+	return e.typ
+}
+
+var dBinaryPrecedence = map[string]int{
+	"||": 3, "&&": 4, "|": 5, "^": 6, "&": 7,
+	"==": 8, "!=": 8, "<": 9, ">": 9, "<=": 9, ">=": 9,
+	"<<": 10, ">>": 10, ">>>": 10, "+": 11, "-": 11, "*": 12, "/": 12, "%": 12,
+}
+
+// Java 运算符优先级，数值越大结合越紧
+func (e *dexpr) precedence() int {
+	switch e.kind {
+	case dLambda:
+		return 0
+	case dAssign:
+		return 1
+	case dTernary:
+		return 2
+	case dBinary:
+		return dBinaryPrecedence[e.text]
+	case dInstanceOf:
+		return 9
+	case dUnary, dCast:
+		return 13
+	case dPostfix:
+		return 14
+	case dLiteral:
+		switch {
+		case strings.Contains(e.text, " / "):
+			return 12
+		case strings.HasPrefix(e.text, "-"):
+			return 13
+		}
+	}
+	return 15
+}
+
+var dNegations = map[string]string{"==": "!=", "!=": "==", "<": ">=", ">=": "<", ">": "<=", "<=": ">"}
+
+// 逻辑取反：比较运算符取反，&& 与 || 按德摩根定律展开
+func negate(e *dexpr) *dexpr {
+	switch {
+	case e.kind == dBinary && dNegations[e.text] != "":
+		return dbinary(dNegations[e.text], e.args[0], e.args[1], "Z")
+	case e.kind == dBinary && e.text == "&&":
+		return dbinary("||", negate(e.args[0]), negate(e.args[1]), "Z")
+	case e.kind == dBinary && e.text == "||":
+		return dbinary("&&", negate(e.args[0]), negate(e.args[1]), "Z")
+	case e.kind == dUnary && e.text == "!":
+		return e.args[0]
+	case e.kind == dLiteral && e.text == "true":
+		return dlit("false", "Z")
+	case e.kind == dLiteral && e.text == "false":
+		return dlit("true", "Z")
+	}
+	return &dexpr{kind: dUnary, text: "!", typ: "Z", args: []*dexpr{e}}
+}
+
+// 按目标类型改写 int 常量：boolean 与 char 在字节码中都是 int
+func coerce(e *dexpr, hint string) *dexpr {
+	if hint != "Z" && hint != "C" {
+		return e
+	}
+	switch e.kind {
+	case dLiteral:
+		v, err := strconv.Atoi(e.text)
+		switch {
+		case err != nil || e.typ != "I":
+		case hint == "Z" && (v == 0 || v == 1):
+			return dlit(strconv.FormatBool(v != 0), "Z")
+		case hint == "C" && v >= 0 && v <= 0xffff:
+			return dlit(javaCharLiteral(int32(v)), "C")
+		}
+	case dTernary:
+		a, b := coerce(e.args[1], hint), coerce(e.args[2], hint)
+		if hint == "Z" && a.kind == dLiteral && b.kind == dLiteral {
+			switch {
+			case a.text == "true" && b.text == "false":
+				return e.args[0]
+			case a.text == "false" && b.text == "true":
+				return negate(e.args[0])
+			}
+		}
+		return &dexpr{kind: dTernary, typ: hint, args: []*dexpr{e.args[0], a, b}}
+	}
+	return e
+}
+
+// 结构相同的两个表达式，用于识别 x = x + 1 之类的写法
+func sameExpr(a, b *dexpr) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.kind != b.kind || a.text != b.text || a.owner != b.owner || a.v != b.v ||
+		len(a.args) != len(b.args) || !sameExpr(a.recv, b.recv) {
+		return false
+	}
+	switch a.kind {
+	case dLiteral, dLocal, dField, dIndex:
+	default:
+		return false
+	}
+	for i := range a.args {
+		if !sameExpr(a.args[i], b.args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// e 或其子表达式是否满足 f；不进入 lambda 方法体
+func containsExpr(e *dexpr, f func(*dexpr) bool) bool {
+	if e == nil {
+		return false
+	}
+	if f(e) || containsExpr(e.recv, f) {
+		return true
+	}
+	for _, arg := range e.args {
+		if containsExpr(arg, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// 求值时可能有副作用的表达式
+func hasSideEffect(e *dexpr) bool {
+	return containsExpr(e, func(e *dexpr) bool {
+		return e.kind == dCall || e.kind == dNew && !e.uninit || e.kind == dAssign || e.kind == dPostfix
+	})
+}
+
+func isOne(e *dexpr) bool {
+	return e.kind == dLiteral && (e.text == "1" || e.text == "1L")
+}
+
+func (m *methodDecompiler) sub(e *dexpr, precedence int) string {
+	s := m.expr(e)
+	if e.precedence() < precedence {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (m *methodDecompiler) exprAs(e *dexpr, hint string) string {
+	return m.expr(coerce(e, hint))
+}
+
+func (m *methodDecompiler) arguments(e *dexpr) string {
+	args := make([]string, len(e.args))
+	for i, arg := range e.args {
+		hint := ""
+		if i < len(e.hints) {
+			hint = e.hints[i]
+		}
+		args[i] = m.exprAs(arg, hint)
+	}
+	return strings.Join(args, ", ")
+}
+
+func (m *methodDecompiler) expr(e *dexpr) string {
+	switch e.kind {
+	case dLiteral, dRaw:
+		return e.text
+	case dLocal:
+		return e.v.name
+	case dUnary:
+		s := m.sub(e.args[0], 13)
+		if e.text != "!" && strings.HasPrefix(s, e.text) {
+			s = "(" + s + ")"
+		}
+		return e.text + s
+	case dCast:
+		return "(" + e.text + ") " + m.sub(e.args[0], 13)
+	case dBinary:
+		p := e.precedence()
+		l, r := e.args[0], e.args[1]
+		if dNegations[e.text] != "" {
+			if t := l.vtype(); t == "Z" || t == "C" {
+				r = coerce(r, t)
+			} else if t := r.vtype(); t == "Z" || t == "C" {
+				l = coerce(l, t)
+			}
+		}
+		rs := m.sub(r, p+1)
+		// && 与 || 满足结合律
+		if (e.text == "&&" || e.text == "||") && r.kind == dBinary && r.text == e.text {
+			rs = m.expr(r)
+		}
+		return m.sub(l, p) + " " + e.text + " " + rs
+	case dCompare:
+		return e.text + "(" + m.expr(e.args[0]) + ", " + m.expr(e.args[1]) + ")"
+	case dTernary:
+		return m.sub(e.args[0], 3) + " ? " + m.sub(e.args[1], 3) + " : " + m.sub(e.args[2], 2)
+	case dAssign:
+		return m.assignment(e, false)
+	case dPostfix:
+		return m.sub(e.args[0], 14) + e.text
+	case dField:
+		switch {
+		case e.recv != nil:
+			return m.sub(e.recv, 15) + "." + e.text
+		case e.owner != "":
+			return e.owner + "." + e.text
+		}
+		return e.text
+	case dIndex:
+		return m.sub(e.args[0], 15) + "[" + m.expr(e.args[1]) + "]"
+	case dCall:
+		s := e.text + "(" + m.arguments(e) + ")"
+		switch {
+		case e.recv != nil:
+			return m.sub(e.recv, 15) + "." + s
+		case e.owner != "":
+			return e.owner + "." + s
+		}
+		return s
+	case dNew:
+		return "new " + e.text + "(" + m.arguments(e) + ")"
+	case dNewArray:
+		if e.init {
+			elem := ""
+			if strings.HasPrefix(e.typ, "[") {
+				elem = e.typ[1:]
+			}
+			elems := make([]string, len(e.args))
+			for i, arg := range e.args {
+				elems[i] = m.exprAs(arg, elem)
+			}
+			return "new " + e.text + "{" + strings.Join(elems, ", ") + "}"
+		}
+		// text 为完整的数组类型，如 int[][]，args 为已给出长度的各维
+		base := strings.TrimRight(e.text, "[]")
+		dims := strings.Count(e.text, "[]")
+		s := "new " + base
+		for _, arg := range e.args {
+			s += "[" + m.expr(arg) + "]"
+		}
+		return s + strings.Repeat("[]", dims-len(e.args))
+	case dInstanceOf:
+		return m.sub(e.args[0], 9) + " instanceof " + e.text
+	case dLambda:
+		if e.lambda != nil {
+			return m.lambdaText(e.lambda)
+		}
+		// 方法引用
+		if e.recv != nil {
+			return m.sub(e.recv, 15) + "::" + e.text
+		}
+		return e.owner + "::" + e.text
+	}
+	return e.text
+}
+
+// 复合赋值可以省略的窄化转换
+var dCompoundCasts = map[string]bool{"B": true, "S": true, "C": true}
+
+// 赋值；x = x op y 写成复合赋值，x += 1 作为语句时写成 x++，作为表达式时写成 ++x
+func (m *methodDecompiler) assignment(e *dexpr, statement bool) string {
+	target, value := e.args[0], e.args[1]
+	t := m.expr(target)
+	op := value
+	if op.kind == dCast && dCompoundCasts[target.vtype()] {
+		op = op.args[0]
+	}
+	if op.kind == dBinary && dBinaryPrecedence[op.text] >= 5 && dNegations[op.text] == "" && sameExpr(op.args[0], target) {
+		r := op.args[1]
+		if (op.text == "+" || op.text == "-") && isOne(r) && target.vtype() != "Ljava/lang/String;" {
+			if statement {
+				return t + op.text + op.text
+			}
+			return op.text + op.text + t
+		}
+		return t + " " + op.text + "= " + m.sub(r, 1)
+	}
+	return t + " = " + m.sub(coerce(value, target.vtype()), 1)
+}
+
+func (m *methodDecompiler) lambdaText(l *dlambda) string {
+	params := "(" + strings.Join(l.params, ", ") + ")"
+	if len(l.params) == 1 {
+		params = l.params[0]
+	}
+	if l.expr != nil {
+		l.m.indent = m.indent
+		if l.expr.kind == dAssign {
+			return params + " -> " + l.m.assignment(l.expr, true)
+		}
+		return params + " -> " + l.m.sub(coerce(l.expr, l.m.result), 1)
+	}
+	b := &bytes.Buffer{}
+	b.WriteString(params + " -> {\n")
+	l.m.writeStmts(b, m.indent+1, l.body)
+	b.WriteString(strings.Repeat("\t", m.indent) + "}")
+	return b.String()
+}
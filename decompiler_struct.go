@@ -0,0 +1,1483 @@
+package jclass
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type dstmtKind int
+
+const (
+	sExpr dstmtKind = iota
+	sReturn
+	sThrow
+	sIf
+	sWhile
+	sDoWhile
+	sFor
+	sSwitch
+	sTry
+	sBreak
+	sContinue
+	// 局部变量声明，初值在 e 中
+	sDecl
+	// 注释；text 为空的注释不输出
+	sComment
+	// monitorenter、monitorexit，无法还原为 synchronized 时以注释形式输出
+	sMonitor
+	sSynchronized
+)
+
+// 反编译得到的语句
+type dstmt struct {
+	kind dstmtKind
+	// 表达式、条件或 switch 的选择值
+	e    *dexpr
+	body []*dstmt
+	els  []*dstmt
+	// for 循环的初始化与更新语句
+	init    *dstmt
+	update  *dstmt
+	cases   []*dcase
+	catches []*dcatch
+	// 循环与 switch 的标签
+	label string
+	// break 与 continue 跳出或继续的循环、switch
+	target *dstmt
+	v      *dvar
+	text   string
+}
+
+type dcase struct {
+	// 为空表示 default
+	labels []string
+	def    bool
+	body   []*dstmt
+}
+
+type dcatch struct {
+	types []string
+	v     *dvar
+	body  []*dstmt
+}
+
+// 依次访问语句及其中嵌套的语句
+func walkStmts(stmts []*dstmt, f func(*dstmt)) {
+	for _, s := range stmts {
+		f(s)
+		if s.init != nil {
+			f(s.init)
+		}
+		if s.update != nil {
+			f(s.update)
+		}
+		walkStmts(s.body, f)
+		walkStmts(s.els, f)
+		for _, c := range s.cases {
+			walkStmts(c.body, f)
+		}
+		for _, c := range s.catches {
+			walkStmts(c.body, f)
+		}
+	}
+}
+
+// 自内向外改写各层语句列表
+func mapLists(stmts []*dstmt, f func([]*dstmt) []*dstmt) []*dstmt {
+	for _, s := range stmts {
+		s.body = mapLists(s.body, f)
+		s.els = mapLists(s.els, f)
+		for _, c := range s.cases {
+			c.body = mapLists(c.body, f)
+		}
+		for _, c := range s.catches {
+			c.body = mapLists(c.body, f)
+		}
+	}
+	return f(stmts)
+}
+
+// 自底向上改写语句中的全部表达式，不进入 lambda
+func rewriteExprs(stmts []*dstmt, f func(*dexpr) *dexpr) {
+	var rewrite func(e *dexpr) *dexpr
+	rewrite = func(e *dexpr) *dexpr {
+		if e == nil {
+			return nil
+		}
+		e.recv = rewrite(e.recv)
+		for i, arg := range e.args {
+			e.args[i] = rewrite(arg)
+		}
+		return f(e)
+	}
+	walkStmts(stmts, func(s *dstmt) {
+		s.e = rewrite(s.e)
+	})
+}
+
+func isBreak(s *dstmt, target *dstmt) bool {
+	return s.kind == sBreak && s.target == target
+}
+
+// 是否为 if (c) { break; } 的形式
+func isBreakIf(s *dstmt, target *dstmt) bool {
+	return s.kind == sIf && len(s.els) == 0 && len(s.body) == 1 && isBreak(s.body[0], target)
+}
+
+func hasJump(stmts []*dstmt, kind dstmtKind, target *dstmt) bool {
+	found := false
+	walkStmts(stmts, func(s *dstmt) {
+		if s.kind == kind && s.target == target {
+			found = true
+		}
+	})
+	return found
+}
+
+// 把 &&、|| 编译成的连续条件跳转合并为一个条件
+func (m *methodDecompiler) mergeConditions() {
+	for changed := true; changed; {
+		changed = false
+		for i := len(m.order) - 1; i >= 0; i-- {
+			a := m.order[i]
+			if a.merged || a.cond == nil || len(a.succs) != 2 {
+				continue
+			}
+			for j, b := range a.succs {
+				if b == a || b.merged || b.cond == nil || len(b.succs) != 2 || len(b.stmts) > 0 || b.caught != nil ||
+					len(b.preds) != 1 || !sameHandlers(a.b, b.b) {
+					continue
+				}
+				x := a.succs[1-j]
+				var cond *dexpr
+				var succs []*dblock
+				switch {
+				case j == 1 && b.succs[0] == x:
+					cond, succs = dbinary("||", a.cond, b.cond, "Z"), []*dblock{x, b.succs[1]}
+				case j == 1 && b.succs[1] == x:
+					cond, succs = dbinary("||", a.cond, negate(b.cond), "Z"), []*dblock{x, b.succs[0]}
+				case j == 0 && b.succs[1] == x:
+					cond, succs = dbinary("&&", a.cond, b.cond, "Z"), []*dblock{b.succs[0], x}
+				case j == 0 && b.succs[0] == x:
+					cond, succs = dbinary("&&", a.cond, negate(b.cond), "Z"), []*dblock{b.succs[1], x}
+				default:
+					continue
+				}
+				a.cond, a.succs = cond, succs
+				b.merged = true
+				changed = true
+				break
+			}
+		}
+	}
+	for _, db := range m.order {
+		db.preds = nil
+	}
+	for _, db := range m.order {
+		if !db.merged {
+			for _, s := range db.succs {
+				s.preds = append(s.preds, db)
+			}
+		}
+	}
+}
+
+func sameHandlers(a, b *BasicBlock) bool {
+	if len(a.Handlers) != len(b.Handlers) {
+		return false
+	}
+	for i := range a.Handlers {
+		if a.Handlers[i] != b.Handlers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type dloop struct {
+	header  *dblock
+	body    map[*dblock]bool
+	follow  *dblock
+	entered bool
+}
+
+type dtry struct {
+	start, end int
+	handlers   []*dhandler
+	opened     bool
+}
+
+type dhandler struct {
+	b     *dblock
+	types []string
+}
+
+// break 与 continue 的目标
+type dtarget struct {
+	stmt *dstmt
+	// continue 转到的块，switch 为 nil
+	header *dblock
+	follow *dblock
+}
+
+// try 块或异常处理器的范围，离开范围的跳转先记为占位的注释，确定后续块之后再补全
+type drange struct {
+	contains func(*dblock) bool
+	exits    []*dstmt
+	targets  []*dblock
+}
+
+// 把控制流图还原为结构化的语句
+type structurer struct {
+	m       *methodDecompiler
+	done    map[*dblock]bool
+	loops   map[*dblock]*dloop
+	tries   []*dtry
+	stops   map[*dblock]bool
+	targets []*dtarget
+	ranges  []*drange
+}
+
+func (m *methodDecompiler) structure() []*dstmt {
+	s := &structurer{
+		m:     m,
+		done:  make(map[*dblock]bool),
+		loops: make(map[*dblock]*dloop),
+		stops: make(map[*dblock]bool),
+	}
+	var blocks []*dblock
+	for _, db := range m.order {
+		if !db.merged {
+			blocks = append(blocks, db)
+		}
+	}
+	postDominators(blocks)
+	s.findLoops(blocks)
+	s.findTries()
+	return s.seq(m.order[0], nil)
+}
+
+// 后必经关系：在反向图上以虚拟出口为根计算必经关系（Cooper 等人的迭代算法），只考虑正常的控制流
+func postDominators(blocks []*dblock) {
+	n := len(blocks)
+	index := make(map[*dblock]int, n)
+	for i, b := range blocks {
+		index[b] = i
+	}
+	// 反向图的后序编号，虚拟出口为 n
+	po := make([]int, n+1)
+	for i := range po {
+		po[i] = -1
+	}
+	var order []int
+	visited := make([]bool, n+1)
+	var dfs func(v int)
+	dfs = func(v int) {
+		visited[v] = true
+		if v == n {
+			for i, b := range blocks {
+				if len(b.succs) == 0 && !visited[i] {
+					dfs(i)
+				}
+			}
+		} else {
+			for _, p := range blocks[v].preds {
+				if i, ok := index[p]; ok && !visited[i] {
+					dfs(i)
+				}
+			}
+		}
+		po[v] = len(order)
+		order = append(order, v)
+	}
+	dfs(n)
+
+	idom := make([]int, n+1)
+	for i := range idom {
+		idom[i] = -1
+	}
+	idom[n] = n
+	intersect := func(a, b int) int {
+		for a != b {
+			for po[a] < po[b] {
+				a = idom[a]
+			}
+			for po[b] < po[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(order) - 2; i >= 0; i-- {
+			v := order[i]
+			d := -1
+			var next []int
+			if len(blocks[v].succs) == 0 {
+				next = append(next, n)
+			}
+			for _, s := range blocks[v].succs {
+				if j, ok := index[s]; ok {
+					next = append(next, j)
+				}
+			}
+			for _, j := range next {
+				if idom[j] < 0 {
+					continue
+				}
+				if d < 0 {
+					d = j
+				} else {
+					d = intersect(j, d)
+				}
+			}
+			if d >= 0 && idom[v] != d {
+				idom[v] = d
+				changed = true
+			}
+		}
+	}
+	for i, b := range blocks {
+		if d := idom[i]; d >= 0 && d != n {
+			b.ipdom = blocks[d]
+		}
+	}
+}
+
+// 找出以回边确定的自然循环及其后续块
+func (s *structurer) findLoops(blocks []*dblock) {
+	epreds := make(map[*dblock][]*dblock)
+	for _, b := range blocks {
+		for _, h := range b.b.Handlers {
+			if hb := s.m.blocks[h]; hb != nil {
+				epreds[hb] = append(epreds[hb], b)
+			}
+		}
+	}
+	for _, h := range blocks {
+		var latches []*dblock
+		for _, p := range h.preds {
+			if h.b.Dominates(p.b) {
+				latches = append(latches, p)
+			}
+		}
+		if len(latches) == 0 {
+			continue
+		}
+		l := &dloop{header: h, body: map[*dblock]bool{h: true}}
+		stack := append([]*dblock(nil), latches...)
+		for len(stack) > 0 {
+			x := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if l.body[x] || !h.b.Dominates(x.b) {
+				continue
+			}
+			l.body[x] = true
+			stack = append(stack, x.preds...)
+			stack = append(stack, epreds[x]...)
+		}
+		// 后续块依次取循环头、回边所在块离开循环的后继，其次是偏移最小且不以 return、throw 结束的出口
+		exit := func(x *dblock) *dblock {
+			for _, succ := range x.succs {
+				if !l.body[succ] {
+					return succ
+				}
+			}
+			return nil
+		}
+		l.follow = exit(h)
+		for _, x := range latches {
+			if l.follow == nil {
+				l.follow = exit(x)
+			}
+		}
+		if l.follow == nil {
+			var exits []*dblock
+			for _, x := range blocks {
+				if l.body[x] {
+					for _, succ := range x.succs {
+						if !l.body[succ] {
+							exits = append(exits, succ)
+						}
+					}
+				}
+			}
+			sort.SliceStable(exits, func(i, j int) bool {
+				if exits[i].exit != exits[j].exit {
+					return !exits[i].exit
+				}
+				return exits[i].b.Start < exits[j].b.Start
+			})
+			if len(exits) > 0 && !exits[0].exit {
+				l.follow = exits[0]
+			}
+		}
+		s.loops[h] = l
+	}
+}
+
+// 按异常处理器合并异常表的各段范围，再把范围相同的处理器归为一个 try
+func (s *structurer) findTries() {
+	m := s.m
+	type span struct {
+		start, end int
+		types      []string
+	}
+	spans := make(map[*dblock]*span)
+	var handlers []*dblock
+	for _, e := range m.code.ExceptionTable {
+		h := m.blocks[m.g.BlockAt(int(e.HandlerPc))]
+		if h == nil {
+			continue
+		}
+		typ := "Throwable"
+		if e.CatchType != 0 {
+			typ = m.c.w.sourceName(m.cf.ClassNameAt(e.CatchType))
+		}
+		sp := spans[h]
+		if sp == nil {
+			sp = &span{start: int(e.StartPc), end: int(e.EndPc)}
+			spans[h] = sp
+			handlers = append(handlers, h)
+		}
+		if int(e.StartPc) < sp.start {
+			sp.start = int(e.StartPc)
+		}
+		if int(e.EndPc) > sp.end {
+			sp.end = int(e.EndPc)
+		}
+		dup := false
+		for _, t := range sp.types {
+			dup = dup || t == typ
+		}
+		if !dup {
+			sp.types = append(sp.types, typ)
+		}
+	}
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].b.Start < handlers[j].b.Start })
+	for _, h := range handlers {
+		sp := spans[h]
+		// synchronized 生成的处理器覆盖自身
+		if sp.start < h.b.Start && h.b.Start < sp.end {
+			sp.end = h.b.Start
+		}
+		if sp.start >= sp.end || m.blocks[m.g.BlockAt(sp.start)] == nil {
+			continue
+		}
+		var t *dtry
+		for _, o := range s.tries {
+			if o.start == sp.start && o.end == sp.end {
+				t = o
+			}
+		}
+		if t == nil {
+			t = &dtry{start: sp.start, end: sp.end}
+			s.tries = append(s.tries, t)
+		}
+		t.handlers = append(t.handlers, &dhandler{b: h, types: sp.types})
+	}
+	sort.SliceStable(s.tries, func(i, j int) bool {
+		if s.tries[i].start != s.tries[j].start {
+			return s.tries[i].start < s.tries[j].start
+		}
+		return s.tries[i].end > s.tries[j].end
+	})
+}
+
+func (s *structurer) tryAt(b *dblock) *dtry {
+	for _, t := range s.tries {
+		if !t.opened && t.start == b.b.Start {
+			return t
+		}
+	}
+	return nil
+}
+
+// 沿正常控制流从 a 能否到达 b，不经过外层循环与 switch 的跳转目标
+func (s *structurer) reaches(a, b *dblock) bool {
+	if a == b {
+		return true
+	}
+	blocked := make(map[*dblock]bool)
+	for _, t := range s.targets {
+		blocked[t.header] = true
+		blocked[t.follow] = true
+	}
+	visited := map[*dblock]bool{a: true}
+	stack := []*dblock{a}
+	for len(stack) > 0 {
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if blocked[x] {
+			continue
+		}
+		for _, succ := range x.succs {
+			if succ == b {
+				return true
+			}
+			if !visited[succ] {
+				visited[succ] = true
+				stack = append(stack, succ)
+			}
+		}
+	}
+	return false
+}
+
+// 是否为外层循环的 continue、break 目标或非最内层 switch 的后续块
+func (s *structurer) isTarget(b *dblock) bool {
+	for i, t := range s.targets {
+		if t.header == b || t.follow == b && (t.header != nil || i != len(s.targets)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// 从 b 开始顺序输出，直到 follow、跳转目标或已输出的块
+func (s *structurer) seq(b, follow *dblock) []*dstmt {
+	var stmts []*dstmt
+	for b != nil && b != follow && !s.stops[b] {
+		if st := s.leave(b); st != nil {
+			return append(stmts, st)
+		}
+		if st := s.jump(b); st != nil {
+			return append(stmts, st)
+		}
+		if s.done[b] {
+			return append(stmts, &dstmt{kind: sComment, text: "goto " + strconv.Itoa(b.b.Start)})
+		}
+		var ss []*dstmt
+		ss, b = s.block(b)
+		stmts = append(stmts, ss...)
+	}
+	return stmts
+}
+
+// 从 b 本身开始输出，即使 b 是跳转目标
+func (s *structurer) from(b, follow *dblock) []*dstmt {
+	stmts, next := s.block(b)
+	return append(stmts, s.seq(next, follow)...)
+}
+
+func (s *structurer) leave(b *dblock) *dstmt {
+	for i := len(s.ranges) - 1; i >= 0; i-- {
+		r := s.ranges[i]
+		if !r.contains(b) {
+			st := &dstmt{kind: sComment}
+			r.exits = append(r.exits, st)
+			r.targets = append(r.targets, b)
+			return st
+		}
+	}
+	return nil
+}
+
+func (s *structurer) jump(b *dblock) *dstmt {
+	for i := len(s.targets) - 1; i >= 0; i-- {
+		t := s.targets[i]
+		if t.header == b {
+			return &dstmt{kind: sContinue, target: t.stmt}
+		}
+		if t.follow == b {
+			return &dstmt{kind: sBreak, target: t.stmt}
+		}
+	}
+	return nil
+}
+
+func (s *structurer) block(b *dblock) ([]*dstmt, *dblock) {
+	s.done[b] = true
+	l := s.loops[b]
+	if t := s.tryAt(b); t != nil && (l == nil || l.entered || s.tryContains(t, l)) {
+		return s.try(b, t)
+	}
+	if l != nil && !l.entered {
+		return s.loop(l)
+	}
+	stmts := append([]*dstmt(nil), b.stmts...)
+	switch {
+	case b.sw != nil:
+		st, next := s.switchStmt(b)
+		return append(stmts, st), next
+	case b.cond != nil:
+		ss, next := s.ifElse(b)
+		return append(stmts, ss...), next
+	case b.exit || len(b.succs) == 0:
+		return stmts, nil
+	}
+	return stmts, b.succs[0]
+}
+
+func (s *structurer) tryContains(t *dtry, l *dloop) bool {
+	for b := range l.body {
+		if b.b.Start < t.start || b.b.Start >= t.end {
+			return false
+		}
+	}
+	return true
+}
+
+// if 语句：两个分支在直接后必经块汇合；没有汇合点时，不能到达另一分支的分支写在 if 中
+func (s *structurer) ifElse(b *dblock) ([]*dstmt, *dblock) {
+	t, f, c := b.succs[0], b.succs[1], b.cond
+	// 偏移较小的分支通常是源码中的 then 分支
+	if t.b.Start > f.b.Start {
+		t, f, c = f, t, negate(c)
+	}
+	merge := b.ipdom
+	if merge != nil && s.isTarget(merge) {
+		merge = nil
+	}
+	if merge != nil {
+		if merge == t {
+			t, f, c = f, t, negate(c)
+		}
+		if merge == f {
+			return ifStmt(c, s.seq(t, merge), nil), merge
+		}
+		body := s.seq(t, merge)
+		return ifStmt(c, body, s.seq(f, merge)), merge
+	}
+	// 跳出或继续循环的分支写成 if (c) break; 的形式
+	switch {
+	case s.jump(f) != nil:
+		return ifStmt(negate(c), s.seq(f, nil), nil), t
+	case s.jump(t) != nil:
+		return ifStmt(c, s.seq(t, nil), nil), f
+	case !s.reaches(t, f):
+		return ifStmt(c, s.seq(t, nil), nil), f
+	case !s.reaches(f, t):
+		return ifStmt(negate(c), s.seq(f, nil), nil), t
+	}
+	body := s.seq(t, nil)
+	return ifStmt(c, body, s.seq(f, nil)), nil
+}
+
+func ifStmt(c *dexpr, body, els []*dstmt) []*dstmt {
+	switch {
+	case len(body) == 0 && len(els) == 0:
+		if hasSideEffect(c) {
+			return []*dstmt{{kind: sExpr, e: c}}
+		}
+		return nil
+	case len(body) == 0:
+		c, body, els = negate(c), els, nil
+	}
+	return []*dstmt{{kind: sIf, e: c, body: body, els: els}}
+}
+
+func (s *structurer) loop(l *dloop) ([]*dstmt, *dblock) {
+	l.entered = true
+	st := &dstmt{kind: sWhile, e: dlit("true", "Z")}
+	s.targets = append(s.targets, &dtarget{stmt: st, header: l.header, follow: l.follow})
+	st.body = s.from(l.header, nil)
+	s.targets = s.targets[:len(s.targets)-1]
+
+	body := stripContinue(st.body, st)
+	switch n := len(body); {
+	case n > 0 && isBreakIf(body[0], st):
+		st.e, body = negate(body[0].e), body[1:]
+	case n > 0 && isBreakIf(body[n-1], st) && !hasJump(body[:n-1], sContinue, st):
+		st.kind, st.e, body = sDoWhile, negate(body[n-1].e), body[:n-1]
+	}
+	st.body = body
+	return []*dstmt{st}, l.follow
+}
+
+// 去掉循环体末尾多余的 continue
+func stripContinue(stmts []*dstmt, loop *dstmt) []*dstmt {
+	n := len(stmts)
+	if n == 0 {
+		return stmts
+	}
+	switch last := stmts[n-1]; {
+	case last.kind == sContinue && last.target == loop:
+		return stmts[:n-1]
+	case last.kind == sIf:
+		last.body = stripContinue(last.body, loop)
+		last.els = stripContinue(last.els, loop)
+		if len(last.body) == 0 {
+			return append(stmts[:n-1], ifStmt(last.e, last.els, nil)...)
+		}
+	case last.kind == sTry:
+		last.body = stripContinue(last.body, loop)
+		for _, c := range last.catches {
+			c.body = stripContinue(c.body, loop)
+		}
+	}
+	return stmts
+}
+
+func (s *structurer) switchStmt(b *dblock) (*dstmt, *dblock) {
+	sw := b.sw
+	st := &dstmt{kind: sSwitch, e: sw.e}
+	follow := b.ipdom
+	if follow != nil && s.isTarget(follow) {
+		follow = nil
+	}
+
+	label := func(k int32) string {
+		if sw.e.vtype() == "C" && k >= 0 && k <= 0xffff {
+			return javaCharLiteral(k)
+		}
+		return strconv.Itoa(int(k))
+	}
+	if e, names := s.m.enumSwitch(sw); e != nil {
+		st.e = e
+		label = func(k int32) string { return names[k] }
+	}
+
+	var cases []*dcase
+	starts := make(map[*dcase]*dblock)
+	byBlock := make(map[*dblock]*dcase)
+	add := func(t *dblock) *dcase {
+		c := byBlock[t]
+		if c == nil {
+			c = &dcase{}
+			byBlock[t] = c
+			starts[c] = t
+			cases = append(cases, c)
+		}
+		return c
+	}
+	for i, k := range sw.keys {
+		if t := sw.targets[i]; t != nil && t != follow {
+			c := add(t)
+			c.labels = append(c.labels, label(k))
+		}
+	}
+	if sw.def != nil && sw.def != follow {
+		add(sw.def).def = true
+	}
+	sort.SliceStable(cases, func(i, j int) bool { return starts[cases[i]].b.Start < starts[cases[j]].b.Start })
+
+	stops := s.stops
+	s.stops = make(map[*dblock]bool)
+	for b := range byBlock {
+		s.stops[b] = true
+	}
+	s.targets = append(s.targets, &dtarget{stmt: st, follow: follow})
+	for _, c := range cases {
+		c.body = s.from(starts[c], nil)
+	}
+	s.targets = s.targets[:len(s.targets)-1]
+	s.stops = stops
+
+	if n := len(cases); n > 0 {
+		if last := cases[n-1]; len(last.body) > 0 && isBreak(last.body[len(last.body)-1], st) {
+			last.body = last.body[:len(last.body)-1]
+		}
+	}
+	st.cases = cases
+	return st, follow
+}
+
+func (s *structurer) try(b *dblock, t *dtry) ([]*dstmt, *dblock) {
+	t.opened = true
+	st := &dstmt{kind: sTry}
+	// javac 把 return 指令排除在范围之外，只从范围内接过操作数栈的块视为仍在 try 中
+	var contains func(x *dblock) bool
+	contains = func(x *dblock) bool {
+		if x.b.Start >= t.start && x.b.Start < t.end {
+			return true
+		}
+		return x.in != nil && x.caught == nil && len(x.preds) == 1 && contains(x.preds[0])
+	}
+	r := &drange{contains: contains}
+	s.ranges = append(s.ranges, r)
+	st.body = s.from(b, nil)
+	s.ranges = s.ranges[:len(s.ranges)-1]
+
+	rs := []*drange{r}
+	for _, h := range t.handlers {
+		if s.done[h.b] {
+			continue
+		}
+		hb := h.b.b
+		hr := &drange{contains: func(x *dblock) bool { return hb.Dominates(x.b) }}
+		s.ranges = append(s.ranges, hr)
+		body := s.from(h.b, nil)
+		s.ranges = s.ranges[:len(s.ranges)-1]
+		rs = append(rs, hr)
+
+		// 处理器开头把异常保存到局部变量
+		v := h.b.caught
+		if len(body) > 0 && body[0].kind == sExpr && body[0].e.kind == dAssign {
+			target, value := body[0].e.args[0], body[0].e.args[1]
+			if target.kind == dLocal && value.kind == dLocal && value.v == v {
+				v, body = target.v, body[1:]
+				v.declared = true
+			}
+		}
+		st.catches = append(st.catches, &dcatch{types: h.types, v: v, body: body})
+	}
+
+	// 后续块取离开 try 与各处理器次数最多的目标，只含 goto 的块以其目标计
+	counts := make(map[*dblock]int)
+	var follow *dblock
+	for _, r := range rs {
+		for i, target := range r.targets {
+			for len(target.stmts) == 0 && target.cond == nil && target.sw == nil && !target.exit && len(target.succs) == 1 &&
+				target.in == nil && s.loops[target] == nil && s.tryAt(target) == nil && !s.done[target] && len(target.preds) <= len(rs) {
+				target = target.succs[0]
+			}
+			r.targets[i] = target
+			counts[target]++
+			if follow == nil || counts[target] > counts[follow] || counts[target] == counts[follow] && target.b.Start < follow.b.Start {
+				follow = target
+			}
+		}
+	}
+	for _, r := range rs {
+		for i, target := range r.targets {
+			switch st := s.jump(target); {
+			case target == follow:
+			case st != nil:
+				*r.exits[i] = *st
+			default:
+				r.exits[i].text = "goto " + strconv.Itoa(target.b.Start)
+			}
+		}
+	}
+	return []*dstmt{st}, follow
+}
+
+// 枚举 switch：以 $SwitchMap$ 数组按 ordinal() 映射的序号还原为枚举常量
+func (m *methodDecompiler) enumSwitch(sw *dswitch) (*dexpr, map[int32]string) {
+	e := sw.e
+	if e.kind != dIndex {
+		return nil, nil
+	}
+	array, index := e.args[0], e.args[1]
+	class := m.fieldClasses[array]
+	if class == "" || index.kind != dCall || index.text != "ordinal" || index.recv == nil {
+		return nil, nil
+	}
+	names := m.c.switchMap(class, array.text)
+	for _, k := range sw.keys {
+		if names[k] == "" {
+			return nil, nil
+		}
+	}
+	return index.recv, names
+}
+
+func (m *methodDecompiler) simplify(stmts []*dstmt) []*dstmt {
+	stmts = mapLists(stmts, foldTernaries)
+	stmts = mapLists(stmts, m.stringSwitches)
+	stmts = mapLists(stmts, synchronizedBlocks)
+	stmts = m.inlineTemps(stmts)
+	rewriteExprs(stmts, simplifyBoolean)
+	stmts = mapLists(stmts, forLoops)
+	if n := len(stmts); n > 0 && stmts[n-1].kind == sReturn && stmts[n-1].e == nil {
+		stmts = stmts[:n-1]
+	}
+	stmts = m.declare(stmts)
+	m.labels(stmts, nil, nil)
+	return stmts
+}
+
+func assignedLocal(s *dstmt) *dvar {
+	if s.kind == sExpr && s.e.kind == dAssign && s.e.args[0].kind == dLocal {
+		return s.e.args[0].v
+	}
+	return nil
+}
+
+// if (c) { t = a; } else { t = b; } 合并为 t = c ? a : b
+func foldTernaries(stmts []*dstmt) []*dstmt {
+	for i, s := range stmts {
+		if s.kind != sIf || len(s.body) != 1 || len(s.els) != 1 {
+			continue
+		}
+		v := assignedLocal(s.body[0])
+		if v == nil || !v.temp || assignedLocal(s.els[0]) != v {
+			continue
+		}
+		a, b := s.body[0].e.args[1], s.els[0].e.args[1]
+		typ := a.vtype()
+		if typ == "" {
+			typ = b.vtype()
+		}
+		t := &dexpr{kind: dTernary, typ: typ, args: []*dexpr{s.e, a, b}}
+		stmts[i] = &dstmt{kind: sExpr, e: &dexpr{kind: dAssign, typ: typ, args: []*dexpr{dload(v), t}}}
+	}
+	return stmts
+}
+
+// javac 把字符串 switch 编译为先按 hashCode() 与 equals() 求出序号，再按序号 switch
+func (m *methodDecompiler) stringSwitches(stmts []*dstmt) []*dstmt {
+	for i := 0; i+1 < len(stmts); i++ {
+		s := stmts[i]
+		if s.kind != sSwitch || s.e.kind != dCall || s.e.text != "hashCode" || len(s.e.args) != 0 || s.e.recv == nil || s.e.recv.kind != dLocal {
+			continue
+		}
+		str := s.e.recv.v
+		var index *dvar
+		values := make(map[string]string)
+		ok := true
+		for _, c := range s.cases {
+			body := c.body
+			if n := len(body); n > 0 && isBreak(body[n-1], s) {
+				body = body[:n-1]
+			}
+			if len(body) != 1 || c.def {
+				ok = false
+			}
+			for len(body) == 1 && ok {
+				b := body[0]
+				if b.kind != sIf || b.e.kind != dCall || b.e.text != "equals" || b.e.recv == nil || b.e.recv.kind != dLocal || b.e.recv.v != str ||
+					len(b.e.args) != 1 || b.e.args[0].kind != dLiteral || len(b.body) != 1 || assignedLocal(b.body[0]) == nil {
+					ok = false
+					break
+				}
+				v := assignedLocal(b.body[0])
+				if index != nil && v != index || b.body[0].e.args[1].kind != dLiteral {
+					ok = false
+					break
+				}
+				index = v
+				values[b.body[0].e.args[1].text] = b.e.args[0].text
+				body = b.els
+			}
+			ok = ok && len(body) == 0
+		}
+		next := stmts[i+1]
+		if !ok || index == nil || next.kind != sSwitch || next.e.kind != dLocal || next.e.v != index {
+			continue
+		}
+		for _, c := range next.cases {
+			for j, l := range c.labels {
+				if values[l] == "" {
+					ok = false
+				}
+				c.labels[j] = values[l]
+			}
+		}
+		if !ok {
+			continue
+		}
+		next.e = dload(str)
+		rest := append([]*dstmt{next}, stmts[i+2:]...)
+		stmts = stmts[:i]
+		// 去掉序号变量的初值 -1
+		if n := len(stmts); n > 0 && assignedLocal(stmts[n-1]) == index {
+			stmts = stmts[:n-1]
+		}
+		if _, ok := m.fallback(str); ok {
+			str.temp = true
+		}
+		stmts = append(stmts, rest...)
+	}
+	return stmts
+}
+
+// 变量是否为没有局部变量表项、按下标生成的变量
+func (m *methodDecompiler) fallback(v *dvar) (string, bool) {
+	for k, o := range m.vars {
+		if o == v {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// javac 把 synchronized 编译为 monitorenter 之后的 try，异常时 monitorexit 再抛出
+func synchronizedBlocks(stmts []*dstmt) []*dstmt {
+	for i := 0; i+1 < len(stmts); i++ {
+		enter, t := stmts[i], stmts[i+1]
+		if enter.kind != sMonitor || enter.text != "monitorenter" || t.kind != sTry || len(t.catches) != 1 {
+			continue
+		}
+		lock, e := enter.e, enter.e
+		if e.kind == dAssign && e.args[0].kind == dLocal {
+			lock, e = e.args[0], e.args[1]
+		}
+		isExit := func(s *dstmt) bool {
+			return s.kind == sMonitor && s.text == "monitorexit" && sameExpr(s.e, lock)
+		}
+		c := t.catches[0]
+		if len(c.body) != 2 || !isExit(c.body[0]) || c.body[1].kind != sThrow || c.body[1].e.kind != dLocal || c.body[1].e.v != c.v {
+			continue
+		}
+		body := mapLists(t.body, func(list []*dstmt) []*dstmt {
+			var rs []*dstmt
+			for _, s := range list {
+				if !isExit(s) {
+					rs = append(rs, s)
+				}
+			}
+			return rs
+		})
+		stmts[i] = &dstmt{kind: sSynchronized, e: e, body: body}
+		stmts = append(stmts[:i+1], stmts[i+2:]...)
+	}
+	return stmts
+}
+
+// 统计局部变量的读取与赋值次数
+func countLocals(stmts []*dstmt) (uses, assigns map[*dvar]int) {
+	uses, assigns = make(map[*dvar]int), make(map[*dvar]int)
+	var count func(e *dexpr)
+	count = func(e *dexpr) {
+		if e == nil {
+			return
+		}
+		switch {
+		case e.kind == dLocal:
+			uses[e.v]++
+			return
+		case e.kind == dAssign && e.args[0].kind == dLocal:
+			assigns[e.args[0].v]++
+			count(e.args[1])
+			return
+		}
+		count(e.recv)
+		for _, arg := range e.args {
+			count(arg)
+		}
+	}
+	walkStmts(stmts, func(s *dstmt) {
+		count(s.e)
+	})
+	return
+}
+
+// 只赋值一次、只在下一条语句中使用一次的临时变量替换为其值，前提是不改变求值顺序
+func (m *methodDecompiler) inlineTemps(stmts []*dstmt) []*dstmt {
+	for changed := true; changed; {
+		changed = false
+		uses, assigns := countLocals(stmts)
+		stmts = mapLists(stmts, func(list []*dstmt) []*dstmt {
+			for i := len(list) - 1; i >= 0; i-- {
+				v := assignedLocal(list[i])
+				if v == nil || !v.temp || assigns[v] != 1 {
+					continue
+				}
+				value := list[i].e.args[1]
+				if uses[v] == 0 {
+					// 值未被使用的临时变量
+					if hasSideEffect(value) {
+						list[i] = &dstmt{kind: sExpr, e: value}
+					} else {
+						list = append(list[:i], list[i+1:]...)
+					}
+					changed = true
+					continue
+				}
+				if uses[v] != 1 || i+1 >= len(list) {
+					continue
+				}
+				next := list[i+1]
+				switch next.kind {
+				case sExpr, sReturn, sThrow, sIf, sSwitch, sMonitor, sDecl:
+				default:
+					continue
+				}
+				if e, state := substitute(next.e, v, value, !hasSideEffect(value)); state == substituted {
+					next.e = e
+					list = append(list[:i], list[i+1:]...)
+					changed = true
+				}
+			}
+			return list
+		})
+	}
+	return stmts
+}
+
+const (
+	searching = iota
+	substituted
+	blocked
+)
+
+// 按求值顺序在 e 中查找对 v 的读取并替换为 value；在此之前求值的部分有副作用（value 有副作用时读取内存也算）则放弃
+func substitute(e *dexpr, v *dvar, value *dexpr, pure bool) (*dexpr, int) {
+	if e == nil {
+		return nil, searching
+	}
+	switch e.kind {
+	case dLiteral, dRaw:
+		return e, searching
+	case dLocal:
+		if e.v == v {
+			return value, substituted
+		}
+		return e, searching
+	case dLambda:
+		if e.lambda != nil {
+			return e, blocked
+		}
+	}
+	children := make([]**dexpr, 0, len(e.args)+1)
+	if e.recv != nil {
+		children = append(children, &e.recv)
+	}
+	for i := range e.args {
+		children = append(children, &e.args[i])
+	}
+	// 赋值目标为局部变量时不是读取
+	if e.kind == dAssign && e.args[0].kind == dLocal {
+		children = children[1:]
+	}
+	for i, c := range children {
+		// 条件求值的部分：value 有副作用时不能移入
+		conditional := e.kind == dTernary && i > 0 || e.kind == dBinary && (e.text == "&&" || e.text == "||") && i > 0
+		r, state := substitute(*c, v, value, pure)
+		switch {
+		case state == substituted && conditional && !pure:
+			return e, blocked
+		case state == substituted:
+			*c = r
+			return e, substituted
+		case state == blocked:
+			return e, blocked
+		}
+	}
+	switch e.kind {
+	case dCall, dAssign, dPostfix, dLambda:
+		return e, blocked
+	case dNew:
+		if !e.uninit {
+			return e, blocked
+		}
+	case dField, dIndex:
+		if !pure {
+			return e, blocked
+		}
+	}
+	return e, searching
+}
+
+// (c ? 1 : 0) != 0、b == false 之类的比较还原为布尔表达式
+func simplifyBoolean(e *dexpr) *dexpr {
+	if e.kind != dBinary || e.text != "==" && e.text != "!=" {
+		return e
+	}
+	l, r := e.args[0], e.args[1]
+	if l.kind == dLiteral {
+		l, r = r, l
+	}
+	if r.kind != dLiteral || r.text != "0" && r.text != "1" && r.text != "false" && r.text != "true" {
+		return e
+	}
+	b := l
+	if l.kind == dTernary {
+		b = coerce(l, "Z")
+		if b.kind == dTernary {
+			return e
+		}
+	} else if l.vtype() != "Z" {
+		return e
+	}
+	if (e.text == "==") == (r.text == "0" || r.text == "false") {
+		return negate(b)
+	}
+	return b
+}
+
+// i = 0; while (i < n) { ...; i++; } 还原为 for 循环；循环体中有 continue 时不转换
+func forLoops(stmts []*dstmt) []*dstmt {
+	for i := 1; i < len(stmts); i++ {
+		s := stmts[i]
+		n := len(s.body)
+		if s.kind != sWhile || n == 0 || s.label != "" {
+			continue
+		}
+		v := assignedLocal(stmts[i-1])
+		if v == nil || v.temp {
+			continue
+		}
+		update := s.body[n-1]
+		if update.kind != sExpr || !(update.e.kind == dAssign || update.e.kind == dPostfix) ||
+			update.e.args[0].kind != dLocal || update.e.args[0].v != v {
+			continue
+		}
+		if !containsExpr(s.e, localWrites(v)) || hasJump(s.body, sContinue, s) {
+			continue
+		}
+		s.kind, s.init, s.update, s.body = sFor, stmts[i-1], update, s.body[:n-1]
+		stmts = append(stmts[:i-1], stmts[i:]...)
+		i--
+	}
+	return stmts
+}
+
+// 变量的一处使用：自外向内每层语句列表中的位置
+type dpos struct {
+	scope, index int
+}
+
+// 声明局部变量：声明在包含其全部使用的最内层语句列表中，能合并时与第一次赋值合并
+func (m *methodDecompiler) declare(stmts []*dstmt) []*dstmt {
+	type scope struct {
+		list *[]*dstmt
+		// for 循环的初始化部分所在的虚拟作用域
+		loop *dstmt
+	}
+	var scopes []*scope
+	var vars []*dvar
+	paths := make(map[*dvar][][]dpos)
+
+	var walk func(list *[]*dstmt, path []dpos)
+	use := func(e *dexpr, path []dpos) {
+		containsExpr(e, func(x *dexpr) bool {
+			if x.kind == dLocal && !x.v.declared {
+				if paths[x.v] == nil {
+					vars = append(vars, x.v)
+				}
+				paths[x.v] = append(paths[x.v], path)
+			}
+			return false
+		})
+	}
+	child := func(path []dpos, scope, index int) []dpos {
+		return append(append([]dpos(nil), path...), dpos{scope, index})
+	}
+	walk = func(list *[]*dstmt, path []dpos) {
+		id := len(scopes)
+		scopes = append(scopes, &scope{list: list})
+		for i, s := range *list {
+			p := child(path, id, i)
+			if s.kind == sFor {
+				loop := len(scopes)
+				scopes = append(scopes, &scope{loop: s})
+				use(s.init.e, child(p, loop, 0))
+				inner := child(p, loop, 1)
+				use(s.e, inner)
+				use(s.update.e, inner)
+				walk(&s.body, inner)
+				continue
+			}
+			use(s.e, p)
+			walk(&s.body, p)
+			walk(&s.els, p)
+			for _, c := range s.cases {
+				walk(&c.body, p)
+			}
+			for _, c := range s.catches {
+				walk(&c.body, p)
+			}
+		}
+	}
+	walk(&stmts, nil)
+
+	type insertion struct {
+		index int
+		s     *dstmt
+	}
+	inserts := make(map[*[]*dstmt][]insertion)
+	for _, v := range vars {
+		ps := paths[v]
+		k := 0
+		for {
+			same := true
+			for _, p := range ps {
+				same = same && len(p) > k+1 && p[k].index == ps[0][k].index && p[k+1].scope == ps[0][k+1].scope
+			}
+			if !same {
+				break
+			}
+			k++
+		}
+		first := ps[0][k].index
+		for _, p := range ps {
+			if p[k].index < first {
+				first = p[k].index
+			}
+		}
+		v.declared = true
+		sc := scopes[ps[0][k].scope]
+		if sc.loop != nil {
+			if first == 0 && assignedLocal(sc.loop.init) == v {
+				sc.loop.init = &dstmt{kind: sDecl, v: v, e: sc.loop.init.e.args[1]}
+				continue
+			}
+			// 在 for 语句之前声明
+			k--
+			sc = scopes[ps[0][k].scope]
+			first = ps[0][k].index
+		}
+		list := *sc.list
+		if s := list[first]; assignedLocal(s) == v && !containsExpr(s.e.args[1], localWrites(v)) {
+			direct := false
+			for _, p := range ps {
+				direct = direct || len(p) == k+1 && p[k].index == first
+			}
+			if direct {
+				list[first] = &dstmt{kind: sDecl, v: v, e: s.e.args[1]}
+				continue
+			}
+		}
+		inserts[sc.list] = append(inserts[sc.list], insertion{first, &dstmt{kind: sDecl, v: v}})
+	}
+	for list, ins := range inserts {
+		sort.SliceStable(ins, func(i, j int) bool { return ins[i].index > ins[j].index })
+		for _, in := range ins {
+			l := *list
+			l = append(l[:in.index], append([]*dstmt{in.s}, l[in.index:]...)...)
+			*list = l
+		}
+	}
+	return stmts
+}
+
+// 为跳出或继续非最内层循环、switch 的 break 与 continue 加标签
+func (m *methodDecompiler) labels(stmts []*dstmt, breakable, loop *dstmt) {
+	for _, s := range stmts {
+		switch s.kind {
+		case sBreak:
+			if s.target != breakable && s.target.label == "" {
+				s.target.label = m.fresh("label")
+			}
+		case sContinue:
+			if s.target != loop && s.target.label == "" {
+				s.target.label = m.fresh("label")
+			}
+		case sWhile, sDoWhile, sFor:
+			m.labels(s.body, s, s)
+			continue
+		case sSwitch:
+			for _, c := range s.cases {
+				m.labels(c.body, s, loop)
+			}
+			continue
+		}
+		m.labels(s.body, breakable, loop)
+		m.labels(s.els, breakable, loop)
+		for _, c := range s.catches {
+			m.labels(c.body, breakable, loop)
+		}
+	}
+}
+
+// 局部变量声明的类型，有泛型签名时使用签名
+func (m *methodDecompiler) declType(v *dvar) string {
+	if v.sig != "" {
+		if t, err := ParseFieldSignature(v.sig); err == nil {
+			return m.c.w.typeName(t)
+		}
+	}
+	if v.typ == "" {
+		return m.c.w.sourceName("java/lang/Object")
+	}
+	return m.c.w.descriptorType(v.typ)
+}
+
+// 不带分号的简单语句
+func (m *methodDecompiler) simpleStmt(s *dstmt) string {
+	switch s.kind {
+	case sDecl:
+		if s.e == nil {
+			return m.declType(s.v) + " " + s.v.name
+		}
+		return m.declType(s.v) + " " + s.v.name + " = " + m.sub(coerce(s.e, s.v.typ), 1)
+	case sExpr:
+		if s.e.kind == dAssign {
+			return m.assignment(s.e, true)
+		}
+		return m.expr(s.e)
+	}
+	return ""
+}
+
+func (m *methodDecompiler) writeStmts(b *bytes.Buffer, indent int, stmts []*dstmt) {
+	line := func(indent int, s string) {
+		b.WriteString(strings.Repeat("\t", indent))
+		b.WriteString(s)
+		b.WriteString("\n")
+	}
+	prefix := func(s *dstmt) string {
+		if s.label != "" {
+			return s.label + ": "
+		}
+		return ""
+	}
+	jump := func(kind string, s *dstmt) string {
+		if s.target != nil && s.target.label != "" {
+			return kind + " " + s.target.label + ";"
+		}
+		return kind + ";"
+	}
+	m.indent = indent
+	for _, s := range stmts {
+		switch s.kind {
+		case sExpr, sDecl:
+			line(indent, m.simpleStmt(s)+";")
+		case sReturn:
+			if s.e == nil {
+				line(indent, "return;")
+			} else {
+				line(indent, "return "+m.exprAs(s.e, m.result)+";")
+			}
+		case sThrow:
+			line(indent, "throw "+m.expr(s.e)+";")
+		case sIf:
+			line(indent, "if ("+m.expr(s.e)+") {")
+			m.writeStmts(b, indent+1, s.body)
+			els := s.els
+			for len(els) == 1 && els[0].kind == sIf {
+				line(indent, "} else if ("+m.expr(els[0].e)+") {")
+				m.writeStmts(b, indent+1, els[0].body)
+				els = els[0].els
+			}
+			if len(els) > 0 {
+				line(indent, "} else {")
+				m.writeStmts(b, indent+1, els)
+			}
+			line(indent, "}")
+		case sWhile:
+			line(indent, prefix(s)+"while ("+m.expr(s.e)+") {")
+			m.writeStmts(b, indent+1, s.body)
+			line(indent, "}")
+		case sDoWhile:
+			line(indent, prefix(s)+"do {")
+			m.writeStmts(b, indent+1, s.body)
+			line(indent, "} while ("+m.expr(s.e)+");")
+		case sFor:
+			line(indent, prefix(s)+"for ("+m.simpleStmt(s.init)+"; "+m.expr(s.e)+"; "+m.simpleStmt(s.update)+") {")
+			m.writeStmts(b, indent+1, s.body)
+			line(indent, "}")
+		case sSwitch:
+			line(indent, prefix(s)+"switch ("+m.expr(s.e)+") {")
+			for _, c := range s.cases {
+				for _, l := range c.labels {
+					line(indent+1, "case "+l+":")
+				}
+				if c.def {
+					line(indent+1, "default:")
+				}
+				m.writeStmts(b, indent+2, c.body)
+			}
+			line(indent, "}")
+		case sTry:
+			line(indent, "try {")
+			m.writeStmts(b, indent+1, s.body)
+			for _, c := range s.catches {
+				line(indent, "} catch ("+strings.Join(c.types, " | ")+" "+c.v.name+") {")
+				m.writeStmts(b, indent+1, c.body)
+			}
+			line(indent, "}")
+		case sBreak:
+			line(indent, jump("break", s))
+		case sContinue:
+			line(indent, jump("continue", s))
+		case sComment:
+			if s.text != "" {
+				line(indent, "// "+s.text)
+			}
+		case sSynchronized:
+			line(indent, "synchronized ("+m.expr(s.e)+") {")
+			m.writeStmts(b, indent+1, s.body)
+			line(indent, "}")
+		case sMonitor:
+			line(indent, "// "+s.text+"("+m.expr(s.e)+")")
+		}
+		m.indent = indent
+	}
+}
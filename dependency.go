@@ -0,0 +1,354 @@
+package jclass
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 一个类对另一个类的依赖
+type Dependency struct {
+	// 被依赖类的内部类名
+	Target string
+	// 依赖产生的位置，如被引用的成员 lib/B.foo()V、字段 x、注解 @lib/Ann 等
+	Ref string
+}
+
+// 从常量池、描述符、签名、注解、throws 子句与异常表中收集本类依赖的所有类，不含自身
+func (cf *ClassFile) Dependencies() []*Dependency {
+	self := cf.ThisClassString()
+	seen := make(map[Dependency]bool)
+	var rs []*Dependency
+
+	add := func(ref string, classes ...string) {
+		for _, class := range classes {
+			class = elementClass(class)
+			if class == "" || class == self {
+				continue
+			}
+			dep := Dependency{Target: class, Ref: ref}
+			if !seen[dep] {
+				seen[dep] = true
+				rs = append(rs, &dep)
+			}
+		}
+	}
+	addAnnotations := func(ref string, anns []*Annotation) {
+		for _, ann := range anns {
+			add("@"+ann.TypeName()+" on "+ref, ann.Classes()...)
+		}
+	}
+	addSignature := func(ref, sig string) {
+		if sig != "" {
+			add(ref, DescriptorClasses(sig)...)
+		}
+	}
+
+	for i := 1; i < len(cf.ConstantPool); i++ {
+		index := uint16(i)
+		info := cf.ConstantPool[i]
+		if info == nil {
+			continue
+		}
+
+		switch info.Tag {
+		case 7:
+			class := cf.ClassNameAt(index)
+			add(elementClass(class), class)
+
+		case 9, 10, 11:
+			ref := cf.ReferenceAt(index)
+			add(ref.String(), ref.Class)
+			add(ref.String(), DescriptorClasses(ref.Descriptor)...)
+
+		case 16:
+			desc := cf.Utf8At((*ConstantMethodTypeInfo)(info).DescriptorIndex())
+			add("MethodType "+desc, DescriptorClasses(desc)...)
+
+		case 17, 18:
+			// CONSTANT_Dynamic 与 CONSTANT_InvokeDynamic 的布局相同
+			name, desc := cf.NameAndTypeAt((*ConstantInvokeDynamicInfo)(info).NameAndTypeIndex())
+			add("invokedynamic "+name+desc, DescriptorClasses(desc)...)
+		}
+	}
+
+	if sig := cf.SignatureString(); sig != "" {
+		if cs, err := ParseClassSignature(sig); err == nil {
+			add("signature of "+self, cs.Classes()...)
+		}
+	}
+	addAnnotations(self, cf.Annotations())
+
+	for _, field := range cf.Fields {
+		ref := "field " + field.NameString()
+		add(ref, DescriptorClasses(field.DescriptorString())...)
+		addSignature(ref, field.SignatureString())
+		addAnnotations(ref, field.Annotations())
+	}
+
+	for _, method := range cf.Methods {
+		ref := "method " + method.NameString() + method.DescriptorString()
+		add(ref, DescriptorClasses(method.DescriptorString())...)
+		addSignature(ref, method.SignatureString())
+		add(ref, method.ExceptionStrings()...)
+		addAnnotations(ref, method.Annotations())
+		for _, anns := range method.ParameterAnnotations() {
+			addAnnotations("parameter of "+ref, anns)
+		}
+		if attr := method.Attribute("AnnotationDefault"); attr != nil {
+			var classes []string
+			attr.DefaultValue.collectClasses(&classes)
+			add("default value of "+ref, classes...)
+		}
+		if code := method.Code(); code != nil {
+			for _, entry := range code.ExceptionTable {
+				if entry.CatchType != 0 {
+					add("catch in "+ref, cf.ClassNameAt(entry.CatchType))
+				}
+			}
+		}
+	}
+
+	return rs
+}
+
+const (
+	DEPENDENCY_LEVEL_CLASS   = "class"
+	DEPENDENCY_LEVEL_PACKAGE = "package"
+	DEPENDENCY_LEVEL_JAR     = "jar"
+	DEPENDENCY_LEVEL_MODULE  = "module"
+)
+
+// 不在 classpath 上的类在 jar 与 module 级别上归入此节点
+const DEPENDENCY_NOT_FOUND = "not found"
+
+// 按类、包、jar 或模块聚合后的依赖图，节点名使用 Java 形式（com.acme.Foo）
+type DependencyGraph struct {
+	Level string
+	Nodes []string
+	// 源节点 -> 目标节点 -> 产生该边的类级依赖数
+	Edges map[string]map[string]int
+}
+
+// 类名模式：以 * 结尾时按前缀匹配，否则精确匹配；均使用 Java 形式的类名，如 java.*
+func matchClassPattern(class string, patterns []string) bool {
+	name := strings.Replace(class, "/", ".", -1)
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(name, pattern[:len(pattern)-1]) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// 构建依赖图；源或目标类名匹配 exclude 中任一模式的依赖被忽略
+func (p *ClassPath) DependencyGraph(level string, exclude []string) (*DependencyGraph, error) {
+	g := &DependencyGraph{
+		Level: level,
+		Edges: make(map[string]map[string]int),
+	}
+
+	nodeOf := func(class string, jar *Jar) string {
+		switch level {
+		case DEPENDENCY_LEVEL_CLASS:
+			return strings.Replace(class, "/", ".", -1)
+		case DEPENDENCY_LEVEL_PACKAGE:
+			pkg := PackageOf(class)
+			if pkg == "" {
+				return "<unnamed>"
+			}
+			return strings.Replace(pkg, "/", ".", -1)
+		case DEPENDENCY_LEVEL_JAR:
+			if jar == nil {
+				return DEPENDENCY_NOT_FOUND
+			}
+			return filepath.Base(jar.Path)
+		default:
+			if jar == nil {
+				return DEPENDENCY_NOT_FOUND
+			}
+			return jar.ModuleName()
+		}
+	}
+
+	switch level {
+	case DEPENDENCY_LEVEL_CLASS, DEPENDENCY_LEVEL_PACKAGE, DEPENDENCY_LEVEL_JAR, DEPENDENCY_LEVEL_MODULE:
+	default:
+		return nil, fmt.Errorf("invalid dependency level: %s", level)
+	}
+
+	nodes := make(map[string]bool)
+	for _, name := range p.ClassNames() {
+		if name == "module-info" || matchClassPattern(name, exclude) {
+			continue
+		}
+		source := nodeOf(name, p.JarOf(name))
+		nodes[source] = true
+
+		targets := make(map[string]bool)
+		for _, dep := range p.Class(name).Dependencies() {
+			if targets[dep.Target] || matchClassPattern(dep.Target, exclude) {
+				continue
+			}
+			targets[dep.Target] = true
+
+			target := nodeOf(dep.Target, p.JarOf(dep.Target))
+			if target == source {
+				continue
+			}
+			nodes[target] = true
+			if g.Edges[source] == nil {
+				g.Edges[source] = make(map[string]int)
+			}
+			g.Edges[source][target]++
+		}
+	}
+
+	for node := range nodes {
+		g.Nodes = append(g.Nodes, node)
+	}
+	sort.Strings(g.Nodes)
+	return g, nil
+}
+
+// 按名称排序的直接后继
+func (g *DependencyGraph) Targets(node string) []string {
+	rs := make([]string, 0, len(g.Edges[node]))
+	for target := range g.Edges[node] {
+		rs = append(rs, target)
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+// 图中的循环依赖：节点数大于 1 的强连通分量（Tarjan 算法）
+func (g *DependencyGraph) Cycles() [][]string {
+	index := make(map[string]int)
+	lowLink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var rs [][]string
+	counter := 0
+
+	var connect func(v string)
+	connect = func(v string) {
+		index[v] = counter
+		lowLink[v] = counter
+		counter++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range g.Targets(v) {
+			if _, ok := index[w]; !ok {
+				connect(w)
+				if lowLink[w] < lowLink[v] {
+					lowLink[v] = lowLink[w]
+				}
+			} else if onStack[w] && index[w] < lowLink[v] {
+				lowLink[v] = index[w]
+			}
+		}
+
+		if lowLink[v] == index[v] {
+			var scc []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			if len(scc) > 1 {
+				sort.Strings(scc)
+				rs = append(rs, scc)
+			}
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if _, ok := index[node]; !ok {
+			connect(node)
+		}
+	}
+
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i][0] < rs[j][0]
+	})
+	return rs
+}
+
+func (g *DependencyGraph) WriteSummary(w io.Writer) error {
+	for _, source := range g.Nodes {
+		for _, target := range g.Targets(source) {
+			_, err := fmt.Fprintf(w, "%s -> %s (%d)\n", source, target, g.Edges[source][target])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *DependencyGraph) WriteDot(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "digraph %q {\n", g.Level+" dependencies")
+	if err != nil {
+		return err
+	}
+	for _, source := range g.Nodes {
+		_, err = fmt.Fprintf(w, "\t%q;\n", source)
+		if err != nil {
+			return err
+		}
+		for _, target := range g.Targets(source) {
+			_, err = fmt.Fprintf(w, "\t%q -> %q [label=%d];\n", source, target, g.Edges[source][target])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
+
+func (g *DependencyGraph) WriteJSON(w io.Writer) error {
+	type edge struct {
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Count int    `json:"count"`
+	}
+	out := struct {
+		Level  string     `json:"level"`
+		Nodes  []string   `json:"nodes"`
+		Edges  []edge     `json:"edges"`
+		Cycles [][]string `json:"cycles"`
+	}{
+		Level:  g.Level,
+		Nodes:  g.Nodes,
+		Edges:  []edge{},
+		Cycles: g.Cycles(),
+	}
+	if out.Cycles == nil {
+		out.Cycles = [][]string{}
+	}
+	for _, source := range g.Nodes {
+		for _, target := range g.Targets(source) {
+			out.Edges = append(out.Edges, edge{source, target, g.Edges[source][target]})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,518 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 单个方法的指令差异最多比较的指令数，超过时只报告 Code 不同
+const DIFF_MAX_INSTRUCTIONS = 5000
+
+type DiffOptions struct {
+	// 为内容不同的方法给出逐条指令的差异
+	Instructions bool
+}
+
+// 一处差异。Kind 为 '+'（新增）、'-'（删除）或 '~'（修改）
+type Change struct {
+	Kind byte
+	// 如 "method foo (I)V"、"field x I"、"class"
+	Subject string
+	Detail  string
+	// 指令差异，每行以 "+ "、"- " 或 "  " 开头
+	Lines []string
+}
+
+func (c *Change) String() string {
+	s := string(c.Kind) + " " + c.Subject
+	if c.Detail != "" {
+		s += ": " + c.Detail
+	}
+	return s
+}
+
+// 两个同名类的语义差异；常量池的顺序与布局不计入
+type ClassDiff struct {
+	Name    string
+	Changes []*Change
+}
+
+// 两个 jar 的差异，类名为内部形式，资源为条目路径，均已排序
+type JarDiff struct {
+	AddedClasses     []string
+	RemovedClasses   []string
+	Classes          []*ClassDiff
+	AddedResources   []string
+	RemovedResources []string
+	ChangedResources []string
+}
+
+func (d *JarDiff) Empty() bool {
+	return len(d.AddedClasses)+len(d.RemovedClasses)+len(d.Classes)+
+		len(d.AddedResources)+len(d.RemovedResources)+len(d.ChangedResources) == 0
+}
+
+// 属性与常量池布局无关的形式：Info 中的常量池索引清零后的字节，加上按出现顺序解析出的常量。
+// 无法识别的属性只能比较原始字节
+func attributeValue(cf *ClassFile, attr *AttributeInfo) (key string, constants []string) {
+	if attr.NameString() == "BootstrapMethods" {
+		// 指令中的 Dynamic 与 InvokeDynamic 已按引导方法的内容比较，表中的顺序无关
+		entries := make([]string, len(attr.BootstrapMethods))
+		for i := range entries {
+			entries[i] = bootstrapText(cf, uint16(i), 0)
+		}
+		sort.Strings(entries)
+		return strings.Join(entries, "\n"), nil
+	}
+
+	data := append([]byte(nil), attr.Info...)
+	c := &refCursor{cf: cf, b: data, visit: func(index uint16, kind refKind, owner uint16) uint16 {
+		constants = append(constants, diffConstant(cf, index, 0))
+		return 0
+	}}
+	ok := func() (ok bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(refCursorError); !ok {
+					panic(r)
+				}
+			}
+		}()
+		c.attribute(attr.NameString())
+		return true
+	}()
+	if !ok {
+		return "raw " + hex.EncodeToString(attr.Info), nil
+	}
+	return hex.EncodeToString(data) + " " + strings.Join(constants, " "), constants
+}
+
+// 与常量池布局无关的常量文本：Dynamic 与 InvokeDynamic 显示引导方法及其参数，而不是 BootstrapMethods 中的下标。
+// depth 限制参数中嵌套的 Dynamic 层数，防止格式错误的类形成环
+func diffConstant(cf *ClassFile, index uint16, depth int) string {
+	if int(index) >= len(cf.ConstantPool) || cf.ConstantPool[index] == nil || depth > 8 {
+		return cf.ConstantString(index)
+	}
+	info := cf.ConstantPool[index]
+	if info.Tag != 17 && info.Tag != 18 {
+		return cf.ConstantString(index)
+	}
+	dynamic := (*ConstantDynamicInfo)(info)
+	name, desc := cf.NameAndTypeAt(dynamic.NameAndTypeIndex())
+	kind := "dynamic"
+	if info.Tag == 18 {
+		kind = "invokedynamic"
+	}
+	return fmt.Sprintf("%s %s:%s %s", kind, name, desc, bootstrapText(cf, dynamic.BootstrapMethodAttrIndex(), depth+1))
+}
+
+// 第 i 个引导方法的方法句柄与静态参数
+func bootstrapText(cf *ClassFile, i uint16, depth int) string {
+	methods := cf.BootstrapMethods()
+	if int(i) >= len(methods) {
+		return fmt.Sprintf("bootstrap #%d", i)
+	}
+	method := methods[i]
+	args := make([]string, len(method.BootstrapArguments))
+	for j, arg := range method.BootstrapArguments {
+		args[j] = diffConstant(cf, arg, depth)
+	}
+	return fmt.Sprintf("bootstrap %s [%s]", diffConstant(cf, method.BootstrapMethodRef, depth), strings.Join(args, ", "))
+}
+
+// 值本身就是少量常量的属性，差异中直接显示新旧值
+var simpleAttributes = map[string]bool{
+	"ConstantValue":       true,
+	"SourceFile":          true,
+	"Signature":           true,
+	"Exceptions":          true,
+	"NestHost":            true,
+	"NestMembers":         true,
+	"PermittedSubclasses": true,
+	"EnclosingMethod":     true,
+	"ModuleMainClass":     true,
+}
+
+// 按属性名比较两组属性；Code 由 diffCode 单独比较
+func diffAttributes(subject string, a, b *ClassFile, as, bs []*AttributeInfo) []*Change {
+	values := func(cf *ClassFile, attrs []*AttributeInfo) (map[string][]string, map[string][]string, []string) {
+		keys := make(map[string][]string)
+		constants := make(map[string][]string)
+		var names []string
+		for _, attr := range attrs {
+			name := attr.NameString()
+			if name == "Code" {
+				continue
+			}
+			if _, ok := keys[name]; !ok {
+				names = append(names, name)
+			}
+			key, cs := attributeValue(cf, attr)
+			keys[name] = append(keys[name], key)
+			constants[name] = append(constants[name], cs...)
+		}
+		return keys, constants, names
+	}
+	aKeys, aConstants, names := values(a, as)
+	bKeys, bConstants, bNames := values(b, bs)
+	for _, name := range bNames {
+		if _, ok := aKeys[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var rs []*Change
+	for _, name := range names {
+		o, inA := aKeys[name]
+		n, inB := bKeys[name]
+		switch {
+		case !inB:
+			rs = append(rs, &Change{Kind: '-', Subject: subject, Detail: name})
+		case !inA:
+			rs = append(rs, &Change{Kind: '+', Subject: subject, Detail: name})
+		case strings.Join(o, "\n") != strings.Join(n, "\n"):
+			detail := name + " changed"
+			if simpleAttributes[name] {
+				detail = fmt.Sprintf("%s %s -> %s", name, strings.Join(aConstants[name], ", "), strings.Join(bConstants[name], ", "))
+			}
+			rs = append(rs, &Change{Kind: '~', Subject: subject, Detail: detail})
+		}
+	}
+	return rs
+}
+
+// 指令的可读形式：常量池操作数解析为常量，跳转目标用指令序号 @N 表示，与常量池布局和指令长度无关
+func instructionText(cf *ClassFile, ins *Instruction, indexOf map[int]int) string {
+	target := func(offset int) string {
+		if i, ok := indexOf[offset]; ok {
+			return fmt.Sprintf("@%d", i)
+		}
+		return fmt.Sprintf("@?%d", offset)
+	}
+	s := &strings.Builder{}
+	s.WriteString(OpcodeName(ins.Opcode))
+	switch {
+	case ins.IsSwitch():
+		s.WriteString(" {")
+		for j, key := range ins.Keys {
+			fmt.Fprintf(s, " %d: %s;", key, target(ins.Targets[j]))
+		}
+		fmt.Fprintf(s, " default: %s }", target(ins.Default))
+	case ins.IsBranch():
+		s.WriteString(" " + target(ins.Branch))
+	case ins.Opcode == OP_IINC:
+		fmt.Fprintf(s, " %d %d", ins.Index, ins.Const)
+	case ins.Opcode == OP_BIPUSH, ins.Opcode == OP_SIPUSH, ins.Opcode == OP_NEWARRAY:
+		fmt.Fprintf(s, " %d", ins.Const)
+	case ins.Opcode == OP_MULTIANEWARRAY:
+		fmt.Fprintf(s, " %s %d", diffConstant(cf, ins.Index, 0), ins.Const)
+	case ins.usesConstantPool():
+		s.WriteString(" " + diffConstant(cf, ins.Index, 0))
+	case ins.usesLocal():
+		fmt.Fprintf(s, " %d", ins.Index)
+	}
+	return s.String()
+}
+
+// Code 属性与常量池布局无关的形式：指令、异常表，以及 max_stack 等
+type codeText struct {
+	header       string
+	instructions []string
+	exceptions   []string
+}
+
+func newCodeText(cf *ClassFile, code *CodeAttribute) (*codeText, error) {
+	instructions, err := code.Instructions()
+	if err != nil {
+		return nil, err
+	}
+	indexOf := make(map[int]int, len(instructions)+1)
+	for i, ins := range instructions {
+		indexOf[ins.Offset] = i
+	}
+	indexOf[len(code.Code)] = len(instructions)
+
+	rs := &codeText{header: fmt.Sprintf("max_stack %d, max_locals %d", code.MaxStack, code.MaxLocals)}
+	for _, ins := range instructions {
+		rs.instructions = append(rs.instructions, instructionText(cf, ins, indexOf))
+	}
+	for _, e := range code.ExceptionTable {
+		catch := "any"
+		if e.CatchType != 0 {
+			catch = cf.ClassNameAt(e.CatchType)
+		}
+		rs.exceptions = append(rs.exceptions, fmt.Sprintf("@%d-@%d -> @%d %s",
+			indexOf[int(e.StartPc)], indexOf[int(e.EndPc)], indexOf[int(e.HandlerPc)], catch))
+	}
+	return rs, nil
+}
+
+// 两个序列的逐行差异：去掉相同的首尾后按 Hirschberg 算法求最长公共子序列，只占用线性空间
+func diffLines(a, b []string) []string {
+	var rs, tail []string
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		rs = append(rs, "  "+a[0])
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		tail = append(tail, "  "+a[len(a)-1])
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	rs = appendLCSDiff(rs, a, b)
+	for i := len(tail) - 1; i >= 0; i-- {
+		rs = append(rs, tail[i])
+	}
+	return rs
+}
+
+func appendLCSDiff(rs, a, b []string) []string {
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			rs = append(rs, "+ "+line)
+		}
+		return rs
+	case len(b) == 0:
+		for _, line := range a {
+			rs = append(rs, "- "+line)
+		}
+		return rs
+	case len(a) == 1:
+		for j, line := range b {
+			if line == a[0] {
+				rs = appendLCSDiff(rs, nil, b[:j])
+				rs = append(rs, "  "+line)
+				return appendLCSDiff(rs, nil, b[j+1:])
+			}
+		}
+		return appendLCSDiff(append(rs, "- "+a[0]), nil, b)
+	}
+
+	// a 从中间分开，前半与 b 的各前缀、后半与 b 的各后缀的 LCS 长度之和最大处即 b 的分割点
+	mid := len(a) / 2
+	forward := lcsLengths(a[:mid], b, false)
+	backward := lcsLengths(a[mid:], b, true)
+	split, best := 0, -1
+	for k := 0; k <= len(b); k++ {
+		if n := forward[k] + backward[len(b)-k]; n > best {
+			split, best = k, n
+		}
+	}
+	rs = appendLCSDiff(rs, a[:mid], b[:split])
+	return appendLCSDiff(rs, a[mid:], b[split:])
+}
+
+// a 与 b 的各前缀（reverse 时为各后缀，按长度索引）的最长公共子序列长度，只保留两行
+func lcsLengths(a, b []string, reverse bool) []int {
+	at := func(s []string, i int) string {
+		if reverse {
+			return s[len(s)-1-i]
+		}
+		return s[i]
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case at(a, i) == at(b, j):
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// 比较两个方法的 Code 属性及其中的 LineNumberTable 等子属性
+func diffCode(subject string, a, b *ClassFile, ac, bc *CodeAttribute, opts *DiffOptions) []*Change {
+	switch {
+	case ac == nil && bc == nil:
+		return nil
+	case bc == nil:
+		return []*Change{{Kind: '-', Subject: subject, Detail: "Code"}}
+	case ac == nil:
+		return []*Change{{Kind: '+', Subject: subject, Detail: "Code"}}
+	}
+
+	var rs []*Change
+	at, aErr := newCodeText(a, ac)
+	bt, bErr := newCodeText(b, bc)
+	if aErr != nil || bErr != nil {
+		if !bytes.Equal(ac.Code, bc.Code) {
+			rs = append(rs, &Change{Kind: '~', Subject: subject, Detail: "Code changed (undecodable)"})
+		}
+		return append(rs, diffAttributes(subject, a, b, ac.Attributes, bc.Attributes)...)
+	}
+
+	if at.header != bt.header {
+		rs = append(rs, &Change{Kind: '~', Subject: subject, Detail: at.header + " -> " + bt.header})
+	}
+	if strings.Join(at.exceptions, "\n") != strings.Join(bt.exceptions, "\n") {
+		rs = append(rs, &Change{Kind: '~', Subject: subject, Detail: fmt.Sprintf("exception table [%s] -> [%s]",
+			strings.Join(at.exceptions, "; "), strings.Join(bt.exceptions, "; "))})
+	}
+	if strings.Join(at.instructions, "\n") != strings.Join(bt.instructions, "\n") {
+		c := &Change{Kind: '~', Subject: subject, Detail: fmt.Sprintf("instructions changed (%d -> %d)",
+			len(at.instructions), len(bt.instructions))}
+		if opts.Instructions && len(at.instructions) <= DIFF_MAX_INSTRUCTIONS && len(bt.instructions) <= DIFF_MAX_INSTRUCTIONS {
+			c.Lines = diffLines(at.instructions, bt.instructions)
+		}
+		rs = append(rs, c)
+	}
+	return append(rs, diffAttributes(subject, a, b, ac.Attributes, bc.Attributes)...)
+}
+
+// 比较两个类的结构：版本号、修饰符、父类与接口、字段、方法（按名称与描述符对应）以及各级属性。
+// 只有常量池顺序或布局不同的两个类没有差异。opts 为 nil 时使用默认选项
+func DiffClasses(a, b *ClassFile, opts *DiffOptions) *ClassDiff {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	rs := &ClassDiff{Name: b.ThisClassString()}
+	change := func(kind byte, subject, format string, args ...interface{}) {
+		rs.Changes = append(rs.Changes, &Change{Kind: kind, Subject: subject, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if a.ThisClassString() != b.ThisClassString() {
+		change('~', "class", "name %s -> %s", a.ThisClassString(), b.ThisClassString())
+	}
+	if a.MajorVersion != b.MajorVersion || a.MinorVersion != b.MinorVersion {
+		change('~', "class", "version %d.%d -> %d.%d", a.MajorVersion, a.MinorVersion, b.MajorVersion, b.MinorVersion)
+	}
+	if a.AccessFlags != b.AccessFlags {
+		change('~', "class", "access %q -> %q", a.AccessFlagsString(), b.AccessFlagsString())
+	}
+	if a.SuperClassString() != b.SuperClassString() {
+		change('~', "class", "superclass %s -> %s", a.SuperClassString(), b.SuperClassString())
+	}
+	aInterfaces := make(map[string]bool)
+	for _, name := range a.InterfaceStrings() {
+		aInterfaces[name] = true
+	}
+	bInterfaces := make(map[string]bool)
+	for _, name := range b.InterfaceStrings() {
+		bInterfaces[name] = true
+		if !aInterfaces[name] {
+			change('+', "class", "interface %s", name)
+		}
+	}
+	for _, name := range a.InterfaceStrings() {
+		if !bInterfaces[name] {
+			change('-', "class", "interface %s", name)
+		}
+	}
+	rs.Changes = append(rs.Changes, diffAttributes("class", a, b, a.Attributes, b.Attributes)...)
+
+	type member struct {
+		access     string
+		attributes []*AttributeInfo
+		code       *CodeAttribute
+	}
+	diffMembers := func(am, bm map[string]*member) {
+		keys := make([]string, 0, len(am)+len(bm))
+		for k := range am {
+			keys = append(keys, k)
+		}
+		for k := range bm {
+			if am[k] == nil {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			o, n := am[k], bm[k]
+			switch {
+			case n == nil:
+				change('-', k, "")
+			case o == nil:
+				change('+', k, "")
+			default:
+				if o.access != n.access {
+					change('~', k, "access %q -> %q", o.access, n.access)
+				}
+				rs.Changes = append(rs.Changes, diffAttributes(k, a, b, o.attributes, n.attributes)...)
+				rs.Changes = append(rs.Changes, diffCode(k, a, b, o.code, n.code, opts)...)
+			}
+		}
+	}
+
+	fields := func(cf *ClassFile) map[string]*member {
+		m := make(map[string]*member)
+		for _, f := range cf.Fields {
+			m["field "+f.NameString()+" "+f.DescriptorString()] = &member{access: f.AccessFlagsString(), attributes: f.Attributes}
+		}
+		return m
+	}
+	methods := func(cf *ClassFile) map[string]*member {
+		m := make(map[string]*member)
+		for _, method := range cf.Methods {
+			m["method "+method.NameString()+" "+method.DescriptorString()] = &member{
+				access:     method.AccessFlagsString(),
+				attributes: method.Attributes,
+				code:       method.Code(),
+			}
+		}
+		return m
+	}
+	diffMembers(fields(a), fields(b))
+	diffMembers(methods(a), methods(b))
+	return rs
+}
+
+// 比较两个 jar：按类名对应的类用 DiffClasses 比较，其余条目（包括 META-INF/versions 下的类）按字节比较
+func DiffJars(a, b *Jar, opts *DiffOptions) *JarDiff {
+	rs := &JarDiff{}
+	for _, name := range a.ClassNames() {
+		if b.Class(name) == nil {
+			rs.RemovedClasses = append(rs.RemovedClasses, name)
+		}
+	}
+	for _, name := range b.ClassNames() {
+		old := a.Class(name)
+		if old == nil {
+			rs.AddedClasses = append(rs.AddedClasses, name)
+			continue
+		}
+		if d := DiffClasses(old, b.Class(name), opts); len(d.Changes) > 0 {
+			rs.Classes = append(rs.Classes, d)
+		}
+	}
+
+	resources := func(j *Jar) map[string][]byte {
+		m := make(map[string][]byte)
+		for _, entry := range j.Entries {
+			if entry.Class == nil {
+				m[entry.Name] = entry.Data
+			}
+		}
+		return m
+	}
+	aResources, bResources := resources(a), resources(b)
+	for name, data := range bResources {
+		old, ok := aResources[name]
+		switch {
+		case !ok:
+			rs.AddedResources = append(rs.AddedResources, name)
+		case !bytes.Equal(old, data):
+			rs.ChangedResources = append(rs.ChangedResources, name)
+		}
+	}
+	for name := range aResources {
+		if _, ok := bResources[name]; !ok {
+			rs.RemovedResources = append(rs.RemovedResources, name)
+		}
+	}
+	sort.Strings(rs.AddedResources)
+	sort.Strings(rs.RemovedResources)
+	sort.Strings(rs.ChangedResources)
+	return rs
+}
@@ -11,6 +11,17 @@ type ElementValue struct {
 	Info              []byte
 	ConstantPoolIndex uint16
 
+	// enum_const_value
+	TypeNameIndex  uint16
+	ConstNameIndex uint16
+
+	// annotation_value
+	AnnotationValue *Annotation
+
+	// array_value
+	NumValues   uint16
+	ArrayValues []*ElementValue
+
 	cp []*ConstantPoolInfo
 }
 
@@ -18,6 +29,34 @@ func (ev *ElementValue) ConstantPoolInfo(i uint16) *ConstantPoolInfo {
 	return ev.cp[int(i)]
 }
 
+// 仅适用于 tag 为 's'（字符串常量）或 'c'（类的返回值描述符）
+func (ev *ElementValue) ConstantString() string {
+	return ((*ConstantUtf8Info)(ev.cp[ev.ConstantPoolIndex])).Utf8()
+}
+
+func (ev *ElementValue) TypeNameString() string {
+	return ((*ConstantUtf8Info)(ev.cp[ev.TypeNameIndex])).Utf8()
+}
+
+func (ev *ElementValue) ConstNameString() string {
+	return ((*ConstantUtf8Info)(ev.cp[ev.ConstNameIndex])).Utf8()
+}
+
+func (ev *ElementValue) collectClasses(rs *[]string) {
+	switch ev.Tag {
+	case "e":
+		*rs = append(*rs, DescriptorClasses(ev.TypeNameString())...)
+	case "c":
+		*rs = append(*rs, DescriptorClasses(ev.ConstantString())...)
+	case "@":
+		ev.AnnotationValue.collectClasses(rs)
+	case "[":
+		for _, v := range ev.ArrayValues {
+			v.collectClasses(rs)
+		}
+	}
+}
+
 func NewElementValue(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*ElementValue, []byte, error) {
 	rs := ElementValue{cp: cp}
 	byteOrder := binary.BigEndian
@@ -46,13 +85,48 @@ func NewElementValue(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*ElementV
 	case "Z":
 		fallthrough
 	case "s":
+		fallthrough
+	case "c":
 		_, err := io.ReadFull(r, buf[:2])
 		if err != nil {
 			return nil, buf, err
 		}
 		rs.ConstantPoolIndex = byteOrder.Uint16(buf)
+
+	case "e":
+		_, err := io.ReadFull(r, buf[:4])
+		if err != nil {
+			return nil, buf, err
+		}
+		rs.TypeNameIndex = byteOrder.Uint16(buf)
+		rs.ConstNameIndex = byteOrder.Uint16(buf[2:])
+
+	case "@":
+		rs.AnnotationValue, buf, err = NewAnnotation(r, buf, cp)
+		if err != nil {
+			return nil, buf, err
+		}
+
+	case "[":
+		_, err := io.ReadFull(r, buf[:2])
+		if err != nil {
+			return nil, buf, err
+		}
+		rs.NumValues = byteOrder.Uint16(buf)
+
+		num := int(rs.NumValues)
+		rs.ArrayValues = make([]*ElementValue, num)
+		var v *ElementValue
+		for i := 0; i < num; i++ {
+			v, buf, err = NewElementValue(r, buf, cp)
+			if err != nil {
+				return nil, buf, err
+			}
+			rs.ArrayValues[i] = v
+		}
+
 	default:
-		panic(fmt.Errorf("invalid element value tag: %s", rs.Tag))
+		return nil, buf, fmt.Errorf("invalid element value tag: %s", rs.Tag)
 	}
 
 	return &rs, buf, nil
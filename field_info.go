@@ -32,6 +32,28 @@ func (i *FieldInfo) NameString() string {
 	return ((*ConstantUtf8Info)(i.cp[int(i.NameIndex)])).Utf8()
 }
 
+// 按名称查找属性，不存在时返回 nil
+func (i *FieldInfo) Attribute(name string) *AttributeInfo {
+	return findAttribute(i.Attributes, name)
+}
+
+// 泛型签名，不存在时返回空串
+func (i *FieldInfo) SignatureString() string {
+	return signatureOf(i.Attributes)
+}
+
+func (i *FieldInfo) Annotations() []*Annotation {
+	return annotationsOf(i.Attributes)
+}
+
+// ConstantValue 属性指向的常量池项，不存在时返回 nil
+func (i *FieldInfo) ConstantValue() *ConstantPoolInfo {
+	if attr := i.Attribute("ConstantValue"); attr != nil {
+		return i.cp[attr.ConstantValueIndex]
+	}
+	return nil
+}
+
 func (i *FieldInfo) AccessFlagsString() string {
 	s := bytes.NewBuffer(nil)
 
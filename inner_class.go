@@ -0,0 +1,35 @@
+package jclass
+
+type InnerClass struct {
+	InnerClassInfoIndex   uint16
+	OuterClassInfoIndex   uint16
+	InnerNameIndex        uint16
+	InnerClassAccessFlags ClassAccessFlags
+
+	cp []*ConstantPoolInfo
+}
+
+func (c *InnerClass) className(index uint16) string {
+	if index == 0 {
+		return ""
+	}
+	classInfo := (*ConstantClassInfo)(c.cp[index])
+	return ((*ConstantUtf8Info)(c.cp[classInfo.NameIndex()])).Utf8()
+}
+
+func (c *InnerClass) InnerClassString() string {
+	return c.className(c.InnerClassInfoIndex)
+}
+
+// 局部类与匿名类返回空串
+func (c *InnerClass) OuterClassString() string {
+	return c.className(c.OuterClassInfoIndex)
+}
+
+// 匿名类返回空串
+func (c *InnerClass) InnerNameString() string {
+	if c.InnerNameIndex == 0 {
+		return ""
+	}
+	return ((*ConstantUtf8Info)(c.cp[c.InnerNameIndex])).Utf8()
+}
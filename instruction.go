@@ -0,0 +1,301 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ERR_TRUNCATED_CODE = errors.New("truncated code")
+	ERR_INVALID_OPCODE = errors.New("invalid opcode")
+	ERR_INVALID_BRANCH = errors.New("branch target out of code")
+	ERR_INVALID_WIDE   = errors.New("invalid wide instruction")
+	// 跳转目标或异常表偏移落在某条指令内部
+	ERR_MISALIGNED_TARGET       = errors.New("target is not the start of an instruction")
+	ERR_INVALID_EXCEPTION_RANGE = errors.New("invalid exception table range")
+)
+
+// 解码后的一条字节码指令
+type Instruction struct {
+	Offset int
+	Length int
+	Opcode byte
+	// 是否带有 wide 前缀
+	Wide bool
+
+	// 常量池索引（ldc、字段/方法引用、new、checkcast 等）或局部变量下标（xload、xstore、iinc、ret）
+	Index uint16
+	// bipush、sipush 的立即数，iinc 的增量，newarray 的 atype，multianewarray 的维数，
+	// invokeinterface 的 count
+	Const int32
+
+	// 跳转指令的目标绝对偏移
+	Branch int
+
+	// tableswitch 与 lookupswitch
+	Default int
+	Keys    []int32
+	Targets []int
+}
+
+func (i *Instruction) String() string {
+	s := &strings.Builder{}
+	fmt.Fprintf(s, "%d: %s", i.Offset, OpcodeName(i.Opcode))
+
+	switch {
+	case i.IsSwitch():
+		fmt.Fprintf(s, " {")
+		for j, key := range i.Keys {
+			fmt.Fprintf(s, " %d: %d;", key, i.Targets[j])
+		}
+		fmt.Fprintf(s, " default: %d }", i.Default)
+
+	case i.IsBranch():
+		fmt.Fprintf(s, " %d", i.Branch)
+
+	case i.Opcode == OP_IINC:
+		fmt.Fprintf(s, " %d %d", i.Index, i.Const)
+
+	case i.Opcode == OP_BIPUSH, i.Opcode == OP_SIPUSH, i.Opcode == OP_NEWARRAY:
+		fmt.Fprintf(s, " %d", i.Const)
+
+	case i.Opcode == OP_MULTIANEWARRAY:
+		fmt.Fprintf(s, " #%d %d", i.Index, i.Const)
+
+	case i.usesConstantPool():
+		fmt.Fprintf(s, " #%d", i.Index)
+
+	case i.usesLocal():
+		fmt.Fprintf(s, " %d", i.Index)
+	}
+
+	return s.String()
+}
+
+func (i *Instruction) usesConstantPool() bool {
+	switch i.Opcode {
+	case OP_LDC, OP_LDC_W, OP_LDC2_W,
+		OP_GETSTATIC, OP_PUTSTATIC, OP_GETFIELD, OP_PUTFIELD,
+		OP_INVOKEVIRTUAL, OP_INVOKESPECIAL, OP_INVOKESTATIC, OP_INVOKEINTERFACE, OP_INVOKEDYNAMIC,
+		OP_NEW, OP_ANEWARRAY, OP_CHECKCAST, OP_INSTANCEOF, OP_MULTIANEWARRAY:
+		return true
+	}
+	return false
+}
+
+func (i *Instruction) usesLocal() bool {
+	switch i.Opcode {
+	case OP_ILOAD, OP_LLOAD, OP_FLOAD, OP_DLOAD, OP_ALOAD,
+		OP_ISTORE, OP_LSTORE, OP_FSTORE, OP_DSTORE, OP_ASTORE,
+		OP_IINC, OP_RET:
+		return true
+	}
+	return false
+}
+
+// 条件跳转、goto、jsr 及其宽跳转形式
+func (i *Instruction) IsBranch() bool {
+	return i.Opcode >= OP_IFEQ && i.Opcode <= OP_JSR ||
+		i.Opcode == OP_IFNULL || i.Opcode == OP_IFNONNULL ||
+		i.Opcode == OP_GOTO_W || i.Opcode == OP_JSR_W
+}
+
+func (i *Instruction) IsConditionalBranch() bool {
+	return i.Opcode >= OP_IFEQ && i.Opcode <= OP_IF_ACMPNE ||
+		i.Opcode == OP_IFNULL || i.Opcode == OP_IFNONNULL
+}
+
+func (i *Instruction) IsGoto() bool {
+	return i.Opcode == OP_GOTO || i.Opcode == OP_GOTO_W
+}
+
+func (i *Instruction) IsJsr() bool {
+	return i.Opcode == OP_JSR || i.Opcode == OP_JSR_W
+}
+
+func (i *Instruction) IsSwitch() bool {
+	return i.Opcode == OP_TABLESWITCH || i.Opcode == OP_LOOKUPSWITCH
+}
+
+func (i *Instruction) IsReturn() bool {
+	return i.Opcode >= OP_IRETURN && i.Opcode <= OP_RETURN
+}
+
+func (i *Instruction) IsInvoke() bool {
+	return i.Opcode >= OP_INVOKEVIRTUAL && i.Opcode <= OP_INVOKEDYNAMIC
+}
+
+// 执行后不会顺序执行下一条指令：无条件跳转、switch、返回、athrow、ret
+func (i *Instruction) EndsFlow() bool {
+	return i.IsGoto() || i.IsSwitch() || i.IsReturn() ||
+		i.Opcode == OP_ATHROW || i.Opcode == OP_RET
+}
+
+// 不带操作数的指令长度为 1；这里列出其余指令的固定长度
+var instructionLengths = map[byte]int{
+	OP_BIPUSH: 2, OP_SIPUSH: 3, OP_LDC: 2, OP_LDC_W: 3, OP_LDC2_W: 3,
+	OP_ILOAD: 2, OP_LLOAD: 2, OP_FLOAD: 2, OP_DLOAD: 2, OP_ALOAD: 2,
+	OP_ISTORE: 2, OP_LSTORE: 2, OP_FSTORE: 2, OP_DSTORE: 2, OP_ASTORE: 2,
+	OP_IINC: 3, OP_RET: 2,
+	OP_GETSTATIC: 3, OP_PUTSTATIC: 3, OP_GETFIELD: 3, OP_PUTFIELD: 3,
+	OP_INVOKEVIRTUAL: 3, OP_INVOKESPECIAL: 3, OP_INVOKESTATIC: 3,
+	OP_INVOKEINTERFACE: 5, OP_INVOKEDYNAMIC: 5,
+	OP_NEW: 3, OP_NEWARRAY: 2, OP_ANEWARRAY: 3, OP_CHECKCAST: 3, OP_INSTANCEOF: 3,
+	OP_MULTIANEWARRAY: 4, OP_GOTO_W: 5, OP_JSR_W: 5,
+}
+
+// 解码 Code 属性中的字节码
+func DecodeInstructions(code []byte) ([]*Instruction, error) {
+	var rs []*Instruction
+	byteOrder := binary.BigEndian
+
+	for pc := 0; pc < len(code); {
+		ins := &Instruction{Offset: pc, Opcode: code[pc]}
+		if OpcodeName(ins.Opcode) == "" {
+			return nil, fmt.Errorf("%d: %s 0x%02x", pc, ERR_INVALID_OPCODE, ins.Opcode)
+		}
+
+		need := func(n int) bool {
+			return pc+n <= len(code)
+		}
+
+		switch {
+		case ins.Opcode == OP_WIDE:
+			if !need(4) {
+				return nil, ERR_TRUNCATED_CODE
+			}
+			ins.Wide = true
+			ins.Opcode = code[pc+1]
+			ins.Index = byteOrder.Uint16(code[pc+2:])
+			switch ins.Opcode {
+			case OP_IINC:
+				if !need(6) {
+					return nil, ERR_TRUNCATED_CODE
+				}
+				ins.Const = int32(int16(byteOrder.Uint16(code[pc+4:])))
+				ins.Length = 6
+			case OP_ILOAD, OP_LLOAD, OP_FLOAD, OP_DLOAD, OP_ALOAD,
+				OP_ISTORE, OP_LSTORE, OP_FSTORE, OP_DSTORE, OP_ASTORE, OP_RET:
+				ins.Length = 4
+			default:
+				return nil, fmt.Errorf("%d: %s", pc, ERR_INVALID_WIDE)
+			}
+
+		case ins.IsSwitch():
+			// 操作数按 4 字节对齐
+			base := pc + 1 + (3-pc%4+4)%4
+			if ins.Opcode == OP_TABLESWITCH {
+				if base+12 > len(code) {
+					return nil, ERR_TRUNCATED_CODE
+				}
+				ins.Default = pc + int(int32(byteOrder.Uint32(code[base:])))
+				low := int32(byteOrder.Uint32(code[base+4:]))
+				high := int32(byteOrder.Uint32(code[base+8:]))
+				// high - low 可能超出 int32
+				n := int64(high) - int64(low) + 1
+				if high < low || int64(base+12)+4*n > int64(len(code)) {
+					return nil, ERR_TRUNCATED_CODE
+				}
+				for j := 0; j < int(n); j++ {
+					ins.Keys = append(ins.Keys, low+int32(j))
+					ins.Targets = append(ins.Targets, pc+int(int32(byteOrder.Uint32(code[base+12+4*j:]))))
+				}
+				ins.Length = base + 12 + 4*int(n) - pc
+			} else {
+				if base+8 > len(code) {
+					return nil, ERR_TRUNCATED_CODE
+				}
+				ins.Default = pc + int(int32(byteOrder.Uint32(code[base:])))
+				npairs := int(int32(byteOrder.Uint32(code[base+4:])))
+				if npairs < 0 || base+8+8*npairs > len(code) {
+					return nil, ERR_TRUNCATED_CODE
+				}
+				for j := 0; j < npairs; j++ {
+					pair := code[base+8+8*j:]
+					ins.Keys = append(ins.Keys, int32(byteOrder.Uint32(pair)))
+					ins.Targets = append(ins.Targets, pc+int(int32(byteOrder.Uint32(pair[4:]))))
+				}
+				ins.Length = base + 8 + 8*npairs - pc
+			}
+
+		default:
+			ins.Length = 1
+			if n, ok := instructionLengths[ins.Opcode]; ok {
+				ins.Length = n
+			} else if ins.IsBranch() {
+				ins.Length = 3
+			}
+			if !need(ins.Length) {
+				return nil, ERR_TRUNCATED_CODE
+			}
+
+			switch {
+			case ins.Opcode == OP_GOTO_W || ins.Opcode == OP_JSR_W:
+				ins.Branch = pc + int(int32(byteOrder.Uint32(code[pc+1:])))
+			case ins.IsBranch():
+				ins.Branch = pc + int(int16(byteOrder.Uint16(code[pc+1:])))
+			case ins.Opcode == OP_BIPUSH:
+				ins.Const = int32(int8(code[pc+1]))
+			case ins.Opcode == OP_SIPUSH:
+				ins.Const = int32(int16(byteOrder.Uint16(code[pc+1:])))
+			case ins.Opcode == OP_NEWARRAY:
+				ins.Const = int32(code[pc+1])
+			case ins.Opcode == OP_LDC:
+				ins.Index = uint16(code[pc+1])
+			case ins.Opcode == OP_IINC:
+				ins.Index = uint16(code[pc+1])
+				ins.Const = int32(int8(code[pc+2]))
+			case ins.usesLocal():
+				ins.Index = uint16(code[pc+1])
+			case ins.usesConstantPool():
+				ins.Index = byteOrder.Uint16(code[pc+1:])
+				switch ins.Opcode {
+				case OP_MULTIANEWARRAY:
+					ins.Const = int32(code[pc+3])
+				case OP_INVOKEINTERFACE:
+					ins.Const = int32(code[pc+3])
+				}
+			}
+		}
+
+		rs = append(rs, ins)
+		pc += ins.Length
+	}
+
+	// 校验跳转目标都落在代码范围内
+	for _, ins := range rs {
+		targets := ins.Targets
+		if ins.IsSwitch() {
+			targets = append([]int{ins.Default}, targets...)
+		} else if ins.IsBranch() {
+			targets = []int{ins.Branch}
+		}
+		for _, target := range targets {
+			if target < 0 || target >= len(code) {
+				return nil, fmt.Errorf("%d: %s", ins.Offset, ERR_INVALID_BRANCH)
+			}
+		}
+	}
+
+	return rs, nil
+}
+
+// 解码本方法的字节码
+func (c *CodeAttribute) Instructions() ([]*Instruction, error) {
+	return DecodeInstructions(c.Code)
+}
+
+// 局部变量下标：xload_<n> 与 xstore_<n> 的隐含下标也会被计算出来；不访问局部变量的指令返回 -1
+func (i *Instruction) LocalIndex() int {
+	switch {
+	case i.usesLocal():
+		return int(i.Index)
+	case i.Opcode >= OP_ILOAD_0 && i.Opcode <= OP_ALOAD_3:
+		return int(i.Opcode-OP_ILOAD_0) % 4
+	case i.Opcode >= OP_ISTORE_0 && i.Opcode <= OP_ASTORE_3:
+		return int(i.Opcode-OP_ISTORE_0) % 4
+	}
+	return -1
+}
@@ -0,0 +1,297 @@
+package jclass
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ERR_DUPLICATE_ENTRY = errors.New("duplicate entry")
+)
+
+type JarEntry struct {
+	Name     string
+	Data     []byte
+	Modified time.Time
+
+	// 仅当条目是 .class 文件时非 nil
+	Class *ClassFile
+
+	// 对条目签名有效的签名者，由 VerifySignatures 设置
+	Signers []*JarSigner
+}
+
+func (e *JarEntry) IsClass() bool {
+	return strings.HasSuffix(e.Name, ".class")
+}
+
+// Jar 表示一个 jar（zip）文件或者展开的类目录
+type Jar struct {
+	Path    string
+	Entries []*JarEntry
+
+	entries map[string]*JarEntry
+	classes map[string]*ClassFile
+
+	// 已解析的 META-INF/versions 下的类，按条目名缓存
+	versioned map[string]*ClassFile
+
+	// 已解析的 MANIFEST.MF 及其来自的条目，见 Manifest
+	manifest      *Manifest
+	manifestErr   error
+	manifestEntry *JarEntry
+}
+
+func (j *Jar) String() string {
+	return j.Path
+}
+
+// 按条目路径查找，如 META-INF/MANIFEST.MF
+func (j *Jar) Entry(name string) *JarEntry {
+	return j.entries[name]
+}
+
+// 按内部类名查找，如 java/lang/String
+func (j *Jar) Class(name string) *ClassFile {
+	return j.classes[name]
+}
+
+// 按名称排序的内部类名
+func (j *Jar) ClassNames() []string {
+	rs := make([]string, 0, len(j.classes))
+	for name := range j.classes {
+		rs = append(rs, name)
+	}
+	sort.Strings(rs)
+	return rs
+}
+
+// 模块名：优先取 module-info.class 中声明的名称，其次是 MANIFEST.MF 中的 Automatic-Module-Name，
+// 否则按 java.lang.module.ModuleFinder 的规则从文件名推导自动模块名
+func (j *Jar) ModuleName() string {
+	if info := j.Class("module-info"); info != nil {
+		if module := info.Module(); module != nil {
+			return module.ModuleNameString()
+		}
+	}
+	if m, err := j.Manifest(); err == nil && m != nil && m.AutomaticModuleName() != "" {
+		return m.AutomaticModuleName()
+	}
+	return automaticModuleName(filepath.Base(j.Path))
+}
+
+func automaticModuleName(file string) string {
+	name := strings.TrimSuffix(file, ".jar")
+
+	// 去掉版本号：第一个后面紧跟数字的连字符及其之后的部分
+	for i := 0; i+1 < len(name); i++ {
+		if name[i] == '-' && name[i+1] >= '0' && name[i+1] <= '9' {
+			name = name[:i]
+			break
+		}
+	}
+
+	s := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			c = '.'
+		}
+		if c == '.' && (len(s) == 0 || s[len(s)-1] == '.') {
+			continue
+		}
+		s = append(s, c)
+	}
+	return strings.TrimSuffix(string(s), ".")
+}
+
+func (j *Jar) addEntry(name string, data []byte, modified time.Time) error {
+	entry := &JarEntry{
+		Name:     name,
+		Data:     data,
+		Modified: modified,
+	}
+
+	// META-INF/versions/ 下的类属于多版本 jar 的附加层，这里只收录基础层，见 ForRelease
+	if entry.IsClass() && !strings.HasPrefix(name, "META-INF/versions/") {
+		class, err := NewClassFile(bytes.NewReader(data))
+		if err != nil {
+			return &JarError{Path: j.Path, Entry: name, Err: err}
+		}
+		entry.Class = class
+		j.classes[class.ThisClassString()] = class
+	}
+
+	j.Entries = append(j.Entries, entry)
+	j.entries[name] = entry
+	return nil
+}
+
+// 创建一个空的 jar，用于在内存中构造后写出
+func NewJar(path string) *Jar {
+	return &Jar{
+		Path:    path,
+		entries: make(map[string]*JarEntry),
+		classes: make(map[string]*ClassFile),
+	}
+}
+
+// 追加条目，.class 条目会被解析；同名条目已存在时返回错误
+func (j *Jar) AddEntry(name string, data []byte, modified time.Time) error {
+	if j.entries[name] != nil {
+		return &JarError{Path: j.Path, Entry: name, Err: ERR_DUPLICATE_ENTRY}
+	}
+	return j.addEntry(name, data, modified)
+}
+
+// 按条目顺序写出 zip 格式的 jar
+func (j *Jar) Write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range j.Entries {
+		header := &zip.FileHeader{
+			Name:     entry.Name,
+			Method:   zip.Deflate,
+			Modified: entry.Modified,
+		}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err = fw.Write(entry.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// 写出到文件，path 为空时使用 j.Path
+func (j *Jar) WriteFile(path string) error {
+	if path == "" {
+		path = j.Path
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = j.Write(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// 打开一个 jar 文件；若 path 为目录，则按展开的类目录读取
+func NewJarFromPath(path string) (*Jar, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := NewJar(path)
+
+	if info.IsDir() {
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			name, err := filepath.Rel(path, p)
+			if err != nil {
+				return err
+			}
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return rs.addEntry(filepath.ToSlash(name), data, info.ModTime())
+		})
+		if err != nil {
+			return nil, err
+		}
+		return rs, nil
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		err = rs.addEntry(f.Name, data, f.Modified)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rs, nil
+}
+
+type JarError struct {
+	Path  string
+	Entry string
+	Err   error
+}
+
+func (e *JarError) Error() string {
+	return e.Path + "!/" + e.Entry + ": " + e.Err.Error()
+}
+
+// jar 检查发现的问题
+type JarIssue struct {
+	// 出问题的条目，针对整个 jar 时为空
+	Entry   string
+	Message string
+}
+
+func (i *JarIssue) String() string {
+	if i.Entry == "" {
+		return i.Message
+	}
+	return i.Entry + ": " + i.Message
+}
+
+// META-INF/services 中登记的服务：服务接口名 -> 实现类名，均为 Java 形式
+func (j *Jar) Services() map[string][]string {
+	rs := make(map[string][]string)
+	for _, entry := range j.Entries {
+		if !strings.HasPrefix(entry.Name, "META-INF/services/") {
+			continue
+		}
+		service := strings.TrimPrefix(entry.Name, "META-INF/services/")
+		if service == "" || strings.Contains(service, "/") {
+			continue
+		}
+		for _, line := range strings.Split(string(entry.Data), "\n") {
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				line = line[:i]
+			}
+			if line = strings.TrimSpace(line); line != "" {
+				rs[service] = append(rs[service], line)
+			}
+		}
+	}
+	return rs
+}
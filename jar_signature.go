@@ -0,0 +1,585 @@
+package jclass
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ERR_UNSUPPORTED_DIGEST    = errors.New("unsupported digest algorithm")
+	ERR_UNSUPPORTED_SIGNATURE = errors.New("unsupported signature algorithm")
+	ERR_SIGNER_NOT_FOUND      = errors.New("signer certificate not found")
+	ERR_BAD_SIGNATURE         = errors.New("signature does not match")
+	ERR_NOT_SIGNED_DATA       = errors.New("not a PKCS#7 SignedData")
+)
+
+// 签名块对应的一个签名者，如 META-INF/CERT.SF 与 META-INF/CERT.RSA
+type JarSigner struct {
+	// 签名文件名去掉扩展名，如 META-INF/CERT
+	Name string
+	// 签名证书在前，其后是签名块中附带的其余证书
+	Certificates []*x509.Certificate
+	// 签名块中的 signingTime 属性，没有时为零值。由签名者自行填写，未经认证，不用于校验证书链
+	SigningTime time.Time
+	// 经时间戳机构签名的 RFC 3161 时间戳，没有、无法验证或未给出信任库时为零值。
+	// 有时间戳时证书链按该时间校验，否则按当前时间校验
+	Timestamp time.Time
+	// 签名证书链可以验证到信任库中的根证书
+	Trusted bool
+	// 不可信的原因，Trusted 为 true 或未给出信任库时为 nil
+	TrustError error
+}
+
+func (s *JarSigner) String() string {
+	if len(s.Certificates) == 0 {
+		return s.Name
+	}
+	return s.Name + " (" + s.Certificates[0].Subject.String() + ")"
+}
+
+// 签名证书
+func (s *JarSigner) Certificate() *x509.Certificate {
+	if len(s.Certificates) == 0 {
+		return nil
+	}
+	return s.Certificates[0]
+}
+
+// 读取 PEM 格式的信任库，可以包含多个证书
+func LoadTrustStore(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	found := false
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		pool.AddCert(cert)
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no PEM certificates", file)
+	}
+	return pool, nil
+}
+
+// MANIFEST.MF 与 .SF 中摘要属性名的前缀，如 SHA-256-Digest
+var digestAlgorithms = map[string]crypto.Hash{
+	"MD5":     crypto.MD5,
+	"SHA1":    crypto.SHA1,
+	"SHA-1":   crypto.SHA1,
+	"SHA-256": crypto.SHA256,
+	"SHA-384": crypto.SHA384,
+	"SHA-512": crypto.SHA512,
+}
+
+func newHash(h crypto.Hash) hash.Hash {
+	switch h {
+	case crypto.MD5:
+		return md5.New()
+	case crypto.SHA1:
+		return sha1.New()
+	case crypto.SHA256:
+		return sha256.New()
+	case crypto.SHA384:
+		return sha512.New384()
+	case crypto.SHA512:
+		return sha512.New()
+	}
+	return nil
+}
+
+func digest(h crypto.Hash, data []byte) []byte {
+	d := newHash(h)
+	d.Write(data)
+	return d.Sum(nil)
+}
+
+// 段中形如 <算法>-Digest<suffix> 的摘要属性，返回算法与解码后的摘要；不支持的算法被跳过
+func sectionDigests(s *ManifestSection, suffix string) map[crypto.Hash][]byte {
+	rs := make(map[crypto.Hash][]byte)
+	for _, attr := range s.Attributes {
+		name := strings.ToUpper(attr.Name)
+		if !strings.HasSuffix(name, "-DIGEST"+strings.ToUpper(suffix)) {
+			continue
+		}
+		h, ok := digestAlgorithms[strings.TrimSuffix(name, "-DIGEST"+strings.ToUpper(suffix))]
+		if !ok {
+			continue
+		}
+		if value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(attr.Value)); err == nil {
+			rs[h] = value
+		}
+	}
+	return rs
+}
+
+// 所有摘要都匹配且至少有一个摘要时返回 true
+func digestsMatch(digests map[crypto.Hash][]byte, data []byte) bool {
+	for h, want := range digests {
+		if !bytes.Equal(digest(h, data), want) {
+			return false
+		}
+	}
+	return len(digests) > 0
+}
+
+// MANIFEST.MF 按段切分的原始字节，每段包含结尾的空行；.SF 中各段的摘要按原始字节计算
+type rawManifest struct {
+	main     []byte
+	sections map[string][]byte
+}
+
+func splitManifest(data []byte) *rawManifest {
+	rs := &rawManifest{sections: make(map[string][]byte)}
+	start := 0
+	flush := func(end int) {
+		section := data[start:end]
+		start = end
+		if len(bytes.TrimSpace(section)) == 0 {
+			return
+		}
+		if rs.main == nil {
+			rs.main = section
+			return
+		}
+		if m, err := ParseManifest(section); err == nil && m.Main.Name() != "" {
+			rs.sections[m.Main.Name()] = section
+		}
+	}
+	for pos := 0; pos < len(data); {
+		end := pos
+		for end < len(data) && data[end] != '\r' && data[end] != '\n' {
+			end++
+		}
+		blank := end == pos
+		switch {
+		case end+1 < len(data) && data[end] == '\r' && data[end+1] == '\n':
+			end += 2
+		case end < len(data):
+			end++
+		}
+		pos = end
+		if blank {
+			flush(pos)
+		}
+	}
+	flush(len(data))
+	if rs.main == nil {
+		rs.main = []byte{}
+	}
+	return rs
+}
+
+// PKCS#7（RFC 2315）中验证 jar 签名所需的结构
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	// RFC 3161 时间戳令牌（非认证属性）与其中的 TSTInfo 内容类型
+	oidTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+	oidTSTInfo        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+
+	pkcs7Digests = map[string]crypto.Hash{
+		"1.2.840.113549.2.5":     crypto.MD5,
+		"1.3.14.3.2.26":          crypto.SHA1,
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+)
+
+// 校验通过的签名块
+type pkcs7Signature struct {
+	// 签名证书在前，其后是签名块中附带的其余证书
+	certs       []*x509.Certificate
+	signingTime time.Time
+	signer      *pkcs7SignerInfo
+	// 签名块中封装的内容类型与内容，用于时间戳令牌中的 TSTInfo
+	contentType asn1.ObjectIdentifier
+	content     []byte
+}
+
+// 校验签名块（.RSA/.DSA/.EC）对 .SF 内容的签名。content 为 nil 时校验签名块中封装的内容
+func verifyPKCS7(block, content []byte) (*pkcs7Signature, error) {
+	var signingTime time.Time
+	var info pkcs7ContentInfo
+	if _, err := asn1.Unmarshal(block, &info); err != nil {
+		return nil, err
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, ERR_NOT_SIGNED_DATA
+	}
+	var sd pkcs7SignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	if content == nil {
+		if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+			return nil, err
+		}
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, ERR_SIGNER_NOT_FOUND
+	}
+
+	// jar 签名块只有一个签名者
+	si := sd.SignerInfos[0]
+	var signer *x509.Certificate
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, si.IssuerAndSerialNumber.Issuer.FullBytes) && cert.SerialNumber.Cmp(si.IssuerAndSerialNumber.Serial) == 0 {
+			signer = cert
+			break
+		}
+	}
+	if signer == nil {
+		return nil, ERR_SIGNER_NOT_FOUND
+	}
+	h, ok := pkcs7Digests[si.DigestAlgorithm.Algorithm.String()]
+	if !ok {
+		return nil, ERR_UNSUPPORTED_DIGEST
+	}
+
+	// 有认证属性时签名的是属性集合的 DER 编码（标签改为 SET），其中的 messageDigest 是内容的摘要
+	signed := content
+	if len(si.AuthenticatedAttributes.FullBytes) > 0 {
+		rest := si.AuthenticatedAttributes.Bytes
+		var messageDigest []byte
+		for len(rest) > 0 {
+			var attr pkcs7Attribute
+			if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+				return nil, err
+			}
+			switch {
+			case attr.Type.Equal(oidMessageDigest):
+				if _, err := asn1.Unmarshal(attr.Values.Bytes, &messageDigest); err != nil {
+					return nil, err
+				}
+			case attr.Type.Equal(oidSigningTime):
+				asn1.Unmarshal(attr.Values.Bytes, &signingTime)
+			}
+		}
+		if !bytes.Equal(messageDigest, digest(h, content)) {
+			return nil, ERR_BAD_SIGNATURE
+		}
+		signed = append([]byte{0x31}, si.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+
+	hashed := digest(h, signed)
+	switch pub := signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(pub, h, hashed, si.EncryptedDigest) != nil {
+			err = ERR_BAD_SIGNATURE
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, hashed, si.EncryptedDigest) {
+			err = ERR_BAD_SIGNATURE
+		}
+	case *dsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		// FIPS 186-3 4.6：摘要截断为子群的字节长度
+		if n := pub.Q.BitLen() / 8; len(hashed) > n {
+			hashed = hashed[:n]
+		}
+		if _, e := asn1.Unmarshal(si.EncryptedDigest, &sig); e != nil || !dsa.Verify(pub, hashed, sig.R, sig.S) {
+			err = ERR_BAD_SIGNATURE
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, signed, si.EncryptedDigest) {
+			err = ERR_BAD_SIGNATURE
+		}
+	default:
+		err = ERR_UNSUPPORTED_SIGNATURE
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rs := []*x509.Certificate{signer}
+	for _, cert := range certs {
+		if cert != signer {
+			rs = append(rs, cert)
+		}
+	}
+	return &pkcs7Signature{
+		certs:       rs,
+		signingTime: signingTime,
+		signer:      &si,
+		contentType: sd.ContentInfo.ContentType,
+		content:     content,
+	}, nil
+}
+
+// RFC 3161 TSTInfo 中用到的字段，其后的可选字段忽略
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint struct {
+		HashAlgorithm pkix.AlgorithmIdentifier
+		HashedMessage []byte
+	}
+	SerialNumber *big.Int
+	GenTime      time.Time `asn1:"generalized"`
+}
+
+// 签名者非认证属性中的时间戳令牌：令牌的签名有效、摘要是对签名值的摘要、
+// 时间戳证书在时间戳时刻可以验证到 roots 且用于时间戳时返回该时刻，否则返回零值
+func verifyTimestamp(si *pkcs7SignerInfo, roots *x509.CertPool) time.Time {
+	rest := si.UnauthenticatedAttributes.Bytes
+	for len(rest) > 0 {
+		var attr pkcs7Attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil || !attr.Type.Equal(oidTimeStampToken) {
+			continue
+		}
+		token, err := verifyPKCS7(attr.Values.Bytes, nil)
+		if err != nil || !token.contentType.Equal(oidTSTInfo) {
+			continue
+		}
+		var tst tstInfo
+		if _, err := asn1.Unmarshal(token.content, &tst); err != nil {
+			continue
+		}
+		h, ok := pkcs7Digests[tst.MessageImprint.HashAlgorithm.Algorithm.String()]
+		if !ok || !bytes.Equal(digest(h, si.EncryptedDigest), tst.MessageImprint.HashedMessage) {
+			continue
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range token.certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err = token.certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   tst.GenTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		})
+		if err == nil {
+			return tst.GenTime
+		}
+	}
+	return time.Time{}
+}
+
+// jar 签名所需的文件：清单、签名文件与签名块，不需要被签名
+func isSignatureRelated(name string) bool {
+	if name == "META-INF/MANIFEST.MF" || isSignatureFile(name) {
+		return true
+	}
+	upper := strings.ToUpper(name)
+	return strings.HasPrefix(upper, "META-INF/SIG-") && strings.Count(name, "/") == 1
+}
+
+// 按 JAR 签名规范校验 jar：签名块对 .SF 的签名、.SF 对清单（整体或逐段）的摘要、
+// 清单对各条目内容的摘要。roots 为 nil 时不校验证书链。校验后每个条目的 Signers 为对其签名有效的签名者，
+// 返回的问题包括被篡改的条目、未签名的条目、只被部分签名者签名的条目以及无效的签名块
+func (j *Jar) VerifySignatures(roots *x509.CertPool) ([]*JarSigner, []*JarIssue, error) {
+	var issues []*JarIssue
+	report := func(entry, format string, args ...interface{}) {
+		issues = append(issues, &JarIssue{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, entry := range j.Entries {
+		entry.Signers = nil
+	}
+
+	manifestEntry := j.Entry("META-INF/MANIFEST.MF")
+	var blocks []*JarEntry
+	for _, entry := range j.Entries {
+		if isSignatureFile(entry.Name) && !strings.HasSuffix(strings.ToUpper(entry.Name), ".SF") {
+			blocks = append(blocks, entry)
+		}
+	}
+	if manifestEntry == nil || len(blocks) == 0 {
+		return nil, nil, nil
+	}
+	manifest, err := ParseManifest(manifestEntry.Data)
+	if err != nil {
+		return nil, nil, &JarError{Path: j.Path, Entry: manifestEntry.Name, Err: err}
+	}
+	raw := splitManifest(manifestEntry.Data)
+
+	var signers []*JarSigner
+	for _, block := range blocks {
+		name := strings.TrimSuffix(block.Name, path.Ext(block.Name))
+		sfEntry := j.Entry(name + ".SF")
+		if sfEntry == nil {
+			report(block.Name, "no signature file %s.SF", name)
+			continue
+		}
+		sig, err := verifyPKCS7(block.Data, sfEntry.Data)
+		if err != nil {
+			report(block.Name, "invalid signature block: %v", err)
+			continue
+		}
+		sf, err := ParseManifest(sfEntry.Data)
+		if err != nil {
+			report(sfEntry.Name, "%v", err)
+			continue
+		}
+
+		certs := sig.certs
+		signer := &JarSigner{Name: name, Certificates: certs, SigningTime: sig.signingTime}
+		if roots != nil {
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+			// signingTime 可以由签名者任意填写，只有经过验证的时间戳才能代替当前时间
+			signer.Timestamp = verifyTimestamp(sig.signer, roots)
+			at := signer.Timestamp
+			if at.IsZero() {
+				at = time.Now()
+			}
+			_, signer.TrustError = certs[0].Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: intermediates,
+				CurrentTime:   at,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			})
+			signer.Trusted = signer.TrustError == nil
+		}
+		signers = append(signers, signer)
+
+		// 清单整体的摘要匹配时 .SF 中的各段都有效，否则逐段校验
+		whole := digestsMatch(sectionDigests(sf.Main, "-Manifest"), manifestEntry.Data)
+		if !whole {
+			if mainDigests := sectionDigests(sf.Main, "-Manifest-Main-Attributes"); len(mainDigests) > 0 && !digestsMatch(mainDigests, raw.main) {
+				report(sfEntry.Name, "manifest main attributes were modified after signing")
+			}
+		}
+		for _, section := range sf.Sections {
+			entryName := section.Name()
+			if !whole {
+				data, ok := raw.sections[entryName]
+				if !ok {
+					report(entryName, "signed by %s but missing from the manifest", name)
+					continue
+				}
+				if !digestsMatch(sectionDigests(section, ""), data) {
+					report(entryName, "manifest section was modified after signing by %s", name)
+					continue
+				}
+			}
+			if entry := j.Entry(entryName); entry != nil {
+				entry.Signers = append(entry.Signers, signer)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		return nil, issues, nil
+	}
+
+	sections := make(map[string]*ManifestSection, len(manifest.Sections))
+	for _, section := range manifest.Sections {
+		if _, ok := sections[section.Name()]; !ok {
+			sections[section.Name()] = section
+		}
+		if digests := sectionDigests(section, ""); len(digests) > 0 && j.Entry(section.Name()) == nil {
+			report(section.Name(), "signed entry is missing from the jar")
+		}
+	}
+	for _, entry := range j.Entries {
+		if isSignatureRelated(entry.Name) {
+			continue
+		}
+		section := sections[entry.Name]
+		switch {
+		case section == nil || len(sectionDigests(section, "")) == 0:
+			report(entry.Name, "unsigned entry")
+			entry.Signers = nil
+		case !digestsMatch(sectionDigests(section, ""), entry.Data):
+			report(entry.Name, "digest does not match, entry was modified after signing")
+			entry.Signers = nil
+		case len(entry.Signers) == 0:
+			report(entry.Name, "unsigned entry, listed in the manifest but in no signature file")
+		case len(entry.Signers) < len(signers):
+			names := make([]string, len(entry.Signers))
+			for i, s := range entry.Signers {
+				names[i] = s.Name
+			}
+			sort.Strings(names)
+			report(entry.Name, "signed only by %s", strings.Join(names, ", "))
+		}
+	}
+	return signers, issues, nil
+}
+
+// 对类签名有效的签名者，需要先调用 VerifySignatures
+func (j *Jar) ClassSigners(name string) []*JarSigner {
+	if entry := j.Entry(name + ".class"); entry != nil {
+		return entry.Signers
+	}
+	return nil
+}
@@ -0,0 +1,541 @@
+package jclass
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ProGuard 风格的保留规则，支持 -keep、-keepclassmembers 与 -keepclasseswithmembers 三种选项：
+//
+//	-keep public class com.acme.Main { public static void main(java.lang.String[]); }
+//	-keep class com.acme.plugin.** implements com.acme.Plugin { <init>(); }
+//	-keep @com.acme.Keep class * { *; }
+//	-keepclassmembers class * { @javax.inject.Inject <fields>; }
+//	-keepclasseswithmembers class * { native <methods>; }
+//
+// 类名中 ? 匹配单个字符，* 匹配不含 . 的任意字符，** 匹配任意字符；以 ! 开头的类名表示排除。
+// 成员类型中 % 匹配基本类型，*** 匹配任意类型，参数列表中 ... 匹配任意参数。
+// 其余选项（如 -dontwarn、-keepattributes）被忽略，记录在 Ignored 中
+type KeepConfig struct {
+	Rules   []*KeepRule
+	Ignored []string
+}
+
+const (
+	KEEP                          = "-keep"
+	KEEP_CLASS_MEMBERS            = "-keepclassmembers"
+	KEEP_CLASSES_WITH_MEMBERS     = "-keepclasseswithmembers"
+	MEMBER_SPEC_FIELD             = "field"
+	MEMBER_SPEC_METHOD            = "method"
+	MEMBER_SPEC_ANY               = "any"
+	keepConfigModifierAllowShrink = "allowshrinking"
+)
+
+type KeepRule struct {
+	Line   int
+	Option string
+	// 带有 allowshrinking 修饰的规则不作为入口
+	AllowShrinking bool
+
+	annotation string
+	// class、interface、enum 或 @interface，为空表示任意
+	classType    string
+	classTypeNot bool
+	access       map[string]bool
+	classNames   []*namePattern
+	extends      string
+	extendsAnn   string
+
+	Members []*MemberSpec
+}
+
+type MemberSpec struct {
+	Kind       string
+	annotation string
+	access     map[string]bool
+	// 类型与名称模式为 nil 时匹配任意
+	typ  *regexp.Regexp
+	name *regexp.Regexp
+	// 参数列表模式，仅方法有效，nil 表示任意参数
+	args *regexp.Regexp
+}
+
+type namePattern struct {
+	not bool
+	re  *regexp.Regexp
+}
+
+// ProGuard 类名模式转换为正则表达式
+func compileKeepPattern(pattern string, types bool) *regexp.Regexp {
+	s := &strings.Builder{}
+	s.WriteString("^")
+	for i := 0; i < len(pattern); {
+		switch {
+		case types && strings.HasPrefix(pattern[i:], "***"):
+			s.WriteString(`.*`)
+			i += 3
+		case strings.HasPrefix(pattern[i:], "**"):
+			s.WriteString(`[^\[\]]*`)
+			i += 2
+		case pattern[i] == '*':
+			s.WriteString(`[^.\[\]]*`)
+			i++
+		case pattern[i] == '?':
+			s.WriteString(`[^.\[\]]`)
+			i++
+		case types && pattern[i] == '%':
+			s.WriteString(`(?:byte|char|double|float|int|long|short|boolean|void)`)
+			i++
+		default:
+			s.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+	s.WriteString("$")
+	return regexp.MustCompile(s.String())
+}
+
+func compileArgsPattern(args []string) *regexp.Regexp {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "..." {
+			parts = append(parts, `.*`)
+			continue
+		}
+		re := compileKeepPattern(arg, true).String()
+		parts = append(parts, re[1:len(re)-1])
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ",") + "$")
+}
+
+var keepAccessModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "static": true, "final": true,
+	"abstract": true, "synchronized": true, "native": true, "volatile": true, "transient": true,
+	"strictfp": true, "synthetic": true, "bridge": true, "varargs": true,
+}
+
+type keepToken struct {
+	text string
+	line int
+}
+
+func tokenizeKeepConfig(s string) []keepToken {
+	var rs []keepToken
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.IndexByte("{};(),", c) >= 0:
+			rs = append(rs, keepToken{s[i : i+1], line})
+			i++
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && strings.IndexByte("{};(),#", s[j]) < 0 {
+				j++
+			}
+			rs = append(rs, keepToken{s[i:j], line})
+			i = j
+		}
+	}
+	return rs
+}
+
+type keepParser struct {
+	tokens []keepToken
+	pos    int
+}
+
+func (p *keepParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *keepParser) next() string {
+	s := p.peek()
+	p.pos++
+	return s
+}
+
+func (p *keepParser) line() int {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].line
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens[len(p.tokens)-1].line
+	}
+	return 0
+}
+
+func (p *keepParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line(), fmt.Sprintf(format, args...))
+}
+
+func ParseKeepConfig(r io.Reader) (*KeepConfig, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &KeepConfig{}
+	p := &keepParser{tokens: tokenizeKeepConfig(string(data))}
+	for p.peek() != "" {
+		line := p.line()
+		option := p.next()
+		if !strings.HasPrefix(option, "-") {
+			return nil, fmt.Errorf("line %d: expected option, found %q", line, option)
+		}
+
+		switch option {
+		case KEEP, KEEP_CLASS_MEMBERS, KEEP_CLASSES_WITH_MEMBERS:
+			rule, err := p.keepRule(option, line)
+			if err != nil {
+				return nil, err
+			}
+			rs.Rules = append(rs.Rules, rule)
+
+		default:
+			// 跳过选项参数，直到下一个选项
+			rs.Ignored = append(rs.Ignored, option)
+			depth := 0
+			for p.peek() != "" && (depth > 0 || !strings.HasPrefix(p.peek(), "-")) {
+				switch p.next() {
+				case "{":
+					depth++
+				case "}":
+					depth--
+				}
+			}
+		}
+	}
+	return rs, nil
+}
+
+func (p *keepParser) keepRule(option string, line int) (*KeepRule, error) {
+	rule := &KeepRule{Line: line, Option: option, access: make(map[string]bool)}
+
+	for p.peek() == "," {
+		p.next()
+		if p.next() == keepConfigModifierAllowShrink {
+			rule.AllowShrinking = true
+		}
+	}
+
+	if strings.HasPrefix(p.peek(), "@") && p.peek() != "@interface" {
+		rule.annotation = p.next()[1:]
+	}
+	for {
+		word := p.peek()
+		if keepAccessModifiers[strings.TrimPrefix(word, "!")] {
+			rule.access[word] = true
+			p.next()
+			continue
+		}
+		break
+	}
+
+	switch t := p.next(); strings.TrimPrefix(t, "!") {
+	case "class", "interface", "enum", "@interface":
+		rule.classTypeNot = strings.HasPrefix(t, "!")
+		rule.classType = strings.TrimPrefix(t, "!")
+		if rule.classType == "class" && !rule.classTypeNot {
+			// ProGuard 中 class 匹配任意类型
+			rule.classType = ""
+		}
+	default:
+		return nil, p.errorf("expected class, interface, enum or @interface, found %q", t)
+	}
+
+	for {
+		name := p.next()
+		if name == "" || strings.IndexByte("{};(),", name[0]) >= 0 {
+			return nil, p.errorf("expected class name, found %q", name)
+		}
+		pattern := &namePattern{not: strings.HasPrefix(name, "!")}
+		pattern.re = compileKeepPattern(strings.TrimPrefix(name, "!"), false)
+		rule.classNames = append(rule.classNames, pattern)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+
+	if p.peek() == "extends" || p.peek() == "implements" {
+		p.next()
+		if strings.HasPrefix(p.peek(), "@") {
+			rule.extendsAnn = p.next()[1:]
+		}
+		rule.extends = p.next()
+		if rule.extends == "" {
+			return nil, p.errorf("expected class name after extends")
+		}
+	}
+
+	if p.peek() != "{" {
+		return rule, nil
+	}
+	p.next()
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, p.errorf("unexpected end of config, missing }")
+		}
+		spec, err := p.memberSpec()
+		if err != nil {
+			return nil, err
+		}
+		rule.Members = append(rule.Members, spec)
+	}
+	p.next()
+	return rule, nil
+}
+
+func (p *keepParser) memberSpec() (*MemberSpec, error) {
+	spec := &MemberSpec{Kind: MEMBER_SPEC_ANY, access: make(map[string]bool)}
+
+	var words []string
+	var args []string
+	hasArgs := false
+	for p.peek() != ";" {
+		switch word := p.next(); word {
+		case "", "}", "{":
+			return nil, p.errorf("expected ; after member specification")
+		case "(":
+			hasArgs = true
+			for p.peek() != ")" {
+				arg := p.next()
+				if arg == "" || arg == ";" {
+					return nil, p.errorf("expected ) in member specification")
+				}
+				if arg != "," {
+					args = append(args, arg)
+				}
+			}
+			p.next()
+		case "=", "return":
+			// -assumenosideeffects 风格的返回值说明，忽略到分号为止
+			for p.peek() != ";" && p.peek() != "" {
+				p.next()
+			}
+		default:
+			words = append(words, word)
+		}
+	}
+	p.next()
+
+	if len(words) > 0 && strings.HasPrefix(words[0], "@") {
+		spec.annotation = words[0][1:]
+		words = words[1:]
+	}
+	for len(words) > 0 && keepAccessModifiers[strings.TrimPrefix(words[0], "!")] {
+		spec.access[words[0]] = true
+		words = words[1:]
+	}
+
+	switch {
+	case len(words) == 1 && words[0] == "*" && !hasArgs:
+	case len(words) == 1 && words[0] == "<fields>":
+		spec.Kind = MEMBER_SPEC_FIELD
+	case len(words) == 1 && words[0] == "<methods>":
+		spec.Kind = MEMBER_SPEC_METHOD
+	case len(words) == 1 && (words[0] == "<init>" || words[0] == "<clinit>") && hasArgs:
+		spec.Kind = MEMBER_SPEC_METHOD
+		spec.name = regexp.MustCompile("^" + regexp.QuoteMeta(words[0]) + "$")
+		spec.args = compileArgsPattern(args)
+	case len(words) == 2:
+		spec.typ = compileKeepPattern(words[0], true)
+		spec.name = compileKeepPattern(words[1], false)
+		spec.Kind = MEMBER_SPEC_FIELD
+		if hasArgs {
+			spec.Kind = MEMBER_SPEC_METHOD
+			spec.args = compileArgsPattern(args)
+		}
+	default:
+		return nil, p.errorf("invalid member specification: %s", strings.Join(words, " "))
+	}
+	return spec, nil
+}
+
+// Java 形式的描述符类型列表：字段为单个类型，方法为 (参数列表, 返回类型)
+func javaParameterNames(desc string) (args string, result string) {
+	sig, err := ParseMethodSignature(desc)
+	if err != nil {
+		return "", ""
+	}
+	names := make([]string, len(sig.Parameters))
+	for i, param := range sig.Parameters {
+		names[i] = param.JavaName()
+	}
+	return strings.Join(names, ","), sig.Result.JavaName()
+}
+
+func matchAccess(required map[string]bool, flags uint16) bool {
+	bits := map[string]uint16{
+		"public": 0x0001, "private": 0x0002, "protected": 0x0004, "static": 0x0008,
+		"final": 0x0010, "synchronized": 0x0020, "volatile": 0x0040, "bridge": 0x0040,
+		"transient": 0x0080, "varargs": 0x0080, "native": 0x0100, "abstract": 0x0400,
+		"strictfp": 0x0800, "synthetic": 0x1000,
+	}
+	for modifier := range required {
+		not := strings.HasPrefix(modifier, "!")
+		set := flags&bits[strings.TrimPrefix(modifier, "!")] != 0
+		if set == not {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MemberSpec) matchField(f *FieldInfo) bool {
+	if s.Kind == MEMBER_SPEC_METHOD || !matchAccess(s.access, uint16(f.AccessFlags)) {
+		return false
+	}
+	if s.annotation != "" && !hasAnnotation(f.Annotations(), []string{s.annotation}) {
+		return false
+	}
+	if s.name != nil && !s.name.MatchString(f.NameString()) {
+		return false
+	}
+	if s.typ != nil {
+		t, err := ParseFieldSignature(f.DescriptorString())
+		if err != nil || !s.typ.MatchString(t.JavaName()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MemberSpec) matchMethod(m *MethodInfo) bool {
+	if s.Kind == MEMBER_SPEC_FIELD || !matchAccess(s.access, uint16(m.AccessFlags)) {
+		return false
+	}
+	if s.annotation != "" && !hasAnnotation(m.Annotations(), []string{s.annotation}) {
+		return false
+	}
+	name := m.NameString()
+	if s.name != nil && !s.name.MatchString(name) {
+		return false
+	}
+	// 方法名中的通配符不匹配构造器与类初始化块
+	if s.name != nil && strings.HasPrefix(name, "<") && s.name.String() != "^"+regexp.QuoteMeta(name)+"$" {
+		return false
+	}
+	args, result := javaParameterNames(m.DescriptorString())
+	if s.args != nil && !s.args.MatchString(args) {
+		return false
+	}
+	if s.typ != nil && !s.typ.MatchString(result) {
+		return false
+	}
+	return true
+}
+
+// 类本身是否匹配规则的类说明部分
+func (r *KeepRule) MatchClass(p *ClassPath, cf *ClassFile) bool {
+	name := strings.Replace(cf.ThisClassString(), "/", ".", -1)
+
+	// 与 ProGuard 一致，由第一个匹配的类名模式决定
+	matched := false
+	for _, pattern := range r.classNames {
+		if pattern.re.MatchString(name) {
+			matched = !pattern.not
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	if r.classType != "" {
+		var is bool
+		switch r.classType {
+		case "class":
+			is = cf.IsClass()
+		case "interface":
+			is = cf.IsInterface()
+		case "enum":
+			is = cf.IsEnum()
+		case "@interface":
+			is = cf.IsAnnotation()
+		}
+		if is == r.classTypeNot {
+			return false
+		}
+	}
+
+	if !matchAccess(r.access, uint16(cf.AccessFlags)) {
+		return false
+	}
+	if r.annotation != "" && !hasAnnotation(cf.Annotations(), []string{r.annotation}) {
+		return false
+	}
+
+	if r.extends != "" || r.extendsAnn != "" {
+		re := compileKeepPattern(r.extends, false)
+		found := false
+		for _, super := range p.Supertypes(cf.ThisClassString()) {
+			if !re.MatchString(strings.Replace(super, "/", ".", -1)) {
+				continue
+			}
+			if r.extendsAnn != "" {
+				scf := p.Class(super)
+				if scf == nil || !hasAnnotation(scf.Annotations(), []string{r.extendsAnn}) {
+					continue
+				}
+			}
+			found = true
+			break
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// 规则匹配的字段与方法
+func (r *KeepRule) MatchMembers(cf *ClassFile) (fields []*FieldInfo, methods []*MethodInfo) {
+	for _, spec := range r.Members {
+		for _, f := range cf.Fields {
+			if spec.matchField(f) {
+				fields = append(fields, f)
+			}
+		}
+		for _, m := range cf.Methods {
+			if spec.matchMethod(m) {
+				methods = append(methods, m)
+			}
+		}
+	}
+	return
+}
+
+// -keepclasseswithmembers 要求每条成员说明至少匹配一个成员
+func (r *KeepRule) hasAllMembers(cf *ClassFile) bool {
+	for _, spec := range r.Members {
+		found := false
+		for _, f := range cf.Fields {
+			found = found || spec.matchField(f)
+		}
+		for _, m := range cf.Methods {
+			found = found || spec.matchMethod(m)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package jclass
+
+import (
+	"sort"
+	"strings"
+)
+
+// 常量池中对其他类或成员的符号引用
+type Reference struct {
+	// "class"、"field"、"method" 或 "interface method"
+	Kind       string
+	Class      string
+	Name       string
+	Descriptor string
+}
+
+func (r *Reference) String() string {
+	switch r.Kind {
+	case "class":
+		return r.Class
+	case "field":
+		return r.Class + "." + r.Name + ":" + r.Descriptor
+	default:
+		return r.Class + "." + r.Name + r.Descriptor
+	}
+}
+
+type LinkageProblem struct {
+	Jar   *Jar
+	Class string
+	Ref   *Reference
+	// 是否经由 invokedynamic 的引导方法句柄引用
+	Bootstrap bool
+	Reason    string
+}
+
+// 返回常量池第 index 项所表示的符号引用；非 Class/Fieldref/Methodref/InterfaceMethodref 项返回 nil
+func (cf *ClassFile) ReferenceAt(index uint16) *Reference {
+	info := cf.ConstantPool[index]
+	if info == nil {
+		return nil
+	}
+
+	switch info.Tag {
+	case 7:
+		return &Reference{Kind: "class", Class: cf.ClassNameAt(index)}
+
+	case 9, 10, 11:
+		rs := &Reference{}
+		rs.Class, rs.Name, rs.Descriptor = cf.MemberRefAt(index)
+		switch info.Tag {
+		case 9:
+			rs.Kind = "field"
+		case 10:
+			rs.Kind = "method"
+		default:
+			rs.Kind = "interface method"
+		}
+		return rs
+	}
+
+	return nil
+}
+
+// 去掉数组维度，返回元素类型的类名；基本类型数组返回空串
+func elementClass(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return name
+	}
+	name = strings.TrimLeft(name, "[")
+	if strings.HasPrefix(name, "L") && strings.HasSuffix(name, ";") {
+		return name[1 : len(name)-1]
+	}
+	return ""
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 检查 classpath 上每个类的 Class/Fieldref/Methodref/InterfaceMethodref 常量以及引导方法句柄，
+// 报告无法解析到已存在的类或成员的引用。以 ignore 中任一前缀开头的类（如 java/）视为总是存在。
+func (p *ClassPath) CheckLinkage(ignore []string) []*LinkageProblem {
+	var rs []*LinkageProblem
+
+	for _, jar := range p.Jars {
+		var problems []*LinkageProblem
+		for _, name := range jar.ClassNames() {
+			problems = append(problems, p.checkClassLinkage(jar, jar.Class(name), ignore)...)
+		}
+
+		sort.SliceStable(problems, func(i, j int) bool {
+			return problems[i].Ref.String() < problems[j].Ref.String()
+		})
+		rs = append(rs, problems...)
+	}
+
+	return rs
+}
+
+func (p *ClassPath) checkClassLinkage(jar *Jar, cf *ClassFile, ignore []string) []*LinkageProblem {
+	var rs []*LinkageProblem
+	self := cf.ThisClassString()
+
+	bootstrap := make(map[uint16]bool)
+	for _, bm := range cf.BootstrapMethods() {
+		bootstrap[bm.MethodHandle().ReferenceIndex()] = true
+		// 引导参数中的方法句柄，如 lambda 的实现方法
+		for _, arg := range bm.BootstrapArguments {
+			if info := cf.ConstantPool[arg]; info.Tag == 15 {
+				bootstrap[(*ConstantMethodHandleInfo)(info).ReferenceIndex()] = true
+			}
+		}
+	}
+
+	missingClasses := make(map[string]bool)
+	report := func(index uint16, ref *Reference, reason string) {
+		rs = append(rs, &LinkageProblem{
+			Jar:       jar,
+			Class:     self,
+			Ref:       ref,
+			Bootstrap: bootstrap[index],
+			Reason:    reason,
+		})
+	}
+
+	// 先检查所有 Class 常量，成员引用所在的类缺失时不再重复报告成员
+	for i := 1; i < len(cf.ConstantPool); i++ {
+		index := uint16(i)
+		info := cf.ConstantPool[i]
+		if info == nil || info.Tag != 7 {
+			continue
+		}
+		class := elementClass(cf.ClassNameAt(index))
+		if class == "" || hasAnyPrefix(class, ignore) || p.Class(class) != nil {
+			continue
+		}
+		if !missingClasses[class] {
+			missingClasses[class] = true
+			report(index, &Reference{Kind: "class", Class: class}, "class not found")
+		}
+	}
+
+	for i := 1; i < len(cf.ConstantPool); i++ {
+		index := uint16(i)
+		ref := cf.ReferenceAt(index)
+		if ref == nil || ref.Kind == "class" {
+			continue
+		}
+		if strings.HasPrefix(ref.Class, "[") || hasAnyPrefix(ref.Class, ignore) || missingClasses[ref.Class] {
+			continue
+		}
+
+		target := p.Class(ref.Class)
+		if target == nil {
+			// 成员引用的 class_index 必然也是一个 Class 常量，已在上面报告
+			continue
+		}
+
+		switch ref.Kind {
+		case "field":
+			if _, _, r := p.ResolveField(ref.Class, ref.Name, ref.Descriptor); r == RESOLVE_MISSING {
+				report(index, ref, "field not found")
+			}
+
+		case "method", "interface method":
+			isInterface := target.AccessFlags&CLASS_ACC_INTERFACE != 0
+			if ref.Kind == "method" && isInterface {
+				report(index, ref, "incompatible class change: expected class but found interface")
+				continue
+			}
+			if ref.Kind == "interface method" && !isInterface {
+				report(index, ref, "incompatible class change: expected interface but found class")
+				continue
+			}
+			if _, _, r := p.ResolveMethod(ref.Class, ref.Name, ref.Descriptor); r == RESOLVE_MISSING {
+				report(index, ref, "method not found")
+			}
+		}
+	}
+
+	return rs
+}
@@ -0,0 +1,381 @@
+package jclass
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MANIFEST.MF 每行（不含换行符）最多 72 字节，更长的值以空格开头的续行接续
+const MANIFEST_LINE_LENGTH = 72
+
+type ManifestAttribute struct {
+	Name  string
+	Value string
+}
+
+// MANIFEST.MF 中的一段：主段，或以 Name 开头的条目段
+type ManifestSection struct {
+	Attributes []*ManifestAttribute
+}
+
+// 属性值，名称不区分大小写；不存在时返回空串
+func (s *ManifestSection) Get(name string) string {
+	for _, attr := range s.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// 设置属性值，已存在时原位替换
+func (s *ManifestSection) Set(name, value string) {
+	for _, attr := range s.Attributes {
+		if strings.EqualFold(attr.Name, name) {
+			attr.Value = value
+			return
+		}
+	}
+	s.Attributes = append(s.Attributes, &ManifestAttribute{Name: name, Value: value})
+}
+
+// 条目段的条目名，主段为空串
+func (s *ManifestSection) Name() string {
+	return s.Get("Name")
+}
+
+type Manifest struct {
+	Main *ManifestSection
+	// 条目段，按出现顺序
+	Sections []*ManifestSection
+}
+
+// 条目段，如 com/example/Foo.class 或包目录 com/example/；不存在时返回 nil
+func (m *Manifest) Section(name string) *ManifestSection {
+	for _, s := range m.Sections {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Main-Class，内部形式
+func (m *Manifest) MainClass() string {
+	return internalName(m.Main.Get("Main-Class"))
+}
+
+// Class-Path 中以空格分隔的相对 URL
+func (m *Manifest) ClassPath() []string {
+	return strings.Fields(m.Main.Get("Class-Path"))
+}
+
+func (m *Manifest) AutomaticModuleName() string {
+	return strings.TrimSpace(m.Main.Get("Automatic-Module-Name"))
+}
+
+func (m *Manifest) IsMultiRelease() bool {
+	return strings.EqualFold(strings.TrimSpace(m.Main.Get("Multi-Release")), "true")
+}
+
+func (m *Manifest) ImplementationVersion() string {
+	return strings.TrimSpace(m.Main.Get("Implementation-Version"))
+}
+
+// 按 JAR 规范解析 MANIFEST.MF：换行可以是 CRLF、LF 或 CR，以空格开头的行接续上一行，
+// 空行分隔各段，主段之后的每段以 Name 属性开头
+func ParseManifest(data []byte) (*Manifest, error) {
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	data = bytes.Replace(data, []byte("\r"), []byte("\n"), -1)
+
+	rs := &Manifest{Main: &ManifestSection{}}
+	var section *ManifestSection = rs.Main
+	var last *ManifestAttribute
+	// 上一段是否已结束，主段之后遇到的第一行属性开始一个新的条目段
+	ended := false
+	for i, line := range strings.Split(string(data), "\n") {
+		switch {
+		case line == "":
+			ended, last = true, nil
+			continue
+
+		case line[0] == ' ':
+			if last == nil {
+				return nil, fmt.Errorf("line %d: continuation without header: %q", i+1, line)
+			}
+			last.Value += line[1:]
+			continue
+		}
+
+		sep := strings.Index(line, ": ")
+		if sep <= 0 || !validHeaderName(line[:sep]) {
+			return nil, fmt.Errorf("line %d: invalid header: %q", i+1, line)
+		}
+		if ended {
+			if !strings.EqualFold(line[:sep], "Name") {
+				return nil, fmt.Errorf("line %d: section does not start with Name: %q", i+1, line)
+			}
+			section = &ManifestSection{}
+			rs.Sections = append(rs.Sections, section)
+			ended = false
+		}
+		last = &ManifestAttribute{Name: line[:sep], Value: line[sep+2:]}
+		section.Attributes = append(section.Attributes, last)
+	}
+	return rs, nil
+}
+
+// 属性名：字母数字开头，由字母数字、-、_ 组成，最长 70 字节
+func validHeaderName(name string) bool {
+	if len(name) == 0 || len(name) > 70 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		alnum := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+		if !alnum && (i == 0 || c != '-' && c != '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// 按规范写出：CRLF 换行，每行不超过 72 字节，不在多字节字符中间断行
+func (m *Manifest) Bytes() []byte {
+	s := &bytes.Buffer{}
+	writeSection := func(section *ManifestSection) {
+		for _, attr := range section.Attributes {
+			line := attr.Name + ": " + attr.Value
+			limit := MANIFEST_LINE_LENGTH
+			for len(line) > limit {
+				n := limit
+				for n > 0 && !utf8.RuneStart(line[n]) {
+					n--
+				}
+				s.WriteString(line[:n])
+				s.WriteString("\r\n ")
+				line = line[n:]
+				limit = MANIFEST_LINE_LENGTH - 1
+			}
+			s.WriteString(line)
+			s.WriteString("\r\n")
+		}
+		s.WriteString("\r\n")
+	}
+	writeSection(m.Main)
+	for _, section := range m.Sections {
+		writeSection(section)
+	}
+	return s.Bytes()
+}
+
+// 解析 META-INF/MANIFEST.MF；jar 中没有清单时返回 nil, nil。
+// 结果在首次调用时解析并缓存，调用方不应修改返回的清单
+func (j *Jar) Manifest() (*Manifest, error) {
+	entry := j.Entry("META-INF/MANIFEST.MF")
+	if entry == nil {
+		return nil, nil
+	}
+	if entry != j.manifestEntry {
+		j.manifest, j.manifestErr = ParseManifest(entry.Data)
+		if j.manifestErr != nil {
+			j.manifest, j.manifestErr = nil, &JarError{Path: j.Path, Entry: entry.Name, Err: j.manifestErr}
+		}
+		j.manifestEntry = entry
+	}
+	return j.manifest, j.manifestErr
+}
+
+// META-INF/maven/<groupId>/<artifactId>/pom.properties 中的 Maven 坐标
+type MavenCoordinate struct {
+	Entry      string
+	GroupId    string
+	ArtifactId string
+	Version    string
+}
+
+func (c *MavenCoordinate) String() string {
+	return c.GroupId + ":" + c.ArtifactId + ":" + c.Version
+}
+
+// 解析 java.util.Properties 格式中常用的部分：# 与 ! 注释、= 或 : 分隔、行尾 \ 续行
+func parseProperties(data []byte) map[string]string {
+	rs := make(map[string]string)
+	lines := strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		sep := strings.IndexAny(line, "=: \t")
+		if sep < 0 {
+			rs[line] = ""
+			continue
+		}
+		key := line[:sep]
+		value := strings.TrimLeft(line[sep:], " \t\f")
+		if value != "" && (value[0] == '=' || value[0] == ':') {
+			value = strings.TrimLeft(value[1:], " \t\f")
+		}
+		rs[key] = strings.TrimRight(value, "\r")
+	}
+	return rs
+}
+
+// jar 中所有 pom.properties 给出的 Maven 坐标，按条目名排序；shade 过的 jar 可能有多个
+func (j *Jar) MavenCoordinates() []*MavenCoordinate {
+	var rs []*MavenCoordinate
+	for _, entry := range j.Entries {
+		if !strings.HasPrefix(entry.Name, "META-INF/maven/") || !strings.HasSuffix(entry.Name, "/pom.properties") {
+			continue
+		}
+		props := parseProperties(entry.Data)
+		rs = append(rs, &MavenCoordinate{
+			Entry:      entry.Name,
+			GroupId:    props["groupId"],
+			ArtifactId: props["artifactId"],
+			Version:    props["version"],
+		})
+	}
+	sort.Slice(rs, func(a, b int) bool {
+		return rs[a].Entry < rs[b].Entry
+	})
+	return rs
+}
+
+// 合法的模块名：以 . 分隔的 Java 标识符
+func validModuleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, part := range strings.Split(name, ".") {
+		if part == "" || javaKeywords[part] {
+			return false
+		}
+		for i, c := range part {
+			if !(unicode.IsLetter(c) || c == '_' || c == '$' || i > 0 && unicode.IsDigit(c)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var javaKeywords = map[string]bool{
+	"abstract": true, "assert": true, "boolean": true, "break": true, "byte": true, "case": true,
+	"catch": true, "char": true, "class": true, "const": true, "continue": true, "default": true,
+	"do": true, "double": true, "else": true, "enum": true, "extends": true, "final": true,
+	"finally": true, "float": true, "for": true, "goto": true, "if": true, "implements": true,
+	"import": true, "instanceof": true, "int": true, "interface": true, "long": true, "native": true,
+	"new": true, "package": true, "private": true, "protected": true, "public": true, "return": true,
+	"short": true, "static": true, "strictfp": true, "super": true, "switch": true, "synchronized": true,
+	"this": true, "throw": true, "throws": true, "transient": true, "try": true, "void": true,
+	"volatile": true, "while": true, "true": true, "false": true, "null": true, "_": true,
+}
+
+// 与启动器一样按 Class.getMethod 查找 public 的 main 方法，包括从超类继承的，再要求其为 static。
+// 类本身不必是 public。超类不在 jar 与类路径中时无法断定，known 为 false
+func hasMainMethod(cf *ClassFile, lookup func(string) *ClassFile) (found, known bool) {
+	visited := make(map[string]bool)
+	for c := cf; !visited[c.ThisClassString()]; {
+		visited[c.ThisClassString()] = true
+		if method := c.FindMethod("main", "([Ljava/lang/String;)V"); method != nil && method.AccessFlags&METHOD_ACC_PUBLIC != 0 {
+			return method.AccessFlags&METHOD_ACC_STATIC != 0, true
+		}
+		super := c.SuperClassString()
+		if super == "" || super == "java/lang/Object" {
+			return false, true
+		}
+		if c = lookup(super); c == nil {
+			return false, false
+		}
+	}
+	return false, true
+}
+
+// 检查清单与 META-INF 元数据：行长度、Main-Class 是否存在且有（可以继承的）public static void main(String[])、
+// Class-Path 指向的文件是否存在、Automatic-Module-Name 是否合法、条目段是否指向存在的条目、
+// pom.properties 是否完整。Main-Class 先在 jar 中查找，再在 p 中查找，p 可为 nil
+func (j *Jar) CheckMetadata(p *ClassPath) []*JarIssue {
+	var rs []*JarIssue
+	report := func(entry, format string, args ...interface{}) {
+		rs = append(rs, &JarIssue{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	const manifestEntry = "META-INF/MANIFEST.MF"
+	for _, coord := range j.MavenCoordinates() {
+		if coord.GroupId == "" || coord.ArtifactId == "" || coord.Version == "" {
+			report(coord.Entry, "incomplete coordinates %s", coord)
+		}
+	}
+
+	entry := j.Entry(manifestEntry)
+	if entry == nil {
+		return rs
+	}
+	m, err := ParseManifest(entry.Data)
+	if err != nil {
+		report(manifestEntry, "%v", err)
+		return rs
+	}
+	for i, line := range strings.Split(strings.Replace(string(entry.Data), "\r\n", "\n", -1), "\n") {
+		if len(line) > MANIFEST_LINE_LENGTH {
+			report(manifestEntry, "line %d is %d bytes, longer than %d", i+1, len(line), MANIFEST_LINE_LENGTH)
+		}
+	}
+
+	if main := m.MainClass(); main != "" {
+		javaName := strings.TrimSpace(m.Main.Get("Main-Class"))
+		lookup := func(name string) *ClassFile {
+			if cf := j.Class(name); cf != nil || p == nil {
+				return cf
+			}
+			return p.Class(name)
+		}
+		cf := lookup(main)
+		if cf == nil {
+			report(manifestEntry, "Main-Class %s not found", javaName)
+		} else if found, known := hasMainMethod(cf, lookup); known && !found {
+			report(manifestEntry, "Main-Class %s has no public static void main(String[])", javaName)
+		}
+	}
+
+	if info, err := os.Stat(j.Path); err == nil && !info.IsDir() {
+		for _, url := range m.ClassPath() {
+			if strings.Contains(url, ":") {
+				continue
+			}
+			file := filepath.Join(filepath.Dir(j.Path), filepath.FromSlash(url))
+			if _, err := os.Stat(file); err != nil {
+				report(manifestEntry, "Class-Path entry %s not found", url)
+			}
+		}
+	}
+
+	if name := m.AutomaticModuleName(); name != "" {
+		switch {
+		case !validModuleName(name):
+			report(manifestEntry, "invalid Automatic-Module-Name %s", name)
+		case j.Class("module-info") != nil:
+			report(manifestEntry, "Automatic-Module-Name %s is ignored in a modular jar", name)
+		}
+	}
+
+	for _, section := range m.Sections {
+		name := section.Name()
+		if !strings.HasSuffix(name, "/") && j.Entry(name) == nil {
+			report(manifestEntry, "section for missing entry %s", name)
+		}
+	}
+	return rs
+}
@@ -0,0 +1,612 @@
+package jclass
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ProGuard/R8 的 mapping.txt
+	MAPPING_PROGUARD = "proguard"
+	// Tiny v2（Fabric）
+	MAPPING_TINY = "tiny"
+	// SRG（MCP）
+	MAPPING_SRG = "srg"
+	// TSRG 与 TSRG2（Forge）
+	MAPPING_TSRG = "tsrg"
+)
+
+// 名称映射，类名使用内部形式。成员的描述符使用映射前的类名，为空表示映射文件没有给出描述符
+type Mapping struct {
+	Classes map[string]*ClassMapping
+	// 包映射（SRG 的 PK 行），内部形式，不含结尾的 /
+	Packages map[string]string
+}
+
+type ClassMapping struct {
+	Name    string
+	NewName string
+	Fields  []*MemberMapping
+	Methods []*MemberMapping
+	// R8 元数据中记录的原始源文件名，如 Foo.kt，未给出时为空
+	SourceFile string
+}
+
+type MemberMapping struct {
+	Name       string
+	Descriptor string
+	NewName    string
+
+	// ProGuard/R8 的行号映射：映射后的行号范围 StartLine-EndLine 对应原方法中的 OriginalStartLine-OriginalEndLine，
+	// 0 表示未给出
+	StartLine         int
+	EndLine           int
+	OriginalStartLine int
+	OriginalEndLine   int
+	// R8 内联进来的方法所在的原始类（内部形式），为空表示本类
+	OriginalClass string
+}
+
+func NewMapping() *Mapping {
+	return &Mapping{
+		Classes:  make(map[string]*ClassMapping),
+		Packages: make(map[string]string),
+	}
+}
+
+func (m *Mapping) addClass(name, newName string) *ClassMapping {
+	cm := m.Classes[name]
+	if cm == nil {
+		cm = &ClassMapping{Name: name}
+		m.Classes[name] = cm
+	}
+	cm.NewName = newName
+	return cm
+}
+
+func (m *Mapping) class(name string) *ClassMapping {
+	if cm := m.Classes[name]; cm != nil {
+		return cm
+	}
+	return m.addClass(name, name)
+}
+
+// 按名称与描述符查找字段映射；映射或参数中的描述符为空时只比较名称
+func (c *ClassMapping) Field(name, descriptor string) *MemberMapping {
+	return findMember(c.Fields, name, descriptor)
+}
+
+// 按名称与描述符查找方法映射；R8 内联帧（OriginalClass 非空）不参与查找
+func (c *ClassMapping) Method(name, descriptor string) *MemberMapping {
+	return findMember(c.Methods, name, descriptor)
+}
+
+func findMember(members []*MemberMapping, name, descriptor string) *MemberMapping {
+	for _, m := range members {
+		if m.Name == name && m.OriginalClass == "" &&
+			(m.Descriptor == "" || descriptor == "" || m.Descriptor == descriptor) {
+			return m
+		}
+	}
+	return nil
+}
+
+// 反向映射：新名称映射回原名称，描述符相应地改写为新名称
+func (m *Mapping) Reverse() *Mapping {
+	rs := NewMapping()
+	mapClass := func(name string) string {
+		if cm := m.Classes[name]; cm != nil {
+			return cm.NewName
+		}
+		return name
+	}
+
+	for _, cm := range m.Classes {
+		rc := rs.addClass(cm.NewName, cm.Name)
+		for _, f := range cm.Fields {
+			rc.Fields = append(rc.Fields, &MemberMapping{
+				Name:       f.NewName,
+				Descriptor: mapDescriptorClasses(f.Descriptor, mapClass),
+				NewName:    f.Name,
+			})
+		}
+		for _, method := range cm.Methods {
+			if method.OriginalClass != "" {
+				continue
+			}
+			rc.Methods = append(rc.Methods, &MemberMapping{
+				Name:       method.NewName,
+				Descriptor: mapDescriptorClasses(method.Descriptor, mapClass),
+				NewName:    method.Name,
+			})
+		}
+	}
+	for pkg, newPkg := range m.Packages {
+		rs.Packages[newPkg] = pkg
+	}
+	return rs
+}
+
+// 根据内容判断映射文件格式
+func DetectMappingFormat(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "tiny\t"):
+			return MAPPING_TINY
+		case strings.HasPrefix(line, "tsrg2 "):
+			return MAPPING_TSRG
+		case strings.HasPrefix(line, "PK: "), strings.HasPrefix(line, "CL: "),
+			strings.HasPrefix(line, "FD: "), strings.HasPrefix(line, "MD: "):
+			return MAPPING_SRG
+		case strings.Contains(line, " -> "):
+			return MAPPING_PROGUARD
+		}
+		return MAPPING_TSRG
+	}
+	return MAPPING_PROGUARD
+}
+
+// 解析映射文件；format 为空时自动识别。Tiny 与 TSRG2 含有多个命名空间，
+// 由 from、to 指定映射方向，为空时取前两个命名空间；其余格式忽略 from、to
+func ParseMapping(r io.Reader, format, from, to string) (*Mapping, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = DetectMappingFormat(data)
+	}
+
+	switch format {
+	case MAPPING_PROGUARD:
+		return parseProGuardMapping(data)
+	case MAPPING_TINY:
+		t, err := parseTinyMapping(data)
+		if err != nil {
+			return nil, err
+		}
+		return t.mapping(from, to)
+	case MAPPING_SRG:
+		return parseSrgMapping(data)
+	case MAPPING_TSRG:
+		if bytes.HasPrefix(data, []byte("tsrg2 ")) {
+			t, err := parseTsrg2Mapping(data)
+			if err != nil {
+				return nil, err
+			}
+			return t.mapping(from, to)
+		}
+		return parseTsrgMapping(data)
+	}
+	return nil, fmt.Errorf("invalid mapping format: %s", format)
+}
+
+// Java 形式的类型名转换为描述符，如 int[] -> [I，java.lang.String -> Ljava/lang/String;
+func javaTypeDescriptor(t string) string {
+	t = strings.TrimSpace(t)
+	dims := ""
+	for strings.HasSuffix(t, "[]") {
+		dims += "["
+		t = t[:len(t)-2]
+	}
+	for c, name := range primitiveJavaNames {
+		if name == t {
+			return dims + string(c)
+		}
+	}
+	return dims + "L" + strings.Replace(t, ".", "/", -1) + ";"
+}
+
+func javaMethodDescriptor(args, result string) string {
+	s := &strings.Builder{}
+	s.WriteByte('(')
+	if args = strings.TrimSpace(args); args != "" {
+		for _, arg := range strings.Split(args, ",") {
+			s.WriteString(javaTypeDescriptor(arg))
+		}
+	}
+	s.WriteByte(')')
+	s.WriteString(javaTypeDescriptor(result))
+	return s.String()
+}
+
+func internalName(javaName string) string {
+	return strings.Replace(strings.TrimSpace(javaName), ".", "/", -1)
+}
+
+// ProGuard/R8 格式：
+//
+//	com.acme.Foo -> a.a:
+//	    int count -> a
+//	    1:4:void run(java.lang.String):10:13 -> b
+//	    5:5:int com.acme.Util.max(int,int):20:20 -> b
+func parseProGuardMapping(data []byte) (*Mapping, error) {
+	rs := NewMapping()
+	var current *ClassMapping
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(trimmed, "#") && current != nil {
+			// R8 的元数据注释：# {"id":"sourceFile","fileName":"Foo.kt"}
+			var meta struct {
+				Id       string `json:"id"`
+				FileName string `json:"fileName"`
+			}
+			if json.Unmarshal([]byte(strings.TrimSpace(trimmed[1:])), &meta) == nil && meta.Id == "sourceFile" {
+				current.SourceFile = meta.FileName
+			}
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		parts := strings.SplitN(trimmed, " -> ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: invalid mapping: %s", line, text)
+		}
+
+		if text[0] != ' ' && text[0] != '\t' {
+			if !strings.HasSuffix(parts[1], ":") {
+				return nil, fmt.Errorf("line %d: invalid class mapping: %s", line, text)
+			}
+			current = rs.addClass(internalName(parts[0]), internalName(strings.TrimSuffix(parts[1], ":")))
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("line %d: member mapping outside class: %s", line, text)
+		}
+
+		member := &MemberMapping{NewName: strings.TrimSpace(parts[1])}
+		left := parts[0]
+
+		// 前导的 startLine:endLine:
+		if fields := strings.SplitN(left, ":", 3); len(fields) == 3 {
+			start, err1 := strconv.Atoi(fields[0])
+			end, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil {
+				member.StartLine, member.EndLine = start, end
+				left = fields[2]
+			}
+		}
+
+		sp := strings.IndexByte(left, ' ')
+		if sp < 0 {
+			return nil, fmt.Errorf("line %d: invalid member mapping: %s", line, text)
+		}
+		typ, rest := left[:sp], strings.TrimSpace(left[sp+1:])
+
+		open := strings.IndexByte(rest, '(')
+		if open < 0 {
+			member.Name = rest
+			member.Descriptor = javaTypeDescriptor(typ)
+			current.Fields = append(current.Fields, member)
+			continue
+		}
+
+		closing := strings.IndexByte(rest, ')')
+		if closing < open {
+			return nil, fmt.Errorf("line %d: invalid method mapping: %s", line, text)
+		}
+		name := rest[:open]
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			member.OriginalClass = internalName(name[:i])
+			name = name[i+1:]
+		}
+		member.Name = name
+		member.Descriptor = javaMethodDescriptor(rest[open+1:closing], typ)
+
+		// 结尾的 :originalStart[:originalEnd]
+		if tail := strings.TrimPrefix(rest[closing+1:], ":"); tail != "" {
+			fields := strings.SplitN(tail, ":", 2)
+			member.OriginalStartLine, _ = strconv.Atoi(fields[0])
+			member.OriginalEndLine = member.OriginalStartLine
+			if len(fields) == 2 {
+				member.OriginalEndLine, _ = strconv.Atoi(fields[1])
+			}
+		} else if member.StartLine != 0 {
+			member.OriginalStartLine, member.OriginalEndLine = member.StartLine, member.EndLine
+		}
+		current.Methods = append(current.Methods, member)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// SRG 格式：PK: CL: FD: MD: 四种行，左边为原名称
+func parseSrgMapping(data []byte) (*Mapping, error) {
+	rs := NewMapping()
+	split := func(s string) (string, string) {
+		i := strings.LastIndexByte(s, '/')
+		if i < 0 {
+			return "", s
+		}
+		return s[:i], s[i+1:]
+	}
+
+	for line, text := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(text)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch {
+		case fields[0] == "PK:" && len(fields) == 3:
+			from, to := strings.TrimSuffix(fields[1], "/"), strings.TrimSuffix(fields[2], "/")
+			if from == "." {
+				from = ""
+			}
+			if to == "." {
+				to = ""
+			}
+			rs.Packages[from] = to
+		case fields[0] == "CL:" && len(fields) == 3:
+			rs.addClass(fields[1], fields[2])
+		case fields[0] == "FD:" && (len(fields) == 3 || len(fields) == 5):
+			owner, name := split(fields[1])
+			_, newName := split(fields[2])
+			member := &MemberMapping{Name: name, NewName: newName}
+			if len(fields) == 5 {
+				// XSRG 带有字段描述符
+				_, newName = split(fields[3])
+				member = &MemberMapping{Name: name, Descriptor: fields[2], NewName: newName}
+			}
+			cm := rs.class(owner)
+			cm.Fields = append(cm.Fields, member)
+		case fields[0] == "MD:" && len(fields) == 5:
+			owner, name := split(fields[1])
+			_, newName := split(fields[3])
+			cm := rs.class(owner)
+			cm.Methods = append(cm.Methods, &MemberMapping{Name: name, Descriptor: fields[2], NewName: newName})
+		default:
+			return nil, fmt.Errorf("line %d: invalid srg mapping: %s", line+1, text)
+		}
+	}
+	return rs, nil
+}
+
+// TSRG v1 格式：类行顶格，成员行以制表符缩进，方法带描述符；以 / 结尾的为包映射
+func parseTsrgMapping(data []byte) (*Mapping, error) {
+	rs := NewMapping()
+	var current *ClassMapping
+
+	for line, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(text, "\r")
+		fields := strings.Fields(text)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if text[0] != '\t' && text[0] != ' ' {
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: invalid tsrg mapping: %s", line+1, text)
+			}
+			if strings.HasSuffix(fields[0], "/") {
+				rs.Packages[strings.TrimSuffix(fields[0], "/")] = strings.TrimSuffix(fields[1], "/")
+				current = nil
+				continue
+			}
+			current = rs.addClass(fields[0], fields[1])
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: member mapping outside class: %s", line+1, text)
+		}
+		switch len(fields) {
+		case 2:
+			current.Fields = append(current.Fields, &MemberMapping{Name: fields[0], NewName: fields[1]})
+		case 3:
+			current.Methods = append(current.Methods, &MemberMapping{Name: fields[0], Descriptor: fields[1], NewName: fields[2]})
+		default:
+			return nil, fmt.Errorf("line %d: invalid tsrg mapping: %s", line+1, text)
+		}
+	}
+	return rs, nil
+}
+
+// 多命名空间的映射表（Tiny v2、TSRG2），成员描述符使用第一个命名空间的类名
+type namespacedMapping struct {
+	namespaces []string
+	classes    []*namespacedClass
+}
+
+type namespacedClass struct {
+	names   []string
+	fields  []*namespacedMember
+	methods []*namespacedMember
+}
+
+type namespacedMember struct {
+	descriptor string
+	names      []string
+}
+
+func (t *namespacedMapping) namespace(name string, def int) (int, error) {
+	if name == "" {
+		if def >= len(t.namespaces) {
+			return 0, fmt.Errorf("mapping has only %d namespaces", len(t.namespaces))
+		}
+		return def, nil
+	}
+	for i, ns := range t.namespaces {
+		if ns == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("namespace not found: %s (available: %s)", name, strings.Join(t.namespaces, ", "))
+}
+
+// 取某个命名空间中的名称，缺省时沿用第一个命名空间的名称
+func namespacedName(names []string, i int) string {
+	if i < len(names) && names[i] != "" {
+		return names[i]
+	}
+	return names[0]
+}
+
+func (t *namespacedMapping) mapping(from, to string) (*Mapping, error) {
+	src, err := t.namespace(from, 0)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := t.namespace(to, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// 把描述符从第一个命名空间改写到源命名空间
+	primary := make(map[string]string)
+	for _, c := range t.classes {
+		primary[c.names[0]] = namespacedName(c.names, src)
+	}
+	toSource := func(name string) string {
+		if s, ok := primary[name]; ok {
+			return s
+		}
+		return name
+	}
+
+	rs := NewMapping()
+	for _, c := range t.classes {
+		cm := rs.addClass(namespacedName(c.names, src), namespacedName(c.names, dst))
+		for _, f := range c.fields {
+			cm.Fields = append(cm.Fields, &MemberMapping{
+				Name:       namespacedName(f.names, src),
+				Descriptor: mapDescriptorClasses(f.descriptor, toSource),
+				NewName:    namespacedName(f.names, dst),
+			})
+		}
+		for _, m := range c.methods {
+			cm.Methods = append(cm.Methods, &MemberMapping{
+				Name:       namespacedName(m.names, src),
+				Descriptor: mapDescriptorClasses(m.descriptor, toSource),
+				NewName:    namespacedName(m.names, dst),
+			})
+		}
+	}
+	return rs, nil
+}
+
+// Tiny v2 格式：
+//
+//	tiny	2	0	official	named
+//	c	a	com/acme/Foo
+//		f	I	a	count
+//		m	(La;)V	b	run
+//			p	1		arg
+func parseTinyMapping(data []byte) (*namespacedMapping, error) {
+	lines := strings.Split(string(data), "\n")
+	header := strings.Split(strings.TrimRight(lines[0], "\r"), "\t")
+	if len(header) < 5 || header[0] != "tiny" || header[1] != "2" {
+		return nil, fmt.Errorf("line 1: unsupported tiny header: %s", lines[0])
+	}
+
+	rs := &namespacedMapping{namespaces: header[3:]}
+	n := len(rs.namespaces)
+	var current *namespacedClass
+
+	for i, text := range lines[1:] {
+		text = strings.TrimRight(text, "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, "\t"))
+		fields := strings.Split(text[indent:], "\t")
+
+		switch {
+		case indent == 0 && fields[0] == "c":
+			if len(fields) < 1+n {
+				return nil, fmt.Errorf("line %d: invalid class mapping: %s", i+2, text)
+			}
+			current = &namespacedClass{names: fields[1 : 1+n]}
+			rs.classes = append(rs.classes, current)
+
+		case indent == 1 && (fields[0] == "f" || fields[0] == "m"):
+			if current == nil || len(fields) < 2+n {
+				return nil, fmt.Errorf("line %d: invalid member mapping: %s", i+2, text)
+			}
+			member := &namespacedMember{descriptor: fields[1], names: fields[2 : 2+n]}
+			if fields[0] == "f" {
+				current.fields = append(current.fields, member)
+			} else {
+				current.methods = append(current.methods, member)
+			}
+		}
+		// 其余为属性、注释、参数与局部变量，忽略
+	}
+	return rs, nil
+}
+
+// TSRG2 格式：
+//
+//	tsrg2 obf srg
+//	a net/acme/Foo
+//		a I field_1
+//		b (La;)V func_2
+//			static
+//			0 o p_1
+func parseTsrg2Mapping(data []byte) (*namespacedMapping, error) {
+	lines := strings.Split(string(data), "\n")
+	header := strings.Fields(lines[0])
+	if len(header) < 3 {
+		return nil, fmt.Errorf("line 1: invalid tsrg2 header: %s", lines[0])
+	}
+
+	rs := &namespacedMapping{namespaces: header[1:]}
+	n := len(rs.namespaces)
+	var current *namespacedClass
+
+	for i, text := range lines[1:] {
+		text = strings.TrimRight(text, "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, "\t"))
+		fields := strings.Fields(text)
+
+		switch indent {
+		case 0:
+			if len(fields) != n {
+				return nil, fmt.Errorf("line %d: invalid class mapping: %s", i+2, text)
+			}
+			current = &namespacedClass{names: fields}
+			rs.classes = append(rs.classes, current)
+
+		case 1:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: member mapping outside class: %s", i+2, text)
+			}
+			switch len(fields) {
+			case n:
+				current.fields = append(current.fields, &namespacedMember{names: fields})
+			case n + 1:
+				member := &namespacedMember{descriptor: fields[1], names: append([]string{fields[0]}, fields[2:]...)}
+				if strings.HasPrefix(member.descriptor, "(") {
+					current.methods = append(current.methods, member)
+				} else {
+					current.fields = append(current.fields, member)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: invalid member mapping: %s", i+2, text)
+			}
+		}
+	}
+	return rs, nil
+}
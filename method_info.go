@@ -32,6 +32,56 @@ func (i *MethodInfo) NameString() string {
 	return ((*ConstantUtf8Info)(i.cp[int(i.NameIndex)])).Utf8()
 }
 
+// 按名称查找属性，不存在时返回 nil
+func (i *MethodInfo) Attribute(name string) *AttributeInfo {
+	return findAttribute(i.Attributes, name)
+}
+
+// 泛型签名，不存在时返回空串
+func (i *MethodInfo) SignatureString() string {
+	return signatureOf(i.Attributes)
+}
+
+func (i *MethodInfo) Annotations() []*Annotation {
+	return annotationsOf(i.Attributes)
+}
+
+// 每个参数上的注解（运行时可见与不可见合并），没有参数注解时返回 nil
+func (i *MethodInfo) ParameterAnnotations() [][]*Annotation {
+	var rs [][]*Annotation
+	for _, attr := range i.Attributes {
+		for j, anns := range attr.ParameterAnnotations {
+			for len(rs) <= j {
+				rs = append(rs, nil)
+			}
+			rs[j] = append(rs[j], anns...)
+		}
+	}
+	return rs
+}
+
+// Code 属性，抽象方法与本地方法返回 nil
+func (i *MethodInfo) Code() *CodeAttribute {
+	if attr := i.Attribute("Code"); attr != nil {
+		return attr.Code
+	}
+	return nil
+}
+
+// throws 子句中的异常类名
+func (i *MethodInfo) ExceptionStrings() []string {
+	attr := i.Attribute("Exceptions")
+	if attr == nil {
+		return nil
+	}
+	rs := make([]string, len(attr.ExceptionIndexTable))
+	for j, index := range attr.ExceptionIndexTable {
+		classInfo := (*ConstantClassInfo)(i.cp[index])
+		rs[j] = ((*ConstantUtf8Info)(i.cp[classInfo.NameIndex()])).Utf8()
+	}
+	return rs
+}
+
 func (i *MethodInfo) AccessFlagsString() string {
 	s := bytes.NewBuffer(nil)
 
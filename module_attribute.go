@@ -0,0 +1,159 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+type ModuleRequires struct {
+	RequiresIndex        uint16
+	RequiresFlags        uint16
+	RequiresVersionIndex uint16
+}
+
+// exports 与 opens 结构相同
+type ModuleExports struct {
+	Index   uint16
+	Flags   uint16
+	ToIndex []uint16
+}
+
+type ModuleProvides struct {
+	ProvidesIndex     uint16
+	ProvidesWithIndex []uint16
+}
+
+// JVMS 4.7.25 Module 属性，仅出现在 module-info.class 中
+type ModuleAttribute struct {
+	ModuleNameIndex    uint16
+	ModuleFlags        uint16
+	ModuleVersionIndex uint16
+
+	Requires  []*ModuleRequires
+	Exports   []*ModuleExports
+	Opens     []*ModuleExports
+	UsesIndex []uint16
+	Provides  []*ModuleProvides
+
+	cp []*ConstantPoolInfo
+}
+
+func (m *ModuleAttribute) utf8(index uint16) string {
+	return ((*ConstantUtf8Info)(m.cp[index])).Utf8()
+}
+
+// CONSTANT_Module 与 CONSTANT_Package 的布局相同，都只有 name_index
+func (m *ModuleAttribute) nameOf(index uint16) string {
+	return m.utf8(((*ConstantModuleInfo)(m.cp[index])).NameIndex())
+}
+
+func (m *ModuleAttribute) classOf(index uint16) string {
+	return m.utf8(((*ConstantClassInfo)(m.cp[index])).NameIndex())
+}
+
+func (m *ModuleAttribute) ModuleNameString() string {
+	return m.nameOf(m.ModuleNameIndex)
+}
+
+func (m *ModuleAttribute) RequiresStrings() []string {
+	rs := make([]string, len(m.Requires))
+	for i, r := range m.Requires {
+		rs[i] = m.nameOf(r.RequiresIndex)
+	}
+	return rs
+}
+
+// 导出的包名（内部形式，如 com/acme/api）
+func (m *ModuleAttribute) ExportsStrings() []string {
+	rs := make([]string, len(m.Exports))
+	for i, e := range m.Exports {
+		rs[i] = m.nameOf(e.Index)
+	}
+	return rs
+}
+
+func (m *ModuleAttribute) UsesStrings() []string {
+	rs := make([]string, len(m.UsesIndex))
+	for i, index := range m.UsesIndex {
+		rs[i] = m.classOf(index)
+	}
+	return rs
+}
+
+// 服务接口名到实现类名列表的映射
+func (m *ModuleAttribute) ProvidesStrings() map[string][]string {
+	rs := make(map[string][]string, len(m.Provides))
+	for _, p := range m.Provides {
+		service := m.classOf(p.ProvidesIndex)
+		for _, index := range p.ProvidesWithIndex {
+			rs[service] = append(rs[service], m.classOf(index))
+		}
+	}
+	return rs
+}
+
+func NewModuleAttribute(r io.Reader, buf []byte, cp []*ConstantPoolInfo) (*ModuleAttribute, []byte, error) {
+	rs := ModuleAttribute{
+		cp: cp,
+	}
+	byteOrder := binary.BigEndian
+
+	var err error
+	u2 := func() uint16 {
+		if err != nil {
+			return 0
+		}
+		_, err = io.ReadFull(r, buf[:2])
+		return byteOrder.Uint16(buf)
+	}
+	u2s := func() []uint16 {
+		num := int(u2())
+		rs := make([]uint16, 0, num)
+		for i := 0; i < num && err == nil; i++ {
+			rs = append(rs, u2())
+		}
+		return rs
+	}
+	exports := func() []*ModuleExports {
+		num := int(u2())
+		rs := make([]*ModuleExports, 0, num)
+		for i := 0; i < num && err == nil; i++ {
+			rs = append(rs, &ModuleExports{
+				Index:   u2(),
+				Flags:   u2(),
+				ToIndex: u2s(),
+			})
+		}
+		return rs
+	}
+
+	rs.ModuleNameIndex = u2()
+	rs.ModuleFlags = u2()
+	rs.ModuleVersionIndex = u2()
+
+	num := int(u2())
+	for i := 0; i < num && err == nil; i++ {
+		rs.Requires = append(rs.Requires, &ModuleRequires{
+			RequiresIndex:        u2(),
+			RequiresFlags:        u2(),
+			RequiresVersionIndex: u2(),
+		})
+	}
+
+	rs.Exports = exports()
+	rs.Opens = exports()
+	rs.UsesIndex = u2s()
+
+	num = int(u2())
+	for i := 0; i < num && err == nil; i++ {
+		rs.Provides = append(rs.Provides, &ModuleProvides{
+			ProvidesIndex:     u2(),
+			ProvidesWithIndex: u2s(),
+		})
+	}
+
+	if err != nil {
+		return nil, buf, err
+	}
+	return &rs, buf, nil
+}
@@ -0,0 +1,232 @@
+package jclass
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 多版本 jar（JEP 238）最早从 Java 9 开始识别 META-INF/versions/N
+const MULTI_RELEASE_MIN_VERSION = 9
+
+// MANIFEST.MF 中声明了 Multi-Release: true
+func (j *Jar) IsMultiRelease() bool {
+	m, err := j.Manifest()
+	return err == nil && m != nil && m.IsMultiRelease()
+}
+
+// 解析 META-INF/versions/N/path 形式的条目名；N 不是数字时 ok 为 false
+func versionedEntryName(name string) (release int, base string, ok bool) {
+	if !strings.HasPrefix(name, "META-INF/versions/") {
+		return 0, name, false
+	}
+	parts := strings.SplitN(name, "/", 4)
+	if len(parts) < 4 || parts[3] == "" {
+		return 0, name, false
+	}
+	release, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, name, false
+	}
+	return release, parts[3], true
+}
+
+// 解析 META-INF/versions 下的类；这些类不在 Jar 中预先解析，结果会被缓存
+func (j *Jar) versionedClass(entry *JarEntry) (*ClassFile, error) {
+	if cf := j.versioned[entry.Name]; cf != nil {
+		return cf, nil
+	}
+	cf, err := NewClassFile(bytes.NewReader(entry.Data))
+	if err != nil {
+		return nil, &JarError{Path: j.Path, Entry: entry.Name, Err: err}
+	}
+	if j.versioned == nil {
+		j.versioned = make(map[string]*ClassFile)
+	}
+	j.versioned[entry.Name] = cf
+	return cf, nil
+}
+
+// 在 Java release 上运行时看到的 jar：每个条目解析为不高于 release 的最高版本层中的条目，
+// 没有版本化条目时取基础层。不是多版本 jar 或 release 低于 9 时返回 j 本身。
+// 返回的 Jar 与 j 共享 Entries，Entry 与 Class 按版本解析
+func (j *Jar) ForRelease(release int) (*Jar, error) {
+	if release < MULTI_RELEASE_MIN_VERSION || !j.IsMultiRelease() {
+		return j, nil
+	}
+
+	chosen := make(map[string]int)
+	view := &Jar{
+		Path:    j.Path,
+		Entries: j.Entries,
+		entries: make(map[string]*JarEntry, len(j.entries)),
+		classes: make(map[string]*ClassFile, len(j.classes)),
+	}
+	for name, entry := range j.entries {
+		view.entries[name] = entry
+	}
+	for name, cf := range j.classes {
+		view.classes[name] = cf
+	}
+
+	for _, entry := range j.Entries {
+		n, base, ok := versionedEntryName(entry.Name)
+		if !ok || n < MULTI_RELEASE_MIN_VERSION || n > release || n <= chosen[base] {
+			continue
+		}
+		chosen[base] = n
+		view.entries[base] = entry
+		if !entry.IsClass() {
+			continue
+		}
+		cf, err := j.versionedClass(entry)
+		if err != nil {
+			return nil, err
+		}
+		// 基础层中同路径的类被整体替换
+		if old := j.entries[base]; old != nil && old.Class != nil {
+			delete(view.classes, old.Class.ThisClassString())
+		}
+		view.classes[cf.ThisClassString()] = cf
+	}
+	return view, nil
+}
+
+// 检查多版本 jar 的一致性：Multi-Release 声明与版本目录是否匹配、版本目录是否有效、
+// 版本化的类是否与路径和版本号相符、公开的版本化类是否有基础层条目且公开 API 与之相同
+func (j *Jar) MultiReleaseIssues() ([]*JarIssue, error) {
+	var rs []*JarIssue
+	report := func(entry, format string, args ...interface{}) {
+		rs = append(rs, &JarIssue{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	multiRelease := j.IsMultiRelease()
+	versioned := 0
+	for _, entry := range j.Entries {
+		if !strings.HasPrefix(entry.Name, "META-INF/versions/") {
+			continue
+		}
+		n, base, ok := versionedEntryName(entry.Name)
+		switch {
+		case !ok:
+			report(entry.Name, "not under a numeric version directory, ignored at runtime")
+			continue
+		case n < MULTI_RELEASE_MIN_VERSION:
+			report(entry.Name, "version %d is below %d, ignored at runtime", n, MULTI_RELEASE_MIN_VERSION)
+			continue
+		}
+		versioned++
+		if !multiRelease || !entry.IsClass() {
+			continue
+		}
+
+		cf, err := j.versionedClass(entry)
+		if err != nil {
+			return nil, err
+		}
+		name := cf.ThisClassString()
+		if name+".class" != base {
+			report(entry.Name, "declares class %s", name)
+		}
+		if cf.MajorVersion > ClassVersion(n) {
+			report(entry.Name, "compiled for %s, above version directory %d", JavaReleaseName(cf.MajorVersion), n)
+		}
+
+		baseEntry := j.Entry(base)
+		if baseEntry == nil || baseEntry.Class == nil {
+			if cf.IsPublic() {
+				report(entry.Name, "public class has no base entry %s", base)
+			}
+			continue
+		}
+		for _, diff := range publicAPIDiff(baseEntry.Class, cf) {
+			report(entry.Name, "public API differs from %s: %s", base, diff)
+		}
+	}
+
+	switch {
+	case versioned > 0 && !multiRelease:
+		report("META-INF/MANIFEST.MF", "%d versioned entries are ignored without Multi-Release: true", versioned)
+	case versioned == 0 && multiRelease:
+		report("META-INF/MANIFEST.MF", "Multi-Release: true but no META-INF/versions entries")
+	}
+	return rs, nil
+}
+
+// 公开 API 中不影响链接的修饰符以外的部分
+const (
+	apiClassFlags  = CLASS_ACC_PUBLIC | CLASS_ACC_FINAL | CLASS_ACC_INTERFACE | CLASS_ACC_ABSTRACT | CLASS_ACC_ANNOTATION | CLASS_ACC_ENUM
+	apiFieldFlags  = FIELD_ACC_PUBLIC | FIELD_ACC_PROTECTED | FIELD_ACC_STATIC | FIELD_ACC_FINAL
+	apiMethodFlags = METHOD_ACC_PUBLIC | METHOD_ACC_PROTECTED | METHOD_ACC_STATIC | METHOD_ACC_FINAL | METHOD_ACC_ABSTRACT
+)
+
+// 类的公开 API：public 或 protected 的字段与方法，键为 "field name desc" 或 "method name desc"，
+// 值为访问修饰符的可读形式
+func publicAPI(cf *ClassFile) map[string]string {
+	rs := make(map[string]string)
+	for _, f := range cf.Fields {
+		if f.AccessFlags&(FIELD_ACC_PUBLIC|FIELD_ACC_PROTECTED) != 0 {
+			c := *f
+			c.AccessFlags &= apiFieldFlags
+			rs["field "+f.NameString()+" "+f.DescriptorString()] = c.AccessFlagsString()
+		}
+	}
+	for _, m := range cf.Methods {
+		if m.AccessFlags&(METHOD_ACC_PUBLIC|METHOD_ACC_PROTECTED) != 0 {
+			c := *m
+			c.AccessFlags &= apiMethodFlags
+			rs["method "+m.NameString()+" "+m.DescriptorString()] = c.AccessFlagsString()
+		}
+	}
+	return rs
+}
+
+// 两个版本的类在公开 API 上的差异，按可读的描述列出
+func publicAPIDiff(old, new *ClassFile) []string {
+	var rs []string
+	if old.AccessFlags&apiClassFlags != new.AccessFlags&apiClassFlags {
+		o, n := *old, *new
+		o.AccessFlags &= apiClassFlags
+		n.AccessFlags &= apiClassFlags
+		rs = append(rs, fmt.Sprintf("class modifiers %q -> %q", o.AccessFlagsString(), n.AccessFlagsString()))
+	}
+	if !old.IsPublic() && !new.IsPublic() {
+		return rs
+	}
+	if old.SuperClassString() != new.SuperClassString() {
+		rs = append(rs, fmt.Sprintf("superclass %s -> %s", old.SuperClassString(), new.SuperClassString()))
+	}
+	oldInterfaces, newInterfaces := old.InterfaceStrings(), new.InterfaceStrings()
+	sort.Strings(oldInterfaces)
+	sort.Strings(newInterfaces)
+	if strings.Join(oldInterfaces, ",") != strings.Join(newInterfaces, ",") {
+		rs = append(rs, fmt.Sprintf("interfaces [%s] -> [%s]", strings.Join(oldInterfaces, ", "), strings.Join(newInterfaces, ", ")))
+	}
+
+	oldAPI, newAPI := publicAPI(old), publicAPI(new)
+	keys := make([]string, 0, len(oldAPI)+len(newAPI))
+	for k := range oldAPI {
+		keys = append(keys, k)
+	}
+	for k := range newAPI {
+		if _, ok := oldAPI[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		o, inOld := oldAPI[k]
+		n, inNew := newAPI[k]
+		switch {
+		case !inNew:
+			rs = append(rs, k+" removed")
+		case !inOld:
+			rs = append(rs, k+" added")
+		case o != n:
+			rs = append(rs, fmt.Sprintf("%s modifiers %q -> %q", k, o, n))
+		}
+	}
+	return rs
+}
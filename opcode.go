@@ -0,0 +1,417 @@
+package jclass
+
+// JVMS 6.5 操作码
+const (
+	OP_NOP             byte = 0x00
+	OP_ACONST_NULL     byte = 0x01
+	OP_ICONST_M1       byte = 0x02
+	OP_ICONST_0        byte = 0x03
+	OP_ICONST_1        byte = 0x04
+	OP_ICONST_2        byte = 0x05
+	OP_ICONST_3        byte = 0x06
+	OP_ICONST_4        byte = 0x07
+	OP_ICONST_5        byte = 0x08
+	OP_LCONST_0        byte = 0x09
+	OP_LCONST_1        byte = 0x0a
+	OP_FCONST_0        byte = 0x0b
+	OP_FCONST_1        byte = 0x0c
+	OP_FCONST_2        byte = 0x0d
+	OP_DCONST_0        byte = 0x0e
+	OP_DCONST_1        byte = 0x0f
+	OP_BIPUSH          byte = 0x10
+	OP_SIPUSH          byte = 0x11
+	OP_LDC             byte = 0x12
+	OP_LDC_W           byte = 0x13
+	OP_LDC2_W          byte = 0x14
+	OP_ILOAD           byte = 0x15
+	OP_LLOAD           byte = 0x16
+	OP_FLOAD           byte = 0x17
+	OP_DLOAD           byte = 0x18
+	OP_ALOAD           byte = 0x19
+	OP_ILOAD_0         byte = 0x1a
+	OP_ILOAD_1         byte = 0x1b
+	OP_ILOAD_2         byte = 0x1c
+	OP_ILOAD_3         byte = 0x1d
+	OP_LLOAD_0         byte = 0x1e
+	OP_LLOAD_1         byte = 0x1f
+	OP_LLOAD_2         byte = 0x20
+	OP_LLOAD_3         byte = 0x21
+	OP_FLOAD_0         byte = 0x22
+	OP_FLOAD_1         byte = 0x23
+	OP_FLOAD_2         byte = 0x24
+	OP_FLOAD_3         byte = 0x25
+	OP_DLOAD_0         byte = 0x26
+	OP_DLOAD_1         byte = 0x27
+	OP_DLOAD_2         byte = 0x28
+	OP_DLOAD_3         byte = 0x29
+	OP_ALOAD_0         byte = 0x2a
+	OP_ALOAD_1         byte = 0x2b
+	OP_ALOAD_2         byte = 0x2c
+	OP_ALOAD_3         byte = 0x2d
+	OP_IALOAD          byte = 0x2e
+	OP_LALOAD          byte = 0x2f
+	OP_FALOAD          byte = 0x30
+	OP_DALOAD          byte = 0x31
+	OP_AALOAD          byte = 0x32
+	OP_BALOAD          byte = 0x33
+	OP_CALOAD          byte = 0x34
+	OP_SALOAD          byte = 0x35
+	OP_ISTORE          byte = 0x36
+	OP_LSTORE          byte = 0x37
+	OP_FSTORE          byte = 0x38
+	OP_DSTORE          byte = 0x39
+	OP_ASTORE          byte = 0x3a
+	OP_ISTORE_0        byte = 0x3b
+	OP_ISTORE_1        byte = 0x3c
+	OP_ISTORE_2        byte = 0x3d
+	OP_ISTORE_3        byte = 0x3e
+	OP_LSTORE_0        byte = 0x3f
+	OP_LSTORE_1        byte = 0x40
+	OP_LSTORE_2        byte = 0x41
+	OP_LSTORE_3        byte = 0x42
+	OP_FSTORE_0        byte = 0x43
+	OP_FSTORE_1        byte = 0x44
+	OP_FSTORE_2        byte = 0x45
+	OP_FSTORE_3        byte = 0x46
+	OP_DSTORE_0        byte = 0x47
+	OP_DSTORE_1        byte = 0x48
+	OP_DSTORE_2        byte = 0x49
+	OP_DSTORE_3        byte = 0x4a
+	OP_ASTORE_0        byte = 0x4b
+	OP_ASTORE_1        byte = 0x4c
+	OP_ASTORE_2        byte = 0x4d
+	OP_ASTORE_3        byte = 0x4e
+	OP_IASTORE         byte = 0x4f
+	OP_LASTORE         byte = 0x50
+	OP_FASTORE         byte = 0x51
+	OP_DASTORE         byte = 0x52
+	OP_AASTORE         byte = 0x53
+	OP_BASTORE         byte = 0x54
+	OP_CASTORE         byte = 0x55
+	OP_SASTORE         byte = 0x56
+	OP_POP             byte = 0x57
+	OP_POP2            byte = 0x58
+	OP_DUP             byte = 0x59
+	OP_DUP_X1          byte = 0x5a
+	OP_DUP_X2          byte = 0x5b
+	OP_DUP2            byte = 0x5c
+	OP_DUP2_X1         byte = 0x5d
+	OP_DUP2_X2         byte = 0x5e
+	OP_SWAP            byte = 0x5f
+	OP_IADD            byte = 0x60
+	OP_LADD            byte = 0x61
+	OP_FADD            byte = 0x62
+	OP_DADD            byte = 0x63
+	OP_ISUB            byte = 0x64
+	OP_LSUB            byte = 0x65
+	OP_FSUB            byte = 0x66
+	OP_DSUB            byte = 0x67
+	OP_IMUL            byte = 0x68
+	OP_LMUL            byte = 0x69
+	OP_FMUL            byte = 0x6a
+	OP_DMUL            byte = 0x6b
+	OP_IDIV            byte = 0x6c
+	OP_LDIV            byte = 0x6d
+	OP_FDIV            byte = 0x6e
+	OP_DDIV            byte = 0x6f
+	OP_IREM            byte = 0x70
+	OP_LREM            byte = 0x71
+	OP_FREM            byte = 0x72
+	OP_DREM            byte = 0x73
+	OP_INEG            byte = 0x74
+	OP_LNEG            byte = 0x75
+	OP_FNEG            byte = 0x76
+	OP_DNEG            byte = 0x77
+	OP_ISHL            byte = 0x78
+	OP_LSHL            byte = 0x79
+	OP_ISHR            byte = 0x7a
+	OP_LSHR            byte = 0x7b
+	OP_IUSHR           byte = 0x7c
+	OP_LUSHR           byte = 0x7d
+	OP_IAND            byte = 0x7e
+	OP_LAND            byte = 0x7f
+	OP_IOR             byte = 0x80
+	OP_LOR             byte = 0x81
+	OP_IXOR            byte = 0x82
+	OP_LXOR            byte = 0x83
+	OP_IINC            byte = 0x84
+	OP_I2L             byte = 0x85
+	OP_I2F             byte = 0x86
+	OP_I2D             byte = 0x87
+	OP_L2I             byte = 0x88
+	OP_L2F             byte = 0x89
+	OP_L2D             byte = 0x8a
+	OP_F2I             byte = 0x8b
+	OP_F2L             byte = 0x8c
+	OP_F2D             byte = 0x8d
+	OP_D2I             byte = 0x8e
+	OP_D2L             byte = 0x8f
+	OP_D2F             byte = 0x90
+	OP_I2B             byte = 0x91
+	OP_I2C             byte = 0x92
+	OP_I2S             byte = 0x93
+	OP_LCMP            byte = 0x94
+	OP_FCMPL           byte = 0x95
+	OP_FCMPG           byte = 0x96
+	OP_DCMPL           byte = 0x97
+	OP_DCMPG           byte = 0x98
+	OP_IFEQ            byte = 0x99
+	OP_IFNE            byte = 0x9a
+	OP_IFLT            byte = 0x9b
+	OP_IFGE            byte = 0x9c
+	OP_IFGT            byte = 0x9d
+	OP_IFLE            byte = 0x9e
+	OP_IF_ICMPEQ       byte = 0x9f
+	OP_IF_ICMPNE       byte = 0xa0
+	OP_IF_ICMPLT       byte = 0xa1
+	OP_IF_ICMPGE       byte = 0xa2
+	OP_IF_ICMPGT       byte = 0xa3
+	OP_IF_ICMPLE       byte = 0xa4
+	OP_IF_ACMPEQ       byte = 0xa5
+	OP_IF_ACMPNE       byte = 0xa6
+	OP_GOTO            byte = 0xa7
+	OP_JSR             byte = 0xa8
+	OP_RET             byte = 0xa9
+	OP_TABLESWITCH     byte = 0xaa
+	OP_LOOKUPSWITCH    byte = 0xab
+	OP_IRETURN         byte = 0xac
+	OP_LRETURN         byte = 0xad
+	OP_FRETURN         byte = 0xae
+	OP_DRETURN         byte = 0xaf
+	OP_ARETURN         byte = 0xb0
+	OP_RETURN          byte = 0xb1
+	OP_GETSTATIC       byte = 0xb2
+	OP_PUTSTATIC       byte = 0xb3
+	OP_GETFIELD        byte = 0xb4
+	OP_PUTFIELD        byte = 0xb5
+	OP_INVOKEVIRTUAL   byte = 0xb6
+	OP_INVOKESPECIAL   byte = 0xb7
+	OP_INVOKESTATIC    byte = 0xb8
+	OP_INVOKEINTERFACE byte = 0xb9
+	OP_INVOKEDYNAMIC   byte = 0xba
+	OP_NEW             byte = 0xbb
+	OP_NEWARRAY        byte = 0xbc
+	OP_ANEWARRAY       byte = 0xbd
+	OP_ARRAYLENGTH     byte = 0xbe
+	OP_ATHROW          byte = 0xbf
+	OP_CHECKCAST       byte = 0xc0
+	OP_INSTANCEOF      byte = 0xc1
+	OP_MONITORENTER    byte = 0xc2
+	OP_MONITOREXIT     byte = 0xc3
+	OP_WIDE            byte = 0xc4
+	OP_MULTIANEWARRAY  byte = 0xc5
+	OP_IFNULL          byte = 0xc6
+	OP_IFNONNULL       byte = 0xc7
+	OP_GOTO_W          byte = 0xc8
+	OP_JSR_W           byte = 0xc9
+)
+
+var opcodeNames = [256]string{
+	OP_NOP:             "nop",
+	OP_ACONST_NULL:     "aconst_null",
+	OP_ICONST_M1:       "iconst_m1",
+	OP_ICONST_0:        "iconst_0",
+	OP_ICONST_1:        "iconst_1",
+	OP_ICONST_2:        "iconst_2",
+	OP_ICONST_3:        "iconst_3",
+	OP_ICONST_4:        "iconst_4",
+	OP_ICONST_5:        "iconst_5",
+	OP_LCONST_0:        "lconst_0",
+	OP_LCONST_1:        "lconst_1",
+	OP_FCONST_0:        "fconst_0",
+	OP_FCONST_1:        "fconst_1",
+	OP_FCONST_2:        "fconst_2",
+	OP_DCONST_0:        "dconst_0",
+	OP_DCONST_1:        "dconst_1",
+	OP_BIPUSH:          "bipush",
+	OP_SIPUSH:          "sipush",
+	OP_LDC:             "ldc",
+	OP_LDC_W:           "ldc_w",
+	OP_LDC2_W:          "ldc2_w",
+	OP_ILOAD:           "iload",
+	OP_LLOAD:           "lload",
+	OP_FLOAD:           "fload",
+	OP_DLOAD:           "dload",
+	OP_ALOAD:           "aload",
+	OP_ILOAD_0:         "iload_0",
+	OP_ILOAD_1:         "iload_1",
+	OP_ILOAD_2:         "iload_2",
+	OP_ILOAD_3:         "iload_3",
+	OP_LLOAD_0:         "lload_0",
+	OP_LLOAD_1:         "lload_1",
+	OP_LLOAD_2:         "lload_2",
+	OP_LLOAD_3:         "lload_3",
+	OP_FLOAD_0:         "fload_0",
+	OP_FLOAD_1:         "fload_1",
+	OP_FLOAD_2:         "fload_2",
+	OP_FLOAD_3:         "fload_3",
+	OP_DLOAD_0:         "dload_0",
+	OP_DLOAD_1:         "dload_1",
+	OP_DLOAD_2:         "dload_2",
+	OP_DLOAD_3:         "dload_3",
+	OP_ALOAD_0:         "aload_0",
+	OP_ALOAD_1:         "aload_1",
+	OP_ALOAD_2:         "aload_2",
+	OP_ALOAD_3:         "aload_3",
+	OP_IALOAD:          "iaload",
+	OP_LALOAD:          "laload",
+	OP_FALOAD:          "faload",
+	OP_DALOAD:          "daload",
+	OP_AALOAD:          "aaload",
+	OP_BALOAD:          "baload",
+	OP_CALOAD:          "caload",
+	OP_SALOAD:          "saload",
+	OP_ISTORE:          "istore",
+	OP_LSTORE:          "lstore",
+	OP_FSTORE:          "fstore",
+	OP_DSTORE:          "dstore",
+	OP_ASTORE:          "astore",
+	OP_ISTORE_0:        "istore_0",
+	OP_ISTORE_1:        "istore_1",
+	OP_ISTORE_2:        "istore_2",
+	OP_ISTORE_3:        "istore_3",
+	OP_LSTORE_0:        "lstore_0",
+	OP_LSTORE_1:        "lstore_1",
+	OP_LSTORE_2:        "lstore_2",
+	OP_LSTORE_3:        "lstore_3",
+	OP_FSTORE_0:        "fstore_0",
+	OP_FSTORE_1:        "fstore_1",
+	OP_FSTORE_2:        "fstore_2",
+	OP_FSTORE_3:        "fstore_3",
+	OP_DSTORE_0:        "dstore_0",
+	OP_DSTORE_1:        "dstore_1",
+	OP_DSTORE_2:        "dstore_2",
+	OP_DSTORE_3:        "dstore_3",
+	OP_ASTORE_0:        "astore_0",
+	OP_ASTORE_1:        "astore_1",
+	OP_ASTORE_2:        "astore_2",
+	OP_ASTORE_3:        "astore_3",
+	OP_IASTORE:         "iastore",
+	OP_LASTORE:         "lastore",
+	OP_FASTORE:         "fastore",
+	OP_DASTORE:         "dastore",
+	OP_AASTORE:         "aastore",
+	OP_BASTORE:         "bastore",
+	OP_CASTORE:         "castore",
+	OP_SASTORE:         "sastore",
+	OP_POP:             "pop",
+	OP_POP2:            "pop2",
+	OP_DUP:             "dup",
+	OP_DUP_X1:          "dup_x1",
+	OP_DUP_X2:          "dup_x2",
+	OP_DUP2:            "dup2",
+	OP_DUP2_X1:         "dup2_x1",
+	OP_DUP2_X2:         "dup2_x2",
+	OP_SWAP:            "swap",
+	OP_IADD:            "iadd",
+	OP_LADD:            "ladd",
+	OP_FADD:            "fadd",
+	OP_DADD:            "dadd",
+	OP_ISUB:            "isub",
+	OP_LSUB:            "lsub",
+	OP_FSUB:            "fsub",
+	OP_DSUB:            "dsub",
+	OP_IMUL:            "imul",
+	OP_LMUL:            "lmul",
+	OP_FMUL:            "fmul",
+	OP_DMUL:            "dmul",
+	OP_IDIV:            "idiv",
+	OP_LDIV:            "ldiv",
+	OP_FDIV:            "fdiv",
+	OP_DDIV:            "ddiv",
+	OP_IREM:            "irem",
+	OP_LREM:            "lrem",
+	OP_FREM:            "frem",
+	OP_DREM:            "drem",
+	OP_INEG:            "ineg",
+	OP_LNEG:            "lneg",
+	OP_FNEG:            "fneg",
+	OP_DNEG:            "dneg",
+	OP_ISHL:            "ishl",
+	OP_LSHL:            "lshl",
+	OP_ISHR:            "ishr",
+	OP_LSHR:            "lshr",
+	OP_IUSHR:           "iushr",
+	OP_LUSHR:           "lushr",
+	OP_IAND:            "iand",
+	OP_LAND:            "land",
+	OP_IOR:             "ior",
+	OP_LOR:             "lor",
+	OP_IXOR:            "ixor",
+	OP_LXOR:            "lxor",
+	OP_IINC:            "iinc",
+	OP_I2L:             "i2l",
+	OP_I2F:             "i2f",
+	OP_I2D:             "i2d",
+	OP_L2I:             "l2i",
+	OP_L2F:             "l2f",
+	OP_L2D:             "l2d",
+	OP_F2I:             "f2i",
+	OP_F2L:             "f2l",
+	OP_F2D:             "f2d",
+	OP_D2I:             "d2i",
+	OP_D2L:             "d2l",
+	OP_D2F:             "d2f",
+	OP_I2B:             "i2b",
+	OP_I2C:             "i2c",
+	OP_I2S:             "i2s",
+	OP_LCMP:            "lcmp",
+	OP_FCMPL:           "fcmpl",
+	OP_FCMPG:           "fcmpg",
+	OP_DCMPL:           "dcmpl",
+	OP_DCMPG:           "dcmpg",
+	OP_IFEQ:            "ifeq",
+	OP_IFNE:            "ifne",
+	OP_IFLT:            "iflt",
+	OP_IFGE:            "ifge",
+	OP_IFGT:            "ifgt",
+	OP_IFLE:            "ifle",
+	OP_IF_ICMPEQ:       "if_icmpeq",
+	OP_IF_ICMPNE:       "if_icmpne",
+	OP_IF_ICMPLT:       "if_icmplt",
+	OP_IF_ICMPGE:       "if_icmpge",
+	OP_IF_ICMPGT:       "if_icmpgt",
+	OP_IF_ICMPLE:       "if_icmple",
+	OP_IF_ACMPEQ:       "if_acmpeq",
+	OP_IF_ACMPNE:       "if_acmpne",
+	OP_GOTO:            "goto",
+	OP_JSR:             "jsr",
+	OP_RET:             "ret",
+	OP_TABLESWITCH:     "tableswitch",
+	OP_LOOKUPSWITCH:    "lookupswitch",
+	OP_IRETURN:         "ireturn",
+	OP_LRETURN:         "lreturn",
+	OP_FRETURN:         "freturn",
+	OP_DRETURN:         "dreturn",
+	OP_ARETURN:         "areturn",
+	OP_RETURN:          "return",
+	OP_GETSTATIC:       "getstatic",
+	OP_PUTSTATIC:       "putstatic",
+	OP_GETFIELD:        "getfield",
+	OP_PUTFIELD:        "putfield",
+	OP_INVOKEVIRTUAL:   "invokevirtual",
+	OP_INVOKESPECIAL:   "invokespecial",
+	OP_INVOKESTATIC:    "invokestatic",
+	OP_INVOKEINTERFACE: "invokeinterface",
+	OP_INVOKEDYNAMIC:   "invokedynamic",
+	OP_NEW:             "new",
+	OP_NEWARRAY:        "newarray",
+	OP_ANEWARRAY:       "anewarray",
+	OP_ARRAYLENGTH:     "arraylength",
+	OP_ATHROW:          "athrow",
+	OP_CHECKCAST:       "checkcast",
+	OP_INSTANCEOF:      "instanceof",
+	OP_MONITORENTER:    "monitorenter",
+	OP_MONITOREXIT:     "monitorexit",
+	OP_WIDE:            "wide",
+	OP_MULTIANEWARRAY:  "multianewarray",
+	OP_IFNULL:          "ifnull",
+	OP_IFNONNULL:       "ifnonnull",
+	OP_GOTO_W:          "goto_w",
+	OP_JSR_W:           "jsr_w",
+}
+
+// 操作码的助记符，未定义的操作码返回空串
+func OpcodeName(op byte) string {
+	return opcodeNames[op]
+}
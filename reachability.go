@@ -0,0 +1,489 @@
+package jclass
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 可达性分析的入口
+type EntryPoints struct {
+	// public static void main(String[])
+	Mains bool
+	// META-INF/services 中登记的服务实现
+	Services bool
+	// 带有这些注解的类（全部成员）与成员，Java 形式的类型名，也可只写简单名，如 RestController
+	Annotations []string
+	// 公开 API 包模式（见 rules.go），其中 public 类的 public、protected 成员都作为入口
+	APIPackages []string
+	// 方法模式，见 ParseMethodPattern
+	Methods []string
+}
+
+// 可达的类、方法（MethodKey）与字段（FieldKey）
+type Reachability struct {
+	Classes map[string]bool
+	Methods map[string]bool
+	Fields  map[string]bool
+}
+
+func FieldKey(class, name, descriptor string) string {
+	return class + "." + name + ":" + descriptor
+}
+
+type virtualCall struct {
+	class      string
+	name       string
+	descriptor string
+}
+
+type reachabilityBuilder struct {
+	p *ClassPath
+	r *Reachability
+
+	methods []virtualCall
+	// 已出现的虚调用，按调用引用的类索引
+	calls    map[virtualCall]bool
+	virtuals map[string][]virtualCall
+	// 可达的类按其超类型（含自身）索引
+	subtypes map[string][]string
+	// 已分派过的接收者类型与方法签名
+	dispatched map[string]bool
+}
+
+// 从入口出发，沿代码中的方法调用、字段访问与类引用计算可达的类和成员。
+// 虚调用只分派到已可达的类型，后续变为可达的类型会补做分派；lambda 与方法句柄指向的方法也视为被调用。
+func (p *ClassPath) Reachable(entries *EntryPoints) (*Reachability, error) {
+	b := newReachabilityBuilder(p)
+
+	var api []*regexp.Regexp
+	for _, pattern := range entries.APIPackages {
+		re, err := compilePackagePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		api = append(api, re)
+	}
+
+	for _, name := range p.ClassNames() {
+		cf := p.Class(name)
+		switch {
+		case hasAnnotation(cf.Annotations(), entries.Annotations):
+			b.class(name)
+			for _, m := range cf.Methods {
+				b.method(name, m.NameString(), m.DescriptorString())
+			}
+			for _, f := range cf.Fields {
+				b.field(name, f.NameString(), f.DescriptorString())
+			}
+			continue
+
+		case api != nil && cf.IsPublic() && matchPackage(name, api):
+			b.class(name)
+			for _, m := range cf.Methods {
+				if m.AccessFlags&(METHOD_ACC_PUBLIC|METHOD_ACC_PROTECTED) != 0 {
+					b.method(name, m.NameString(), m.DescriptorString())
+				}
+			}
+			for _, f := range cf.Fields {
+				if f.AccessFlags&(FIELD_ACC_PUBLIC|FIELD_ACC_PROTECTED) != 0 {
+					b.field(name, f.NameString(), f.DescriptorString())
+				}
+			}
+			continue
+		}
+
+		for _, m := range cf.Methods {
+			if hasAnnotation(m.Annotations(), entries.Annotations) ||
+				entries.Mains && m.NameString() == "main" && m.DescriptorString() == "([Ljava/lang/String;)V" &&
+					m.AccessFlags&METHOD_ACC_STATIC != 0 && m.AccessFlags&METHOD_ACC_PUBLIC != 0 {
+				b.method(name, m.NameString(), m.DescriptorString())
+			}
+		}
+		for _, f := range cf.Fields {
+			if hasAnnotation(f.Annotations(), entries.Annotations) {
+				b.field(name, f.NameString(), f.DescriptorString())
+			}
+		}
+	}
+
+	if entries.Services {
+		for _, jar := range p.Jars {
+			for _, providers := range jar.Services() {
+				for _, provider := range providers {
+					provider = strings.Replace(provider, ".", "/", -1)
+					b.class(provider)
+					b.method(provider, "<init>", "()V")
+					if cf := p.Class(provider); cf != nil {
+						for _, m := range cf.Methods {
+							if m.NameString() == "provider" && m.AccessFlags&METHOD_ACC_STATIC != 0 {
+								b.method(provider, m.NameString(), m.DescriptorString())
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, pattern := range entries.Methods {
+		class, name, desc := ParseMethodPattern(pattern)
+		cf := p.Class(class)
+		if cf == nil {
+			return nil, fmt.Errorf("entry point not found: %s", pattern)
+		}
+		found := false
+		for _, m := range cf.Methods {
+			if m.NameString() == name && (desc == "" || m.DescriptorString() == desc) {
+				b.method(class, name, m.DescriptorString())
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("entry point not found: %s", pattern)
+		}
+	}
+
+	b.drain()
+	return b.r, nil
+}
+
+func newReachabilityBuilder(p *ClassPath) *reachabilityBuilder {
+	return &reachabilityBuilder{
+		p: p,
+		r: &Reachability{
+			Classes: make(map[string]bool),
+			Methods: make(map[string]bool),
+			Fields:  make(map[string]bool),
+		},
+		calls:      make(map[virtualCall]bool),
+		virtuals:   make(map[string][]virtualCall),
+		subtypes:   make(map[string][]string),
+		dispatched: make(map[string]bool),
+	}
+}
+
+// 处理所有待扫描的方法
+func (b *reachabilityBuilder) drain() {
+	for len(b.methods) > 0 {
+		m := b.methods[0]
+		b.methods = b.methods[1:]
+		b.scan(m.class, m.name, m.descriptor)
+	}
+}
+
+// 注解类型是否在 names 中；names 中不含点的名称按简单名匹配
+func hasAnnotation(anns []*Annotation, names []string) bool {
+	for _, ann := range anns {
+		name := strings.Replace(ann.TypeName(), "/", ".", -1)
+		for _, want := range names {
+			if name == want || !strings.Contains(want, ".") && strings.HasSuffix(name, "."+want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *reachabilityBuilder) class(name string) {
+	name = elementClass(name)
+	if name == "" || b.r.Classes[name] {
+		return
+	}
+	b.r.Classes[name] = true
+
+	cf := b.p.Class(name)
+	if cf == nil {
+		return
+	}
+	b.class(cf.SuperClassString())
+	for _, iface := range cf.InterfaceStrings() {
+		b.class(iface)
+	}
+	for _, ann := range cf.Annotations() {
+		b.class(ann.TypeName())
+	}
+	if cf.FindMethod("<clinit>", "()V") != nil {
+		b.method(name, "<clinit>", "()V")
+	}
+
+	external := b.p.hasExternalSupertype(name)
+	for _, m := range cf.Methods {
+		key := m.NameString() + m.DescriptorString()
+		if m.AccessFlags&(METHOD_ACC_STATIC|METHOD_ACC_PRIVATE) == 0 && m.NameString() != "<init>" &&
+			(objectMethods[key] || external) {
+			// 覆盖了 classpath 之外的方法，可能被外部代码回调
+			b.method(name, m.NameString(), m.DescriptorString())
+		} else if cf.IsEnum() && (m.NameString() == "values" || m.NameString() == "valueOf") ||
+			serialMethods[key] && b.p.IsAssignable(name, "java/io/Serializable") == RESOLVE_FOUND {
+			b.method(name, m.NameString(), m.DescriptorString())
+		}
+	}
+
+	if cf.IsInterface() {
+		return
+	}
+	for _, super := range append([]string{name}, b.p.Supertypes(name)...) {
+		b.subtypes[super] = append(b.subtypes[super], name)
+		for _, call := range b.virtuals[super] {
+			b.dispatch(name, call)
+		}
+	}
+}
+
+// 序列化机制通过反射调用的方法
+var serialMethods = map[string]bool{
+	"writeObject(Ljava/io/ObjectOutputStream;)V": true,
+	"readObject(Ljava/io/ObjectInputStream;)V":   true,
+	"readObjectNoData()V":                        true,
+	"writeReplace()Ljava/lang/Object;":           true,
+	"readResolve()Ljava/lang/Object;":            true,
+}
+
+// 除 java/lang/Object 外是否有不在 classpath 上的超类型
+func (p *ClassPath) hasExternalSupertype(class string) bool {
+	cf := p.Class(class)
+	if cf == nil {
+		return class != "java/lang/Object"
+	}
+	if super := cf.SuperClassString(); super != "" && p.hasExternalSupertype(super) {
+		return true
+	}
+	for _, iface := range cf.InterfaceStrings() {
+		if p.hasExternalSupertype(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *reachabilityBuilder) method(class, name, descriptor string) {
+	key := MethodKey(class, name, descriptor)
+	if b.r.Methods[key] {
+		return
+	}
+	b.r.Methods[key] = true
+	b.class(class)
+	b.methods = append(b.methods, virtualCall{class, name, descriptor})
+}
+
+func (b *reachabilityBuilder) field(class, name, descriptor string) {
+	key := FieldKey(class, name, descriptor)
+	if b.r.Fields[key] {
+		return
+	}
+	b.r.Fields[key] = true
+	b.class(class)
+	for _, c := range DescriptorClasses(descriptor) {
+		b.class(c)
+	}
+	if cf := b.p.Class(class); cf != nil {
+		if f := cf.FindField(name, descriptor); f != nil {
+			for _, ann := range f.Annotations() {
+				b.class(ann.TypeName())
+			}
+		}
+	}
+}
+
+// 在接收者类型 t 上分派虚调用，t 是 call.class 的子类型；同一类型与方法签名只分派一次
+func (b *reachabilityBuilder) dispatch(t string, call virtualCall) {
+	key := MethodKey(t, call.name, call.descriptor)
+	if b.dispatched[key] {
+		return
+	}
+	b.dispatched[key] = true
+	if owner, m, r := b.p.Dispatch(t, call.name, call.descriptor); r == RESOLVE_FOUND {
+		b.method(owner.ThisClassString(), m.NameString(), m.DescriptorString())
+	}
+}
+
+func (b *reachabilityBuilder) invoke(class, name, descriptor string, virtual bool) {
+	if strings.HasPrefix(class, "[") {
+		class = "java/lang/Object"
+	}
+	if owner, m, r := b.p.ResolveMethod(class, name, descriptor); r == RESOLVE_FOUND && owner != nil {
+		b.method(owner.ThisClassString(), m.NameString(), m.DescriptorString())
+	} else {
+		b.class(class)
+	}
+	for _, c := range DescriptorClasses(descriptor) {
+		b.class(c)
+	}
+
+	if virtual {
+		call := virtualCall{class, name, descriptor}
+		if b.calls[call] {
+			return
+		}
+		b.calls[call] = true
+		b.virtuals[class] = append(b.virtuals[class], call)
+		for _, t := range b.subtypes[class] {
+			b.dispatch(t, call)
+		}
+	}
+}
+
+func (b *reachabilityBuilder) reference(cf *ClassFile, index uint16) {
+	ref := cf.ReferenceAt(index)
+	if ref.Kind == "field" {
+		b.accessField(ref.Class, ref.Name, ref.Descriptor)
+	} else {
+		b.invoke(ref.Class, ref.Name, ref.Descriptor, false)
+	}
+}
+
+func (b *reachabilityBuilder) accessField(class, name, descriptor string) {
+	if owner, _, r := b.p.ResolveField(class, name, descriptor); r == RESOLVE_FOUND {
+		b.field(owner.ThisClassString(), name, descriptor)
+	} else {
+		b.class(class)
+	}
+}
+
+// 方法句柄常量指向的字段或方法
+func (b *reachabilityBuilder) methodHandle(cf *ClassFile, index uint16) {
+	if info := cf.ConstantPool[index]; info != nil && info.Tag == 15 {
+		b.reference(cf, (*ConstantMethodHandleInfo)(info).ReferenceIndex())
+	}
+}
+
+func (b *reachabilityBuilder) scan(class, name, descriptor string) {
+	cf := b.p.Class(class)
+	if cf == nil {
+		return
+	}
+	m := cf.FindMethod(name, descriptor)
+	if m == nil {
+		return
+	}
+
+	for _, c := range DescriptorClasses(descriptor) {
+		b.class(c)
+	}
+	for _, c := range m.ExceptionStrings() {
+		b.class(c)
+	}
+	for _, ann := range m.Annotations() {
+		b.class(ann.TypeName())
+	}
+
+	code := m.Code()
+	if code == nil {
+		return
+	}
+	for _, entry := range code.ExceptionTable {
+		if entry.CatchType != 0 {
+			b.class(cf.ClassNameAt(entry.CatchType))
+		}
+	}
+
+	instructions, err := code.Instructions()
+	if err != nil {
+		return
+	}
+	for _, ins := range instructions {
+		switch ins.Opcode {
+		case OP_INVOKESTATIC, OP_INVOKESPECIAL:
+			c, n, d := cf.MemberRefAt(ins.Index)
+			b.invoke(c, n, d, false)
+
+		case OP_INVOKEVIRTUAL, OP_INVOKEINTERFACE:
+			c, n, d := cf.MemberRefAt(ins.Index)
+			b.invoke(c, n, d, true)
+
+		case OP_GETSTATIC, OP_PUTSTATIC, OP_GETFIELD, OP_PUTFIELD:
+			c, n, d := cf.MemberRefAt(ins.Index)
+			b.accessField(c, n, d)
+
+		case OP_NEW, OP_CHECKCAST, OP_INSTANCEOF, OP_ANEWARRAY, OP_MULTIANEWARRAY:
+			b.class(cf.ClassNameAt(ins.Index))
+
+		case OP_LDC, OP_LDC_W:
+			switch cf.ConstantPool[ins.Index].Tag {
+			case 7:
+				b.class(cf.ClassNameAt(ins.Index))
+			case 15:
+				b.methodHandle(cf, ins.Index)
+			}
+
+		case OP_INVOKEDYNAMIC:
+			indy := (*ConstantInvokeDynamicInfo)(cf.ConstantPool[ins.Index])
+			bms := cf.BootstrapMethods()
+			if int(indy.BootstrapMethodAttrIndex()) >= len(bms) {
+				continue
+			}
+			bm := bms[indy.BootstrapMethodAttrIndex()]
+			b.reference(cf, bm.MethodHandle().ReferenceIndex())
+			for _, arg := range bm.BootstrapArguments {
+				b.methodHandle(cf, arg)
+			}
+		}
+	}
+}
+
+// 不可达的类，以及可达类中不可达的方法和字段
+type UnusedReport struct {
+	Classes []string `json:"classes"`
+	Methods []string `json:"methods"`
+	Fields  []string `json:"fields"`
+}
+
+// 按可达性结果列出 classpath 上未被使用的代码，module-info 与 package-info 不计入
+func (p *ClassPath) Unused(r *Reachability) *UnusedReport {
+	rs := &UnusedReport{
+		Classes: []string{},
+		Methods: []string{},
+		Fields:  []string{},
+	}
+	for _, name := range p.ClassNames() {
+		if name == "module-info" || strings.HasSuffix(name, "/package-info") {
+			continue
+		}
+		if !r.Classes[name] {
+			rs.Classes = append(rs.Classes, name)
+			continue
+		}
+		cf := p.Class(name)
+		for _, m := range cf.Methods {
+			key := MethodKey(name, m.NameString(), m.DescriptorString())
+			if !r.Methods[key] {
+				rs.Methods = append(rs.Methods, key)
+			}
+		}
+		for _, f := range cf.Fields {
+			key := FieldKey(name, f.NameString(), f.DescriptorString())
+			if !r.Fields[key] {
+				rs.Fields = append(rs.Fields, key)
+			}
+		}
+	}
+	sort.Strings(rs.Methods)
+	sort.Strings(rs.Fields)
+	return rs
+}
+
+func (r *UnusedReport) WriteText(w io.Writer) error {
+	s := &strings.Builder{}
+	for _, c := range r.Classes {
+		fmt.Fprintln(s, "class", strings.Replace(c, "/", ".", -1))
+	}
+	for _, m := range r.Methods {
+		fmt.Fprintln(s, "method", m)
+	}
+	for _, f := range r.Fields {
+		fmt.Fprintln(s, "field", f)
+	}
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+func (r *UnusedReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,327 @@
+package jclass
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// 一条包重定位规则，From、To 为内部形式并以 / 结尾，如 com/google/common/ -> shaded/guava/
+type Relocation struct {
+	From string
+	To   string
+	// 不重定位的类，Java 形式的类名模式（见 matchClassPattern），如 com.google.common.Internal*
+	Excludes []string
+}
+
+// 解析 from:to 形式的规则，包名可以用 . 或 / 分隔
+func ParseRelocation(s string) (*Relocation, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid relocation: %s", s)
+	}
+	normalize := func(pkg string) string {
+		return strings.TrimSuffix(strings.Replace(strings.TrimSpace(pkg), ".", "/", -1), "/") + "/"
+	}
+	return &Relocation{From: normalize(parts[0]), To: normalize(parts[1])}, nil
+}
+
+// 类似 maven-shade-plugin 的 relocations：改写类名、描述符、签名、注解与 InnerClasses 中的包前缀，
+// 并相应地移动 jar 中的条目与服务文件
+type Relocator struct {
+	Relocations []*Relocation
+	// 同时改写看起来像类名或资源路径的字符串常量
+	Strings bool
+}
+
+// 重定位内部形式的类名或资源路径；不匹配任何规则时原样返回
+func (r *Relocator) ClassName(name string) string {
+	for _, rel := range r.Relocations {
+		if strings.HasPrefix(name, rel.From) && !matchClassPattern(name, rel.Excludes) {
+			return rel.To + name[len(rel.From):]
+		}
+	}
+	return name
+}
+
+// 重定位内部形式的包名，如 com/google/common；规则的 From 以 / 结尾，按 name + "/" 匹配，
+// 使被重定位的根包本身也能匹配
+func (r *Relocator) packageName(name string) string {
+	return strings.TrimSuffix(r.ClassName(name+"/"), "/")
+}
+
+// 重定位描述符或签名中的所有类名
+func (r *Relocator) Descriptor(desc string) string {
+	return mapDescriptorClasses(desc, r.ClassName)
+}
+
+// 用 mapClass 替换描述符或签名中的所有类名，内部类签名 Outer<T>.Inner 按完整类名 Outer$Inner 映射。
+// 按 JVMS 4.7.9.1 的语法解析，无法解析时原样返回
+func mapDescriptorClasses(desc string, mapClass func(string) string) string {
+	b := &strings.Builder{}
+	switch {
+	case strings.HasPrefix(desc, "("):
+		if sig, err := ParseMethodSignature(desc); err == nil {
+			sig.writeMapped(b, mapClass)
+			return b.String()
+		}
+	case strings.HasPrefix(desc, "<"):
+		if sig, err := ParseClassSignature(desc); err == nil {
+			sig.writeMapped(b, mapClass)
+			return b.String()
+		}
+		if sig, err := ParseMethodSignature(desc); err == nil {
+			sig.writeMapped(b, mapClass)
+			return b.String()
+		}
+	default:
+		if t, err := ParseFieldSignature(desc); err == nil {
+			t.writeMapped(b, mapClass)
+			return b.String()
+		}
+		if sig, err := ParseClassSignature(desc); err == nil {
+			sig.writeMapped(b, mapClass)
+			return b.String()
+		}
+	}
+	return desc
+}
+
+// 字符串常量：内部形式的类名或路径，以及 Java 形式的类名
+func (r *Relocator) stringConstant(s string) string {
+	if strings.Contains(s, "/") {
+		return r.ClassName(s)
+	}
+	if strings.Contains(s, ".") {
+		slashed := strings.Replace(s, ".", "/", -1)
+		if relocated := r.ClassName(slashed); relocated != slashed {
+			return strings.Replace(relocated, "/", ".", -1)
+		}
+	}
+	return s
+}
+
+func isDescriptorLike(s string) bool {
+	if s == "" {
+		return false
+	}
+	switch s[0] {
+	case '(':
+		_, err := ParseMethodSignature(s)
+		return err == nil
+	case '<':
+		if _, err := ParseClassSignature(s); err == nil {
+			return true
+		}
+		_, err := ParseMethodSignature(s)
+		return err == nil
+	case 'L', '[', 'T':
+		if _, err := ParseFieldSignature(s); err == nil {
+			return true
+		}
+		_, err := ParseClassSignature(s)
+		return err == nil
+	}
+	return false
+}
+
+// 重定位一个类中的所有类名引用，返回新类。
+// Utf8 项按其被引用的方式改写：Class 与 Package 项的名称、可以解析为描述符或签名的字符串，
+// 以及（Strings 为 true 时）只被 String 常量引用的字符串
+func (r *Relocator) RelocateClass(cf *ClassFile) (*ClassFile, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(c.ConstantPool)
+	classNames := make([]bool, n)
+	packages := make([]bool, n)
+	byString := make([]bool, n)
+	byOther := make([]bool, n)
+
+	for _, info := range c.ConstantPool {
+		if info == nil {
+			continue
+		}
+		for _, off := range constantPoolInfoRefs(info) {
+			index := binary.BigEndian.Uint16(info.Info[off:])
+			switch info.Tag {
+			case 8:
+				byString[index] = true
+			case 7:
+				classNames[index] = true
+				byOther[index] = true
+			case 20:
+				packages[index] = true
+				byOther[index] = true
+			default:
+				byOther[index] = true
+			}
+		}
+	}
+	err = c.visitConstantPoolRefs(func(index uint16, kind refKind, owner uint16) uint16 {
+		byOther[index] = true
+		return index
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	relocate := func(index int) string {
+		s := c.Utf8At(uint16(index))
+		switch {
+		case classNames[index] && strings.HasPrefix(s, "["):
+			return r.Descriptor(s)
+		case classNames[index]:
+			return r.ClassName(s)
+		case packages[index]:
+			return r.packageName(s)
+		case byOther[index] && isDescriptorLike(s):
+			return r.Descriptor(s)
+		case byString[index] && !byOther[index] && r.Strings:
+			return r.stringConstant(s)
+		}
+		return s
+	}
+
+	// 字符串常量与类名共用 Utf8 项而不改写字符串时，为字符串复制一份原值
+	if !r.Strings {
+		for _, info := range c.ConstantPool[:n] {
+			if info == nil || info.Tag != 8 {
+				continue
+			}
+			index := int((*ConstantStringInfo)(info).StringIndex())
+			if byOther[index] && relocate(index) != c.Utf8At(uint16(index)) {
+				binary.BigEndian.PutUint16(info.Info, uint16(len(c.ConstantPool)))
+				c.ConstantPool = append(c.ConstantPool, NewConstantUtf8(c.Utf8At(uint16(index))))
+			}
+		}
+		if len(c.ConstantPool) > 0xFFFF {
+			return nil, fmt.Errorf("%s: constant pool overflow", c.ThisClassString())
+		}
+	}
+
+	for i := 1; i < n; i++ {
+		info := c.ConstantPool[i]
+		if info == nil || info.Tag != 1 {
+			continue
+		}
+		if s := relocate(i); s != c.Utf8At(uint16(i)) {
+			c.ConstantPool[i] = NewConstantUtf8(s)
+		}
+	}
+
+	return NewClassFile(bytes.NewReader(c.Bytes()))
+}
+
+// 改写 META-INF/services 文件内容中的实现类名，mapClass 作用于内部形式的类名
+func mapServiceFile(data []byte, mapClass func(string) string) []byte {
+	out := &bytes.Buffer{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		name := strings.TrimSpace(line)
+		if i := strings.IndexByte(name, '#'); i >= 0 {
+			name = strings.TrimSpace(name[:i])
+		}
+		if name != "" {
+			line = strings.Replace(line, name, mapJavaName(name, mapClass), 1)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+func mapJavaName(name string, mapClass func(string) string) string {
+	return strings.Replace(mapClass(strings.Replace(name, ".", "/", -1)), "/", ".", -1)
+}
+
+// 改写 MANIFEST.MF 中的 Main-Class 与类文件的条目段名；无法解析或无需改写时原样返回
+func mapManifest(data []byte, mapClass func(string) string) []byte {
+	m, err := ParseManifest(data)
+	if err != nil {
+		return data
+	}
+	changed := false
+	if main := strings.TrimSpace(m.Main.Get("Main-Class")); main != "" && mapJavaName(main, mapClass) != main {
+		m.Main.Set("Main-Class", mapJavaName(main, mapClass))
+		changed = true
+	}
+	for _, section := range m.Sections {
+		name := section.Name()
+		if !strings.HasSuffix(name, ".class") {
+			continue
+		}
+		if mapped := mapClass(strings.TrimSuffix(name, ".class")) + ".class"; mapped != name {
+			section.Set("Name", mapped)
+			changed = true
+		}
+	}
+	if !changed {
+		return data
+	}
+	return m.Bytes()
+}
+
+// 重定位条目路径：类文件与资源按包路径移动，多版本 jar 的版本目录保持不变，服务文件按接口名改名
+func (r *Relocator) entryName(name string) string {
+	if strings.HasPrefix(name, "META-INF/services/") {
+		return "META-INF/services/" + mapJavaName(strings.TrimPrefix(name, "META-INF/services/"), r.ClassName)
+	}
+	if strings.HasPrefix(name, "META-INF/versions/") {
+		parts := strings.SplitN(name, "/", 4)
+		if len(parts) == 4 {
+			return strings.Join(parts[:3], "/") + "/" + r.ClassName(parts[3])
+		}
+	}
+	if strings.HasPrefix(name, "META-INF/") {
+		return name
+	}
+	return r.ClassName(name)
+}
+
+// 把多个 jar 合并并重定位为一个新 jar；同名条目以先出现者为准，签名文件被去掉
+func (r *Relocator) RelocateJars(path string, jars ...*Jar) (*Jar, error) {
+	out := NewJar(path)
+	for _, jar := range jars {
+		for _, entry := range jar.Entries {
+			name := r.entryName(entry.Name)
+			if out.Entry(name) != nil || isSignatureFile(entry.Name) {
+				continue
+			}
+
+			data := entry.Data
+			switch {
+			case entry.IsClass():
+				cf := entry.Class
+				if cf == nil {
+					// META-INF/versions 下的类不在 Jar 中预先解析
+					var err error
+					if cf, err = NewClassFile(bytes.NewReader(data)); err != nil {
+						return nil, &JarError{Path: jar.Path, Entry: entry.Name, Err: err}
+					}
+				}
+				relocated, err := r.RelocateClass(cf)
+				if err != nil {
+					return nil, &JarError{Path: jar.Path, Entry: entry.Name, Err: err}
+				}
+				data = relocated.Bytes()
+
+			case strings.HasPrefix(entry.Name, "META-INF/services/"):
+				data = mapServiceFile(data, r.ClassName)
+
+			case entry.Name == "META-INF/MANIFEST.MF":
+				data = mapManifest(data, r.ClassName)
+			}
+
+			if err := out.AddEntry(name, data, entry.Modified); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
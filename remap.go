@@ -0,0 +1,389 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// 按映射文件重命名类、字段与方法。成员沿继承关系查找映射，
+// 子类中的覆盖方法与超类型中的声明保持相同的新名称
+type Remapper struct {
+	Mapping *Mapping
+	// 用于查找超类型中的成员映射，为 nil 时只按引用中的类查找
+	ClassPath *ClassPath
+}
+
+// 映射内部形式的类名：未列出的内部类跟随外部类改名，未列出的类按包映射移动
+func (r *Remapper) ClassName(name string) string {
+	if strings.HasPrefix(name, "[") {
+		return r.Descriptor(name)
+	}
+	if cm := r.Mapping.Classes[name]; cm != nil {
+		return cm.NewName
+	}
+	if i := strings.LastIndexByte(name, '$'); i > 0 {
+		if outer := r.ClassName(name[:i]); outer != name[:i] {
+			return outer + name[i:]
+		}
+	}
+	if len(r.Mapping.Packages) > 0 {
+		pkg, simple := "", name
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			pkg, simple = name[:i], name[i+1:]
+		}
+		if newPkg, ok := r.Mapping.Packages[pkg]; ok {
+			if newPkg == "" {
+				return simple
+			}
+			return newPkg + "/" + simple
+		}
+	}
+	return name
+}
+
+// 映射描述符或签名中的类名
+func (r *Remapper) Descriptor(desc string) string {
+	return mapDescriptorClasses(desc, r.ClassName)
+}
+
+// 按引用中的类及其超类型查找成员映射（JVMS 5.4.3.2、5.4.3.3）。超类型中的私有成员与接口的静态方法
+// 不参与解析，跳过；字段、静态方法与私有方法解析到第一个声明它的类为止，
+// 可被覆盖的实例方法继续向上查找，使覆盖方法与超类型中的声明保持相同的新名称
+func (r *Remapper) memberName(owner, name, desc string, method bool) string {
+	if strings.HasPrefix(owner, "[") {
+		owner = "java/lang/Object"
+	}
+	classes := []string{owner}
+	if r.ClassPath != nil {
+		classes = append(classes, r.ClassPath.Supertypes(owner)...)
+	}
+
+	for i, class := range classes {
+		resolved := false
+		if r.ClassPath != nil {
+			if cf := r.ClassPath.Class(class); cf != nil {
+				if method {
+					if m := cf.FindMethod(name, desc); m != nil {
+						if i > 0 && (m.AccessFlags&METHOD_ACC_PRIVATE != 0 ||
+							m.AccessFlags&METHOD_ACC_STATIC != 0 && cf.AccessFlags&CLASS_ACC_INTERFACE != 0) {
+							continue
+						}
+						resolved = m.AccessFlags&(METHOD_ACC_PRIVATE|METHOD_ACC_STATIC) != 0
+					}
+				} else if f := cf.FindField(name, desc); f != nil {
+					if i > 0 && f.AccessFlags&FIELD_ACC_PRIVATE != 0 {
+						continue
+					}
+					resolved = true
+				}
+			}
+		}
+
+		if cm := r.Mapping.Classes[class]; cm != nil {
+			var m *MemberMapping
+			if method {
+				m = cm.Method(name, desc)
+			} else {
+				m = cm.Field(name, desc)
+			}
+			if m != nil {
+				return m.NewName
+			}
+		}
+		if resolved {
+			break
+		}
+	}
+	return name
+}
+
+// 映射字段名，owner 与 desc 使用映射前的名称
+func (r *Remapper) FieldName(owner, name, desc string) string {
+	return r.memberName(owner, name, desc, false)
+}
+
+// 映射方法名，owner 与 desc 使用映射前的名称；desc 为空时只按名称查找。构造方法与静态初始化方法不改名
+func (r *Remapper) MethodName(owner, name, desc string) string {
+	if name == "<init>" || name == "<clinit>" {
+		return name
+	}
+	return r.memberName(owner, name, desc, true)
+}
+
+// 在常量池末尾追加新项，与已有项内容相同时复用已有项
+type constantPoolAppender struct {
+	cf      *ClassFile
+	entries map[string]uint16
+}
+
+func newConstantPoolAppender(cf *ClassFile) *constantPoolAppender {
+	a := &constantPoolAppender{
+		cf:      cf,
+		entries: make(map[string]uint16),
+	}
+	for i, info := range cf.ConstantPool {
+		if info == nil {
+			continue
+		}
+		key := string(append([]byte{info.Tag}, info.Info...))
+		if _, ok := a.entries[key]; !ok {
+			a.entries[key] = uint16(i)
+		}
+	}
+	return a
+}
+
+func (a *constantPoolAppender) entry(tag byte, info []byte) uint16 {
+	key := string(append([]byte{tag}, info...))
+	if index, ok := a.entries[key]; ok {
+		return index
+	}
+	index := uint16(len(a.cf.ConstantPool))
+	a.cf.ConstantPool = append(a.cf.ConstantPool, &ConstantPoolInfo{Tag: tag, Info: info})
+	a.entries[key] = index
+	return index
+}
+
+func (a *constantPoolAppender) refs(tag byte, indexes ...uint16) uint16 {
+	info := make([]byte, 2*len(indexes))
+	for i, index := range indexes {
+		binary.BigEndian.PutUint16(info[2*i:], index)
+	}
+	return a.entry(tag, info)
+}
+
+func (a *constantPoolAppender) utf8(s string) uint16 {
+	return a.entry(1, NewConstantUtf8(s).Info)
+}
+
+func (a *constantPoolAppender) class(name string) uint16 {
+	return a.refs(7, a.utf8(name))
+}
+
+func (a *constantPoolAppender) str(s string) uint16 {
+	return a.refs(8, a.utf8(s))
+}
+
+func (a *constantPoolAppender) nameAndType(name, desc string) uint16 {
+	return a.refs(12, a.utf8(name), a.utf8(desc))
+}
+
+// Fieldref（9）、Methodref（10）或 InterfaceMethodref（11）
+func (a *constantPoolAppender) memberRef(tag byte, class, name, desc string) uint16 {
+	return a.refs(tag, a.class(class), a.nameAndType(name, desc))
+}
+
+func (a *constantPoolAppender) overflow() bool {
+	return len(a.cf.ConstantPool) > 0xFFFF
+}
+
+// lambda 的函数式接口与被实现的方法描述符，不是 LambdaMetafactory 调用时返回空串
+func lambdaInterface(cf *ClassFile, index uint16) (iface, samDesc string) {
+	indy := (*ConstantInvokeDynamicInfo)(cf.ConstantPool[index])
+	bms := cf.BootstrapMethods()
+	if int(indy.BootstrapMethodAttrIndex()) >= len(bms) {
+		return "", ""
+	}
+	bm := bms[indy.BootstrapMethodAttrIndex()]
+	class, _, _ := cf.MemberRefAt(bm.MethodHandle().ReferenceIndex())
+	if class != "java/lang/invoke/LambdaMetafactory" || len(bm.BootstrapArguments) < 1 {
+		return "", ""
+	}
+	info := cf.ConstantPool[bm.BootstrapArguments[0]]
+	if info.Tag != 16 {
+		return "", ""
+	}
+	_, desc := cf.NameAndTypeAt(indy.NameAndTypeIndex())
+	ret := desc[strings.IndexByte(desc, ')')+1:]
+	if !strings.HasPrefix(ret, "L") {
+		return "", ""
+	}
+	return ret[1 : len(ret)-1], cf.Utf8At((*ConstantMethodTypeInfo)(info).DescriptorIndex())
+}
+
+// 按映射改写一个类，返回新类。被改名的 Utf8 项不在原处修改，而是追加新项，
+// 因为同一 Utf8 可能同时被多个成员或字符串常量共用；最后压缩常量池去掉不再使用的项
+func (r *Remapper) RemapClass(cf *ClassFile) (*ClassFile, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, err
+	}
+	name := c.ThisClassString()
+	n := len(c.ConstantPool)
+	a := newConstantPoolAppender(c)
+
+	rename := func(index uint16, s, newS string) uint16 {
+		if newS == s {
+			return index
+		}
+		return a.utf8(newS)
+	}
+
+	// 先改写类结构与属性中的引用，此时常量池中的原有项尚未改动
+	err = c.visitConstantPoolRefs(func(index uint16, kind refKind, owner uint16) uint16 {
+		switch kind {
+		case refDescriptor:
+			s := c.Utf8At(index)
+			return rename(index, s, r.Descriptor(s))
+		case refFieldName, refRecordComponent:
+			s := c.Utf8At(index)
+			return rename(index, s, r.FieldName(name, s, c.Utf8At(owner)))
+		case refMethodName:
+			s := c.Utf8At(index)
+			return rename(index, s, r.MethodName(name, s, c.Utf8At(owner)))
+		case refElementName:
+			s := c.Utf8At(index)
+			typ := c.Utf8At(owner)
+			if strings.HasPrefix(typ, "L") && strings.HasSuffix(typ, ";") {
+				return rename(index, s, r.MethodName(typ[1:len(typ)-1], s, ""))
+			}
+		case refEnumConst:
+			s := c.Utf8At(index)
+			typ := c.Utf8At(owner)
+			if strings.HasPrefix(typ, "L") && strings.HasSuffix(typ, ";") {
+				return rename(index, s, r.FieldName(typ[1:len(typ)-1], s, typ))
+			}
+		case refInnerName:
+			inner := c.ClassNameAt(owner)
+			if newInner := r.ClassName(inner); newInner != inner {
+				simple := newInner[strings.LastIndexAny(newInner, "/$")+1:]
+				return rename(index, c.Utf8At(index), simple)
+			}
+		case refEnclosingMethod:
+			methodName, desc := c.NameAndTypeAt(index)
+			newName := r.MethodName(c.ClassNameAt(owner), methodName, desc)
+			newDesc := r.Descriptor(desc)
+			if newName != methodName || newDesc != desc {
+				return a.nameAndType(newName, newDesc)
+			}
+		}
+		return index
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 成员引用与动态调用点：为改名的引用建立新的 NameAndType，不影响共用原 NameAndType 的其他引用
+	for i := 1; i < n; i++ {
+		info := c.ConstantPool[i]
+		if info == nil {
+			continue
+		}
+		switch info.Tag {
+		case 9, 10, 11:
+			class, memberName, desc := c.MemberRefAt(uint16(i))
+			newName := r.MethodName(class, memberName, desc)
+			if info.Tag == 9 {
+				newName = r.FieldName(class, memberName, desc)
+			}
+			if newDesc := r.Descriptor(desc); newName != memberName || newDesc != desc {
+				binary.BigEndian.PutUint16(info.Info[2:], a.nameAndType(newName, newDesc))
+			}
+		case 17, 18:
+			memberName, desc := c.NameAndTypeAt(binary.BigEndian.Uint16(info.Info[2:]))
+			newName := memberName
+			if info.Tag == 18 {
+				// lambda 的调用点名称是函数式接口方法名，需跟随接口方法改名
+				if iface, samDesc := lambdaInterface(c, uint16(i)); iface != "" {
+					newName = r.MethodName(iface, memberName, samDesc)
+				}
+			}
+			if newDesc := r.Descriptor(desc); newName != memberName || newDesc != desc {
+				binary.BigEndian.PutUint16(info.Info[2:], a.nameAndType(newName, newDesc))
+			}
+		}
+	}
+
+	// 最后改写 Class、MethodType 与 Package 项本身
+	for i := 1; i < n; i++ {
+		info := c.ConstantPool[i]
+		if info == nil {
+			continue
+		}
+		switch info.Tag {
+		case 7:
+			s := c.ClassNameAt(uint16(i))
+			binary.BigEndian.PutUint16(info.Info, rename(binary.BigEndian.Uint16(info.Info), s, r.ClassName(s)))
+		case 16:
+			s := c.Utf8At(binary.BigEndian.Uint16(info.Info))
+			binary.BigEndian.PutUint16(info.Info, rename(binary.BigEndian.Uint16(info.Info), s, r.Descriptor(s)))
+		case 20:
+			s := c.Utf8At(binary.BigEndian.Uint16(info.Info))
+			if newPkg, ok := r.Mapping.Packages[s]; ok {
+				binary.BigEndian.PutUint16(info.Info, rename(binary.BigEndian.Uint16(info.Info), s, newPkg))
+			}
+		}
+	}
+
+	if a.overflow() {
+		return nil, fmt.Errorf("%s: constant pool overflow", name)
+	}
+
+	remapped, err := NewClassFile(bytes.NewReader(c.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if compacted, err := remapped.CompactConstantPool(); err == nil {
+		return compacted, nil
+	}
+	return remapped, nil
+}
+
+// 映射后的条目路径：类文件按新类名移动，服务文件按接口名改名，其余资源保持不变
+func (r *Remapper) entryName(name string) string {
+	switch {
+	case strings.HasPrefix(name, "META-INF/services/"):
+		return "META-INF/services/" + mapJavaName(strings.TrimPrefix(name, "META-INF/services/"), r.ClassName)
+	case !strings.HasSuffix(name, ".class"):
+		return name
+	case strings.HasPrefix(name, "META-INF/versions/"):
+		parts := strings.SplitN(name, "/", 4)
+		if len(parts) == 4 {
+			return strings.Join(parts[:3], "/") + "/" + r.ClassName(strings.TrimSuffix(parts[3], ".class")) + ".class"
+		}
+	}
+	return r.ClassName(strings.TrimSuffix(name, ".class")) + ".class"
+}
+
+// 按映射改写多个 jar 并合并为一个新 jar；同名条目以先出现者为准，签名文件被去掉
+func (r *Remapper) RemapJars(path string, jars ...*Jar) (*Jar, error) {
+	out := NewJar(path)
+	for _, jar := range jars {
+		for _, entry := range jar.Entries {
+			name := r.entryName(entry.Name)
+			if out.Entry(name) != nil || isSignatureFile(entry.Name) {
+				continue
+			}
+
+			data := entry.Data
+			switch {
+			case entry.IsClass():
+				cf := entry.Class
+				if cf == nil {
+					var err error
+					if cf, err = NewClassFile(bytes.NewReader(data)); err != nil {
+						return nil, &JarError{Path: jar.Path, Entry: entry.Name, Err: err}
+					}
+				}
+				remapped, err := r.RemapClass(cf)
+				if err != nil {
+					return nil, &JarError{Path: jar.Path, Entry: entry.Name, Err: err}
+				}
+				data = remapped.Bytes()
+
+			case strings.HasPrefix(entry.Name, "META-INF/services/"):
+				data = mapServiceFile(data, r.ClassName)
+
+			case entry.Name == "META-INF/MANIFEST.MF":
+				data = mapManifest(data, r.ClassName)
+			}
+
+			if err := out.AddEntry(name, data, entry.Modified); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
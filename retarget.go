@@ -0,0 +1,836 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 常见引导方法所在类首次出现的主版本号
+var bootstrapVersions = map[string]uint16{
+	"java/lang/invoke/LambdaMetafactory":   52,
+	"java/lang/invoke/StringConcatFactory": 53,
+	"java/lang/invoke/ConstantBootstraps":  55,
+	"java/lang/runtime/ObjectMethods":      60,
+	"java/lang/runtime/SwitchBootstraps":   65,
+}
+
+// 降级时发现的一处问题
+type RetargetIssue struct {
+	Class string
+	// 方法名与描述符，类级别的问题为空
+	Method string
+	// 字节码偏移，-1 表示与指令无关
+	Offset  int
+	Message string
+	// 已自动改写；为 false 时需要人工处理
+	Fixed bool
+}
+
+func (i *RetargetIssue) String() string {
+	s := i.Class
+	if i.Method != "" {
+		s += "." + i.Method
+	}
+	if i.Offset >= 0 {
+		s += " @" + strconv.Itoa(i.Offset)
+	}
+	status := "ERROR"
+	if i.Fixed {
+		status = "fixed"
+	}
+	return fmt.Sprintf("%s: %s [%s]", s, i.Message, status)
+}
+
+// 把类文件降级到较低的主版本号：改写目标版本无法运行但可以等价表达的结构，其余的列为问题
+type Retargeter struct {
+	// 目标主版本号
+	Target uint16
+
+	// 巢内其他类对私有成员的访问改为调用的访问方法，键见 nestAccessKey
+	accessors map[string]*nestAccessor
+	// 各类需要生成的访问方法，按类名
+	ownerAccessors map[string][]*nestAccessor
+	// 被同一巢中其他类调用的私有构造方法，MethodKey
+	nestConstructors map[string]bool
+}
+
+// 巢内对私有成员的访问方式
+const (
+	nestGet = iota
+	nestPut
+	nestInvoke
+)
+
+// Java 11 之前没有巢，其他类对私有成员的访问改为调用成员所在类中合成的静态方法 access$NNN，
+// 与旧版 javac 生成的相同。访问方法的参数依次是接收者（实例成员）与原访问的操作数，
+// 栈效果与原指令相同
+type nestAccessor struct {
+	owner string
+	iface bool
+	// 访问方法的名称与描述符
+	name string
+	desc string
+
+	access     int
+	static     bool
+	member     string
+	memberDesc string
+}
+
+// 被访问的成员，方法为 name(args)ret，字段为 name:desc
+func (a *nestAccessor) memberString() string {
+	if a.access == nestInvoke {
+		return a.member + a.memberDesc
+	}
+	return a.member + ":" + a.memberDesc
+}
+
+// 引用访问方法时使用的常量池项类型：接口中的静态方法只能通过 InterfaceMethodref 引用
+func (a *nestAccessor) tag() byte {
+	if a.iface {
+		return 11
+	}
+	return 10
+}
+
+func nestAccessKey(access int, class, name, desc string) string {
+	return strconv.Itoa(access) + " " + MethodKey(class, name, desc)
+}
+
+// 字段与方法访问指令对应的访问方式
+var nestAccessOpcodes = map[byte]int{
+	OP_GETFIELD: nestGet, OP_GETSTATIC: nestGet, OP_PUTFIELD: nestPut, OP_PUTSTATIC: nestPut,
+	OP_INVOKEVIRTUAL: nestInvoke, OP_INVOKESPECIAL: nestInvoke, OP_INVOKESTATIC: nestInvoke, OP_INVOKEINTERFACE: nestInvoke,
+}
+
+// 方法句柄的 reference_kind 对应的访问方式，下标即 kind；newInvokeSpecial 不经过访问方法
+var nestAccessKinds = []int{-1, nestGet, nestGet, nestPut, nestPut,
+	nestInvoke, nestInvoke, nestInvoke, -1, nestInvoke}
+
+// p 用于找出巢内互相访问的私有成员，为 nil 时不生成访问方法
+func NewRetargeter(target uint16, p *ClassPath) *Retargeter {
+	r := &Retargeter{
+		Target:           target,
+		accessors:        make(map[string]*nestAccessor),
+		ownerAccessors:   make(map[string][]*nestAccessor),
+		nestConstructors: make(map[string]bool),
+	}
+	if p == nil {
+		return r
+	}
+
+	for _, name := range p.ClassNames() {
+		cf := p.Class(name)
+		host := nestHost(cf)
+		// 引用同一巢中其他类私有成员的常量池项 -> 成员所在的类
+		refs := make(map[uint16]*ClassFile)
+		for i, info := range cf.ConstantPool {
+			if info == nil || info.Tag < 9 || info.Tag > 11 {
+				continue
+			}
+			class, member, desc := cf.MemberRefAt(uint16(i))
+			owner := p.Class(class)
+			if class == name || owner == nil || nestHost(owner) != host {
+				continue
+			}
+			if info.Tag == 9 {
+				if f := owner.FindField(member, desc); f != nil && f.AccessFlags&FIELD_ACC_PRIVATE != 0 {
+					refs[uint16(i)] = owner
+				}
+			} else if m := owner.FindMethod(member, desc); m != nil && m.AccessFlags&METHOD_ACC_PRIVATE != 0 {
+				if member == "<init>" {
+					r.nestConstructors[MethodKey(class, member, desc)] = true
+				} else {
+					refs[uint16(i)] = owner
+				}
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		// 按实际的访问方式生成访问方法，只读的 final 字段不会有写访问方法
+		for _, info := range cf.ConstantPool {
+			if info == nil || info.Tag != 15 {
+				continue
+			}
+			mh := (*ConstantMethodHandleInfo)(info)
+			if owner := refs[mh.ReferenceIndex()]; owner != nil && int(mh.ReferenceKind()) < len(nestAccessKinds) && nestAccessKinds[mh.ReferenceKind()] >= 0 {
+				_, member, desc := cf.MemberRefAt(mh.ReferenceIndex())
+				r.addAccessor(owner, nestAccessKinds[mh.ReferenceKind()], member, desc)
+			}
+		}
+		for _, m := range cf.Methods {
+			code := m.Code()
+			if code == nil {
+				continue
+			}
+			instructions, err := code.Instructions()
+			if err != nil {
+				continue
+			}
+			for _, ins := range instructions {
+				access, ok := nestAccessOpcodes[ins.Opcode]
+				if owner := refs[ins.Index]; ok && owner != nil {
+					_, member, desc := cf.MemberRefAt(ins.Index)
+					r.addAccessor(owner, access, member, desc)
+				}
+			}
+		}
+	}
+	return r
+}
+
+func (r *Retargeter) addAccessor(owner *ClassFile, access int, member, desc string) {
+	class := owner.ThisClassString()
+	key := nestAccessKey(access, class, member, desc)
+	if r.accessors[key] != nil {
+		return
+	}
+
+	acc := &nestAccessor{
+		owner:      class,
+		iface:      owner.IsInterface(),
+		access:     access,
+		member:     member,
+		memberDesc: desc,
+	}
+	// 访问方式与成员种类不符的指令无法通过验证，不生成访问方法
+	if access == nestInvoke {
+		m := owner.FindMethod(member, desc)
+		if m == nil {
+			return
+		}
+		acc.static = m.AccessFlags&METHOD_ACC_STATIC != 0
+	} else {
+		f := owner.FindField(member, desc)
+		if f == nil {
+			return
+		}
+		acc.static = f.AccessFlags&FIELD_ACC_STATIC != 0
+	}
+	self := ""
+	if !acc.static {
+		self = "L" + class + ";"
+	}
+	switch access {
+	case nestGet:
+		acc.desc = "(" + self + ")" + desc
+	case nestPut:
+		acc.desc = "(" + self + desc + ")V"
+	default:
+		acc.desc = "(" + self + desc[1:]
+	}
+
+	// 名称避开类中已有的方法与已分配的访问方法
+	accessors := r.ownerAccessors[class]
+	for i := len(accessors); ; i++ {
+		acc.name = fmt.Sprintf("access$%03d", i)
+		used := false
+		for _, m := range owner.Methods {
+			used = used || m.NameString() == acc.name
+		}
+		for _, a := range accessors {
+			used = used || a.name == acc.name
+		}
+		if !used {
+			break
+		}
+	}
+	r.accessors[key] = acc
+	r.ownerAccessors[class] = append(accessors, acc)
+}
+
+// 巢的宿主类：NestHost 属性指向的类，没有时为自身
+func nestHost(cf *ClassFile) string {
+	if attr := cf.Attribute("NestHost"); attr != nil && len(attr.Info) == 2 {
+		return cf.ClassNameAt(binary.BigEndian.Uint16(attr.Info))
+	}
+	return cf.ThisClassString()
+}
+
+func removeAttributes(attrs []*AttributeInfo, names ...string) ([]*AttributeInfo, bool) {
+	rs := attrs[:0]
+	removed := false
+	for _, attr := range attrs {
+		drop := false
+		for _, name := range names {
+			if attr.NameString() == name {
+				drop = true
+			}
+		}
+		if drop {
+			removed = true
+		} else {
+			rs = append(rs, attr)
+		}
+	}
+	return rs, removed
+}
+
+type retargetContext struct {
+	r      *Retargeter
+	c      *ClassFile
+	a      *constantPoolAppender
+	name   string
+	issues []*RetargetIssue
+
+	// 字符串拼接辅助方法：调用点描述符与拼接方式 -> 方法名
+	concats     map[string]string
+	methodNames map[string]bool
+	helpers     []*MethodInfo
+}
+
+func (x *retargetContext) issue(method string, offset int, fixed bool, format string, args ...interface{}) {
+	x.issues = append(x.issues, &RetargetIssue{
+		Class:   x.name,
+		Method:  method,
+		Offset:  offset,
+		Message: fmt.Sprintf(format, args...),
+		Fixed:   fixed,
+	})
+}
+
+// 降级一个类，返回新类与发现的问题（包括已自动改写的）。只有所有问题都已改写时，新类才能在目标版本上运行
+func (r *Retargeter) Retarget(cf *ClassFile) (*ClassFile, []*RetargetIssue, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.MajorVersion <= r.Target && c.MinorVersion != 0xFFFF {
+		return c, nil, nil
+	}
+
+	x := &retargetContext{
+		r:           r,
+		c:           c,
+		a:           newConstantPoolAppender(c),
+		name:        c.ThisClassString(),
+		concats:     make(map[string]string),
+		methodNames: make(map[string]bool),
+	}
+	for _, m := range c.Methods {
+		x.methodNames[m.NameString()] = true
+	}
+
+	if c.MinorVersion == 0xFFFF {
+		x.issue("", -1, false, "compiled with preview features of Java %d", JavaRelease(c.MajorVersion))
+	}
+	x.classAttributes()
+	for _, m := range c.Methods {
+		if err := x.method(m); err != nil {
+			return nil, nil, err
+		}
+	}
+	c.Methods = append(c.Methods, x.helpers...)
+
+	if x.a.overflow() {
+		return nil, nil, fmt.Errorf("%s: constant pool overflow", x.name)
+	}
+	c.MajorVersion, c.MinorVersion = r.Target, 0
+
+	rs, err := NewClassFile(bytes.NewReader(c.Bytes()))
+	if err != nil {
+		return nil, nil, err
+	}
+	if compacted, err := rs.CompactConstantPool(); err == nil {
+		rs = compacted
+	}
+	return rs, x.issues, nil
+}
+
+func (x *retargetContext) classAttributes() {
+	c, target := x.c, x.r.Target
+
+	if x.name == "module-info" && target < 53 {
+		x.issue("", -1, false, "module descriptor requires Java 9")
+	}
+
+	if target < 60 {
+		var removed bool
+		if c.Attributes, removed = removeAttributes(c.Attributes, "Record"); removed {
+			x.issue("", -1, true, "Record attribute removed")
+		}
+		if c.SuperClassString() == "java/lang/Record" {
+			x.issue("", -1, false, "record class extends java/lang/Record, which requires Java 16")
+		}
+	}
+
+	if target < 61 {
+		var removed bool
+		if c.Attributes, removed = removeAttributes(c.Attributes, "PermittedSubclasses"); removed {
+			x.issue("", -1, true, "PermittedSubclasses attribute removed, the class is no longer sealed")
+		}
+	}
+
+	// 没有巢时，巢内其他类对私有成员的访问改为调用访问方法，见 nestAccessor
+	if target < 55 {
+		var removed bool
+		if c.Attributes, removed = removeAttributes(c.Attributes, "NestHost", "NestMembers"); removed {
+			x.issue("", -1, true, "NestHost/NestMembers attributes removed")
+		}
+		for _, m := range c.Methods {
+			if m.AccessFlags&METHOD_ACC_PRIVATE != 0 && x.r.nestConstructors[MethodKey(x.name, m.NameString(), m.DescriptorString())] {
+				// 构造方法不会被继承或覆盖，放宽为包内可见（同一巢的类一定在同一个包中）不改变调用的目标
+				m.AccessFlags &^= METHOD_ACC_PRIVATE
+				x.issue(m.NameString()+m.DescriptorString(), -1, true, "private constructor called by nestmates made package-private")
+			}
+		}
+		for _, acc := range x.r.ownerAccessors[x.name] {
+			if err := x.accessor(acc); err != nil {
+				x.issue("", -1, false, "accessor for private %s used by nestmates: %s", acc.memberString(), err)
+			} else {
+				x.issue("", -1, true, "accessor %s added for private %s used by nestmates", acc.name, acc.memberString())
+			}
+		}
+		x.nestMethodHandles()
+	}
+
+	if target < 52 && c.IsInterface() {
+		for _, m := range c.Methods {
+			if m.AccessFlags&METHOD_ACC_ABSTRACT == 0 && m.NameString() != "<clinit>" {
+				x.issue(m.NameString()+m.DescriptorString(), -1, false, "non-abstract interface method requires Java 8")
+			}
+		}
+	}
+}
+
+// 指令操作数引用的常量池项，索引越界或类型不符时返回 nil
+func (x *retargetContext) constant(index uint16, tags ...uint8) *ConstantPoolInfo {
+	if int(index) < len(x.c.ConstantPool) {
+		if info := x.c.ConstantPool[index]; info != nil {
+			for _, tag := range tags {
+				if info.Tag == tag {
+					return info
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 本类中声明的私有方法，index 是方法引用
+func (x *retargetContext) isOwnPrivateMethod(index uint16) bool {
+	class, name, desc := x.c.MemberRefAt(index)
+	if class != x.name {
+		return false
+	}
+	m := x.c.FindMethod(name, desc)
+	return m != nil && m.AccessFlags&METHOD_ACC_PRIVATE != 0
+}
+
+// 指令访问的同一巢中其他类的私有成员的访问方法，不是这样的访问时返回 nil
+func (x *retargetContext) nestAccess(ins *Instruction) *nestAccessor {
+	access, ok := nestAccessOpcodes[ins.Opcode]
+	if !ok {
+		return nil
+	}
+	class, name, desc := x.c.MemberRefAt(ins.Index)
+	if class == x.name {
+		return nil
+	}
+	return x.r.accessors[nestAccessKey(access, class, name, desc)]
+}
+
+// 指向同一巢中其他类私有成员的方法句柄改为 invokeStatic 访问方法，方法句柄的类型不变
+func (x *retargetContext) nestMethodHandles() {
+	c := x.c
+	for _, info := range c.ConstantPool {
+		if info == nil || info.Tag != 15 {
+			continue
+		}
+		mh := (*ConstantMethodHandleInfo)(info)
+		kind := mh.ReferenceKind()
+		if int(kind) >= len(nestAccessKinds) || nestAccessKinds[kind] < 0 {
+			continue
+		}
+		class, name, desc := c.MemberRefAt(mh.ReferenceIndex())
+		acc := x.r.accessors[nestAccessKey(nestAccessKinds[kind], class, name, desc)]
+		if class == x.name || acc == nil {
+			continue
+		}
+		info.Info = []byte{6, 0, 0}
+		binary.BigEndian.PutUint16(info.Info[1:], x.a.memberRef(acc.tag(), acc.owner, acc.name, acc.desc))
+		x.issue("", -1, true, "method handle %s of private %s.%s replaced with accessor %s", methodHandleKinds[kind], acc.owner, acc.memberString(), acc.name)
+	}
+}
+
+// 生成访问方法：依次加载参数，执行原来的访问，返回结果
+func (x *retargetContext) accessor(acc *nestAccessor) error {
+	sig, err := ParseMethodSignature(acc.desc)
+	if err != nil {
+		return err
+	}
+	code := &classWriter{}
+	local := 0
+	for _, t := range sig.Parameters {
+		code.u1(typedOpcode(OP_ILOAD, t))
+		code.u1(uint8(local))
+		local += typeSlots(t)
+	}
+
+	var op, tag byte = OP_INVOKESPECIAL, acc.tag()
+	switch {
+	case acc.access == nestGet && acc.static:
+		op, tag = OP_GETSTATIC, 9
+	case acc.access == nestGet:
+		op, tag = OP_GETFIELD, 9
+	case acc.access == nestPut && acc.static:
+		op, tag = OP_PUTSTATIC, 9
+	case acc.access == nestPut:
+		op, tag = OP_PUTFIELD, 9
+	case acc.static:
+		op = OP_INVOKESTATIC
+	}
+	code.u1(op)
+	code.u2(x.a.memberRef(tag, acc.owner, acc.member, acc.memberDesc))
+	if sig.Result.Kind == 'V' {
+		code.u1(OP_RETURN)
+	} else {
+		code.u1(typedOpcode(OP_IRETURN, sig.Result))
+	}
+
+	stack := local
+	if n := typeSlots(sig.Result); n > stack {
+		stack = n
+	}
+	flags := METHOD_ACC_STATIC | METHOD_ACC_SYNTHETIC
+	if acc.iface {
+		// 接口方法只能是 public 或 private；接口的静态方法不被实现类继承
+		flags |= METHOD_ACC_PUBLIC
+	}
+	x.addMethod(flags, acc.name, acc.desc, code.Bytes(), stack, local)
+	return nil
+}
+
+func (x *retargetContext) method(m *MethodInfo) error {
+	attr := m.Attribute("Code")
+	if attr == nil {
+		return nil
+	}
+	if len(attr.Info) < 8 {
+		return ERR_MALFORMED_ATTRIBUTE
+	}
+	length := int(binary.BigEndian.Uint32(attr.Info[4:]))
+	if 8+length > len(attr.Info) {
+		return ERR_MALFORMED_ATTRIBUTE
+	}
+	code := attr.Info[8 : 8+length]
+	instructions, err := DecodeInstructions(code)
+	if err != nil {
+		return err
+	}
+
+	c, target := x.c, x.r.Target
+	method := m.NameString() + m.DescriptorString()
+	for _, ins := range instructions {
+		var tags []uint8
+		switch ins.Opcode {
+		case OP_INVOKEDYNAMIC:
+			tags = []uint8{18}
+		case OP_INVOKEINTERFACE:
+			tags = []uint8{11}
+		case OP_INVOKEVIRTUAL:
+			tags = []uint8{10}
+		case OP_INVOKESPECIAL, OP_INVOKESTATIC:
+			tags = []uint8{10, 11}
+		case OP_GETFIELD, OP_GETSTATIC, OP_PUTFIELD, OP_PUTSTATIC:
+			tags = []uint8{9}
+		case OP_LDC, OP_LDC_W:
+			tags = []uint8{3, 4, 7, 8, 15, 16, 17}
+		case OP_LDC2_W:
+			tags = []uint8{5, 6, 17}
+		}
+		if tags != nil && x.constant(ins.Index, tags...) == nil {
+			return fmt.Errorf("%s: %s @%d: illegal constant pool index #%d", ERR_MALFORMED_ATTRIBUTE, method, ins.Offset, ins.Index)
+		}
+
+		if acc := x.nestAccess(ins); acc != nil && target < 55 {
+			// 指令长度不变：invokeinterface 多出的两个字节用 nop 补齐
+			code[ins.Offset] = OP_INVOKESTATIC
+			binary.BigEndian.PutUint16(code[ins.Offset+1:], x.a.memberRef(acc.tag(), acc.owner, acc.name, acc.desc))
+			if ins.Opcode == OP_INVOKEINTERFACE {
+				code[ins.Offset+3], code[ins.Offset+4] = OP_NOP, OP_NOP
+			}
+			x.issue(method, ins.Offset, true, "%s of private %s.%s replaced with accessor %s", OpcodeName(ins.Opcode), acc.owner, acc.memberString(), acc.name)
+			continue
+		}
+
+		switch ins.Opcode {
+		case OP_INVOKEDYNAMIC:
+			if target < 51 {
+				x.issue(method, ins.Offset, false, "invokedynamic requires Java 7")
+				continue
+			}
+			indy := (*ConstantInvokeDynamicInfo)(c.ConstantPool[ins.Index])
+			bms := c.BootstrapMethods()
+			if int(indy.BootstrapMethodAttrIndex()) >= len(bms) {
+				return ERR_MALFORMED_ATTRIBUTE
+			}
+			bm := bms[indy.BootstrapMethodAttrIndex()]
+			owner, bootstrap, _ := c.MemberRefAt(bm.MethodHandle().ReferenceIndex())
+			if owner == "java/lang/invoke/StringConcatFactory" && target < 53 {
+				if err := x.stringConcat(code, ins, bm, bootstrap); err != nil {
+					x.issue(method, ins.Offset, false, "string concatenation: %s", err)
+				} else {
+					x.issue(method, ins.Offset, true, "invokedynamic string concatenation replaced with StringBuilder")
+				}
+				continue
+			}
+			if min, ok := bootstrapVersions[owner]; ok && target < min {
+				x.issue(method, ins.Offset, false, "bootstrap method %s.%s requires Java %d", owner, bootstrap, JavaRelease(min))
+			}
+
+		case OP_INVOKEINTERFACE:
+			// 接口调用自身的私有方法：Java 11 之前只能用 invokespecial
+			if target < 55 && c.IsInterface() && x.isOwnPrivateMethod(ins.Index) {
+				code[ins.Offset] = OP_INVOKESPECIAL
+				code[ins.Offset+3], code[ins.Offset+4] = OP_NOP, OP_NOP
+				x.issue(method, ins.Offset, true, "invokeinterface of private method replaced with invokespecial")
+			}
+
+		case OP_INVOKEVIRTUAL:
+			if target < 55 && x.isOwnPrivateMethod(ins.Index) {
+				code[ins.Offset] = OP_INVOKESPECIAL
+				x.issue(method, ins.Offset, true, "invokevirtual of private method replaced with invokespecial")
+			}
+
+		case OP_LDC, OP_LDC_W:
+			switch tag := c.ConstantPool[ins.Index].Tag; {
+			case (tag == 15 || tag == 16) && target < 51:
+				x.issue(method, ins.Offset, false, "ldc of MethodHandle/MethodType requires Java 7")
+			case tag == 17 && target < 55:
+				x.issue(method, ins.Offset, false, "ldc of dynamic constant requires Java 11")
+			case tag == 7 && target < 49:
+				x.issue(method, ins.Offset, false, "ldc of class literal requires Java 5")
+			}
+
+		case OP_LDC2_W:
+			if c.ConstantPool[ins.Index].Tag == 17 && target < 55 {
+				x.issue(method, ins.Offset, false, "ldc of dynamic constant requires Java 11")
+			}
+		}
+	}
+	return nil
+}
+
+// StringBuilder.append 的参数描述符
+func appendDescriptor(t *TypeSignature) string {
+	switch t.Kind {
+	case 'B', 'S', 'I':
+		return "(I)Ljava/lang/StringBuilder;"
+	case 'Z', 'C', 'J', 'F', 'D':
+		return "(" + string(t.Kind) + ")Ljava/lang/StringBuilder;"
+	case 'L':
+		if t.Name == "java/lang/String" {
+			return "(Ljava/lang/String;)Ljava/lang/StringBuilder;"
+		}
+	}
+	return "(Ljava/lang/Object;)Ljava/lang/StringBuilder;"
+}
+
+// 把 StringConcatFactory 调用点替换为调用一个用 StringBuilder 拼接的私有静态方法。
+// invokedynamic 与 invokestatic 的栈效果相同，后者短两个字节，用 nop 补齐，因此其余指令的偏移与栈图都不变
+func (x *retargetContext) stringConcat(code []byte, ins *Instruction, bm *BootstrapMethod, bootstrap string) error {
+	c, a := x.c, x.a
+	indy := (*ConstantInvokeDynamicInfo)(c.ConstantPool[ins.Index])
+	_, desc := c.NameAndTypeAt(indy.NameAndTypeIndex())
+	sig, err := ParseMethodSignature(desc)
+	if err != nil {
+		return err
+	}
+
+	var recipe string
+	var constants []uint16
+	switch bootstrap {
+	case "makeConcatWithConstants":
+		if len(bm.BootstrapArguments) < 1 || c.ConstantPool[bm.BootstrapArguments[0]].Tag != 8 {
+			return fmt.Errorf("missing recipe")
+		}
+		recipe = c.Utf8At((*ConstantStringInfo)(c.ConstantPool[bm.BootstrapArguments[0]]).StringIndex())
+		constants = bm.BootstrapArguments[1:]
+	case "makeConcat":
+		recipe = strings.Repeat("\u0001", len(sig.Parameters))
+	default:
+		return fmt.Errorf("unknown bootstrap method %s", bootstrap)
+	}
+
+	key := desc + "\x00" + recipe + fmt.Sprint(constants)
+	helper, ok := x.concats[key]
+	if !ok {
+		for i := len(x.concats); ; i++ {
+			helper = "concat$" + strconv.Itoa(i)
+			if !x.methodNames[helper] {
+				break
+			}
+		}
+		x.methodNames[helper] = true
+		x.concats[key] = helper
+		body, maxLocals, err := x.concatBody(sig, recipe, constants)
+		if err != nil {
+			return err
+		}
+
+		x.addMethod(METHOD_ACC_PRIVATE|METHOD_ACC_STATIC|METHOD_ACC_SYNTHETIC, helper, desc, body, 3, maxLocals)
+	}
+
+	var tag byte = 10
+	if c.IsInterface() {
+		tag = 11
+	}
+	code[ins.Offset] = OP_INVOKESTATIC
+	binary.BigEndian.PutUint16(code[ins.Offset+1:], a.memberRef(tag, x.name, helper, desc))
+	code[ins.Offset+3], code[ins.Offset+4] = OP_NOP, OP_NOP
+	return nil
+}
+
+// 添加一个合成方法，其代码没有分支与异常表，不需要栈图
+func (x *retargetContext) addMethod(flags MethodAccessFlags, name, desc string, body []byte, maxStack, maxLocals int) {
+	a := x.a
+	info := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint16(info, uint16(maxStack))
+	binary.BigEndian.PutUint16(info[2:], uint16(maxLocals))
+	binary.BigEndian.PutUint32(info[4:], uint32(len(body)))
+	info = append(info, body...)
+	info = append(info, 0, 0, 0, 0)
+	x.helpers = append(x.helpers, &MethodInfo{
+		AccessFlags:     flags,
+		NameIndex:       a.utf8(name),
+		DescriptorIndex: a.utf8(desc),
+		Attributes:      []*AttributeInfo{{NameIndex: a.utf8("Code"), Info: info}},
+	})
+}
+
+// 按类型选择 xload 或 xreturn 指令：base 为 int 版本，其后依次是 long、float、double 与引用版本
+func typedOpcode(base byte, t *TypeSignature) byte {
+	switch t.Kind {
+	case 'B', 'S', 'I', 'Z', 'C':
+		return base
+	case 'J':
+		return base + 1
+	case 'F':
+		return base + 2
+	case 'D':
+		return base + 3
+	}
+	return base + 4
+}
+
+// 值占用的局部变量或操作数栈槽数
+func typeSlots(t *TypeSignature) int {
+	switch t.Kind {
+	case 'V':
+		return 0
+	case 'J', 'D':
+		return 2
+	}
+	return 1
+}
+
+// 拼接方法的字节码：\u0001 依次取参数，\u0002 依次取引导方法的常量参数，其余为字面文本
+func (x *retargetContext) concatBody(sig *MethodSignature, recipe string, constants []uint16) ([]byte, int, error) {
+	c, a := x.c, x.a
+	code := &classWriter{}
+	u2 := func(op byte, index uint16) {
+		code.u1(op)
+		code.u2(index)
+	}
+	const builder = "java/lang/StringBuilder"
+	appendString := func(s string) {
+		u2(OP_LDC_W, a.str(s))
+		u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", "(Ljava/lang/String;)Ljava/lang/StringBuilder;"))
+	}
+
+	u2(OP_NEW, a.class(builder))
+	code.u1(OP_DUP)
+	u2(OP_INVOKESPECIAL, a.memberRef(10, builder, "<init>", "()V"))
+
+	literal := &strings.Builder{}
+	local, arg, constant := 0, 0, 0
+	for _, ch := range recipe {
+		if ch != '\u0001' && ch != '\u0002' {
+			literal.WriteRune(ch)
+			continue
+		}
+		if literal.Len() > 0 {
+			appendString(literal.String())
+			literal.Reset()
+		}
+
+		if ch == '\u0001' {
+			if arg >= len(sig.Parameters) {
+				return nil, 0, fmt.Errorf("recipe has more arguments than %d", len(sig.Parameters))
+			}
+			t := sig.Parameters[arg]
+			arg++
+			code.u1(typedOpcode(OP_ILOAD, t))
+			code.u1(uint8(local))
+			local += typeSlots(t)
+			u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", appendDescriptor(t)))
+			continue
+		}
+
+		if constant >= len(constants) {
+			return nil, 0, fmt.Errorf("recipe has more constants than %d", len(constants))
+		}
+		index := constants[constant]
+		constant++
+		switch c.ConstantPool[index].Tag {
+		case 3:
+			u2(OP_LDC_W, index)
+			u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", "(I)Ljava/lang/StringBuilder;"))
+		case 4:
+			u2(OP_LDC_W, index)
+			u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", "(F)Ljava/lang/StringBuilder;"))
+		case 5:
+			u2(OP_LDC2_W, index)
+			u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", "(J)Ljava/lang/StringBuilder;"))
+		case 6:
+			u2(OP_LDC2_W, index)
+			u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", "(D)Ljava/lang/StringBuilder;"))
+		case 8:
+			appendString(c.Utf8At((*ConstantStringInfo)(c.ConstantPool[index]).StringIndex()))
+		default:
+			u2(OP_LDC_W, index)
+			u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "append", "(Ljava/lang/Object;)Ljava/lang/StringBuilder;"))
+		}
+	}
+	if literal.Len() > 0 {
+		appendString(literal.String())
+	}
+	if local > 255 {
+		return nil, 0, fmt.Errorf("too many arguments")
+	}
+
+	u2(OP_INVOKEVIRTUAL, a.memberRef(10, builder, "toString", "()Ljava/lang/String;"))
+	code.u1(OP_ARETURN)
+	return code.Bytes(), local, nil
+}
+
+// 降级 jar 中的基础层类；META-INF/versions 下的类面向更高版本的运行时，原样保留。
+// 签名文件被去掉，因为类的内容已经改变
+func (r *Retargeter) RetargetJar(path string, jar *Jar) (*Jar, []*RetargetIssue, error) {
+	out := NewJar(path)
+	var issues []*RetargetIssue
+	for _, entry := range jar.Entries {
+		if isSignatureFile(entry.Name) {
+			continue
+		}
+		data := entry.Data
+		if entry.Class != nil {
+			c, found, err := r.Retarget(entry.Class)
+			if err != nil {
+				return nil, nil, &JarError{Path: jar.Path, Entry: entry.Name, Err: err}
+			}
+			issues = append(issues, found...)
+			data = c.Bytes()
+		}
+		if err := out.AddEntry(entry.Name, data, entry.Modified); err != nil {
+			return nil, nil, err
+		}
+	}
+	return out, issues, nil
+}
@@ -0,0 +1,323 @@
+package jclass
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 还原后的一个栈帧，类名为 Java 形式
+type RetracedFrame struct {
+	Class  string
+	Method string
+	// 原方法描述符，无法确定时为空
+	Descriptor string
+	SourceFile string
+	// 0 表示行号未知
+	Line int
+}
+
+func (f *RetracedFrame) String() string {
+	location := f.SourceFile
+	if location == "" {
+		location = "Unknown Source"
+	} else if f.Line > 0 {
+		location += ":" + strconv.Itoa(f.Line)
+	}
+	return fmt.Sprintf("%s.%s(%s)", f.Class, f.Method, location)
+}
+
+// Java 源码形式的方法签名，如 void run(int, java.lang.String)；描述符未知时返回空串
+func (f *RetracedFrame) Signature() string {
+	sig, err := ParseMethodSignature(f.Descriptor)
+	if err != nil {
+		return ""
+	}
+	params := make([]string, len(sig.Parameters))
+	for i, p := range sig.Parameters {
+		params[i] = p.JavaName()
+	}
+	return fmt.Sprintf("%s %s(%s)", sig.Result.JavaName(), f.Method, strings.Join(params, ", "))
+}
+
+// 按 ProGuard/R8 的映射还原混淆后的堆栈。给出混淆后的 jar 时，
+// 用其中的 LineNumberTable 区分同名的重载方法，用 SourceFile 还原源文件名
+type Retracer struct {
+	Mapping *Mapping
+	// 混淆后的类，可为 nil
+	ClassPath *ClassPath
+
+	// 混淆后的类名 -> 映射
+	obfuscated map[string]*ClassMapping
+}
+
+func NewRetracer(mapping *Mapping, classPath *ClassPath) *Retracer {
+	r := &Retracer{
+		Mapping:    mapping,
+		ClassPath:  classPath,
+		obfuscated: make(map[string]*ClassMapping),
+	}
+	for _, cm := range mapping.Classes {
+		r.obfuscated[cm.NewName] = cm
+	}
+	return r
+}
+
+// 还原混淆后的类名，Java 形式；不在映射中的类原样返回
+func (r *Retracer) ClassName(name string) string {
+	if cm := r.obfuscated[internalName(name)]; cm != nil {
+		return strings.Replace(cm.Name, "/", ".", -1)
+	}
+	return name
+}
+
+// 内联链：同一混淆行号范围内连续的映射项，内联进来的方法在前，最外层的方法在最后
+func inlineChains(methods []*MemberMapping, name string) [][]*MemberMapping {
+	var rs [][]*MemberMapping
+	var last *MemberMapping
+	for _, m := range methods {
+		if m.NewName != name {
+			last = nil
+			continue
+		}
+		if last != nil && m.StartLine != 0 && m.StartLine == last.StartLine && m.EndLine == last.EndLine {
+			rs[len(rs)-1] = append(rs[len(rs)-1], m)
+		} else {
+			rs = append(rs, []*MemberMapping{m})
+		}
+		last = m
+	}
+	return rs
+}
+
+// 混淆后的类中名为 name 且行号表包含 line 的方法描述符；line 为 0 时返回所有同名方法
+func (r *Retracer) obfuscatedDescriptors(class, name string, line int) map[string]bool {
+	if r.ClassPath == nil {
+		return nil
+	}
+	cf := r.ClassPath.Class(class)
+	if cf == nil {
+		return nil
+	}
+	rs := make(map[string]bool)
+	for _, m := range cf.Methods {
+		if m.NameString() != name {
+			continue
+		}
+		if line == 0 {
+			rs[m.DescriptorString()] = true
+			continue
+		}
+		if code := m.Code(); code != nil {
+			for _, entry := range code.LineNumberTable() {
+				if int(entry.LineNumber) == line {
+					rs[m.DescriptorString()] = true
+					break
+				}
+			}
+		}
+	}
+	return rs
+}
+
+// 源文件名：优先取映射中的元数据，其次是混淆后类中未被抹去的 SourceFile 属性，
+// 最后按最外层类名推断为 .java 文件
+func (r *Retracer) sourceFile(origClass string, cm *ClassMapping) string {
+	if origClass == cm.Name {
+		if cm.SourceFile != "" {
+			return cm.SourceFile
+		}
+		if r.ClassPath != nil {
+			if cf := r.ClassPath.Class(cm.NewName); cf != nil {
+				if s := cf.SourceFileString(); s != "" && s != "SourceFile" {
+					return s
+				}
+			}
+		}
+	} else if other := r.Mapping.Classes[origClass]; other != nil && other.SourceFile != "" {
+		return other.SourceFile
+	}
+	outer := origClass[strings.LastIndexByte(origClass, '/')+1:]
+	if i := strings.IndexByte(outer, '$'); i > 0 {
+		outer = outer[:i]
+	}
+	return outer + ".java"
+}
+
+// 还原一个栈帧。每一组为一种可能的解释，组内内联进来的方法在前、实际调用的方法在后；
+// 无法唯一确定时返回多组。不在映射中的类原样返回
+func (r *Retracer) Frame(class, method, sourceFile string, line int) [][]*RetracedFrame {
+	cm := r.obfuscated[internalName(class)]
+	if cm == nil {
+		return [][]*RetracedFrame{{{Class: class, Method: method, SourceFile: sourceFile, Line: line}}}
+	}
+
+	chains := inlineChains(cm.Methods, method)
+	if line > 0 {
+		var matched, unranged [][]*MemberMapping
+		for _, chain := range chains {
+			switch head := chain[0]; {
+			case head.StartLine == 0:
+				unranged = append(unranged, chain)
+			case head.StartLine <= line && line <= head.EndLine:
+				matched = append(matched, chain)
+			}
+		}
+		if len(matched) > 0 {
+			chains = matched
+		} else if len(unranged) > 0 {
+			chains = unranged
+		}
+	} else {
+		// 行号未知时无法确定内联位置，只保留最外层方法
+		var outer [][]*MemberMapping
+		seen := make(map[string]bool)
+		for _, chain := range chains {
+			m := chain[len(chain)-1]
+			if key := m.Name + m.Descriptor; !seen[key] {
+				seen[key] = true
+				outer = append(outer, []*MemberMapping{m})
+			}
+		}
+		chains = outer
+	}
+
+	// 按混淆后类中的行号表与方法描述符排除不可能的解释
+	if len(chains) > 1 {
+		if descs := r.obfuscatedDescriptors(cm.NewName, method, line); len(descs) > 0 {
+			toObfuscated := func(name string) string {
+				if other := r.Mapping.Classes[name]; other != nil {
+					return other.NewName
+				}
+				return name
+			}
+			var filtered [][]*MemberMapping
+			for _, chain := range chains {
+				if descs[mapDescriptorClasses(chain[len(chain)-1].Descriptor, toObfuscated)] {
+					filtered = append(filtered, chain)
+				}
+			}
+			if len(filtered) > 0 {
+				chains = filtered
+			}
+		}
+	}
+
+	if len(chains) == 0 {
+		// 映射中未列出的覆盖方法跟随超类型中的声明改名
+		name := method
+		if r.ClassPath != nil {
+			for _, super := range r.ClassPath.Supertypes(cm.NewName) {
+				if sm := r.obfuscated[super]; sm != nil {
+					if m := inlineChains(sm.Methods, method); len(m) > 0 {
+						name = m[0][len(m[0])-1].Name
+						break
+					}
+				}
+			}
+		}
+		return [][]*RetracedFrame{{{
+			Class:      strings.Replace(cm.Name, "/", ".", -1),
+			Method:     name,
+			SourceFile: r.sourceFile(cm.Name, cm),
+			Line:       line,
+		}}}
+	}
+
+	rs := make([][]*RetracedFrame, len(chains))
+	for i, chain := range chains {
+		for _, m := range chain {
+			origClass := cm.Name
+			if m.OriginalClass != "" {
+				origClass = m.OriginalClass
+			}
+			origLine := line
+			switch {
+			case line == 0 || m.StartLine == 0:
+			case m.OriginalEndLine != m.OriginalStartLine:
+				origLine = m.OriginalStartLine + line - m.StartLine
+			default:
+				origLine = m.OriginalStartLine
+			}
+			rs[i] = append(rs[i], &RetracedFrame{
+				Class:      strings.Replace(origClass, "/", ".", -1),
+				Method:     m.Name,
+				Descriptor: m.Descriptor,
+				SourceFile: r.sourceFile(origClass, cm),
+				Line:       origLine,
+			})
+		}
+	}
+	return rs
+}
+
+var (
+	// \tat [module/]class.method(location)
+	stackFramePattern = regexp.MustCompile(`^(\s*)at ((?:[^\s/()]*/)*)([^\s()/]+)\.([^\s.()]+)\(([^()]*)\)(.*)$`)
+	// 异常行开头的异常类名，可能没有包名
+	exceptionPattern = regexp.MustCompile(`^(\s*(?:Caused by: |Suppressed: |Exception in thread "[^"]*" )?)([\p{L}_$][\p{L}\p{N}_$.]*)(:.*)?$`)
+	// 消息中带包名的类名
+	dottedNamePattern = regexp.MustCompile(`[\p{L}_$][\p{L}\p{N}_$]*(?:\.[\p{L}_$][\p{L}\p{N}_$]*)+`)
+)
+
+// 还原一行非栈帧文本中的类名
+func (r *Retracer) retraceText(line string) string {
+	head, rest := "", line
+	if m := exceptionPattern.FindStringSubmatch(line); m != nil {
+		head = m[1] + r.ClassName(m[2])
+		rest = m[3]
+	}
+	return head + dottedNamePattern.ReplaceAllStringFunc(rest, r.ClassName)
+}
+
+// 逐行还原堆栈文本。存在多种可能时，其余解释以 <OR> 标出
+func (r *Retracer) Retrace(in io.Reader, out io.Writer) error {
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		m := stackFramePattern.FindStringSubmatch(line)
+		if m == nil {
+			fmt.Fprintln(w, r.retraceText(line))
+			continue
+		}
+
+		indent, module, class, method, location, tail := m[1], m[2], m[3], m[4], m[5], m[6]
+		sourceFile, lineNumber := location, 0
+		if i := strings.LastIndexByte(location, ':'); i >= 0 {
+			if n, err := strconv.Atoi(location[i+1:]); err == nil {
+				sourceFile, lineNumber = location[:i], n
+			}
+		}
+		if sourceFile == "Unknown Source" || sourceFile == "Native Method" {
+			sourceFile = ""
+		}
+
+		alternatives := r.Frame(class, method, sourceFile, lineNumber)
+		for i, frames := range alternatives {
+			for j, frame := range frames {
+				prefix := indent + "at " + module
+				if i > 0 && j == 0 {
+					prefix = indent + "<OR> at " + module
+				}
+				text := frame.String()
+				if location == "Native Method" {
+					text = frame.Class + "." + frame.Method + "(Native Method)"
+				}
+				// 有歧义时附上方法签名以便区分重载
+				if len(alternatives) > 1 && frame.Descriptor != "" {
+					text += " [" + frame.Signature() + "]"
+				}
+				fmt.Fprintf(w, "%s%s%s\n", prefix, text, tail)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,364 @@
+package jclass
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 架构规则文件，每行一条规则，# 开头为注释。包模式沿用 ArchUnit 的写法：
+// .. 匹配任意层级的包（含零层），* 匹配包名中的一段，如 com.acme.domain..、..service..
+//
+//	package com.acme.domain.. must not depend on com.acme.web.., javax.servlet..
+//	package com.acme.api.. may only depend on com.acme.api.., java..
+//	classes annotated @org.springframework.stereotype.Service must reside in ..service..
+//	classes named *Controller must reside in ..web..
+//	layer web = com.acme.web..
+//	layer service = com.acme.service..
+//	layer domain = com.acme.domain..
+//	layer web may not be accessed by any layer
+//	layer service may only be accessed by web
+//	layer domain may only access domain
+//
+// 选择器可以是 package P[, P...]、classes in P[, P...]、classes annotated @T、classes named G 或 classes。
+type RuleSet struct {
+	Rules []*Rule
+
+	layers     map[string][]*regexp.Regexp
+	layerOrder []string
+}
+
+const (
+	RULE_MUST_NOT_DEPEND_ON = iota
+	RULE_MAY_ONLY_DEPEND_ON
+	RULE_MUST_RESIDE_IN
+	RULE_MAY_ONLY_BE_ACCESSED_BY
+	RULE_MAY_ONLY_ACCESS
+)
+
+type Rule struct {
+	Line int
+	Text string
+	Kind int
+
+	selector func(cf *ClassFile) bool
+	patterns []*regexp.Regexp
+
+	// 分层规则
+	layer  string
+	layers map[string]bool
+}
+
+type RuleViolation struct {
+	Rule *Rule
+	// Java 形式的类名
+	Class  string
+	Target string
+	// 产生依赖的位置，如被引用的成员
+	Ref     string
+	Message string
+}
+
+func (v *RuleViolation) String() string {
+	return fmt.Sprintf("line %d: %q violated by %s", v.Rule.Line, v.Rule.Text, v.Message)
+}
+
+// 将 ArchUnit 风格的包模式转换为匹配 Java 形式包名的正则表达式
+func compilePackagePattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, fmt.Errorf("empty package pattern")
+	}
+	if pattern == ".." {
+		return regexp.Compile(`^.*$`)
+	}
+
+	quote := func(s string) string {
+		parts := strings.Split(s, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		return strings.Join(parts, `[^.]*`)
+	}
+
+	s := &strings.Builder{}
+	s.WriteString("^")
+	if strings.HasPrefix(pattern, "..") {
+		s.WriteString(`(?:.*\.)?`)
+		pattern = pattern[2:]
+	}
+	trailing := strings.HasSuffix(pattern, "..")
+	if trailing {
+		pattern = pattern[:len(pattern)-2]
+	}
+	for i, part := range strings.Split(pattern, "..") {
+		if i > 0 {
+			s.WriteString(`\.(?:.*\.)?`)
+		}
+		s.WriteString(quote(part))
+	}
+	if trailing {
+		s.WriteString(`(?:\..*)?`)
+	}
+	s.WriteString("$")
+	return regexp.Compile(s.String())
+}
+
+func compilePackagePatterns(list string) ([]*regexp.Regexp, error) {
+	var rs []*regexp.Regexp
+	for _, pattern := range strings.Split(list, ",") {
+		re, err := compilePackagePattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		rs = append(rs, re)
+	}
+	return rs, nil
+}
+
+// Java 形式的包名
+func javaPackageOf(class string) string {
+	return strings.Replace(PackageOf(class), "/", ".", -1)
+}
+
+func matchPackage(class string, patterns []*regexp.Regexp) bool {
+	pkg := javaPackageOf(class)
+	for _, re := range patterns {
+		if re.MatchString(pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSelector(s string) (func(cf *ClassFile) bool, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "classes":
+		return func(cf *ClassFile) bool { return true }, nil
+
+	case strings.HasPrefix(s, "package "), strings.HasPrefix(s, "classes in "):
+		list := strings.TrimPrefix(strings.TrimPrefix(s, "package "), "classes in ")
+		patterns, err := compilePackagePatterns(list)
+		if err != nil {
+			return nil, err
+		}
+		return func(cf *ClassFile) bool {
+			return matchPackage(cf.ThisClassString(), patterns)
+		}, nil
+
+	case strings.HasPrefix(s, "classes annotated @"):
+		want := strings.TrimSpace(strings.TrimPrefix(s, "classes annotated @"))
+		return func(cf *ClassFile) bool {
+			return hasAnnotation(cf.Annotations(), []string{want})
+		}, nil
+
+	case strings.HasPrefix(s, "classes named "):
+		glob := strings.TrimSpace(strings.TrimPrefix(s, "classes named "))
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, err
+		}
+		return func(cf *ClassFile) bool {
+			name := cf.ThisClassString()
+			ok, _ := path.Match(glob, name[strings.LastIndexByte(name, '/')+1:])
+			return ok
+		}, nil
+	}
+
+	return nil, fmt.Errorf("invalid selector: %s", s)
+}
+
+func ParseRules(r io.Reader) (*RuleSet, error) {
+	rs := &RuleSet{
+		layers: make(map[string][]*regexp.Regexp),
+	}
+
+	layerNames := func(list string) (map[string]bool, error) {
+		names := make(map[string]bool)
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if _, ok := rs.layers[name]; !ok {
+				return nil, fmt.Errorf("undefined layer: %s", name)
+			}
+			names[name] = true
+		}
+		return names, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		rule := &Rule{Line: line, Text: text}
+		var err error
+
+		switch {
+		case strings.HasPrefix(text, "layer ") && strings.Contains(text, "="):
+			i := strings.Index(text, "=")
+			name := strings.TrimSpace(text[len("layer "):i])
+			if _, ok := rs.layers[name]; ok {
+				return nil, fmt.Errorf("line %d: duplicate layer: %s", line, name)
+			}
+			patterns, err := compilePackagePatterns(text[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			rs.layers[name] = patterns
+			rs.layerOrder = append(rs.layerOrder, name)
+			continue
+
+		case strings.HasPrefix(text, "layer ") && strings.HasSuffix(text, " may not be accessed by any layer"):
+			rule.Kind = RULE_MAY_ONLY_BE_ACCESSED_BY
+			rule.layer = strings.TrimSpace(strings.TrimSuffix(text[len("layer "):], " may not be accessed by any layer"))
+			rule.layers = map[string]bool{}
+			if _, ok := rs.layers[rule.layer]; !ok {
+				err = fmt.Errorf("undefined layer: %s", rule.layer)
+			}
+
+		case strings.HasPrefix(text, "layer ") && strings.Contains(text, " may only be accessed by "):
+			rule.Kind = RULE_MAY_ONLY_BE_ACCESSED_BY
+			parts := strings.SplitN(text[len("layer "):], " may only be accessed by ", 2)
+			rule.layer = strings.TrimSpace(parts[0])
+			rule.layers, err = layerNames(parts[0] + "," + parts[1])
+
+		case strings.HasPrefix(text, "layer ") && strings.Contains(text, " may only access "):
+			rule.Kind = RULE_MAY_ONLY_ACCESS
+			parts := strings.SplitN(text[len("layer "):], " may only access ", 2)
+			rule.layer = strings.TrimSpace(parts[0])
+			rule.layers, err = layerNames(parts[0] + "," + parts[1])
+
+		default:
+			var sep string
+			for kind, keyword := range []string{" must not depend on ", " may only depend on ", " must reside in "} {
+				if strings.Contains(text, keyword) {
+					rule.Kind = kind
+					sep = keyword
+					break
+				}
+			}
+			if sep == "" {
+				return nil, fmt.Errorf("line %d: invalid rule: %s", line, text)
+			}
+			parts := strings.SplitN(text, sep, 2)
+			rule.selector, err = parseSelector(parts[0])
+			if err == nil {
+				rule.patterns, err = compilePackagePatterns(parts[1])
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		rs.Rules = append(rs.Rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// 类所属的层，按定义顺序取第一个匹配的层；不属于任何层时返回空串
+func (rs *RuleSet) layerOf(class string) string {
+	for _, name := range rs.layerOrder {
+		if matchPackage(class, rs.layers[name]) {
+			return name
+		}
+	}
+	return ""
+}
+
+// 对 classpath 上的所有类逐条检查规则
+func (rs *RuleSet) Check(p *ClassPath) []*RuleViolation {
+	var violations []*RuleViolation
+
+	for _, name := range p.ClassNames() {
+		if name == "module-info" {
+			continue
+		}
+		cf := p.Class(name)
+		class := strings.Replace(name, "/", ".", -1)
+		source := rs.layerOf(name)
+
+		var deps []*Dependency
+		for _, rule := range rs.Rules {
+			if rule.selector != nil && !rule.selector(cf) {
+				continue
+			}
+			if rule.Kind == RULE_MAY_ONLY_ACCESS && rule.layer != source {
+				continue
+			}
+
+			if rule.Kind == RULE_MUST_RESIDE_IN {
+				if !matchPackage(name, rule.patterns) {
+					violations = append(violations, &RuleViolation{
+						Rule:    rule,
+						Class:   class,
+						Message: fmt.Sprintf("%s in package %s", class, javaPackageOf(name)),
+					})
+				}
+				continue
+			}
+
+			if deps == nil {
+				deps = cf.Dependencies()
+			}
+
+			// 同一目标既有成员引用又有类常量时，只报告更具体的成员引用
+			refs := make(map[string][]string)
+			var targets []string
+			for _, dep := range deps {
+				var violated bool
+				switch rule.Kind {
+				case RULE_MUST_NOT_DEPEND_ON:
+					violated = matchPackage(dep.Target, rule.patterns)
+				case RULE_MAY_ONLY_DEPEND_ON:
+					violated = !matchPackage(dep.Target, rule.patterns)
+				case RULE_MAY_ONLY_BE_ACCESSED_BY:
+					target := rs.layerOf(dep.Target)
+					violated = target == rule.layer && source != rule.layer && !rule.layers[source]
+				case RULE_MAY_ONLY_ACCESS:
+					target := rs.layerOf(dep.Target)
+					violated = target != "" && !rule.layers[target]
+				}
+
+				if violated {
+					if refs[dep.Target] == nil {
+						targets = append(targets, dep.Target)
+					}
+					refs[dep.Target] = append(refs[dep.Target], dep.Ref)
+				}
+			}
+
+			for _, t := range targets {
+				target := strings.Replace(t, "/", ".", -1)
+				for _, ref := range refs[t] {
+					if ref == t && len(refs[t]) > 1 {
+						continue
+					}
+					violations = append(violations, &RuleViolation{
+						Rule:    rule,
+						Class:   class,
+						Target:  target,
+						Ref:     ref,
+						Message: fmt.Sprintf("%s -> %s (%s)", class, target, ref),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Rule.Line < violations[j].Rule.Line
+	})
+	return violations
+}
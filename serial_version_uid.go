@@ -0,0 +1,160 @@
+package jclass
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// java.lang.reflect.Modifier 中参与计算的修饰符
+const (
+	suidClassMask       = 0x0001 | 0x0010 | 0x0200 | 0x0400
+	suidFieldMask       = 0x0001 | 0x0002 | 0x0004 | 0x0008 | 0x0010 | 0x0040 | 0x0080
+	suidMethodMask      = 0x0001 | 0x0002 | 0x0004 | 0x0008 | 0x0010 | 0x0020 | 0x0100 | 0x0400 | 0x0800
+	suidModifierStatic  = 0x0008
+	suidModifierPrivate = 0x0002
+)
+
+// 显式声明的 static final long serialVersionUID 的值
+func DeclaredSerialVersionUID(cf *ClassFile) (int64, bool) {
+	field := cf.FindField("serialVersionUID", "J")
+	if field == nil || field.AccessFlags&(FIELD_ACC_STATIC|FIELD_ACC_FINAL) != FIELD_ACC_STATIC|FIELD_ACC_FINAL {
+		return 0, false
+	}
+	value := field.ConstantValue()
+	if value == nil || value.Tag != 5 {
+		return 0, false
+	}
+	return (*ConstantLongInfo)(value).Long(), true
+}
+
+// 与 java.io.ObjectStreamClass.getSerialVersionUID 一致：枚举总是 0；
+// 显式声明了 serialVersionUID 时返回声明值；record 未声明时为 0；否则按默认算法计算
+func ComputeSerialVersionUID(cf *ClassFile) int64 {
+	if cf.IsEnum() {
+		return 0
+	}
+	if suid, ok := DeclaredSerialVersionUID(cf); ok {
+		return suid
+	}
+	if cf.SuperClassString() == "java/lang/Record" {
+		return 0
+	}
+	return DefaultSerialVersionUID(cf)
+}
+
+// java.io.ObjectStreamClass.computeDefaultSUID 的实现：对类名、修饰符、接口、字段、
+// 静态初始化块、构造器与方法做 SHA-1，取前 8 字节按小端序组成 long
+func DefaultSerialVersionUID(cf *ClassFile) int64 {
+	buf := &bytes.Buffer{}
+	writeUTF := func(s string) {
+		b := encodeModifiedUTF8(s)
+		binary.Write(buf, binary.BigEndian, uint16(len(b)))
+		buf.Write(b)
+	}
+	writeInt := func(i int) {
+		binary.Write(buf, binary.BigEndian, int32(i))
+	}
+	javaName := func(s string) string {
+		return strings.Replace(s, "/", ".", -1)
+	}
+
+	writeUTF(javaName(cf.ThisClassString()))
+
+	// Class.getModifiers() 对内部类取 InnerClasses 中记录的修饰符
+	flags := cf.AccessFlags
+	if inner := cf.InnerClassEntry(); inner != nil {
+		flags = inner.InnerClassAccessFlags
+	}
+	modifiers := int(flags) & suidClassMask
+
+	var methods, constructors []*MethodInfo
+	var hasClinit bool
+	for _, method := range cf.Methods {
+		switch method.NameString() {
+		case "<clinit>":
+			hasClinit = true
+		case "<init>":
+			constructors = append(constructors, method)
+		default:
+			methods = append(methods, method)
+		}
+	}
+
+	if flags&CLASS_ACC_INTERFACE != 0 {
+		if len(methods) > 0 {
+			modifiers |= int(CLASS_ACC_ABSTRACT)
+		} else {
+			modifiers &^= int(CLASS_ACC_ABSTRACT)
+		}
+	}
+	writeInt(modifiers)
+
+	interfaces := cf.InterfaceStrings()
+	for i := range interfaces {
+		interfaces[i] = javaName(interfaces[i])
+	}
+	sort.Strings(interfaces)
+	for _, name := range interfaces {
+		writeUTF(name)
+	}
+
+	fields := append([]*FieldInfo(nil), cf.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].NameString() < fields[j].NameString()
+	})
+	for _, field := range fields {
+		mods := int(field.AccessFlags) & suidFieldMask
+		if mods&suidModifierPrivate != 0 && field.AccessFlags&(FIELD_ACC_STATIC|FIELD_ACC_TRANSIENT) != 0 {
+			continue
+		}
+		writeUTF(field.NameString())
+		writeInt(mods)
+		writeUTF(field.DescriptorString())
+	}
+
+	if hasClinit {
+		writeUTF("<clinit>")
+		writeInt(suidModifierStatic)
+		writeUTF("()V")
+	}
+
+	sort.SliceStable(constructors, func(i, j int) bool {
+		return constructors[i].DescriptorString() < constructors[j].DescriptorString()
+	})
+	for _, method := range constructors {
+		mods := int(method.AccessFlags) & suidMethodMask
+		if mods&suidModifierPrivate != 0 {
+			continue
+		}
+		writeUTF("<init>")
+		writeInt(mods)
+		writeUTF(javaName(method.DescriptorString()))
+	}
+
+	sort.SliceStable(methods, func(i, j int) bool {
+		a, b := methods[i], methods[j]
+		if a.NameString() != b.NameString() {
+			return a.NameString() < b.NameString()
+		}
+		return a.DescriptorString() < b.DescriptorString()
+	})
+	for _, method := range methods {
+		mods := int(method.AccessFlags) & suidMethodMask
+		if mods&suidModifierPrivate != 0 {
+			continue
+		}
+		writeUTF(method.NameString())
+		writeInt(mods)
+		writeUTF(javaName(method.DescriptorString()))
+	}
+
+	hash := sha1.Sum(buf.Bytes())
+	var rs int64
+	for i := 7; i >= 0; i-- {
+		rs = rs<<8 | int64(hash[i])
+	}
+	return rs
+}
@@ -0,0 +1,209 @@
+package jclass
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type ServiceProblem struct {
+	Jar *Jar
+	// META-INF/services/<service> 或 module-info.class
+	Entry string
+	// 服务接口与实现类，均为 Java 形式；问题与具体实现类无关时 Provider 为空
+	Service  string
+	Provider string
+	Reason   string
+}
+
+func (p *ServiceProblem) String() string {
+	if p.Provider == "" {
+		return p.Entry + ": " + p.Service + ": " + p.Reason
+	}
+	return p.Entry + ": " + p.Provider + ": " + p.Reason
+}
+
+// 合法的二进制类名（Java 形式），如 com.acme.Foo$Bar
+func validBinaryName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return false
+		}
+		for i, c := range part {
+			if !(unicode.IsLetter(c) || c == '_' || c == '$' || i > 0 && unicode.IsDigit(c)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 按 java.util.ServiceLoader 的规则检查 classpath 上每个 jar 的 META-INF/services 文件，
+// 以及模块 jar 中 module-info 的 provides：服务接口存在，实现类存在、public、非抽象、
+// 有 public 无参构造方法（模块中也可以是 public static provider() 方法），并且可以赋值给服务接口。
+// 模块 jar 的 provides 与 services 文件不一致时也会报告，因为 jar 放在 classpath 与 modulepath 上
+// 时看到的实现不同。以 ignore 中任一前缀开头的类（如 java/）视为总是存在
+func (p *ClassPath) CheckServices(ignore []string) []*ServiceProblem {
+	var rs []*ServiceProblem
+	for _, jar := range p.Jars {
+		rs = append(rs, p.checkJarServices(jar, ignore)...)
+	}
+	return rs
+}
+
+func (p *ClassPath) checkJarServices(jar *Jar, ignore []string) []*ServiceProblem {
+	var rs []*ServiceProblem
+	report := func(entry, service, provider, reason string) {
+		rs = append(rs, &ServiceProblem{
+			Jar:      jar,
+			Entry:    entry,
+			Service:  service,
+			Provider: provider,
+			Reason:   reason,
+		})
+	}
+
+	services := jar.Services()
+	names := make([]string, 0, len(services))
+	for service := range services {
+		names = append(names, service)
+	}
+	sort.Strings(names)
+
+	for _, service := range names {
+		entry := "META-INF/services/" + service
+		if !validBinaryName(service) {
+			report(entry, service, "", "invalid service name")
+			continue
+		}
+		serviceFound := p.checkServiceType(service, ignore, func(reason string) {
+			report(entry, service, "", reason)
+		})
+
+		seen := make(map[string]bool)
+		for _, provider := range services[service] {
+			if seen[provider] {
+				report(entry, service, provider, "listed more than once")
+				continue
+			}
+			seen[provider] = true
+			if !validBinaryName(provider) {
+				report(entry, service, provider, "invalid class name")
+				continue
+			}
+			for _, reason := range p.checkProvider(service, provider, serviceFound, false, ignore) {
+				report(entry, service, provider, reason)
+			}
+		}
+	}
+
+	info := jar.Class("module-info")
+	if info == nil || info.Module() == nil {
+		return rs
+	}
+	const entry = "module-info.class"
+	provides := info.Module().ProvidesStrings()
+	names = names[:0]
+	for service := range provides {
+		names = append(names, service)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		service := strings.Replace(name, "/", ".", -1)
+		serviceFound := p.checkServiceType(service, ignore, func(reason string) {
+			report(entry, service, "", reason)
+		})
+
+		listed := make(map[string]bool)
+		for _, provider := range services[service] {
+			listed[provider] = true
+		}
+		provided := make(map[string]bool)
+		for _, class := range provides[name] {
+			provider := strings.Replace(class, "/", ".", -1)
+			provided[provider] = true
+			if p.JarOf(class) != nil && p.JarOf(class) != jar {
+				report(entry, service, provider, "not in module "+jar.ModuleName())
+			}
+			for _, reason := range p.checkProvider(service, provider, serviceFound, true, ignore) {
+				report(entry, service, provider, reason)
+			}
+			if !listed[provider] {
+				report(entry, service, provider, "provided by the module but missing from META-INF/services/"+service)
+			}
+		}
+		for _, provider := range services[service] {
+			if !provided[provider] {
+				report("META-INF/services/"+service, service, provider, "listed but not provided by module-info, ignored on the module path")
+			}
+		}
+	}
+	return rs
+}
+
+// 检查服务接口是否存在；不存在且不被忽略时调用 report 并返回 false
+func (p *ClassPath) checkServiceType(service string, ignore []string, report func(string)) bool {
+	name := strings.Replace(service, ".", "/", -1)
+	if p.Class(name) != nil {
+		return true
+	}
+	if !hasAnyPrefix(name, ignore) {
+		report("service type not found")
+	}
+	return false
+}
+
+// 检查一个实现类，返回发现的问题。module 为 true 时按模块中的 provides 规则，允许 provider() 方法
+func (p *ClassPath) checkProvider(service, provider string, serviceFound, module bool, ignore []string) []string {
+	serviceName := strings.Replace(service, ".", "/", -1)
+	name := strings.Replace(provider, ".", "/", -1)
+	cf := p.Class(name)
+	if cf == nil {
+		if hasAnyPrefix(name, ignore) {
+			return nil
+		}
+		return []string{"class not found"}
+	}
+
+	var rs []string
+	if !cf.IsPublic() {
+		rs = append(rs, "not public")
+	}
+
+	// 模块中的 provider() 方法优先于构造方法，返回值须可以赋值给服务接口
+	if module {
+		for _, m := range cf.Methods {
+			if m.NameString() != "provider" || m.AccessFlags&(METHOD_ACC_PUBLIC|METHOD_ACC_STATIC) != METHOD_ACC_PUBLIC|METHOD_ACC_STATIC {
+				continue
+			}
+			sig, err := ParseMethodSignature(m.DescriptorString())
+			if err != nil || len(sig.Parameters) != 0 {
+				continue
+			}
+			if result := elementClass(sig.Result.Name); serviceFound && result != "" && p.IsAssignable(result, serviceName) == RESOLVE_MISSING {
+				rs = append(rs, "provider() returns "+strings.Replace(result, "/", ".", -1)+", not a subtype of "+service)
+			}
+			return rs
+		}
+	}
+
+	switch {
+	case cf.IsInterface():
+		rs = append(rs, "is an interface")
+	case cf.IsAbstract():
+		rs = append(rs, "is abstract")
+	}
+	if ctor := cf.FindMethod("<init>", "()V"); ctor == nil {
+		rs = append(rs, "no public no-arg constructor")
+	} else if ctor.AccessFlags&METHOD_ACC_PUBLIC == 0 {
+		rs = append(rs, "no-arg constructor is not public")
+	}
+	if serviceFound && p.IsAssignable(name, serviceName) == RESOLVE_MISSING {
+		rs = append(rs, "not a subtype of "+service)
+	}
+	return rs
+}
@@ -0,0 +1,171 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// 按保留规则计算可达的类与成员：满足 -keep、-keepclasseswithmembers 的类和成员以及
+// META-INF/services 中登记的实现类作为入口，-keepclassmembers 只对可达的类生效
+func (p *ClassPath) KeepReachable(config *KeepConfig) *Reachability {
+	b := newReachabilityBuilder(p)
+
+	for _, name := range p.ClassNames() {
+		cf := p.Class(name)
+		for _, rule := range config.Rules {
+			if rule.AllowShrinking || rule.Option == KEEP_CLASS_MEMBERS || !rule.MatchClass(p, cf) {
+				continue
+			}
+			if rule.Option == KEEP_CLASSES_WITH_MEMBERS && !rule.hasAllMembers(cf) {
+				continue
+			}
+			b.keepMembers(rule, cf)
+		}
+	}
+
+	for _, jar := range p.Jars {
+		for _, providers := range jar.Services() {
+			for _, provider := range providers {
+				provider = strings.Replace(provider, ".", "/", -1)
+				b.class(provider)
+				b.method(provider, "<init>", "()V")
+			}
+		}
+	}
+	b.drain()
+
+	// -keepclassmembers 可能引入新的可达类，重复直到不再变化
+	for {
+		before := len(b.r.Classes) + len(b.r.Methods) + len(b.r.Fields)
+		for _, name := range p.ClassNames() {
+			if !b.r.Classes[name] {
+				continue
+			}
+			cf := p.Class(name)
+			for _, rule := range config.Rules {
+				if !rule.AllowShrinking && rule.Option == KEEP_CLASS_MEMBERS && rule.MatchClass(p, cf) {
+					fields, methods := rule.MatchMembers(cf)
+					for _, f := range fields {
+						b.field(name, f.NameString(), f.DescriptorString())
+					}
+					for _, m := range methods {
+						b.method(name, m.NameString(), m.DescriptorString())
+					}
+				}
+			}
+		}
+		b.drain()
+		if len(b.r.Classes)+len(b.r.Methods)+len(b.r.Fields) == before {
+			break
+		}
+	}
+
+	return b.r
+}
+
+func (b *reachabilityBuilder) keepMembers(rule *KeepRule, cf *ClassFile) {
+	name := cf.ThisClassString()
+	b.class(name)
+	fields, methods := rule.MatchMembers(cf)
+	for _, f := range fields {
+		b.field(name, f.NameString(), f.DescriptorString())
+	}
+	for _, m := range methods {
+		b.method(name, m.NameString(), m.DescriptorString())
+	}
+}
+
+// 去掉不可达的字段、方法以及指向已删除类的 InnerClasses 项，并压缩常量池。
+// 类中含有格式错误的属性、无法压缩常量池时返回错误
+func (p *ClassPath) shrinkClass(cf *ClassFile, r *Reachability) (*ClassFile, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, err
+	}
+	name := c.ThisClassString()
+
+	fields := c.Fields[:0]
+	for _, f := range c.Fields {
+		if r.Fields[FieldKey(name, f.NameString(), f.DescriptorString())] {
+			fields = append(fields, f)
+		}
+	}
+	c.Fields = fields
+
+	methods := c.Methods[:0]
+	for _, m := range c.Methods {
+		if r.Methods[MethodKey(name, m.NameString(), m.DescriptorString())] {
+			methods = append(methods, m)
+		}
+	}
+	c.Methods = methods
+
+	if attr := c.Attribute("InnerClasses"); attr != nil {
+		info := make([]byte, 2, len(attr.Info))
+		count := 0
+		for i, inner := range attr.InnerClasses {
+			class := inner.InnerClassString()
+			if p.Class(class) != nil && !r.Classes[class] {
+				continue
+			}
+			info = append(info, attr.Info[2+8*i:2+8*i+8]...)
+			count++
+		}
+		binary.BigEndian.PutUint16(info, uint16(count))
+		attr.Info = info
+	}
+
+	return c.CompactConstantPool()
+}
+
+// jar 签名文件，内容改变后签名失效，需要去掉
+func isSignatureFile(name string) bool {
+	if !strings.HasPrefix(name, "META-INF/") || strings.Count(name, "/") != 1 {
+		return false
+	}
+	for _, ext := range []string{".SF", ".RSA", ".DSA", ".EC"} {
+		if strings.HasSuffix(strings.ToUpper(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// 按保留规则把 classpath 上的所有 jar 合并裁剪为一个新 jar，返回新 jar 与被删除的内容。
+// 同名条目以先出现者为准；module-info、package-info 与 META-INF/versions 下的类原样保留
+func (p *ClassPath) Shrink(config *KeepConfig, path string) (*Jar, *UnusedReport, error) {
+	r := p.KeepReachable(config)
+	out := NewJar(path)
+
+	for _, jar := range p.Jars {
+		for _, entry := range jar.Entries {
+			if out.Entry(entry.Name) != nil || isSignatureFile(entry.Name) {
+				continue
+			}
+
+			data := entry.Data
+			if entry.Class != nil {
+				name := entry.Class.ThisClassString()
+				switch {
+				case p.JarOf(name) != jar:
+					continue
+				case name == "module-info" || strings.HasSuffix(name, "/package-info"):
+				case !r.Classes[name]:
+					continue
+				default:
+					c, err := p.shrinkClass(entry.Class, r)
+					if err != nil {
+						return nil, nil, &JarError{Path: jar.Path, Entry: entry.Name, Err: err}
+					}
+					data = c.Bytes()
+				}
+			}
+
+			if err := out.AddEntry(entry.Name, data, entry.Modified); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return out, p.Unused(r), nil
+}
@@ -0,0 +1,408 @@
+package jclass
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ERR_INVALID_SIGNATURE = errors.New("invalid signature")
+)
+
+// JVMS 4.7.9.1 中的类型签名；字段描述符是其子集，同样可以用本文件的函数解析
+type TypeSignature struct {
+	// 基本类型为 B C D F I J S Z，V 表示 void，
+	// L 表示类类型，T 表示类型变量，[ 表示数组
+	Kind byte
+
+	// 类类型的内部类名（内部类以 $ 连接），或类型变量名
+	Name          string
+	TypeArguments []*TypeArgument
+
+	// 形如 Outer<T>.Inner 的内部类签名中的外部类部分
+	Owner *TypeSignature
+
+	// 数组的元素类型
+	Elem *TypeSignature
+}
+
+type TypeArgument struct {
+	// '*' 表示 ?，'+' 表示 ? extends，'-' 表示 ? super，0 表示确切类型
+	Wildcard byte
+	Type     *TypeSignature
+}
+
+type TypeParameter struct {
+	Name            string
+	ClassBound      *TypeSignature
+	InterfaceBounds []*TypeSignature
+}
+
+type ClassSignature struct {
+	TypeParameters []*TypeParameter
+	SuperClass     *TypeSignature
+	Interfaces     []*TypeSignature
+}
+
+type MethodSignature struct {
+	TypeParameters []*TypeParameter
+	Parameters     []*TypeSignature
+	Result         *TypeSignature
+	Throws         []*TypeSignature
+}
+
+type signatureParser struct {
+	s   string
+	pos int
+	err error
+}
+
+func (p *signatureParser) fail() {
+	if p.err == nil {
+		p.err = ERR_INVALID_SIGNATURE
+	}
+}
+
+func (p *signatureParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *signatureParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail()
+		return
+	}
+	p.pos++
+}
+
+func (p *signatureParser) identifier(stops string) string {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(stops, p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail()
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *signatureParser) typeParameters() []*TypeParameter {
+	if p.peek() != '<' {
+		return nil
+	}
+	p.pos++
+
+	var rs []*TypeParameter
+	for p.err == nil && p.peek() != '>' {
+		tp := &TypeParameter{Name: p.identifier(":>")}
+		p.expect(':')
+		switch p.peek() {
+		case 'L', 'T', '[':
+			tp.ClassBound = p.referenceType()
+		}
+		for p.err == nil && p.peek() == ':' {
+			p.pos++
+			tp.InterfaceBounds = append(tp.InterfaceBounds, p.referenceType())
+		}
+		rs = append(rs, tp)
+	}
+	p.expect('>')
+	return rs
+}
+
+func (p *signatureParser) javaType() *TypeSignature {
+	switch c := p.peek(); c {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 'V':
+		p.pos++
+		return &TypeSignature{Kind: c}
+	default:
+		return p.referenceType()
+	}
+}
+
+func (p *signatureParser) referenceType() *TypeSignature {
+	switch p.peek() {
+	case 'L':
+		p.pos++
+		return p.classType(nil)
+
+	case 'T':
+		p.pos++
+		rs := &TypeSignature{Kind: 'T', Name: p.identifier(";")}
+		p.expect(';')
+		return rs
+
+	case '[':
+		p.pos++
+		return &TypeSignature{Kind: '[', Elem: p.javaType()}
+
+	default:
+		p.fail()
+		return &TypeSignature{Kind: 'L', Name: "java/lang/Object"}
+	}
+}
+
+func (p *signatureParser) classType(owner *TypeSignature) *TypeSignature {
+	rs := &TypeSignature{Kind: 'L', Owner: owner}
+	name := p.identifier("<;.")
+	if owner != nil {
+		name = owner.Name + "$" + name
+	}
+	rs.Name = name
+
+	if p.peek() == '<' {
+		p.pos++
+		for p.err == nil && p.peek() != '>' {
+			arg := &TypeArgument{}
+			switch c := p.peek(); c {
+			case '*':
+				p.pos++
+				arg.Wildcard = c
+			case '+', '-':
+				p.pos++
+				arg.Wildcard = c
+				arg.Type = p.referenceType()
+			default:
+				arg.Type = p.referenceType()
+			}
+			rs.TypeArguments = append(rs.TypeArguments, arg)
+		}
+		p.expect('>')
+	}
+
+	if p.peek() == '.' {
+		p.pos++
+		return p.classType(rs)
+	}
+	p.expect(';')
+	return rs
+}
+
+func (p *signatureParser) end() error {
+	if p.err == nil && p.pos != len(p.s) {
+		p.fail()
+	}
+	return p.err
+}
+
+// 解析字段签名或字段描述符
+func ParseFieldSignature(s string) (*TypeSignature, error) {
+	p := &signatureParser{s: s}
+	rs := p.javaType()
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func ParseClassSignature(s string) (*ClassSignature, error) {
+	p := &signatureParser{s: s}
+	rs := &ClassSignature{}
+	rs.TypeParameters = p.typeParameters()
+	rs.SuperClass = p.referenceType()
+	for p.err == nil && p.pos < len(s) {
+		rs.Interfaces = append(rs.Interfaces, p.referenceType())
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// 解析方法签名或方法描述符
+func ParseMethodSignature(s string) (*MethodSignature, error) {
+	p := &signatureParser{s: s}
+	rs := &MethodSignature{}
+	rs.TypeParameters = p.typeParameters()
+	p.expect('(')
+	for p.err == nil && p.peek() != ')' {
+		rs.Parameters = append(rs.Parameters, p.javaType())
+	}
+	p.expect(')')
+	rs.Result = p.javaType()
+	for p.err == nil && p.peek() == '^' {
+		p.pos++
+		rs.Throws = append(rs.Throws, p.referenceType())
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// 类型中出现的所有类名（含类型参数与数组元素）
+func (t *TypeSignature) Classes() []string {
+	var rs []string
+	t.collectClasses(&rs)
+	return rs
+}
+
+func (t *TypeSignature) collectClasses(rs *[]string) {
+	if t == nil {
+		return
+	}
+	switch t.Kind {
+	case 'L':
+		if t.Owner != nil {
+			t.Owner.collectClasses(rs)
+		}
+		*rs = append(*rs, t.Name)
+		for _, arg := range t.TypeArguments {
+			arg.Type.collectClasses(rs)
+		}
+	case '[':
+		t.Elem.collectClasses(rs)
+	}
+}
+
+func collectTypeParameterClasses(params []*TypeParameter, rs *[]string) {
+	for _, param := range params {
+		param.ClassBound.collectClasses(rs)
+		for _, bound := range param.InterfaceBounds {
+			bound.collectClasses(rs)
+		}
+	}
+}
+
+func (s *ClassSignature) Classes() []string {
+	var rs []string
+	collectTypeParameterClasses(s.TypeParameters, &rs)
+	s.SuperClass.collectClasses(&rs)
+	for _, iface := range s.Interfaces {
+		iface.collectClasses(&rs)
+	}
+	return rs
+}
+
+func (s *MethodSignature) Classes() []string {
+	var rs []string
+	collectTypeParameterClasses(s.TypeParameters, &rs)
+	for _, param := range s.Parameters {
+		param.collectClasses(&rs)
+	}
+	s.Result.collectClasses(&rs)
+	for _, t := range s.Throws {
+		t.collectClasses(&rs)
+	}
+	return rs
+}
+
+// 描述符或签名中出现的所有类名；既可以是字段也可以是方法的描述符，无法解析时返回 nil
+func DescriptorClasses(desc string) []string {
+	if strings.HasPrefix(desc, "(") || strings.HasPrefix(desc, "<") {
+		if sig, err := ParseMethodSignature(desc); err == nil {
+			return sig.Classes()
+		}
+		return nil
+	}
+	if t, err := ParseFieldSignature(desc); err == nil {
+		return t.Classes()
+	}
+	return nil
+}
+
+// 按签名语法写出 mapClass 替换类名后的签名
+func (t *TypeSignature) writeMapped(b *strings.Builder, mapClass func(string) string) {
+	switch t.Kind {
+	case 'L':
+		t.writeMappedClass(b, mapClass)
+		b.WriteByte(';')
+	case 'T':
+		b.WriteString("T" + t.Name + ";")
+	case '[':
+		b.WriteByte('[')
+		t.Elem.writeMapped(b, mapClass)
+	default:
+		b.WriteByte(t.Kind)
+	}
+}
+
+// 写出不含结尾 ; 的类类型，返回映射后的类名。内部类只写出简单名，
+// 新名称不以外部类的新名称加 $ 开头时取最后一个 $ 之后的部分
+func (t *TypeSignature) writeMappedClass(b *strings.Builder, mapClass func(string) string) string {
+	name := mapClass(t.Name)
+	if t.Owner == nil {
+		b.WriteString("L" + name)
+	} else {
+		outer := t.Owner.writeMappedClass(b, mapClass)
+		simple := name[strings.LastIndexByte(name, '$')+1:]
+		if strings.HasPrefix(name, outer+"$") {
+			simple = name[len(outer)+1:]
+		}
+		b.WriteString("." + simple)
+	}
+	if len(t.TypeArguments) > 0 {
+		b.WriteByte('<')
+		for _, arg := range t.TypeArguments {
+			if arg.Wildcard != 0 {
+				b.WriteByte(arg.Wildcard)
+			}
+			if arg.Type != nil {
+				arg.Type.writeMapped(b, mapClass)
+			}
+		}
+		b.WriteByte('>')
+	}
+	return name
+}
+
+func writeMappedTypeParameters(b *strings.Builder, params []*TypeParameter, mapClass func(string) string) {
+	if len(params) == 0 {
+		return
+	}
+	b.WriteByte('<')
+	for _, param := range params {
+		b.WriteString(param.Name + ":")
+		if param.ClassBound != nil {
+			param.ClassBound.writeMapped(b, mapClass)
+		}
+		for _, bound := range param.InterfaceBounds {
+			b.WriteByte(':')
+			bound.writeMapped(b, mapClass)
+		}
+	}
+	b.WriteByte('>')
+}
+
+func (s *ClassSignature) writeMapped(b *strings.Builder, mapClass func(string) string) {
+	writeMappedTypeParameters(b, s.TypeParameters, mapClass)
+	s.SuperClass.writeMapped(b, mapClass)
+	for _, iface := range s.Interfaces {
+		iface.writeMapped(b, mapClass)
+	}
+}
+
+func (s *MethodSignature) writeMapped(b *strings.Builder, mapClass func(string) string) {
+	writeMappedTypeParameters(b, s.TypeParameters, mapClass)
+	b.WriteByte('(')
+	for _, param := range s.Parameters {
+		param.writeMapped(b, mapClass)
+	}
+	b.WriteByte(')')
+	s.Result.writeMapped(b, mapClass)
+	for _, t := range s.Throws {
+		b.WriteByte('^')
+		t.writeMapped(b, mapClass)
+	}
+}
+
+var primitiveJavaNames = map[byte]string{
+	'B': "byte", 'C': "char", 'D': "double", 'F': "float",
+	'I': "int", 'J': "long", 'S': "short", 'Z': "boolean", 'V': "void",
+}
+
+// 擦除泛型后的 Java 源码形式，如 int、java.lang.String[]、java.util.Map$Entry
+func (t *TypeSignature) JavaName() string {
+	switch t.Kind {
+	case 'L':
+		return strings.Replace(t.Name, "/", ".", -1)
+	case 'T':
+		return t.Name
+	case '[':
+		return t.Elem.JavaName() + "[]"
+	}
+	return primitiveJavaNames[t.Kind]
+}
@@ -0,0 +1,219 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 去掉哪些可选的调试信息
+type StripOptions struct {
+	// LineNumberTable
+	LineNumbers bool
+	// LocalVariableTable 与 LocalVariableTypeTable
+	LocalVariables bool
+	// SourceFile
+	SourceFile bool
+	// SourceFile 只保留文件名，去掉构建机器上的目录；SourceFile 为 true 时无意义
+	SourceFileBaseName bool
+	// SourceDebugExtension（如 JSP、Kotlin 内联的 SMAP）
+	SourceDebugExtension bool
+	// 其他要去掉的属性名，如 MethodParameters
+	Attributes []string
+}
+
+// 去掉所有调试信息
+var STRIP_ALL = &StripOptions{
+	LineNumbers:          true,
+	LocalVariables:       true,
+	SourceFile:           true,
+	SourceDebugExtension: true,
+}
+
+// 可重复构建的最早时间戳，zip 的 DOS 时间不能早于 1980 年
+var REPRODUCIBLE_TIMESTAMP = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (o *StripOptions) strips(name string) bool {
+	switch name {
+	case "LineNumberTable":
+		return o.LineNumbers
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		return o.LocalVariables
+	case "SourceFile":
+		return o.SourceFile
+	case "SourceDebugExtension":
+		return o.SourceDebugExtension
+	}
+	for _, attr := range o.Attributes {
+		if attr == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 去掉被选中的属性，其余按名称排序
+func (o *StripOptions) filterAttributes(attrs []*AttributeInfo) []*AttributeInfo {
+	rs := attrs[:0]
+	for _, attr := range attrs {
+		if !o.strips(attr.NameString()) {
+			rs = append(rs, attr)
+		}
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rs[i].NameString() < rs[j].NameString()
+	})
+	return rs
+}
+
+// 在 Code 属性的原始字节中去掉被选中的子属性，其余按名称排序
+func (o *StripOptions) stripCode(cf *ClassFile, info []byte) ([]byte, error) {
+	if len(info) < 8 {
+		return nil, ERR_MALFORMED_ATTRIBUTE
+	}
+	pos := 8 + int(binary.BigEndian.Uint32(info[4:]))
+	if pos+2 > len(info) {
+		return nil, ERR_MALFORMED_ATTRIBUTE
+	}
+	pos += 2 + 8*int(binary.BigEndian.Uint16(info[pos:]))
+	if pos+2 > len(info) {
+		return nil, ERR_MALFORMED_ATTRIBUTE
+	}
+
+	type rawAttribute struct {
+		name string
+		data []byte
+	}
+	var attrs []rawAttribute
+	off := pos + 2
+	for n := binary.BigEndian.Uint16(info[pos:]); n > 0; n-- {
+		if off+6 > len(info) {
+			return nil, ERR_MALFORMED_ATTRIBUTE
+		}
+		end := off + 6 + int(binary.BigEndian.Uint32(info[off+2:]))
+		if end > len(info) {
+			return nil, ERR_MALFORMED_ATTRIBUTE
+		}
+		name := cf.Utf8At(binary.BigEndian.Uint16(info[off:]))
+		if !o.strips(name) {
+			attrs = append(attrs, rawAttribute{name, info[off:end]})
+		}
+		off = end
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return attrs[i].name < attrs[j].name
+	})
+
+	rs := make([]byte, pos+2, len(info))
+	copy(rs, info[:pos])
+	binary.BigEndian.PutUint16(rs[pos:], uint16(len(attrs)))
+	for _, attr := range attrs {
+		rs = append(rs, attr.data...)
+	}
+	return rs, nil
+}
+
+// 去掉调试信息并规范化：属性按名称排序，常量池合并去重并按引用顺序重排。
+// 类中含有格式错误的属性时保留原有的常量池顺序
+func (cf *ClassFile) Strip(opts *StripOptions) (*ClassFile, error) {
+	c, err := cf.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	stripMembers := func(attrs []*AttributeInfo) ([]*AttributeInfo, error) {
+		for _, attr := range attrs {
+			if attr.NameString() == "Code" {
+				if attr.Info, err = opts.stripCode(c, attr.Info); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return opts.filterAttributes(attrs), nil
+	}
+	for _, f := range c.Fields {
+		f.Attributes = opts.filterAttributes(f.Attributes)
+	}
+	for _, m := range c.Methods {
+		if m.Attributes, err = stripMembers(m.Attributes); err != nil {
+			return nil, err
+		}
+	}
+	c.Attributes = opts.filterAttributes(c.Attributes)
+
+	if attr := c.Attribute("SourceFile"); attr != nil && opts.SourceFileBaseName && len(attr.Info) == 2 {
+		s := c.Utf8At(binary.BigEndian.Uint16(attr.Info))
+		if base := path.Base(strings.Replace(s, "\\", "/", -1)); base != s {
+			binary.BigEndian.PutUint16(attr.Info, uint16(len(c.ConstantPool)))
+			c.ConstantPool = append(c.ConstantPool, NewConstantUtf8(base))
+		}
+	}
+
+	stripped, err := NewClassFile(bytes.NewReader(c.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if canonical, err := stripped.CanonicalConstantPool(); err == nil {
+		return canonical, nil
+	}
+	if compacted, err := stripped.CompactConstantPool(); err == nil {
+		return compacted, nil
+	}
+	return stripped, nil
+}
+
+// 可重复构建的 jar：类文件去掉调试信息并规范化，条目按名称排序（MANIFEST.MF 与 META-INF/ 在最前），
+// 时间戳统一为 modified，签名文件被去掉。opts 为 nil 时只规范化、不去掉调试信息
+func (j *Jar) Normalize(path string, opts *StripOptions, modified time.Time) (*Jar, error) {
+	if opts == nil {
+		opts = &StripOptions{}
+	}
+
+	entries := make([]*JarEntry, len(j.Entries))
+	copy(entries, j.Entries)
+	rank := func(name string) int {
+		switch {
+		case name == "META-INF/MANIFEST.MF":
+			return 0
+		case strings.HasPrefix(name, "META-INF/"):
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		ra, rb := rank(entries[a].Name), rank(entries[b].Name)
+		if ra != rb {
+			return ra < rb
+		}
+		return entries[a].Name < entries[b].Name
+	})
+
+	out := NewJar(path)
+	for _, entry := range entries {
+		if isSignatureFile(entry.Name) {
+			continue
+		}
+		data := entry.Data
+		if entry.IsClass() {
+			cf := entry.Class
+			if cf == nil {
+				var err error
+				if cf, err = NewClassFile(bytes.NewReader(data)); err != nil {
+					return nil, &JarError{Path: j.Path, Entry: entry.Name, Err: err}
+				}
+			}
+			stripped, err := cf.Strip(opts)
+			if err != nil {
+				return nil, &JarError{Path: j.Path, Entry: entry.Name, Err: err}
+			}
+			data = stripped.Bytes()
+		}
+		if err := out.AddEntry(entry.Name, data, modified); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
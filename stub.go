@@ -0,0 +1,775 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 源码中可以直接写出的字符，其余的用 \uXXXX 转义
+func javaPrintable(c rune) bool {
+	return c >= 0x20 && c < 0x7f
+}
+
+func javaCharEscape(c rune) string {
+	switch c {
+	case '\b':
+		return `\b`
+	case '\t':
+		return `\t`
+	case '\n':
+		return `\n`
+	case '\f':
+		return `\f`
+	case '\r':
+		return `\r`
+	case '\\':
+		return `\\`
+	}
+	if javaPrintable(c) {
+		return string(c)
+	}
+	// 补充平面的字符写成代理对
+	s := ""
+	for _, u := range utf16.Encode([]rune{c}) {
+		s += fmt.Sprintf(`\u%04x`, u)
+	}
+	return s
+}
+
+// Java 字符串字面量
+func javaStringLiteral(s string) string {
+	b := &strings.Builder{}
+	b.WriteByte('"')
+	for _, c := range s {
+		if c == '"' {
+			b.WriteString(`\"`)
+		} else {
+			b.WriteString(javaCharEscape(c))
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Java 字符字面量，c 为 UTF-16 码元
+func javaCharLiteral(c int32) string {
+	switch {
+	case c == '\'':
+		return `'\''`
+	case c >= 0xd800 && c <= 0xdfff:
+		return fmt.Sprintf(`'\u%04x'`, c)
+	}
+	return "'" + javaCharEscape(rune(c)) + "'"
+}
+
+// 浮点数字面量；NaN 与无穷写成常量表达式，保证仍是编译期常量
+func javaFloatLiteral(f float64, bits int, suffix string) string {
+	switch {
+	case math.IsNaN(f):
+		return "0.0" + suffix + " / 0.0" + suffix
+	case math.IsInf(f, 1):
+		return "1.0" + suffix + " / 0.0" + suffix
+	case math.IsInf(f, -1):
+		return "-1.0" + suffix + " / 0.0" + suffix
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s + suffix
+}
+
+// 常量池中数值或字符串常量的 Java 字面量；desc 为字段描述符，用于区分 int 常量表示的 boolean、char 等
+func javaLiteral(info *ConstantPoolInfo, cp []*ConstantPoolInfo, desc string) string {
+	switch info.Tag {
+	case 3:
+		v := (*ConstantIntegerInfo)(info).Integer()
+		switch desc {
+		case "Z":
+			return strconv.FormatBool(v != 0)
+		case "C":
+			return javaCharLiteral(v)
+		}
+		return strconv.Itoa(int(v))
+	case 4:
+		return javaFloatLiteral(float64((*ConstantFloatInfo)(info).Float()), 32, "f")
+	case 5:
+		return strconv.FormatInt((*ConstantLongInfo)(info).Long(), 10) + "L"
+	case 6:
+		return javaFloatLiteral((*ConstantDoubleInfo)(info).Double(), 64, "d")
+	case 8:
+		index := (*ConstantStringInfo)(info).StringIndex()
+		return javaStringLiteral((*ConstantUtf8Info)(cp[index]).Utf8())
+	}
+	return "null"
+}
+
+// 非常量的 final 字段的初值：基本类型经过装箱再拆箱，避免成为会被内联的编译期常量
+var stubDefaultValues = map[byte]string{
+	'Z': "java.lang.Boolean.FALSE",
+	'B': "java.lang.Byte.valueOf((byte) 0)",
+	'C': "java.lang.Character.valueOf('\\0')",
+	'S': "java.lang.Short.valueOf((short) 0)",
+	'I': "java.lang.Integer.valueOf(0)",
+	'J': "java.lang.Long.valueOf(0L)",
+	'F': "java.lang.Float.valueOf(0.0f)",
+	'D': "java.lang.Double.valueOf(0.0d)",
+}
+
+// 调用父类构造方法时的实参
+var stubArguments = map[byte]string{
+	'Z': "false", 'B': "(byte) 0", 'C': "(char) 0", 'S': "(short) 0",
+	'I': "0", 'J': "0L", 'F': "0.0f", 'D': "0.0d",
+}
+
+// 生成一个顶层类的存根源码
+type stubWriter struct {
+	p *ClassPath
+	b *bytes.Buffer
+	// 已知的内部类项，用于把内部类名转换为 Outer.Inner 形式
+	inners map[string]*InnerClass
+	names  map[string]string
+	// 正在转换的类名，InnerClasses 中的外部类成环时用于终止递归
+	visiting map[string]bool
+	// 反编译时非空：输出全部成员与方法体，java.lang 与本包的类型写简单名
+	d   *classDecompiler
+	pkg string
+}
+
+func (w *stubWriter) printf(indent int, format string, args ...interface{}) {
+	w.b.WriteString(strings.Repeat("\t", indent))
+	fmt.Fprintf(w.b, format, args...)
+}
+
+func (w *stubWriter) addInnerClasses(cf *ClassFile) {
+	for _, c := range cf.InnerClasses() {
+		if c.OuterClassString() != "" && c.InnerNameString() != "" {
+			w.inners[c.InnerClassString()] = c
+		}
+	}
+}
+
+// 内部类名在源码中的形式，如 java/util/Map$Entry -> java.util.Map.Entry
+func (w *stubWriter) sourceName(name string) string {
+	if s, ok := w.names[name]; ok {
+		return s
+	}
+	s := strings.Replace(name, "/", ".", -1)
+	inner := w.inners[name]
+	if inner == nil {
+		if cf := w.p.Class(name); cf != nil {
+			inner = cf.InnerClassEntry()
+		}
+	}
+	if inner != nil && inner.OuterClassString() != "" && inner.InnerNameString() != "" && !w.visiting[inner.OuterClassString()] {
+		w.visiting[name] = true
+		s = w.sourceName(inner.OuterClassString()) + "." + inner.InnerNameString()
+		delete(w.visiting, name)
+	} else if pkg := PackageOf(name); w.d != nil && pkg != "" && (pkg == "java/lang" || pkg == w.pkg) {
+		s = s[len(pkg)+1:]
+	}
+	w.names[name] = s
+	return s
+}
+
+func hasTypeArguments(t *TypeSignature) bool {
+	for ; t != nil; t = t.Owner {
+		if len(t.TypeArguments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *stubWriter) typeName(t *TypeSignature) string {
+	switch t.Kind {
+	case 'T':
+		return t.Name
+	case '[':
+		return w.typeName(t.Elem) + "[]"
+	case 'L':
+	default:
+		return primitiveJavaNames[t.Kind]
+	}
+
+	var s string
+	if t.Owner != nil && hasTypeArguments(t.Owner) {
+		s = w.typeName(t.Owner) + "." + strings.TrimPrefix(t.Name, t.Owner.Name+"$")
+	} else {
+		s = w.sourceName(t.Name)
+	}
+	if len(t.TypeArguments) == 0 {
+		return s
+	}
+	args := make([]string, len(t.TypeArguments))
+	for i, arg := range t.TypeArguments {
+		switch arg.Wildcard {
+		case '*':
+			args[i] = "?"
+		case '+':
+			args[i] = "? extends " + w.typeName(arg.Type)
+		case '-':
+			args[i] = "? super " + w.typeName(arg.Type)
+		default:
+			args[i] = w.typeName(arg.Type)
+		}
+	}
+	return s + "<" + strings.Join(args, ", ") + ">"
+}
+
+// 描述符表示的类型，解析失败时返回 java.lang.Object
+func (w *stubWriter) descriptorType(desc string) string {
+	t, err := ParseFieldSignature(desc)
+	if err != nil {
+		return "java.lang.Object"
+	}
+	return w.typeName(t)
+}
+
+func (w *stubWriter) typeParameters(params []*TypeParameter) string {
+	if len(params) == 0 {
+		return ""
+	}
+	rs := make([]string, len(params))
+	for i, tp := range params {
+		var bounds []string
+		if tp.ClassBound != nil && !(tp.ClassBound.Kind == 'L' && tp.ClassBound.Name == "java/lang/Object" && len(tp.InterfaceBounds) == 0) {
+			bounds = append(bounds, w.typeName(tp.ClassBound))
+		}
+		for _, bound := range tp.InterfaceBounds {
+			bounds = append(bounds, w.typeName(bound))
+		}
+		rs[i] = tp.Name
+		if len(bounds) > 0 {
+			rs[i] += " extends " + strings.Join(bounds, " & ")
+		}
+	}
+	return "<" + strings.Join(rs, ", ") + ">"
+}
+
+func (w *stubWriter) elementValue(ev *ElementValue) string {
+	switch ev.Tag {
+	case "B", "C", "D", "F", "I", "J", "S", "Z":
+		return javaLiteral(ev.ConstantPoolInfo(ev.ConstantPoolIndex), ev.cp, ev.Tag)
+	case "s":
+		return javaStringLiteral(ev.ConstantString())
+	case "c":
+		if ev.ConstantString() == "V" {
+			return "void.class"
+		}
+		return w.descriptorType(ev.ConstantString()) + ".class"
+	case "e":
+		return w.descriptorType(ev.TypeNameString()) + "." + ev.ConstNameString()
+	case "@":
+		return w.annotation(ev.AnnotationValue)
+	case "[":
+		values := make([]string, len(ev.ArrayValues))
+		for i, v := range ev.ArrayValues {
+			values[i] = w.elementValue(v)
+		}
+		return "{" + strings.Join(values, ", ") + "}"
+	}
+	return ""
+}
+
+func (w *stubWriter) annotation(a *Annotation) string {
+	s := "@" + w.sourceName(a.TypeName())
+	if len(a.ElementValuePairs) == 0 {
+		return s
+	}
+	if len(a.ElementValuePairs) == 1 && a.ElementValuePairs[0].ElementNameString() == "value" {
+		return s + "(" + w.elementValue(a.ElementValuePairs[0].Value) + ")"
+	}
+	pairs := make([]string, len(a.ElementValuePairs))
+	for i, evp := range a.ElementValuePairs {
+		pairs[i] = evp.ElementNameString() + " = " + w.elementValue(evp.Value)
+	}
+	return s + "(" + strings.Join(pairs, ", ") + ")"
+}
+
+func (w *stubWriter) annotations(indent int, anns []*Annotation) {
+	for _, a := range anns {
+		w.printf(indent, "%s\n", w.annotation(a))
+	}
+}
+
+// Record 属性中的组件，按声明顺序
+func (w *stubWriter) recordComponents(cf *ClassFile) []string {
+	attr := cf.Attribute("Record")
+	if attr == nil || len(attr.Info) < 2 {
+		return nil
+	}
+	b := attr.Info
+	var rs []string
+	pos := 2
+	for n := int(binary.BigEndian.Uint16(b)); n > 0 && pos+6 <= len(b); n-- {
+		name := cf.Utf8At(binary.BigEndian.Uint16(b[pos:]))
+		typ := w.descriptorType(cf.Utf8At(binary.BigEndian.Uint16(b[pos+2:])))
+		count := int(binary.BigEndian.Uint16(b[pos+4:]))
+		pos += 6
+		for ; count > 0 && pos+6 <= len(b); count-- {
+			length := int(binary.BigEndian.Uint32(b[pos+2:]))
+			if cf.Utf8At(binary.BigEndian.Uint16(b[pos:])) == "Signature" && length == 2 && pos+8 <= len(b) {
+				if t, err := ParseFieldSignature(cf.Utf8At(binary.BigEndian.Uint16(b[pos+6:]))); err == nil {
+					typ = w.typeName(t)
+				}
+			}
+			pos += 6 + length
+		}
+		rs = append(rs, typ+" "+name)
+	}
+	return rs
+}
+
+// 要生成存根的成员类：InnerClasses 中以 cf 为外部类、对外可见（反编译时不限）且不是合成的项
+func (w *stubWriter) memberClasses(cf *ClassFile) []*InnerClass {
+	var rs []*InnerClass
+	for _, c := range cf.InnerClasses() {
+		if c.OuterClassString() != cf.ThisClassString() || c.InnerNameString() == "" || c.InnerClassAccessFlags&CLASS_ACC_SYNTHETIC != 0 ||
+			w.d == nil && c.InnerClassAccessFlags&(CLASS_ACC_PUBLIC|stubProtected) == 0 {
+			continue
+		}
+		if w.p.Class(c.InnerClassString()) != nil {
+			rs = append(rs, c)
+		}
+	}
+	return rs
+}
+
+// InnerClasses 中成员类的 protected、private 与 static 标志，与字段的取值相同
+const (
+	stubProtected = ClassAccessFlags(FIELD_ACC_PROTECTED)
+	stubPrivate   = ClassAccessFlags(FIELD_ACC_PRIVATE)
+	stubStatic    = ClassAccessFlags(FIELD_ACC_STATIC)
+)
+
+func isRecord(cf *ClassFile) bool {
+	return cf.SuperClassString() == "java/lang/Record" && cf.Attribute("Record") != nil
+}
+
+// 输出一个类的声明；inner 为其成员类项，顶层类为 nil
+func (w *stubWriter) class(indent int, cf *ClassFile, inner *InnerClass) {
+	w.addInnerClasses(cf)
+	flags := cf.AccessFlags
+	if inner != nil {
+		flags = inner.InnerClassAccessFlags
+	}
+
+	var modifiers []string
+	switch {
+	case flags&CLASS_ACC_PUBLIC != 0:
+		modifiers = append(modifiers, "public")
+	case flags&stubProtected != 0:
+		modifiers = append(modifiers, "protected")
+	case flags&stubPrivate != 0 && w.d != nil:
+		modifiers = append(modifiers, "private")
+	}
+	kind := "class"
+	switch {
+	case cf.IsAnnotation():
+		kind = "@interface"
+	case cf.IsInterface():
+		kind = "interface"
+	case cf.IsEnum():
+		kind = "enum"
+	case isRecord(cf):
+		kind = "record"
+	default:
+		if flags&stubStatic != 0 {
+			modifiers = append(modifiers, "static")
+		}
+		if flags&CLASS_ACC_ABSTRACT != 0 {
+			modifiers = append(modifiers, "abstract")
+		} else if flags&CLASS_ACC_FINAL != 0 {
+			modifiers = append(modifiers, "final")
+		}
+	}
+	modifiers = append(modifiers, kind)
+
+	name := w.sourceName(cf.ThisClassString())
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	superClass := &TypeSignature{Kind: 'L', Name: cf.SuperClassString()}
+	var interfaces []*TypeSignature
+	for _, iface := range cf.InterfaceStrings() {
+		interfaces = append(interfaces, &TypeSignature{Kind: 'L', Name: iface})
+	}
+	var typeParams []*TypeParameter
+	if sig, err := ParseClassSignature(cf.SignatureString()); err == nil && cf.SignatureString() != "" {
+		typeParams, superClass, interfaces = sig.TypeParameters, sig.SuperClass, sig.Interfaces
+	}
+
+	w.annotations(indent, cf.Annotations())
+	header := strings.Join(modifiers, " ") + " " + name + w.typeParameters(typeParams)
+	if kind == "record" {
+		header += "(" + strings.Join(w.recordComponents(cf), ", ") + ")"
+	}
+	if kind == "class" && superClass.Name != "java/lang/Object" {
+		header += " extends " + w.typeName(superClass)
+	}
+	if kind != "@interface" && len(interfaces) > 0 {
+		names := make([]string, len(interfaces))
+		for i, iface := range interfaces {
+			names[i] = w.typeName(iface)
+		}
+		if kind == "interface" {
+			header += " extends " + strings.Join(names, ", ")
+		} else {
+			header += " implements " + strings.Join(names, ", ")
+		}
+	}
+	w.printf(indent, "%s {\n", header)
+
+	if kind == "enum" {
+		var constants []string
+		for _, f := range cf.Fields {
+			if f.AccessFlags&FIELD_ACC_ENUM != 0 {
+				constants = append(constants, f.NameString())
+			}
+		}
+		if w.d != nil {
+			constants = w.d.enumConstants(cf, constants)
+		}
+		w.printf(indent+1, "%s;\n", strings.Join(constants, ", "))
+	}
+	for _, f := range cf.Fields {
+		if f.AccessFlags&(FIELD_ACC_SYNTHETIC|FIELD_ACC_ENUM) == 0 && (w.d != nil || f.AccessFlags&(FIELD_ACC_PUBLIC|FIELD_ACC_PROTECTED) != 0) {
+			w.field(indent+1, cf, f, kind)
+		}
+	}
+
+	visibleCtor := false
+	for _, m := range cf.Methods {
+		if m.AccessFlags&(METHOD_ACC_SYNTHETIC|METHOD_ACC_BRIDGE) != 0 ||
+			w.d == nil && m.AccessFlags&(METHOD_ACC_PUBLIC|METHOD_ACC_PROTECTED) == 0 {
+			continue
+		}
+		if kind == "enum" && (m.NameString() == "values" || m.NameString() == "valueOf" || w.d == nil && m.NameString() == "<init>") {
+			continue
+		}
+		switch m.NameString() {
+		case "<init>":
+			visibleCtor = true
+			w.method(indent+1, cf, m, kind, name, inner)
+		case "<clinit>":
+			if w.d != nil {
+				w.d.staticInitializer(indent+1, cf, m)
+			}
+		default:
+			w.method(indent+1, cf, m, kind, name, inner)
+		}
+	}
+	// 没有可见的构造方法时声明一个 private 的，以免编译器生成 public 的默认构造方法
+	if kind == "class" && !visibleCtor && w.d == nil {
+		w.b.WriteString("\n")
+		w.printf(indent+1, "private %s() {\n", name)
+		w.body(indent+2, w.superCall(cf))
+		w.printf(indent+1, "}\n")
+	}
+
+	for _, c := range w.memberClasses(cf) {
+		w.b.WriteString("\n")
+		w.class(indent+1, w.p.Class(c.InnerClassString()), c)
+	}
+	w.printf(indent, "}\n")
+}
+
+func (w *stubWriter) field(indent int, cf *ClassFile, f *FieldInfo, kind string) {
+	w.b.WriteString("\n")
+	w.annotations(indent, f.Annotations())
+
+	var modifiers []string
+	if kind != "interface" && kind != "@interface" {
+		switch {
+		case f.AccessFlags&FIELD_ACC_PUBLIC != 0:
+			modifiers = append(modifiers, "public")
+		case f.AccessFlags&FIELD_ACC_PROTECTED != 0 || w.d == nil:
+			modifiers = append(modifiers, "protected")
+		case f.AccessFlags&FIELD_ACC_PRIVATE != 0:
+			modifiers = append(modifiers, "private")
+		}
+		if f.AccessFlags&FIELD_ACC_STATIC != 0 {
+			modifiers = append(modifiers, "static")
+		}
+		if f.AccessFlags&FIELD_ACC_FINAL != 0 {
+			modifiers = append(modifiers, "final")
+		}
+		if f.AccessFlags&FIELD_ACC_VOLATILE != 0 {
+			modifiers = append(modifiers, "volatile")
+		}
+		if f.AccessFlags&FIELD_ACC_TRANSIENT != 0 {
+			modifiers = append(modifiers, "transient")
+		}
+	}
+
+	typ := w.descriptorType(f.DescriptorString())
+	if t, err := ParseFieldSignature(f.SignatureString()); err == nil && f.SignatureString() != "" {
+		typ = w.typeName(t)
+	}
+	decl := strings.Join(append(modifiers, typ, f.NameString()), " ")
+
+	final := f.AccessFlags&FIELD_ACC_FINAL != 0 || kind == "interface" || kind == "@interface"
+	switch {
+	case f.ConstantValue() != nil && f.AccessFlags&FIELD_ACC_STATIC != 0:
+		decl += " = " + javaLiteral(f.ConstantValue(), f.cp, f.DescriptorString())
+	case w.d != nil:
+		// 反编译时其余初值在构造方法或静态初始化块中
+	case final && stubDefaultValues[f.DescriptorString()[0]] != "":
+		decl += " = " + stubDefaultValues[f.DescriptorString()[0]]
+	case final:
+		decl += " = null"
+	}
+	w.printf(indent, "%s;\n", decl)
+}
+
+// 方法参数名：优先取 MethodParameters，名称缺失或不是合法标识符时用 argN
+func methodParameterNames(cf *ClassFile, m *MethodInfo, count int) []string {
+	var names []string
+	if attr := m.Attribute("MethodParameters"); attr != nil && len(attr.Info) > 0 {
+		n := int(attr.Info[0])
+		for i := 0; i < n && 1+4*i+4 <= len(attr.Info); i++ {
+			index := binary.BigEndian.Uint16(attr.Info[1+4*i:])
+			name := ""
+			if index != 0 {
+				name = cf.Utf8At(index)
+			}
+			names = append(names, name)
+		}
+	}
+	// 内部类构造方法的描述符多出外部类实例参数
+	if len(names) > count {
+		names = names[len(names)-count:]
+	}
+
+	rs := make([]string, count)
+	seen := make(map[string]bool)
+	for i := range rs {
+		if i < len(names) && !strings.Contains(names[i], ".") && validModuleName(names[i]) && !seen[names[i]] {
+			rs[i] = names[i]
+		} else {
+			rs[i] = "arg" + strconv.Itoa(i)
+		}
+		seen[rs[i]] = true
+	}
+	return rs
+}
+
+// 调用父类构造方法的语句：父类有可访问的无参构造方法或不在 classpath 上时为空，
+// 否则以参数最少的可访问构造方法和默认值实参调用
+func (w *stubWriter) superCall(cf *ClassFile) string {
+	sc := w.p.Class(cf.SuperClassString())
+	if sc == nil {
+		return ""
+	}
+	samePackage := PackageOf(sc.ThisClassString()) == PackageOf(cf.ThisClassString())
+	var best *MethodSignature
+	for _, m := range sc.Methods {
+		if m.NameString() != "<init>" || m.AccessFlags&METHOD_ACC_PRIVATE != 0 ||
+			m.AccessFlags&(METHOD_ACC_PUBLIC|METHOD_ACC_PROTECTED) == 0 && !samePackage {
+			continue
+		}
+		sig, err := ParseMethodSignature(m.DescriptorString())
+		if err != nil {
+			continue
+		}
+		if best == nil || len(sig.Parameters) < len(best.Parameters) {
+			best = sig
+		}
+	}
+	if best == nil || len(best.Parameters) == 0 {
+		return ""
+	}
+	args := make([]string, len(best.Parameters))
+	for i, t := range best.Parameters {
+		if arg, ok := stubArguments[t.Kind]; ok {
+			args[i] = arg
+		} else {
+			args[i] = "(" + w.typeName(t) + ") null"
+		}
+	}
+	return "super(" + strings.Join(args, ", ") + ");"
+}
+
+func (w *stubWriter) body(indent int, statements ...string) {
+	for _, s := range statements {
+		if s != "" {
+			w.printf(indent, "%s\n", s)
+		}
+	}
+	w.printf(indent, "throw new UnsupportedOperationException();\n")
+}
+
+func (w *stubWriter) method(indent int, cf *ClassFile, m *MethodInfo, kind, className string, inner *InnerClass) {
+	desc, err := ParseMethodSignature(m.DescriptorString())
+	if err != nil {
+		return
+	}
+	ctor := m.NameString() == "<init>"
+	params, result, throws := desc.Parameters, desc.Result, []*TypeSignature(nil)
+	for _, e := range m.ExceptionStrings() {
+		throws = append(throws, &TypeSignature{Kind: 'L', Name: e})
+	}
+	// 非静态内部类的构造方法描述符以外部类实例开头
+	if ctor && inner != nil && inner.InnerClassAccessFlags&stubStatic == 0 && kind == "class" && len(params) > 0 &&
+		params[0].Kind == 'L' && params[0].Name == inner.OuterClassString() {
+		params = params[1:]
+	}
+	// 枚举构造方法的描述符以常量名与序号开头
+	if ctor && kind == "enum" && len(params) >= 2 && params[0].Name == "java/lang/String" && params[1].Kind == 'I' {
+		params = params[2:]
+	}
+	var typeParams []*TypeParameter
+	if sig, err := ParseMethodSignature(m.SignatureString()); err == nil && m.SignatureString() != "" && len(sig.Parameters) == len(params) {
+		typeParams, params, result = sig.TypeParameters, sig.Parameters, sig.Result
+		if len(sig.Throws) > 0 {
+			throws = sig.Throws
+		}
+	}
+
+	w.b.WriteString("\n")
+	w.annotations(indent, m.Annotations())
+
+	static := m.AccessFlags&METHOD_ACC_STATIC != 0
+	abstract := m.AccessFlags&METHOD_ACC_ABSTRACT != 0
+	var modifiers []string
+	switch kind {
+	case "@interface":
+	case "interface":
+		private := m.AccessFlags&METHOD_ACC_PRIVATE != 0
+		if private {
+			modifiers = append(modifiers, "private")
+		}
+		switch {
+		case static:
+			modifiers = append(modifiers, "static")
+		case !abstract && !private:
+			modifiers = append(modifiers, "default")
+		}
+	default:
+		switch {
+		case m.AccessFlags&METHOD_ACC_PUBLIC != 0:
+			modifiers = append(modifiers, "public")
+		case m.AccessFlags&METHOD_ACC_PROTECTED != 0 || w.d == nil:
+			modifiers = append(modifiers, "protected")
+		case m.AccessFlags&METHOD_ACC_PRIVATE != 0:
+			modifiers = append(modifiers, "private")
+		}
+		// 枚举常量没有类体，抽象方法改为普通方法
+		if abstract && (kind != "enum" || w.d != nil) {
+			modifiers = append(modifiers, "abstract")
+		}
+		if static {
+			modifiers = append(modifiers, "static")
+		}
+		if m.AccessFlags&METHOD_ACC_FINAL != 0 {
+			modifiers = append(modifiers, "final")
+		}
+		if w.d != nil && m.AccessFlags&METHOD_ACC_SYNCHRONIZED != 0 {
+			modifiers = append(modifiers, "synchronized")
+		}
+		if w.d != nil && m.AccessFlags&METHOD_ACC_NATIVE != 0 {
+			modifiers = append(modifiers, "native")
+		}
+	}
+	if tps := w.typeParameters(typeParams); tps != "" {
+		modifiers = append(modifiers, tps)
+	}
+	if ctor {
+		modifiers = append(modifiers, className)
+	} else {
+		modifiers = append(modifiers, w.typeName(result), m.NameString())
+	}
+
+	var names []string
+	if w.d != nil {
+		names = w.d.parameterNames(cf, m, len(params))
+	} else {
+		names = methodParameterNames(cf, m, len(params))
+	}
+	paramAnnotations := m.ParameterAnnotations()
+	if len(paramAnnotations) > len(params) {
+		paramAnnotations = paramAnnotations[len(paramAnnotations)-len(params):]
+	}
+	decls := make([]string, len(params))
+	for i, t := range params {
+		typ := w.typeName(t)
+		if i == len(params)-1 && m.AccessFlags&METHOD_ACC_VARARGS != 0 && strings.HasSuffix(typ, "[]") {
+			typ = typ[:len(typ)-2] + "..."
+		}
+		decls[i] = typ + " " + names[i]
+		if i < len(paramAnnotations) {
+			for j := len(paramAnnotations[i]) - 1; j >= 0; j-- {
+				decls[i] = w.annotation(paramAnnotations[i][j]) + " " + decls[i]
+			}
+		}
+	}
+	decl := strings.Join(modifiers, " ") + "(" + strings.Join(decls, ", ") + ")"
+	if len(throws) > 0 {
+		names := make([]string, len(throws))
+		for i, t := range throws {
+			names[i] = w.typeName(t)
+		}
+		decl += " throws " + strings.Join(names, ", ")
+	}
+
+	switch {
+	case kind == "@interface":
+		if attr := m.Attribute("AnnotationDefault"); attr != nil && attr.DefaultValue != nil {
+			decl += " default " + w.elementValue(attr.DefaultValue)
+		}
+		w.printf(indent, "%s;\n", decl)
+	case abstract && (kind != "enum" || w.d != nil) || w.d != nil && m.AccessFlags&METHOD_ACC_NATIVE != 0:
+		w.printf(indent, "%s;\n", decl)
+	default:
+		w.printf(indent, "%s {\n", decl)
+		if w.d != nil {
+			w.d.methodBody(indent+1, cf, m)
+		} else if ctor && kind == "class" {
+			w.body(indent+1, w.superCall(cf))
+		} else {
+			w.body(indent + 1)
+		}
+		w.printf(indent, "}\n")
+	}
+}
+
+// 生成顶层类 cf 的 Java 存根源码：包声明、修饰符、泛型、注解、throws、常量值与公开的成员类，
+// 只包含 public 与 protected 的成员，方法体抛出 UnsupportedOperationException。
+// 类型一律写全限定名，成员类、父类构造方法等从 p 中查找。
+// 不处理 sealed/permits 与类型注解，父类是非静态内部类时生成的源码可能无法编译
+func (p *ClassPath) JavaStub(cf *ClassFile) string {
+	w := &stubWriter{
+		p:        p,
+		b:        &bytes.Buffer{},
+		inners:   make(map[string]*InnerClass),
+		names:    make(map[string]string),
+		visiting: make(map[string]bool),
+	}
+	if pkg := PackageOf(cf.ThisClassString()); pkg != "" {
+		fmt.Fprintf(w.b, "package %s;\n\n", strings.Replace(pkg, "/", ".", -1))
+	}
+	w.class(0, cf, nil)
+	return w.b.String()
+}
+
+// jar 中每个 public 顶层类的存根，键为源文件路径，如 com/acme/Foo.java。
+// 成员类写在外部类的源文件中；module-info、package-info 与合成类不生成
+func (p *ClassPath) JavaStubs(jar *Jar) map[string]string {
+	rs := make(map[string]string)
+	for _, name := range jar.ClassNames() {
+		cf := jar.Class(name)
+		if !cf.IsPublic() || cf.IsSynthetic() || cf.InnerClassEntry() != nil ||
+			name == "module-info" || strings.HasSuffix(name, "/package-info") || name == "package-info" {
+			continue
+		}
+		rs[name+".java"] = p.JavaStub(cf)
+	}
+	return rs
+}
@@ -1,7 +1,9 @@
 package jclass
 
 import (
+	"bytes"
 	"io"
+	"unicode/utf16"
 	"unsafe"
 )
 
@@ -30,3 +32,41 @@ func readEnoughBytes(r io.Reader, buf []byte, n int) ([]byte, error) {
 	copy(info, buf)
 	return info, nil
 }
+
+// 读取 n 个字节。n 来自类文件中的长度字段，可能远大于实际剩余的数据，
+// 较大时分块读取，避免截断或损坏的输入先分配大块内存
+func readBytes(r io.Reader, n int) ([]byte, error) {
+	const chunk = 1 << 16
+	if n <= chunk {
+		rs := make([]byte, n)
+		if _, err := io.ReadFull(r, rs); err != nil {
+			return nil, err
+		}
+		return rs, nil
+	}
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// 按 JVMS 4.4.7 的 modified UTF-8 编码字符串：\u0000 编码为两个字节，
+// 增补字符先拆分为 UTF-16 代理对再逐个编码
+func encodeModifiedUTF8(s string) []byte {
+	rs := make([]byte, 0, len(s))
+	for _, c := range utf16.Encode([]rune(s)) {
+		switch {
+		case c != 0 && c < 0x80:
+			rs = append(rs, byte(c))
+		case c < 0x800:
+			rs = append(rs, byte(0xC0|c>>6), byte(0x80|c&0x3F))
+		default:
+			rs = append(rs, byte(0xE0|c>>12), byte(0x80|(c>>6)&0x3F), byte(0x80|c&0x3F))
+		}
+	}
+	return rs
+}
@@ -0,0 +1,894 @@
+package jclass
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 类文件格式检查（JVMS 4.8）发现的问题
+type FormatProblem struct {
+	// 出错的位置，如 constant pool #12、method run(I)V attribute Code
+	Location string
+	Reason   string
+}
+
+func (p *FormatProblem) Error() string {
+	return p.Location + ": " + p.Reason
+}
+
+// 各种常量池项 Info 的固定长度；CONSTANT_Utf8 为变长
+var constantInfoSizes = map[uint8]int{
+	3: 4, 4: 4, 5: 8, 6: 8, 7: 2, 8: 2, 9: 4, 10: 4, 11: 4, 12: 4,
+	15: 3, 16: 2, 17: 4, 18: 4, 19: 2, 20: 2,
+}
+
+// 常量池项最早出现的主版本号
+var constantMinVersions = map[uint8]uint16{15: 51, 16: 51, 18: 51, 17: 55, 19: 53, 20: 53}
+
+// 可以出现预定义属性的位置；未列出的属性不检查
+var attributeContexts = map[string]string{
+	"ConstantValue":                        "field",
+	"Code":                                 "method",
+	"StackMapTable":                        "code",
+	"Exceptions":                           "method",
+	"InnerClasses":                         "class",
+	"EnclosingMethod":                      "class",
+	"Synthetic":                            "class field method",
+	"Signature":                            "class field method record",
+	"SourceFile":                           "class",
+	"SourceDebugExtension":                 "class",
+	"LineNumberTable":                      "code",
+	"LocalVariableTable":                   "code",
+	"LocalVariableTypeTable":               "code",
+	"Deprecated":                           "class field method",
+	"RuntimeVisibleAnnotations":            "class field method record",
+	"RuntimeInvisibleAnnotations":          "class field method record",
+	"RuntimeVisibleParameterAnnotations":   "method",
+	"RuntimeInvisibleParameterAnnotations": "method",
+	"RuntimeVisibleTypeAnnotations":        "class field method code record",
+	"RuntimeInvisibleTypeAnnotations":      "class field method code record",
+	"AnnotationDefault":                    "method",
+	"BootstrapMethods":                     "class",
+	"MethodParameters":                     "method",
+	"Module":                               "class",
+	"ModulePackages":                       "class",
+	"ModuleMainClass":                      "class",
+	"NestHost":                             "class",
+	"NestMembers":                          "class",
+	"Record":                               "class",
+	"PermittedSubclasses":                  "class",
+}
+
+// 由 formatValidator.attribute 逐项检查常量池项类型的属性，其余属性只检查索引范围
+var typedAttributes = map[string]bool{
+	"ConstantValue": true, "SourceFile": true, "Signature": true, "NestHost": true, "ModuleMainClass": true,
+	"Exceptions": true, "NestMembers": true, "PermittedSubclasses": true, "ModulePackages": true,
+	"InnerClasses": true, "EnclosingMethod": true, "LocalVariableTable": true, "LocalVariableTypeTable": true,
+	"MethodParameters": true, "BootstrapMethods": true,
+}
+
+// 同一结构中可以出现多次的预定义属性
+var repeatableAttributes = map[string]bool{
+	"LineNumberTable": true, "LocalVariableTable": true, "LocalVariableTypeTable": true,
+	"Synthetic": true, "Deprecated": true,
+}
+
+type formatValidator struct {
+	cf       *ClassFile
+	problems []*FormatProblem
+	// 自身格式错误的常量池项，引用它们时不再重复报告
+	broken map[uint16]bool
+}
+
+func (v *formatValidator) report(location, format string, args ...interface{}) {
+	v.problems = append(v.problems, &FormatProblem{Location: location, Reason: fmt.Sprintf(format, args...)})
+}
+
+// 常量池第 index 项，下标越界、为空、格式错误或类型不是 tags 之一时返回 nil
+func (v *formatValidator) entry(index uint16, tags ...uint8) *ConstantPoolInfo {
+	if int(index) >= len(v.cf.ConstantPool) || v.broken[index] {
+		return nil
+	}
+	info := v.cf.ConstantPool[index]
+	if info == nil {
+		return nil
+	}
+	for _, tag := range tags {
+		if info.Tag == tag {
+			return info
+		}
+	}
+	return nil
+}
+
+// 检查常量池索引指向 tags 类型的项；optional 时 0 表示无引用
+func (v *formatValidator) ref(location string, index uint16, optional bool, tags ...uint8) bool {
+	if index == 0 && optional {
+		return true
+	}
+	if v.entry(index, tags...) != nil {
+		return true
+	}
+	if !v.broken[index] {
+		v.report(location, "constant pool index #%d is not a %s", index, constantTagNames(tags))
+	}
+	return false
+}
+
+func (v *formatValidator) utf8(index uint16) (string, bool) {
+	if info := v.entry(index, 1); info != nil {
+		return (*ConstantUtf8Info)(info).Utf8(), true
+	}
+	return "", false
+}
+
+var constantNames = map[uint8]string{
+	1: "Utf8", 3: "Integer", 4: "Float", 5: "Long", 6: "Double", 7: "Class", 8: "String",
+	9: "Fieldref", 10: "Methodref", 11: "InterfaceMethodref", 12: "NameAndType",
+	15: "MethodHandle", 16: "MethodType", 17: "Dynamic", 18: "InvokeDynamic", 19: "Module", 20: "Package",
+}
+
+func constantTagNames(tags []uint8) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = "CONSTANT_" + constantNames[tag]
+	}
+	return strings.Join(names, " or ")
+}
+
+// 按 JVMS 4.8 检查类文件格式：常量池索引的范围与类型、名称与描述符、访问标志组合、属性长度、
+// 重复的字段与方法、必需的属性。返回发现的全部问题，没有问题时返回 nil。
+// 不检查字节码本身，字节码由 ClassPath.Verify 校验。NewClassFile 无法解析的字节用 ValidateClassBytes 检查
+func (cf *ClassFile) Validate() []*FormatProblem {
+	v := &formatValidator{cf: cf, broken: make(map[uint16]bool)}
+	if cf.Magic != MAGIC {
+		v.report("class", "bad magic 0x%08X", cf.Magic)
+	}
+	if cf.MajorVersion < 45 {
+		v.report("class", "unsupported class file version %d.%d", cf.MajorVersion, cf.MinorVersion)
+	}
+	v.constantPool()
+	v.class()
+	for _, field := range cf.Fields {
+		v.field(field)
+	}
+	for _, method := range cf.Methods {
+		v.method(method)
+	}
+	v.attributes("class", cf.Attributes, "class")
+	v.classAttributes()
+	return v.problems
+}
+
+// 从字节解析并检查类文件。NewClassFile 无法解析的类（常量池 tag 无效、属性名索引无效、
+// 已知属性被截断等）没有 ClassFile 可供 Validate 检查，解析错误作为唯一的问题返回
+func ValidateClassBytes(data []byte) []*FormatProblem {
+	r := bytes.NewReader(data)
+	cf, err := NewClassFile(r)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("truncated class file")
+		}
+		return []*FormatProblem{{Location: "class", Reason: err.Error()}}
+	}
+	problems := cf.Validate()
+	if r.Len() > 0 {
+		problems = append(problems, &FormatProblem{Location: "class", Reason: fmt.Sprintf("%d extra bytes after the class file", r.Len())})
+	}
+	return problems
+}
+
+func (v *formatValidator) constantPool() {
+	cp := v.cf.ConstantPool
+	if int(v.cf.ConstantPoolCount) != len(cp) {
+		v.report("constant pool", "constant_pool_count %d does not match %d entries", v.cf.ConstantPoolCount, len(cp))
+	}
+	if len(cp) > 0 && cp[0] != nil {
+		v.report("constant pool #0", "entry 0 must be unused")
+	}
+
+	// 先检查各项自身，再检查项之间的引用
+	for i := 1; i < len(cp); i++ {
+		location := fmt.Sprintf("constant pool #%d", i)
+		info := cp[i]
+		if info == nil {
+			if prev := cp[i-1]; prev == nil || prev.Tag != 5 && prev.Tag != 6 {
+				v.report(location, "missing entry")
+			}
+			continue
+		}
+		size, ok := constantInfoSizes[info.Tag]
+		switch {
+		case info.Tag == 1:
+			ok = len(info.Info) >= 2 && int(binary.BigEndian.Uint16(info.Info)) == len(info.Info)-2
+			size = len(info.Info)
+		case !ok:
+			v.report(location, "invalid tag %d", info.Tag)
+			v.broken[uint16(i)] = true
+			continue
+		}
+		if !ok || len(info.Info) != size {
+			v.report(location, "CONSTANT_%s has %d bytes of data", constantNames[info.Tag], len(info.Info))
+			v.broken[uint16(i)] = true
+			continue
+		}
+		if min := constantMinVersions[info.Tag]; v.cf.MajorVersion < min {
+			v.report(location, "CONSTANT_%s requires class file version %d or later", constantNames[info.Tag], min)
+		}
+		if info.Tag == 1 {
+			// 修改版 UTF-8 不含 0 字节与 0xF0 及以上的字节
+			for _, b := range info.Info[2:] {
+				if b == 0 || b >= 0xF0 {
+					v.report(location, "invalid modified UTF-8 byte 0x%02X", b)
+					break
+				}
+			}
+		}
+		if (info.Tag == 5 || info.Tag == 6) && (i+1 >= len(cp) || cp[i+1] != nil) {
+			v.report(location, "CONSTANT_%s must be followed by an unused entry", constantNames[info.Tag])
+		}
+	}
+
+	// 属性名可能无效，不能用 ClassFile.BootstrapMethods
+	bootstraps := 0
+	if attr := v.named(v.cf.Attributes, "BootstrapMethods"); attr != nil {
+		bootstraps = len(attr.BootstrapMethods)
+	}
+	for i := 1; i < len(cp); i++ {
+		info := cp[i]
+		if info == nil || v.broken[uint16(i)] {
+			continue
+		}
+		location := fmt.Sprintf("constant pool #%d", i)
+		u2 := func(off int) uint16 {
+			return binary.BigEndian.Uint16(info.Info[off:])
+		}
+		switch info.Tag {
+		case 7:
+			if v.ref(location, u2(0), false, 1) {
+				name, _ := v.utf8(u2(0))
+				if !validClassName(name) && !(strings.HasPrefix(name, "[") && validFieldDescriptor(name)) {
+					v.report(location, "invalid class name %q", name)
+				}
+			}
+		case 8:
+			v.ref(location, u2(0), false, 1)
+		case 9, 10, 11:
+			v.ref(location, u2(0), false, 7)
+			if v.ref(location, u2(2), false, 12) {
+				v.memberRef(location, info.Tag, u2(2))
+			}
+		case 12:
+			v.ref(location, u2(0), false, 1)
+			v.ref(location, u2(2), false, 1)
+		case 15:
+			v.methodHandle(location, info.Info[0], u2(1))
+		case 16:
+			if v.ref(location, u2(0), false, 1) {
+				if desc, _ := v.utf8(u2(0)); !validMethodDescriptor(desc) {
+					v.report(location, "invalid method descriptor %q", desc)
+				}
+			}
+		case 17, 18:
+			if int(u2(0)) >= bootstraps {
+				v.report(location, "bootstrap method index %d out of range (%d bootstrap methods)", u2(0), bootstraps)
+			}
+			if v.ref(location, u2(2), false, 12) {
+				name, desc := v.nameAndType(u2(2))
+				if !validUnqualifiedName(name, info.Tag == 18) {
+					v.report(location, "invalid name %q", name)
+				}
+				if info.Tag == 17 && !validFieldDescriptor(desc) {
+					v.report(location, "invalid field descriptor %q", desc)
+				}
+				if info.Tag == 18 && !validMethodDescriptor(desc) {
+					v.report(location, "invalid method descriptor %q", desc)
+				}
+			}
+		case 19, 20:
+			v.ref(location, u2(0), false, 1)
+			if v.cf.AccessFlags&CLASS_ACC_MODULE == 0 {
+				v.report(location, "CONSTANT_%s outside of module-info", constantNames[info.Tag])
+			}
+		}
+	}
+}
+
+// NameAndType 项的名称与描述符；调用前已确认 index 指向 NameAndType
+func (v *formatValidator) nameAndType(index uint16) (name, descriptor string) {
+	info := v.cf.ConstantPool[index]
+	name, _ = v.utf8(binary.BigEndian.Uint16(info.Info))
+	descriptor, _ = v.utf8(binary.BigEndian.Uint16(info.Info[2:]))
+	return
+}
+
+// Fieldref、Methodref 与 InterfaceMethodref 的名称与描述符
+func (v *formatValidator) memberRef(location string, tag uint8, nat uint16) {
+	name, desc := v.nameAndType(nat)
+	if tag == 9 {
+		if !validUnqualifiedName(name, false) {
+			v.report(location, "invalid field name %q", name)
+		}
+		if !validFieldDescriptor(desc) {
+			v.report(location, "invalid field descriptor %q", desc)
+		}
+		return
+	}
+	if !validMethodDescriptor(desc) {
+		v.report(location, "invalid method descriptor %q", desc)
+	}
+	switch {
+	case tag == 10 && name == "<init>":
+		if !strings.HasSuffix(desc, ")V") {
+			v.report(location, "<init> must return void")
+		}
+	case !validUnqualifiedName(name, true):
+		v.report(location, "invalid method name %q", name)
+	}
+}
+
+// JVMS 4.4.8：reference_kind 决定被引用项的类型与方法名
+func (v *formatValidator) methodHandle(location string, kind uint8, index uint16) {
+	var tags []uint8
+	switch kind {
+	case 1, 2, 3, 4:
+		tags = []uint8{9}
+	case 5, 8:
+		tags = []uint8{10}
+	case 6, 7:
+		tags = []uint8{10}
+		if v.cf.MajorVersion >= 52 {
+			tags = append(tags, 11)
+		}
+	case 9:
+		tags = []uint8{11}
+	default:
+		v.report(location, "invalid method handle kind %d", kind)
+		return
+	}
+	if !v.ref(location, index, false, tags...) || kind <= 4 {
+		return
+	}
+	nat := binary.BigEndian.Uint16(v.cf.ConstantPool[index].Info[2:])
+	if v.entry(nat, 12) == nil {
+		return
+	}
+	if name, _ := v.nameAndType(nat); kind == 8 && name != "<init>" {
+		v.report(location, "newInvokeSpecial method handle must refer to <init>, not %s", name)
+	} else if kind != 8 && (name == "<init>" || name == "<clinit>") {
+		v.report(location, "%s method handle cannot refer to %s", methodHandleKinds[kind], name)
+	}
+}
+
+func (v *formatValidator) className(index uint16) string {
+	if info := v.entry(index, 7); info != nil {
+		name, _ := v.utf8(binary.BigEndian.Uint16(info.Info))
+		return name
+	}
+	return ""
+}
+
+func (v *formatValidator) class() {
+	cf := v.cf
+	flags := cf.AccessFlags
+	v.ref("this_class", cf.ThisClass, false, 7)
+	self := v.className(cf.ThisClass)
+
+	if flags&CLASS_ACC_MODULE != 0 {
+		if flags != CLASS_ACC_MODULE {
+			v.report("class", "module-info must have only ACC_MODULE set, found 0x%04X", uint16(flags))
+		}
+		if self != "module-info" {
+			v.report("this_class", "module class must be named module-info, not %q", self)
+		}
+		if cf.SuperClass != 0 || len(cf.Interfaces) > 0 || len(cf.Fields) > 0 || len(cf.Methods) > 0 {
+			v.report("class", "module-info must not have a superclass, interfaces, fields or methods")
+		}
+		if v.named(cf.Attributes, "Module") == nil {
+			v.report("class", "missing Module attribute")
+		}
+		return
+	}
+
+	interfaceFlag := flags&CLASS_ACC_INTERFACE != 0
+	switch {
+	case interfaceFlag && (flags&CLASS_ACC_ABSTRACT == 0 || flags&(CLASS_ACC_FINAL|CLASS_ACC_SUPER|CLASS_ACC_ENUM) != 0):
+		v.report("class", "illegal interface access flags 0x%04X", uint16(flags))
+	case !interfaceFlag && flags&CLASS_ACC_ANNOTATION != 0:
+		v.report("class", "ACC_ANNOTATION requires ACC_INTERFACE")
+	case !interfaceFlag && flags&CLASS_ACC_FINAL != 0 && flags&CLASS_ACC_ABSTRACT != 0:
+		v.report("class", "class cannot be both final and abstract")
+	}
+
+	if cf.SuperClass == 0 {
+		if self != "java/lang/Object" {
+			v.report("super_class", "only java/lang/Object may have no superclass")
+		}
+	} else if v.ref("super_class", cf.SuperClass, false, 7) {
+		super := v.className(cf.SuperClass)
+		if strings.HasPrefix(super, "[") {
+			v.report("super_class", "superclass cannot be an array type %s", super)
+		}
+		if interfaceFlag && super != "java/lang/Object" {
+			v.report("super_class", "superclass of an interface must be java/lang/Object, not %s", super)
+		}
+	}
+
+	if int(cf.InterfaceCount) != len(cf.Interfaces) {
+		v.report("interfaces", "interfaces_count %d does not match %d interfaces", cf.InterfaceCount, len(cf.Interfaces))
+	}
+	seen := make(map[string]bool)
+	for _, iface := range cf.Interfaces {
+		if !v.ref("interfaces", iface, false, 7) {
+			continue
+		}
+		name := v.className(iface)
+		if seen[name] {
+			v.report("interfaces", "duplicate interface %s", name)
+		}
+		seen[name] = true
+	}
+
+	if int(cf.FieldsCount) != len(cf.Fields) {
+		v.report("fields", "fields_count %d does not match %d fields", cf.FieldsCount, len(cf.Fields))
+	}
+	if int(cf.MethodsCount) != len(cf.Methods) {
+		v.report("methods", "methods_count %d does not match %d methods", cf.MethodsCount, len(cf.Methods))
+	}
+	if int(cf.AttributesCount) != len(cf.Attributes) {
+		v.report("class", "attributes_count %d does not match %d attributes", cf.AttributesCount, len(cf.Attributes))
+	}
+}
+
+// 成员的位置描述；名称或描述符无效时用常量池索引代替
+func (v *formatValidator) member(kind string, nameIndex, descIndex uint16) (location, name, desc string) {
+	name, ok := v.utf8(nameIndex)
+	if !ok {
+		name = fmt.Sprintf("#%d", nameIndex)
+	}
+	desc, ok = v.utf8(descIndex)
+	if !ok {
+		desc = fmt.Sprintf("#%d", descIndex)
+	}
+	if kind == "field" {
+		return "field " + name + ":" + desc, name, desc
+	}
+	return "method " + name + desc, name, desc
+}
+
+// 访问标志中可见性标志的个数
+func visibilityCount(flags uint16) int {
+	n := 0
+	for _, f := range []uint16{0x0001, 0x0002, 0x0004} {
+		if flags&f != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (v *formatValidator) field(field *FieldInfo) {
+	location, name, desc := v.member("field", field.NameIndex, field.DescriptorIndex)
+	if v.ref(location, field.NameIndex, false, 1) && !validUnqualifiedName(name, false) {
+		v.report(location, "invalid field name %q", name)
+	}
+	if v.ref(location, field.DescriptorIndex, false, 1) && !validFieldDescriptor(desc) {
+		v.report(location, "invalid field descriptor %q", desc)
+	}
+
+	flags := field.AccessFlags
+	if visibilityCount(uint16(flags)) > 1 {
+		v.report(location, "more than one of public, private and protected")
+	}
+	if flags&FIELD_ACC_FINAL != 0 && flags&FIELD_ACC_VOLATILE != 0 {
+		v.report(location, "field cannot be both final and volatile")
+	}
+	if v.cf.IsInterface() {
+		const required = FIELD_ACC_PUBLIC | FIELD_ACC_STATIC | FIELD_ACC_FINAL
+		if flags&required != required || flags&^(required|FIELD_ACC_SYNTHETIC) != 0 {
+			v.report(location, "interface field must be public static final, found 0x%04X", uint16(flags))
+		}
+	}
+
+	for _, other := range v.cf.Fields {
+		if other == field {
+			break
+		}
+		if other.NameIndex == field.NameIndex && other.DescriptorIndex == field.DescriptorIndex ||
+			v.sameMember(other.NameIndex, other.DescriptorIndex, name, desc) {
+			v.report(location, "duplicate field")
+			break
+		}
+	}
+
+	v.attributes(location, field.Attributes, "field")
+	if attr := v.named(field.Attributes, "ConstantValue"); attr != nil && len(attr.Info) == 2 {
+		index := binary.BigEndian.Uint16(attr.Info)
+		var tags []uint8
+		switch desc {
+		case "I", "S", "C", "B", "Z":
+			tags = []uint8{3}
+		case "F":
+			tags = []uint8{4}
+		case "J":
+			tags = []uint8{5}
+		case "D":
+			tags = []uint8{6}
+		case "Ljava/lang/String;":
+			tags = []uint8{8}
+		default:
+			v.report(location+" attribute ConstantValue", "field of type %s cannot have a constant value", desc)
+			return
+		}
+		v.ref(location+" attribute ConstantValue", index, false, tags...)
+	}
+}
+
+func (v *formatValidator) sameMember(nameIndex, descIndex uint16, name, desc string) bool {
+	n, ok1 := v.utf8(nameIndex)
+	d, ok2 := v.utf8(descIndex)
+	return ok1 && ok2 && n == name && d == desc
+}
+
+func (v *formatValidator) method(m *MethodInfo) {
+	location, name, desc := v.member("method", m.NameIndex, m.DescriptorIndex)
+	flags := m.AccessFlags
+	static := flags&METHOD_ACC_STATIC != 0
+	major := v.cf.MajorVersion
+	interfaceFlag := v.cf.IsInterface()
+
+	if v.ref(location, m.NameIndex, false, 1) && name != "<init>" && name != "<clinit>" && !validUnqualifiedName(name, true) {
+		v.report(location, "invalid method name %q", name)
+	}
+	if v.ref(location, m.DescriptorIndex, false, 1) {
+		slots, ok := methodDescriptorSlots(desc)
+		if !static {
+			slots++
+		}
+		switch {
+		case !ok:
+			v.report(location, "invalid method descriptor %q", desc)
+		case slots > 255:
+			v.report(location, "parameters take %d local variable slots, more than 255", slots)
+		case name == "<init>" && !strings.HasSuffix(desc, ")V"):
+			v.report(location, "<init> must return void")
+		case name == "<clinit>" && desc != "()V" && major >= 51:
+			v.report(location, "<clinit> must have descriptor ()V")
+		}
+	}
+
+	const visibility = METHOD_ACC_PUBLIC | METHOD_ACC_PRIVATE | METHOD_ACC_PROTECTED
+	switch {
+	case name == "<clinit>":
+		if major >= 51 && !static {
+			v.report(location, "<clinit> must be static")
+		}
+	case visibilityCount(uint16(flags)) > 1:
+		v.report(location, "more than one of public, private and protected")
+	case name == "<init>" && interfaceFlag:
+		v.report(location, "interface cannot declare <init>")
+	case name == "<init>" && flags&^(visibility|METHOD_ACC_VARARGS|METHOD_ACC_STRICT|METHOD_ACC_SYNTHETIC) != 0:
+		v.report(location, "illegal <init> access flags 0x%04X", uint16(flags))
+	case interfaceFlag && major < 52:
+		if flags&(METHOD_ACC_PUBLIC|METHOD_ACC_ABSTRACT) != METHOD_ACC_PUBLIC|METHOD_ACC_ABSTRACT ||
+			flags&^(METHOD_ACC_PUBLIC|METHOD_ACC_ABSTRACT|METHOD_ACC_VARARGS|METHOD_ACC_BRIDGE|METHOD_ACC_SYNTHETIC) != 0 {
+			v.report(location, "interface method must be public abstract before Java 8, found 0x%04X", uint16(flags))
+		}
+	case interfaceFlag:
+		if flags&(METHOD_ACC_PUBLIC|METHOD_ACC_PRIVATE) == 0 ||
+			flags&(METHOD_ACC_PROTECTED|METHOD_ACC_FINAL|METHOD_ACC_SYNCHRONIZED|METHOD_ACC_NATIVE) != 0 {
+			v.report(location, "illegal interface method access flags 0x%04X", uint16(flags))
+		}
+		fallthrough
+	default:
+		illegal := METHOD_ACC_PRIVATE | METHOD_ACC_STATIC | METHOD_ACC_FINAL | METHOD_ACC_SYNCHRONIZED | METHOD_ACC_NATIVE
+		if major >= 46 && major < 61 {
+			illegal |= METHOD_ACC_STRICT
+		}
+		if flags&METHOD_ACC_ABSTRACT != 0 && flags&illegal != 0 {
+			v.report(location, "abstract method cannot be private, static, final, synchronized, native or strictfp")
+		}
+	}
+
+	for _, other := range v.cf.Methods {
+		if other == m {
+			break
+		}
+		if other.NameIndex == m.NameIndex && other.DescriptorIndex == m.DescriptorIndex ||
+			v.sameMember(other.NameIndex, other.DescriptorIndex, name, desc) {
+			v.report(location, "duplicate method")
+			break
+		}
+	}
+
+	v.attributes(location, m.Attributes, "method")
+
+	codes := 0
+	for _, attr := range m.Attributes {
+		if n, _ := v.utf8(attr.NameIndex); n == "Code" {
+			codes++
+		}
+	}
+	switch {
+	case flags&(METHOD_ACC_ABSTRACT|METHOD_ACC_NATIVE) != 0:
+		if codes > 0 {
+			v.report(location, "abstract or native method must not have a Code attribute")
+		}
+	case codes == 0:
+		v.report(location, "missing Code attribute")
+	}
+	if attr := v.named(m.Attributes, "Code"); attr != nil && attr.Code != nil && codes == 1 {
+		code := attr.Code
+		if slots, ok := methodDescriptorSlots(desc); ok {
+			if !static {
+				slots++
+			}
+			if slots > int(code.MaxLocals) {
+				v.report(location+" attribute Code", "max_locals %d is less than the %d parameter slots", code.MaxLocals, slots)
+			}
+		}
+	}
+}
+
+func (v *formatValidator) classAttributes() {
+	if v.named(v.cf.Attributes, "BootstrapMethods") != nil {
+		return
+	}
+	for _, info := range v.cf.ConstantPool {
+		if info != nil && (info.Tag == 17 || info.Tag == 18) {
+			v.report("class", "missing BootstrapMethods attribute required by CONSTANT_%s", constantNames[info.Tag])
+			return
+		}
+	}
+}
+
+// 按名称查找属性，跳过名称索引无效的属性
+func (v *formatValidator) named(attrs []*AttributeInfo, name string) *AttributeInfo {
+	for _, attr := range attrs {
+		if n, ok := v.utf8(attr.NameIndex); ok && n == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// context 为 class、field、method、code 或 record
+func (v *formatValidator) attributes(location string, attrs []*AttributeInfo, context string) {
+	seen := make(map[string]bool)
+	for _, attr := range attrs {
+		name, ok := v.utf8(attr.NameIndex)
+		if !ok {
+			v.ref(location, attr.NameIndex, false, 1)
+			continue
+		}
+		at := location + " attribute " + name
+		if int(attr.Length) != len(attr.Info) {
+			v.report(at, "attribute_length %d does not match %d bytes of data", attr.Length, len(attr.Info))
+			continue
+		}
+		contexts, known := attributeContexts[name]
+		if !known {
+			// 无法识别的属性按 JVMS 4.7.1 忽略
+			continue
+		}
+		if !strings.Contains(contexts, context) {
+			v.report(at, "not allowed in a %s", context)
+			continue
+		}
+		if seen[name] && !repeatableAttributes[name] {
+			v.report(at, "duplicate attribute")
+		}
+		seen[name] = true
+		if v.attributeLayout(at, name, attr) {
+			v.attribute(at, name, attr)
+		}
+	}
+}
+
+// 检查属性的结构恰好占满属性长度、其中的常量池索引都在范围内
+func (v *formatValidator) attributeLayout(location, name string, attr *AttributeInfo) (ok bool) {
+	switch name {
+	case "Code":
+		return v.codeLayout(location, attr)
+	case "LineNumberTable":
+		if len(attr.Info) < 2 || len(attr.Info) != 2+4*int(binary.BigEndian.Uint16(attr.Info)) {
+			v.report(location, "attribute length %d is inconsistent with its contents", len(attr.Info))
+			return false
+		}
+		return true
+	case "SourceDebugExtension":
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			reason := fmt.Sprint(r)
+			if e, ok := r.(refCursorError); ok {
+				reason = e.err.Error()
+			}
+			v.report(location, "%s", reason)
+			ok = false
+		}
+	}()
+	c := &refCursor{cf: v.cf, b: attr.Info, visit: func(index uint16, kind refKind, owner uint16) uint16 {
+		if !typedAttributes[name] && (int(index) >= len(v.cf.ConstantPool) || v.cf.ConstantPool[index] == nil) {
+			v.report(location, "constant pool index #%d out of range", index)
+		}
+		return index
+	}}
+	c.attribute(name)
+	if c.pos != len(attr.Info) {
+		v.report(location, "attribute length %d is inconsistent with its contents (%d bytes used)", len(attr.Info), c.pos)
+		return false
+	}
+	return true
+}
+
+func (v *formatValidator) codeLayout(location string, attr *AttributeInfo) bool {
+	code := attr.Code
+	if code == nil {
+		v.report(location, "malformed Code attribute")
+		return false
+	}
+	size := 12 + len(code.Code) + 8*len(code.ExceptionTable)
+	for _, nested := range code.Attributes {
+		size += 6 + len(nested.Info)
+	}
+	if size != len(attr.Info) {
+		v.report(location, "attribute length %d is inconsistent with its contents (%d bytes)", len(attr.Info), size)
+		return false
+	}
+	if int(code.CodeLength) != len(code.Code) || len(code.Code) == 0 || len(code.Code) >= 65536 {
+		v.report(location, "invalid code_length %d", code.CodeLength)
+	}
+	for _, e := range code.ExceptionTable {
+		if e.StartPc >= e.EndPc || int(e.EndPc) > len(code.Code) || int(e.HandlerPc) >= len(code.Code) {
+			v.report(location, "exception table entry [%d, %d) -> %d out of code range", e.StartPc, e.EndPc, e.HandlerPc)
+		}
+		v.ref(location, e.CatchType, true, 7)
+	}
+	v.attributes(location, code.Attributes, "code")
+	return true
+}
+
+// 检查属性中常量池索引的类型；调用前已确认结构完整
+func (v *formatValidator) attribute(location, name string, attr *AttributeInfo) {
+	b := attr.Info
+	u2 := func(off int) uint16 {
+		return binary.BigEndian.Uint16(b[off:])
+	}
+	switch name {
+	case "SourceFile", "Signature":
+		v.ref(location, u2(0), false, 1)
+	case "NestHost", "ModuleMainClass":
+		v.ref(location, u2(0), false, 7)
+	case "Exceptions", "NestMembers", "PermittedSubclasses", "ModulePackages":
+		tag := uint8(7)
+		if name == "ModulePackages" {
+			tag = 20
+		}
+		for i := 0; i < int(u2(0)); i++ {
+			v.ref(location, u2(2+2*i), false, tag)
+		}
+	case "InnerClasses":
+		for i := 0; i < int(u2(0)); i++ {
+			inner, outer, innerName := u2(2+8*i), u2(2+8*i+2), u2(2+8*i+4)
+			v.ref(location, inner, false, 7)
+			v.ref(location, outer, true, 7)
+			v.ref(location, innerName, true, 1)
+			// HotSpot 拒绝加载这两种项
+			if inner == outer {
+				v.report(location, "class %s is both outer and inner class", v.className(inner))
+			}
+			if v.cf.MajorVersion >= 51 && innerName == 0 && outer != 0 {
+				v.report(location, "anonymous class %s has a non-zero outer_class_info_index", v.className(inner))
+			}
+		}
+	case "EnclosingMethod":
+		v.ref(location, u2(0), false, 7)
+		v.ref(location, u2(2), true, 12)
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		for i := 0; i < int(u2(0)); i++ {
+			v.ref(location, u2(2+10*i+4), false, 1)
+			if v.ref(location, u2(2+10*i+6), false, 1) && name == "LocalVariableTable" {
+				if desc, _ := v.utf8(u2(2 + 10*i + 6)); !validFieldDescriptor(desc) {
+					v.report(location, "invalid local variable descriptor %q", desc)
+				}
+			}
+		}
+	case "MethodParameters":
+		for i := 0; i < int(b[0]); i++ {
+			v.ref(location, u2(1+4*i), true, 1)
+		}
+	case "BootstrapMethods":
+		for i, off := 0, 2; i < int(u2(0)); i++ {
+			v.ref(location, u2(off), false, 15)
+			n := int(u2(off + 2))
+			for j := 0; j < n; j++ {
+				v.ref(location, u2(off+4+2*j), false, 3, 4, 5, 6, 7, 8, 15, 16, 17)
+			}
+			off += 4 + 2*n
+		}
+	}
+}
+
+// JVMS 4.2.2 非限定名：非空且不含 . ; [ /，方法名还不能含 < >
+func validUnqualifiedName(name string, method bool) bool {
+	if name == "" {
+		return false
+	}
+	illegal := ".;[/"
+	if method {
+		illegal += "<>"
+	}
+	return !strings.ContainsAny(name, illegal)
+}
+
+// JVMS 4.2.1 内部形式的二进制类名，如 java/lang/Object
+func validClassName(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if !validUnqualifiedName(part, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// desc[i:] 开头的字段描述符的结束位置，不合法时返回 -1
+func fieldDescriptorEnd(desc string, i int) int {
+	start := i
+	for i < len(desc) && desc[i] == '[' {
+		i++
+	}
+	if i-start > 255 || i >= len(desc) {
+		return -1
+	}
+	switch desc[i] {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z':
+		return i + 1
+	case 'L':
+		j := strings.IndexByte(desc[i:], ';')
+		if j < 0 || !validClassName(desc[i+1:i+j]) {
+			return -1
+		}
+		return i + j + 1
+	}
+	return -1
+}
+
+func validFieldDescriptor(desc string) bool {
+	return fieldDescriptorEnd(desc, 0) == len(desc)
+}
+
+func validMethodDescriptor(desc string) bool {
+	_, ok := methodDescriptorSlots(desc)
+	return ok
+}
+
+// 方法描述符合法时返回参数占用的局部变量个数（不含 this）
+func methodDescriptorSlots(desc string) (int, bool) {
+	if !strings.HasPrefix(desc, "(") {
+		return 0, false
+	}
+	slots := 0
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		j := fieldDescriptorEnd(desc, i)
+		if j < 0 {
+			return 0, false
+		}
+		slots++
+		if desc[i] == 'J' || desc[i] == 'D' {
+			slots++
+		}
+		i = j
+	}
+	if i >= len(desc) {
+		return 0, false
+	}
+	i++
+	return slots, desc[i:] == "V" || fieldDescriptorEnd(desc, i) == len(desc)
+}
@@ -0,0 +1,1008 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// 字节码校验失败：方法中第一处不满足 JVMS 4.10.1 类型检查规则的位置
+type VerifyError struct {
+	Class string
+	// 方法名与描述符，如 run(I)V
+	Method string
+	// 出错指令的偏移，与具体指令无关时为 -1
+	Offset int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("%s.%s: %s", e.Class, e.Method, e.Reason)
+	}
+	return fmt.Sprintf("%s.%s: %d: %s", e.Class, e.Method, e.Offset, e.Reason)
+}
+
+// 校验类型，tag 与 StackMapTable 中 verification_type_info 的 tag 一致
+type vtype struct {
+	tag byte
+	// Object 的类名，数组为描述符，如 [I
+	class string
+	// Uninitialized 对应的 new 指令偏移
+	offset int
+}
+
+const (
+	vtTop byte = iota
+	vtInteger
+	vtFloat
+	vtDouble
+	vtLong
+	vtNull
+	vtUninitializedThis
+	vtObject
+	vtUninitialized
+)
+
+var (
+	vTop       = vtype{tag: vtTop}
+	vInt       = vtype{tag: vtInteger}
+	vFloat     = vtype{tag: vtFloat}
+	vDouble    = vtype{tag: vtDouble}
+	vLong      = vtype{tag: vtLong}
+	vNull      = vtype{tag: vtNull}
+	vThisInit  = vtype{tag: vtUninitializedThis}
+	vObject    = vobject("java/lang/Object")
+	vThrowable = vobject("java/lang/Throwable")
+)
+
+var vtypeNames = []string{"top", "int", "float", "double", "long", "null", "uninitializedThis"}
+
+func vobject(class string) vtype {
+	return vtype{tag: vtObject, class: class}
+}
+
+func (t vtype) String() string {
+	switch t.tag {
+	case vtObject:
+		return t.class
+	case vtUninitialized:
+		return fmt.Sprintf("uninitialized(%d)", t.offset)
+	}
+	return vtypeNames[t.tag]
+}
+
+// long 与 double 占两个局部变量或两个栈单元，第二个单元为 top
+func (t vtype) wide() bool {
+	return t.tag == vtLong || t.tag == vtDouble
+}
+
+func (t vtype) reference() bool {
+	return t.tag >= vtNull
+}
+
+func (t vtype) array() bool {
+	return t.tag == vtObject && strings.HasPrefix(t.class, "[")
+}
+
+// 字段描述符对应的校验类型；boolean、byte、char、short 都按 int 处理
+func descriptorVType(desc string) vtype {
+	switch desc[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return vInt
+	case 'F':
+		return vFloat
+	case 'J':
+		return vLong
+	case 'D':
+		return vDouble
+	case 'L':
+		return vobject(desc[1 : len(desc)-1])
+	}
+	return vobject(desc)
+}
+
+// 以类名或数组描述符为元素的数组类型
+func arrayOf(class string) string {
+	if strings.HasPrefix(class, "[") {
+		return "[" + class
+	}
+	return "[L" + class + ";"
+}
+
+// long 与 double 后补一个 top，得到按单元排列的类型
+func expandVTypes(types []vtype) []vtype {
+	var rs []vtype
+	for _, t := range types {
+		rs = append(rs, t)
+		if t.wide() {
+			rs = append(rs, vTop)
+		}
+	}
+	return rs
+}
+
+// 类型状态：局部变量表（长度为 max_locals）与操作数栈，long 与 double 都占两个单元
+type vframe struct {
+	locals []vtype
+	stack  []vtype
+	// 构造方法中尚未调用 this() 或 super()，即 JVMS 中的 flagThisUninit
+	thisUninit bool
+}
+
+func (f *vframe) clone() *vframe {
+	return &vframe{
+		locals:     append([]vtype(nil), f.locals...),
+		stack:      append([]vtype(nil), f.stack...),
+		thisUninit: f.thisUninit,
+	}
+}
+
+type verifyFailure struct {
+	offset int
+	reason string
+}
+
+type methodVerifier struct {
+	p     *ClassPath
+	cf    *ClassFile
+	m     *MethodInfo
+	code  *CodeAttribute
+	class string
+
+	instructions []*Instruction
+	at           map[int]*Instruction
+	// StackMapTable 中声明的类型状态，按偏移索引
+	frames map[int]*vframe
+
+	// 当前指令执行前的类型状态
+	f  *vframe
+	pc int
+}
+
+func (v *methodVerifier) fail(format string, args ...interface{}) {
+	panic(verifyFailure{v.pc, fmt.Sprintf(format, args...)})
+}
+
+// 校验一个方法，通过时返回 nil
+func (v *methodVerifier) verify() (err *VerifyError) {
+	v.pc = -1
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(verifyFailure)
+			if !ok {
+				// 常量池项类型不符等格式错误
+				failure = verifyFailure{v.pc, fmt.Sprint("malformed class file: ", r)}
+			}
+			err = &VerifyError{
+				Class:  v.class,
+				Method: v.m.NameString() + v.m.DescriptorString(),
+				Offset: failure.offset,
+				Reason: failure.reason,
+			}
+		}
+	}()
+
+	code := v.code
+	if len(code.Code) == 0 || len(code.Code) >= 65536 {
+		v.fail("invalid code length %d", len(code.Code))
+	}
+	instructions, decodeErr := code.Instructions()
+	if decodeErr != nil {
+		v.fail("%s", decodeErr)
+	}
+	v.instructions = instructions
+	v.at = make(map[int]*Instruction, len(instructions))
+	for _, ins := range instructions {
+		v.at[ins.Offset] = ins
+	}
+
+	initial := v.initialFrame()
+	v.stackMap(initial)
+	v.exceptionTable()
+
+	// 第一条指令之前的状态视为顺序执行而来
+	v.f = v.pad(initial)
+	fallsThrough := true
+	for _, ins := range instructions {
+		v.pc = ins.Offset
+		if frame := v.frames[ins.Offset]; frame != nil {
+			if fallsThrough {
+				v.match(v.f, frame, "stack map frame")
+			}
+			v.f = frame.clone()
+		} else if !fallsThrough {
+			v.fail("expecting a stack map frame at this location")
+		}
+		v.handlers(ins)
+		v.execute(ins)
+		fallsThrough = !ins.EndsFlow()
+	}
+	if fallsThrough {
+		v.fail("falling off the end of the code")
+	}
+	return nil
+}
+
+// 方法入口的类型状态，局部变量只包含 this 与参数，long 与 double 未展开
+func (v *methodVerifier) initialFrame() *vframe {
+	f := &vframe{}
+	if v.m.AccessFlags&METHOD_ACC_STATIC == 0 {
+		if v.m.NameString() == "<init>" && v.class != "java/lang/Object" {
+			f.locals = append(f.locals, vThisInit)
+			f.thisUninit = true
+		} else {
+			f.locals = append(f.locals, vobject(v.class))
+		}
+	}
+	for _, param := range descriptorParameters(v.m.DescriptorString()) {
+		f.locals = append(f.locals, descriptorVType(param))
+	}
+	return f
+}
+
+// 展开 long 与 double 并把局部变量补齐到 max_locals
+func (v *methodVerifier) pad(f *vframe) *vframe {
+	rs := &vframe{locals: expandVTypes(f.locals), stack: expandVTypes(f.stack), thisUninit: f.thisUninit}
+	if len(rs.locals) > int(v.code.MaxLocals) {
+		v.fail("%d local variable slots exceed max_locals %d", len(rs.locals), v.code.MaxLocals)
+	}
+	if len(rs.stack) > int(v.code.MaxStack) {
+		v.fail("%d operand stack slots exceed max_stack %d", len(rs.stack), v.code.MaxStack)
+	}
+	for len(rs.locals) < int(v.code.MaxLocals) {
+		rs.locals = append(rs.locals, vTop)
+	}
+	return rs
+}
+
+// 解码 StackMapTable，增量帧相对于前一帧（第一帧相对于方法入口）的局部变量
+func (v *methodVerifier) stackMap(initial *vframe) {
+	v.frames = make(map[int]*vframe)
+	attr := v.code.Attribute("StackMapTable")
+	if attr == nil {
+		return
+	}
+
+	b, pos := attr.Info, 0
+	u1 := func() int {
+		if pos+1 > len(b) {
+			v.fail("truncated StackMapTable")
+		}
+		pos++
+		return int(b[pos-1])
+	}
+	u2 := func() int {
+		if pos+2 > len(b) {
+			v.fail("truncated StackMapTable")
+		}
+		pos += 2
+		return int(binary.BigEndian.Uint16(b[pos-2:]))
+	}
+	types := func(n int) []vtype {
+		rs := make([]vtype, n)
+		for i := range rs {
+			switch tag := byte(u1()); tag {
+			case vtObject:
+				index := uint16(u2())
+				v.constant(index, 7)
+				rs[i] = vobject(v.cf.ClassNameAt(index))
+			case vtUninitialized:
+				offset := u2()
+				if ins := v.at[offset]; ins == nil || ins.Opcode != OP_NEW {
+					v.fail("uninitialized(%d) does not refer to a new instruction", offset)
+				}
+				rs[i] = vtype{tag: vtUninitialized, offset: offset}
+			default:
+				if tag > vtUninitialized {
+					v.fail("invalid verification type tag %d", tag)
+				}
+				rs[i] = vtype{tag: tag}
+			}
+		}
+		return rs
+	}
+
+	locals := initial.locals
+	offset := -1
+	for n := u2(); n > 0; n-- {
+		var stack []vtype
+		delta := 0
+		switch frameType := u1(); {
+		case frameType < 64:
+			delta = frameType
+		case frameType < 128:
+			delta = frameType - 64
+			stack = types(1)
+		case frameType < 247:
+			v.fail("invalid stack map frame type %d", frameType)
+		case frameType == 247:
+			delta = u2()
+			stack = types(1)
+		case frameType < 251:
+			delta = u2()
+			k := 251 - frameType
+			if k > len(locals) {
+				v.fail("chop frame removes %d locals but only %d are defined", k, len(locals))
+			}
+			locals = locals[: len(locals)-k : len(locals)-k]
+		case frameType == 251:
+			delta = u2()
+		case frameType < 255:
+			delta = u2()
+			locals = append(locals[:len(locals):len(locals)], types(frameType-251)...)
+		default:
+			delta = u2()
+			locals = types(u2())
+			stack = types(u2())
+		}
+
+		offset += delta + 1
+		v.pc = offset
+		if v.at[offset] == nil {
+			v.fail("stack map frame is not at an instruction boundary")
+		}
+		f := v.pad(&vframe{locals: locals, stack: stack})
+		for _, t := range f.locals {
+			f.thisUninit = f.thisUninit || t == vThisInit
+		}
+		v.frames[offset] = f
+	}
+	v.pc = -1
+	if pos != len(b) {
+		v.fail("StackMapTable has %d trailing bytes", len(b)-pos)
+	}
+}
+
+// 异常表中的范围与处理器都要落在指令边界上，捕获类型必须是 Throwable 的子类
+func (v *methodVerifier) exceptionTable() {
+	for _, e := range v.code.ExceptionTable {
+		start, end, handler := int(e.StartPc), int(e.EndPc), int(e.HandlerPc)
+		if start >= end || v.at[start] == nil || end > len(v.code.Code) || end < len(v.code.Code) && v.at[end] == nil {
+			v.fail("illegal exception handler range [%d, %d)", start, end)
+		}
+		if v.at[handler] == nil {
+			v.fail("exception handler %d is not at an instruction boundary", handler)
+		}
+		if e.CatchType != 0 {
+			v.constant(e.CatchType, 7)
+			if catch := vobject(v.cf.ClassNameAt(e.CatchType)); !v.assignable(catch, vThrowable) {
+				v.fail("catch type %s is not a subclass of java/lang/Throwable", catch)
+			}
+		}
+	}
+}
+
+// 位于异常处理范围内的指令：以指令执行前的局部变量与捕获的异常类型跳转到处理器
+func (v *methodVerifier) handlers(ins *Instruction) {
+	for _, e := range v.code.ExceptionTable {
+		if ins.Offset < int(e.StartPc) || ins.Offset >= int(e.EndPc) {
+			continue
+		}
+		catch := vThrowable
+		if e.CatchType != 0 {
+			catch = vobject(v.cf.ClassNameAt(e.CatchType))
+		}
+		f := &vframe{locals: v.f.locals, stack: []vtype{catch}, thisUninit: v.f.thisUninit}
+		v.target(f, int(e.HandlerPc), "exception handler")
+	}
+}
+
+// 跳转到 offset 处：必须有 StackMapTable 帧，且当前状态可以赋值给它
+func (v *methodVerifier) target(f *vframe, offset int, what string) {
+	frame := v.frames[offset]
+	if frame == nil {
+		v.fail("expecting a stack map frame at %s %d", what, offset)
+	}
+	v.match(f, frame, fmt.Sprintf("%s %d", what, offset))
+}
+
+func (v *methodVerifier) match(f, frame *vframe, what string) {
+	if len(f.stack) != len(frame.stack) {
+		v.fail("%s: operand stack has %d slots, stack map frame has %d", what, len(f.stack), len(frame.stack))
+	}
+	for i, t := range frame.locals {
+		if !v.assignable(f.locals[i], t) {
+			v.fail("%s: local %d is %s, stack map frame expects %s", what, i, f.locals[i], t)
+		}
+	}
+	for i, t := range frame.stack {
+		if !v.assignable(f.stack[i], t) {
+			v.fail("%s: stack slot %d is %s, stack map frame expects %s", what, i, f.stack[i], t)
+		}
+	}
+	if f.thisUninit && !frame.thisUninit {
+		v.fail("%s: this is uninitialized but stack map frame expects it initialized", what)
+	}
+}
+
+// JVMS 4.10.1.2 的子类型关系；接口类型与 Object 一样接受任何引用
+func (v *methodVerifier) assignable(from, to vtype) bool {
+	switch {
+	case from == to || to.tag == vtTop:
+		return true
+	case from.tag == vtNull:
+		return to.tag == vtObject
+	case from.tag == vtObject && to.tag == vtObject:
+		return v.classAssignable(from.class, to.class)
+	}
+	return false
+}
+
+// 类层次不在 classpath 上时无法判断，按可赋值处理
+func (v *methodVerifier) classAssignable(from, to string) bool {
+	switch {
+	case from == to || to == "java/lang/Object":
+		return true
+	case strings.HasPrefix(to, "["):
+		if !strings.HasPrefix(from, "[") {
+			return false
+		}
+		fe, te := descriptorVType(from[1:]), descriptorVType(to[1:])
+		if fe.tag == vtObject && te.tag == vtObject {
+			return v.classAssignable(fe.class, te.class)
+		}
+		return false
+	case strings.HasPrefix(from, "["):
+		return to == "java/lang/Cloneable" || to == "java/io/Serializable"
+	}
+	target := v.p.Class(to)
+	if target == nil || target.IsInterface() {
+		return true
+	}
+	return v.p.IsAssignable(from, to) != RESOLVE_MISSING
+}
+
+// 检查常量池第 index 项存在且类型为 tags 之一
+func (v *methodVerifier) constant(index uint16, tags ...uint8) *ConstantPoolInfo {
+	if int(index) < len(v.cf.ConstantPool) {
+		if info := v.cf.ConstantPool[index]; info != nil {
+			for _, tag := range tags {
+				if info.Tag == tag {
+					return info
+				}
+			}
+		}
+	}
+	v.fail("illegal constant pool index #%d", index)
+	return nil
+}
+
+func (v *methodVerifier) push(t vtype) {
+	v.f.stack = append(v.f.stack, t)
+	if t.wide() {
+		v.f.stack = append(v.f.stack, vTop)
+	}
+	if len(v.f.stack) > int(v.code.MaxStack) {
+		v.fail("operand stack overflow")
+	}
+}
+
+// 弹出 t 类型的值，返回栈上的实际类型
+func (v *methodVerifier) pop(t vtype) vtype {
+	n := 1
+	if t.wide() {
+		n = 2
+	}
+	stack := v.f.stack
+	if len(stack) < n {
+		v.fail("operand stack underflow")
+	}
+	actual := stack[len(stack)-n]
+	if n == 2 && stack[len(stack)-1] != vTop || !v.assignable(actual, t) {
+		v.fail("bad type on operand stack: expecting %s, found %s", t, actual)
+	}
+	v.f.stack = stack[:len(stack)-n]
+	return actual
+}
+
+// 弹出任意引用，包括 null 与未初始化的对象
+func (v *methodVerifier) popReference() vtype {
+	stack := v.f.stack
+	if len(stack) == 0 {
+		v.fail("operand stack underflow")
+	}
+	t := stack[len(stack)-1]
+	if !t.reference() {
+		v.fail("bad type on operand stack: expecting a reference, found %s", t)
+	}
+	v.f.stack = stack[:len(stack)-1]
+	return t
+}
+
+// 弹出数组或 null
+func (v *methodVerifier) popArray() vtype {
+	t := v.popReference()
+	if t.tag != vtNull && !t.array() {
+		v.fail("bad type on operand stack: expecting an array, found %s", t)
+	}
+	return t
+}
+
+func (v *methodVerifier) local(index int, wide bool) {
+	if index < 0 || wide && index+1 >= len(v.f.locals) || index >= len(v.f.locals) {
+		v.fail("local variable index %d out of range", index)
+	}
+}
+
+// xload，kind 为 IJFDA 之一
+func (v *methodVerifier) load(index int, kind byte) {
+	if kind == 'A' {
+		v.local(index, false)
+		t := v.f.locals[index]
+		if !t.reference() {
+			v.fail("bad local variable type: expecting a reference in local %d, found %s", index, t)
+		}
+		v.push(t)
+		return
+	}
+	t := descriptorVType(string(kind))
+	v.local(index, t.wide())
+	if v.f.locals[index] != t {
+		v.fail("bad local variable type: expecting %s in local %d, found %s", t, index, v.f.locals[index])
+	}
+	v.push(t)
+}
+
+func (v *methodVerifier) store(index int, kind byte) {
+	var t vtype
+	if kind == 'A' {
+		t = v.popReference()
+	} else {
+		t = descriptorVType(string(kind))
+		v.pop(t)
+	}
+	v.local(index, t.wide())
+	locals := v.f.locals
+	// 覆盖 long 或 double 的第二个单元时，前一个单元也失效
+	if index > 0 && locals[index-1].wide() {
+		locals[index-1] = vTop
+	}
+	locals[index] = t
+	if t.wide() {
+		locals[index+1] = vTop
+	}
+}
+
+// 只在操作数栈上做类型运算的指令：冒号前为依次压栈的操作数，冒号后为结果
+var verifyOperations = map[byte]string{
+	OP_ICONST_M1: ":I", OP_ICONST_0: ":I", OP_ICONST_1: ":I", OP_ICONST_2: ":I", OP_ICONST_3: ":I",
+	OP_ICONST_4: ":I", OP_ICONST_5: ":I", OP_BIPUSH: ":I", OP_SIPUSH: ":I",
+	OP_LCONST_0: ":J", OP_LCONST_1: ":J", OP_FCONST_0: ":F", OP_FCONST_1: ":F", OP_FCONST_2: ":F",
+	OP_DCONST_0: ":D", OP_DCONST_1: ":D",
+
+	OP_IADD: "II:I", OP_ISUB: "II:I", OP_IMUL: "II:I", OP_IDIV: "II:I", OP_IREM: "II:I",
+	OP_ISHL: "II:I", OP_ISHR: "II:I", OP_IUSHR: "II:I", OP_IAND: "II:I", OP_IOR: "II:I", OP_IXOR: "II:I",
+	OP_LADD: "JJ:J", OP_LSUB: "JJ:J", OP_LMUL: "JJ:J", OP_LDIV: "JJ:J", OP_LREM: "JJ:J",
+	OP_LSHL: "JI:J", OP_LSHR: "JI:J", OP_LUSHR: "JI:J", OP_LAND: "JJ:J", OP_LOR: "JJ:J", OP_LXOR: "JJ:J",
+	OP_FADD: "FF:F", OP_FSUB: "FF:F", OP_FMUL: "FF:F", OP_FDIV: "FF:F", OP_FREM: "FF:F",
+	OP_DADD: "DD:D", OP_DSUB: "DD:D", OP_DMUL: "DD:D", OP_DDIV: "DD:D", OP_DREM: "DD:D",
+	OP_INEG: "I:I", OP_LNEG: "J:J", OP_FNEG: "F:F", OP_DNEG: "D:D",
+
+	OP_I2L: "I:J", OP_I2F: "I:F", OP_I2D: "I:D", OP_L2I: "J:I", OP_L2F: "J:F", OP_L2D: "J:D",
+	OP_F2I: "F:I", OP_F2L: "F:J", OP_F2D: "F:D", OP_D2I: "D:I", OP_D2L: "D:J", OP_D2F: "D:F",
+	OP_I2B: "I:I", OP_I2C: "I:I", OP_I2S: "I:I",
+
+	OP_LCMP: "JJ:I", OP_FCMPL: "FF:I", OP_FCMPG: "FF:I", OP_DCMPL: "DD:I", OP_DCMPG: "DD:I",
+}
+
+// 操作数栈调整指令：复制栈顶若干单元并插到第几个单元之下；复制 0 个单元表示弹出
+var verifyShuffles = map[byte][2]int{
+	OP_POP: {0, 1}, OP_POP2: {0, 2},
+	OP_DUP: {1, 1}, OP_DUP_X1: {1, 2}, OP_DUP_X2: {1, 3},
+	OP_DUP2: {2, 2}, OP_DUP2_X1: {2, 3}, OP_DUP2_X2: {2, 4},
+}
+
+// xaload 与 xastore 的数组元素类型，下标为 opcode - OP_IALOAD 或 opcode - OP_IASTORE
+const verifyArrayElements = "IJFDABCS"
+
+func (v *methodVerifier) execute(ins *Instruction) {
+	op := ins.Opcode
+	if operation, ok := verifyOperations[op]; ok {
+		i := strings.IndexByte(operation, ':')
+		for j := i - 1; j >= 0; j-- {
+			v.pop(descriptorVType(operation[j : j+1]))
+		}
+		v.push(descriptorVType(operation[i+1:]))
+		return
+	}
+	if shuffle, ok := verifyShuffles[op]; ok {
+		v.shuffle(shuffle[0], shuffle[1])
+		return
+	}
+
+	switch {
+	case op >= OP_ILOAD && op <= OP_ALOAD:
+		v.load(ins.LocalIndex(), "IJFDA"[op-OP_ILOAD])
+		return
+	case op >= OP_ILOAD_0 && op <= OP_ALOAD_3:
+		v.load(ins.LocalIndex(), "IJFDA"[(op-OP_ILOAD_0)/4])
+		return
+	case op >= OP_ISTORE && op <= OP_ASTORE:
+		v.store(ins.LocalIndex(), "IJFDA"[op-OP_ISTORE])
+		return
+	case op >= OP_ISTORE_0 && op <= OP_ASTORE_3:
+		v.store(ins.LocalIndex(), "IJFDA"[(op-OP_ISTORE_0)/4])
+		return
+	case op >= OP_IALOAD && op <= OP_SALOAD:
+		v.arrayLoad(verifyArrayElements[op-OP_IALOAD])
+		return
+	case op >= OP_IASTORE && op <= OP_SASTORE:
+		v.arrayStore(verifyArrayElements[op-OP_IASTORE])
+		return
+	case op >= OP_IFEQ && op <= OP_IFLE:
+		v.pop(vInt)
+		v.target(v.f, ins.Branch, "branch target")
+		return
+	case op >= OP_IF_ICMPEQ && op <= OP_IF_ICMPLE:
+		v.pop(vInt)
+		v.pop(vInt)
+		v.target(v.f, ins.Branch, "branch target")
+		return
+	case op >= OP_IRETURN && op <= OP_RETURN:
+		v.ret(op)
+		return
+	}
+
+	switch op {
+	case OP_NOP:
+	case OP_ACONST_NULL:
+		v.push(vNull)
+	case OP_LDC, OP_LDC_W, OP_LDC2_W:
+		v.ldc(ins)
+	case OP_SWAP:
+		stack := v.f.stack
+		n := len(stack)
+		if n < 2 {
+			v.fail("operand stack underflow")
+		}
+		if stack[n-1] == vTop || stack[n-2] == vTop {
+			v.fail("swap of a long or double value")
+		}
+		stack[n-1], stack[n-2] = stack[n-2], stack[n-1]
+	case OP_IINC:
+		v.local(ins.LocalIndex(), false)
+		if t := v.f.locals[ins.LocalIndex()]; t != vInt {
+			v.fail("bad local variable type: expecting int in local %d, found %s", ins.LocalIndex(), t)
+		}
+	case OP_IF_ACMPEQ, OP_IF_ACMPNE:
+		v.popReference()
+		v.popReference()
+		v.target(v.f, ins.Branch, "branch target")
+	case OP_IFNULL, OP_IFNONNULL:
+		v.popReference()
+		v.target(v.f, ins.Branch, "branch target")
+	case OP_GOTO, OP_GOTO_W:
+		v.target(v.f, ins.Branch, "branch target")
+	case OP_JSR, OP_JSR_W, OP_RET:
+		v.fail("%s is not allowed in class files verified by type checking", OpcodeName(op))
+	case OP_TABLESWITCH, OP_LOOKUPSWITCH:
+		v.pop(vInt)
+		for i := 1; op == OP_LOOKUPSWITCH && i < len(ins.Keys); i++ {
+			if ins.Keys[i-1] >= ins.Keys[i] {
+				v.fail("lookupswitch keys are not sorted")
+			}
+		}
+		v.target(v.f, ins.Default, "branch target")
+		for _, target := range ins.Targets {
+			v.target(v.f, target, "branch target")
+		}
+	case OP_GETSTATIC, OP_PUTSTATIC, OP_GETFIELD, OP_PUTFIELD:
+		v.field(ins)
+	case OP_INVOKEVIRTUAL, OP_INVOKESPECIAL, OP_INVOKESTATIC, OP_INVOKEINTERFACE, OP_INVOKEDYNAMIC:
+		v.invoke(ins)
+	case OP_NEW:
+		v.constant(ins.Index, 7)
+		if class := v.cf.ClassNameAt(ins.Index); strings.HasPrefix(class, "[") {
+			v.fail("new of array class %s", class)
+		}
+		t := vtype{tag: vtUninitialized, offset: ins.Offset}
+		for _, s := range v.f.stack {
+			if s == t {
+				v.fail("%s is already on the operand stack", t)
+			}
+		}
+		v.replace(t, vTop)
+		v.push(t)
+	case OP_NEWARRAY:
+		if ins.Const < 4 || ins.Const > 11 {
+			v.fail("invalid newarray type %d", ins.Const)
+		}
+		v.pop(vInt)
+		v.push(vobject("[" + string("ZCFDBSIJ"[ins.Const-4])))
+	case OP_ANEWARRAY:
+		v.constant(ins.Index, 7)
+		v.pop(vInt)
+		v.push(vobject(arrayOf(v.cf.ClassNameAt(ins.Index))))
+	case OP_MULTIANEWARRAY:
+		v.constant(ins.Index, 7)
+		class := v.cf.ClassNameAt(ins.Index)
+		if ins.Const < 1 || len(class)-len(strings.TrimLeft(class, "[")) < int(ins.Const) {
+			v.fail("multianewarray of %d dimensions with type %s", ins.Const, class)
+		}
+		for i := 0; i < int(ins.Const); i++ {
+			v.pop(vInt)
+		}
+		v.push(vobject(class))
+	case OP_ARRAYLENGTH:
+		v.popArray()
+		v.push(vInt)
+	case OP_ATHROW:
+		v.pop(vThrowable)
+	case OP_CHECKCAST:
+		v.constant(ins.Index, 7)
+		v.pop(vObject)
+		v.push(vobject(v.cf.ClassNameAt(ins.Index)))
+	case OP_INSTANCEOF:
+		v.constant(ins.Index, 7)
+		v.pop(vObject)
+		v.push(vInt)
+	case OP_MONITORENTER, OP_MONITOREXIT:
+		v.popReference()
+	default:
+		v.fail("unsupported instruction %s", OpcodeName(op))
+	}
+}
+
+// 复制栈顶 n 个单元插到第 depth 个单元之下，n 为 0 时弹出 depth 个单元；不能拆开 long 与 double
+func (v *methodVerifier) shuffle(n, depth int) {
+	stack := v.f.stack
+	size := len(stack)
+	if size < depth {
+		v.fail("operand stack underflow")
+	}
+	for _, i := range []int{size - n, size - depth} {
+		if i < size && stack[i] == vTop {
+			v.fail("%s splits a long or double value", OpcodeName(v.at[v.pc].Opcode))
+		}
+	}
+	if n == 0 {
+		v.f.stack = stack[:size-depth]
+		return
+	}
+	copied := append([]vtype(nil), stack[size-n:]...)
+	below := append([]vtype(nil), stack[size-depth:]...)
+	v.f.stack = append(append(stack[:size-depth], copied...), below...)
+	if len(v.f.stack) > int(v.code.MaxStack) {
+		v.fail("operand stack overflow")
+	}
+}
+
+func (v *methodVerifier) arrayLoad(elem byte) {
+	v.pop(vInt)
+	array := v.popArray()
+	if elem == 'A' {
+		if array.tag == vtNull {
+			v.push(vNull)
+			return
+		}
+		t := descriptorVType(array.class[1:])
+		if t.tag != vtObject {
+			v.fail("aaload from %s", array)
+		}
+		v.push(t)
+		return
+	}
+	v.checkArray(array, elem)
+	v.push(descriptorVType(string(elem)))
+}
+
+func (v *methodVerifier) arrayStore(elem byte) {
+	if elem == 'A' {
+		v.pop(vObject)
+	} else {
+		v.pop(descriptorVType(string(elem)))
+	}
+	v.pop(vInt)
+	array := v.popArray()
+	if elem == 'A' {
+		if array.tag != vtNull && descriptorVType(array.class[1:]).tag != vtObject {
+			v.fail("aastore into %s", array)
+		}
+		return
+	}
+	v.checkArray(array, elem)
+}
+
+// 基本类型数组的元素类型必须与指令一致，baload 与 bastore 也可用于 boolean 数组
+func (v *methodVerifier) checkArray(array vtype, elem byte) {
+	if array.tag == vtNull {
+		return
+	}
+	if e := array.class[1:]; e != string(elem) && !(elem == 'B' && e == "Z") {
+		v.fail("bad type on operand stack: expecting [%c, found %s", elem, array)
+	}
+}
+
+func (v *methodVerifier) ldc(ins *Instruction) {
+	info := v.constant(ins.Index, 3, 4, 5, 6, 7, 8, 15, 16, 17)
+	var t vtype
+	switch info.Tag {
+	case 3:
+		t = vInt
+	case 4:
+		t = vFloat
+	case 5:
+		t = vLong
+	case 6:
+		t = vDouble
+	case 7:
+		t = vobject("java/lang/Class")
+	case 8:
+		t = vobject("java/lang/String")
+	case 15:
+		t = vobject("java/lang/invoke/MethodHandle")
+	case 16:
+		t = vobject("java/lang/invoke/MethodType")
+	case 17:
+		_, desc := v.cf.NameAndTypeAt((*ConstantDynamicInfo)(info).NameAndTypeIndex())
+		t = descriptorVType(desc)
+	}
+	if t.wide() != (ins.Opcode == OP_LDC2_W) {
+		v.fail("%s cannot load constant #%d of type %s", OpcodeName(ins.Opcode), ins.Index, t)
+	}
+	v.push(t)
+}
+
+func (v *methodVerifier) ret(op byte) {
+	desc := v.m.DescriptorString()
+	result := desc[strings.LastIndexByte(desc, ')')+1:]
+	if op == OP_RETURN {
+		if result != "V" {
+			v.fail("return in method returning %s", result)
+		}
+		if v.f.thisUninit {
+			v.fail("constructor returns before calling this() or super()")
+		}
+		return
+	}
+	if result == "V" {
+		v.fail("%s in void method", OpcodeName(op))
+	}
+	t := descriptorVType(result)
+	if want := []byte{vtInteger, vtLong, vtFloat, vtDouble, vtObject}[op-OP_IRETURN]; t.tag != want {
+		v.fail("%s in method returning %s", OpcodeName(op), result)
+	}
+	v.pop(t)
+}
+
+func (v *methodVerifier) field(ins *Instruction) {
+	v.constant(ins.Index, 9)
+	class, name, desc := v.cf.MemberRefAt(ins.Index)
+	t := descriptorVType(desc)
+	switch ins.Opcode {
+	case OP_GETSTATIC:
+		v.push(t)
+	case OP_PUTSTATIC:
+		v.pop(t)
+	case OP_GETFIELD:
+		v.pop(vobject(class))
+		v.push(t)
+	case OP_PUTFIELD:
+		v.pop(t)
+		// 调用 super() 之前可以给本类声明的字段赋值，如内部类的 this$0
+		if stack := v.f.stack; len(stack) > 0 && stack[len(stack)-1] == vThisInit &&
+			class == v.class && v.cf.FindField(name, desc) != nil {
+			v.f.stack = stack[:len(stack)-1]
+			return
+		}
+		v.pop(vobject(class))
+	}
+}
+
+func (v *methodVerifier) invoke(ins *Instruction) {
+	op := ins.Opcode
+	var class, name, desc string
+	switch op {
+	case OP_INVOKEDYNAMIC:
+		info := v.constant(ins.Index, 18)
+		name, desc = v.cf.NameAndTypeAt((*ConstantInvokeDynamicInfo)(info).NameAndTypeIndex())
+		if v.code.Code[ins.Offset+3] != 0 || v.code.Code[ins.Offset+4] != 0 {
+			v.fail("invokedynamic operand bytes 3 and 4 must be zero")
+		}
+	case OP_INVOKEVIRTUAL:
+		v.constant(ins.Index, 10)
+	case OP_INVOKEINTERFACE:
+		v.constant(ins.Index, 11)
+	default:
+		// Java 8 起 invokespecial 与 invokestatic 可以引用接口方法
+		v.constant(ins.Index, 10, 11)
+	}
+	if op != OP_INVOKEDYNAMIC {
+		class, name, desc = v.cf.MemberRefAt(ins.Index)
+	}
+	if strings.HasPrefix(name, "<") && !(op == OP_INVOKESPECIAL && name == "<init>") {
+		v.fail("illegal call to %s", name)
+	}
+
+	params := descriptorParameters(desc)
+	if op == OP_INVOKEINTERFACE {
+		count := 1
+		for _, param := range params {
+			count++
+			if descriptorVType(param).wide() {
+				count++
+			}
+		}
+		if int(ins.Const) != count || v.code.Code[ins.Offset+4] != 0 {
+			v.fail("invokeinterface count %d does not match %s", ins.Const, desc)
+		}
+	}
+	for i := len(params) - 1; i >= 0; i-- {
+		v.pop(descriptorVType(params[i]))
+	}
+
+	switch {
+	case op == OP_INVOKESPECIAL && name == "<init>":
+		v.initialize(class)
+	case op == OP_INVOKESPECIAL:
+		// 父类方法、私有方法与接口默认方法只能在本类的实例上调用
+		v.pop(vobject(v.class))
+	case op == OP_INVOKEVIRTUAL || op == OP_INVOKEINTERFACE:
+		v.pop(vobject(class))
+	}
+
+	if result := desc[strings.LastIndexByte(desc, ')')+1:]; result != "V" {
+		v.push(descriptorVType(result))
+	}
+}
+
+// invokespecial <init>：把栈上与局部变量中同一个未初始化对象都替换为已初始化的类型
+func (v *methodVerifier) initialize(class string) {
+	recv := v.popReference()
+	switch recv.tag {
+	case vtUninitializedThis:
+		if class != v.class && class != v.cf.SuperClassString() {
+			v.fail("%s.<init> called on uninitializedThis, expecting %s or its direct superclass", class, v.class)
+		}
+		v.replace(recv, vobject(v.class))
+		v.f.thisUninit = false
+	case vtUninitialized:
+		created := v.cf.ClassNameAt(v.at[recv.offset].Index)
+		if class != created {
+			v.fail("%s.<init> called on %s created by new %s", class, recv, created)
+		}
+		v.replace(recv, vobject(created))
+	default:
+		v.fail("bad type on operand stack: expecting an uninitialized object for %s.<init>, found %s", class, recv)
+	}
+}
+
+func (v *methodVerifier) replace(from, to vtype) {
+	for _, types := range [][]vtype{v.f.locals, v.f.stack} {
+		for i, t := range types {
+			if t == from {
+				types[i] = to
+			}
+		}
+	}
+}
+
+// 按 JVMS 4.10.1 的类型检查规则，用 StackMapTable 校验类中每个有字节码的方法，每个方法最多报告一个错误。
+// 类层次从 classpath 上查找，找不到的类按可赋值处理；主版本号低于 50 的类只能由类型推导校验，这里跳过
+func (p *ClassPath) Verify(cf *ClassFile) []*VerifyError {
+	if cf.MajorVersion < 50 {
+		return nil
+	}
+	var rs []*VerifyError
+	for _, m := range cf.Methods {
+		code := m.Code()
+		if code == nil {
+			continue
+		}
+		v := &methodVerifier{p: p, cf: cf, m: m, code: code, class: cf.ThisClassString()}
+		if err := v.verify(); err != nil {
+			rs = append(rs, err)
+		}
+	}
+	return rs
+}
+
+// 校验 jar 中的全部类，按类名排序
+func (p *ClassPath) VerifyJar(jar *Jar) []*VerifyError {
+	var rs []*VerifyError
+	for _, name := range jar.ClassNames() {
+		rs = append(rs, p.Verify(jar.Class(name))...)
+	}
+	return rs
+}
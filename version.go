@@ -0,0 +1,170 @@
+package jclass
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 使用了预览特性的类的次版本号
+const PREVIEW_MINOR_VERSION = 0xFFFF
+
+// Java 版本对应的 class 文件主版本号，如 8 -> 52；1.1 写作 1
+func ClassVersion(release int) uint16 {
+	if release <= 1 {
+		return 45
+	}
+	return uint16(44 + release)
+}
+
+// class 文件主版本号对应的 Java 版本，如 52 -> 8；1.1 返回 1
+func JavaRelease(major uint16) int {
+	if major <= 45 {
+		return 1
+	}
+	return int(major) - 44
+}
+
+// 主版本号对应的 Java 版本名称，如 52 -> Java 8，48 -> Java 1.4
+func JavaReleaseName(major uint16) string {
+	if release := JavaRelease(major); release < 5 {
+		return "Java 1." + strconv.Itoa(release)
+	}
+	return "Java " + strconv.Itoa(JavaRelease(major))
+}
+
+// 解析 8、1.8 形式的 Java 版本
+func ParseJavaRelease(s string) (int, error) {
+	release, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(s), "1."))
+	if err != nil || release < 1 {
+		return 0, fmt.Errorf("invalid Java release: %s", s)
+	}
+	return release, nil
+}
+
+// jar 中一个类文件的版本
+type ClassFileVersion struct {
+	Entry string
+	Major uint16
+	Minor uint16
+}
+
+func (v *ClassFileVersion) Preview() bool {
+	return v.Minor == PREVIEW_MINOR_VERSION
+}
+
+// 一个版本号及其类的数量
+type VersionCount struct {
+	Major uint16
+	Minor uint16
+	Count int
+}
+
+func (c *VersionCount) String() string {
+	s := fmt.Sprintf("%d.%d (%s)", c.Major, c.Minor, JavaReleaseName(c.Major))
+	if c.Minor == PREVIEW_MINOR_VERSION {
+		s = fmt.Sprintf("%d.preview (%s preview)", c.Major, JavaReleaseName(c.Major))
+	}
+	return s
+}
+
+// jar 的一层：基础层或多版本 jar 中的 META-INF/versions/N
+type VersionLayer struct {
+	// 基础层为 0
+	Release int
+	Classes []*ClassFileVersion
+}
+
+func (l *VersionLayer) String() string {
+	if l.Release == 0 {
+		return "base"
+	}
+	return "META-INF/versions/" + strconv.Itoa(l.Release)
+}
+
+// 按版本号升序的直方图
+func (l *VersionLayer) Histogram() []*VersionCount {
+	counts := make(map[[2]uint16]int)
+	for _, c := range l.Classes {
+		counts[[2]uint16{c.Major, c.Minor}]++
+	}
+	rs := make([]*VersionCount, 0, len(counts))
+	for v, n := range counts {
+		rs = append(rs, &VersionCount{Major: v[0], Minor: v[1], Count: n})
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].Major != rs[j].Major {
+			return rs[i].Major < rs[j].Major
+		}
+		return rs[i].Minor < rs[j].Minor
+	})
+	return rs
+}
+
+// 本层允许的最高主版本号：基础层为 max，META-INF/versions/N 只会在 Java N 及以上加载，上限为 N
+func (l *VersionLayer) Limit(max uint16) uint16 {
+	if l.Release == 0 {
+		return max
+	}
+	return ClassVersion(l.Release)
+}
+
+// 超过本层上限或使用预览特性的类
+func (l *VersionLayer) Violations(max uint16) []*ClassFileVersion {
+	var rs []*ClassFileVersion
+	limit := l.Limit(max)
+	for _, c := range l.Classes {
+		if c.Major > limit || c.Preview() {
+			rs = append(rs, c)
+		}
+	}
+	return rs
+}
+
+// 按层统计 jar 中类文件的版本，基础层在前，版本层按版本升序。
+// 只读取类文件头，因此 META-INF/versions 下未解析的类同样计入
+func (j *Jar) VersionLayers() ([]*VersionLayer, error) {
+	layers := map[int]*VersionLayer{0: {}}
+	for _, entry := range j.Entries {
+		if !entry.IsClass() {
+			continue
+		}
+		release := 0
+		if strings.HasPrefix(entry.Name, "META-INF/versions/") {
+			parts := strings.SplitN(entry.Name, "/", 4)
+			if len(parts) < 4 {
+				continue
+			}
+			n, err := strconv.Atoi(parts[2])
+			if err != nil {
+				continue
+			}
+			release = n
+		}
+		if len(entry.Data) < 8 || binary.BigEndian.Uint32(entry.Data) != MAGIC {
+			return nil, &JarError{Path: j.Path, Entry: entry.Name, Err: ERR_NOT_CLASS_FILE}
+		}
+
+		layer := layers[release]
+		if layer == nil {
+			layer = &VersionLayer{Release: release}
+			layers[release] = layer
+		}
+		layer.Classes = append(layer.Classes, &ClassFileVersion{
+			Entry: entry.Name,
+			Minor: binary.BigEndian.Uint16(entry.Data[4:]),
+			Major: binary.BigEndian.Uint16(entry.Data[6:]),
+		})
+	}
+
+	rs := make([]*VersionLayer, 0, len(layers))
+	for _, layer := range layers {
+		rs = append(rs, layer)
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return rs[i].Release < rs[j].Release
+	})
+	return rs, nil
+}